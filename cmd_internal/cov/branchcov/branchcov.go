@@ -0,0 +1,247 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package branchcov implements the "branch" coverage instrumentation
+// strategy, used by the cover tool in addition to its usual
+// instrumentation of basic blocks. It adds counters for each outcome
+// of the if, switch and select statements of a function, and for the
+// true and false evaluations of each operand of its && and ||
+// expressions, and describes them with coverable units of kind
+// coverage.UnitBranch, coverage.UnitCondTrue and
+// coverage.UnitCondFalse.
+//
+// Counter updates in statement position are inserted as statements
+// supplied by the cover tool. Updates in expression position (the
+// condition of an if statement, and the operands of && and ||) are
+// wrapped in a call to a helper that the cover tool adds to the
+// package, of the form
+//
+//	func cond[T ~bool](t, f *uint32, b T) T {
+//		if b {
+//			*t++ // or atomic.AddUint32(t, 1), or *t = 1
+//		} else {
+//			*f++
+//		}
+//		return b
+//	}
+//
+// Since the instrumentation works on the source without type
+// information, the operands of a && or || expression that has both
+// untyped operands (such as comparisons) and operands of a defined
+// boolean type won't type check after instrumentation.
+package branchcov
+
+import (
+	"go/ast"
+	"go/token"
+
+	"compile/cmd_internal/edit"
+	"compile/src_internal/coverage"
+)
+
+// An Instrumenter adds branch and condition counters to functions.
+type Instrumenter struct {
+	// Fset and Edit are the file set of the source and the buffer
+	// the edits to the source are added to.
+	Fset *token.FileSet
+	Edit *edit.Buffer
+
+	// Counter returns the text of a statement that updates the
+	// counter for the unit with index 'unit' in the function.
+	Counter func(unit int) string
+
+	// Cond returns the text of the start of a call to the condition
+	// helper, up to the boolean argument, for the units with indexes
+	// 't' and 'f' in the function, such as "cond(&c[5], &c[6], ".
+	// The boolean and the closing parenthesis follow.
+	Cond func(t, f int) string
+}
+
+// Func instruments the body of a function declaration or literal,
+// whose block units are 'units', and returns the units with the new
+// branch and condition units appended. Function literals within the
+// body are not instrumented: they have units of their own. The
+// counters for units are expected to be laid out in the same order as
+// the units, so this requires per-block counter granularity.
+func (in *Instrumenter) Func(body *ast.BlockStmt, units []coverage.CoverableUnit) []coverage.CoverableUnit {
+	w := &walker{in: in, units: units, nblocks: len(units)}
+	// The suffixes for a node are inserted once its children have
+	// been visited, so that edits at the same offset nest properly.
+	var stack [][]suffix
+	ast.Inspect(body, func(n ast.Node) bool {
+		if n == nil {
+			for _, s := range stack[len(stack)-1] {
+				in.Edit.Insert(w.offset(s.pos), s.text)
+			}
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.GenDecl:
+			if n.Tok == token.CONST {
+				// Constant expressions can't have calls.
+				return false
+			}
+		}
+		stack = append(stack, w.node(n))
+		return true
+	})
+	return w.units
+}
+
+// A suffix is text to insert at pos after the children of a node have
+// been instrumented.
+type suffix struct {
+	pos  token.Pos
+	text string
+}
+
+type walker struct {
+	in       *Instrumenter
+	units    []coverage.CoverableUnit
+	nblocks  int    // number of block units
+	decision uint32 // last decision number
+}
+
+// node instruments n itself and returns the suffixes to insert after
+// its children.
+func (w *walker) node(n ast.Node) []suffix {
+	switch n := n.(type) {
+	case *ast.IfStmt:
+		// The condition helper counts the outcomes.
+		d := w.newDecision()
+		parent := w.parent(n.Pos())
+		t := w.addUnit(coverage.UnitBranch, d, parent, n.Body.Pos(), n.Body.End())
+		var f int
+		if n.Else != nil {
+			f = w.addUnit(coverage.UnitBranch, d, parent, n.Else.Pos(), n.Else.End())
+		} else {
+			f = w.addUnit(coverage.UnitBranch, d, parent, n.End(), n.End())
+		}
+		w.in.Edit.Insert(w.offset(n.Cond.Pos()), w.in.Cond(t, f))
+		return []suffix{{n.Cond.End(), ")"}}
+
+	case *ast.SwitchStmt:
+		w.clauses(n.Pos(), n.Body, true)
+	case *ast.TypeSwitchStmt:
+		w.clauses(n.Pos(), n.Body, true)
+	case *ast.SelectStmt:
+		w.clauses(n.Pos(), n.Body, false)
+
+	case *ast.BinaryExpr:
+		if !isLogical(n) {
+			break
+		}
+		var s []suffix
+		for _, x := range []ast.Expr{n.X, n.Y} {
+			if isLogical(x) || isBoolLit(x) {
+				// Nested operands are instrumented as part of their
+				// own expression.
+				continue
+			}
+			d := w.newDecision()
+			parent := w.parent(x.Pos())
+			t := w.addUnit(coverage.UnitCondTrue, d, parent, x.Pos(), x.End())
+			f := w.addUnit(coverage.UnitCondFalse, d, parent, x.Pos(), x.End())
+			w.in.Edit.Insert(w.offset(x.Pos()), w.in.Cond(t, f))
+			s = append(s, suffix{x.End(), ")"})
+		}
+		return s
+	}
+	return nil
+}
+
+// clauses instruments the clauses of a switch or select statement
+// starting at pos. If implicitDefault is set and there's no default
+// clause, one is added to count the executions that match no case.
+func (w *walker) clauses(pos token.Pos, body *ast.BlockStmt, implicitDefault bool) {
+	d := w.newDecision()
+	parent := w.parent(pos)
+	hasDefault := false
+	for _, c := range body.List {
+		var colon token.Pos
+		switch c := c.(type) {
+		case *ast.CaseClause:
+			colon = c.Colon
+			hasDefault = hasDefault || c.List == nil
+		case *ast.CommClause:
+			colon = c.Colon
+			hasDefault = hasDefault || c.Comm == nil
+		}
+		u := w.addUnit(coverage.UnitBranch, d, parent, c.Pos(), c.End())
+		w.in.Edit.Insert(w.offset(colon)+1, w.in.Counter(u)+";")
+	}
+	if implicitDefault && !hasDefault {
+		u := w.addUnit(coverage.UnitBranch, d, parent, body.End(), body.End())
+		w.in.Edit.Insert(w.offset(body.Rbrace), "default:"+w.in.Counter(u)+";")
+	}
+}
+
+func (w *walker) newDecision() uint32 {
+	w.decision++
+	return w.decision
+}
+
+// addUnit adds a unit for the source range [start, end) and returns
+// its index.
+func (w *walker) addUnit(kind coverage.UnitKind, decision, parent uint32, start, end token.Pos) int {
+	st := w.in.Fset.Position(start)
+	en := w.in.Fset.Position(end)
+	w.units = append(w.units, coverage.CoverableUnit{
+		StLine:   uint32(st.Line),
+		StCol:    uint32(st.Column),
+		EnLine:   uint32(en.Line),
+		EnCol:    uint32(en.Column),
+		Parent:   parent,
+		Kind:     kind,
+		Decision: decision,
+	})
+	return len(w.units) - 1
+}
+
+// parent returns 1 plus the index of the block unit containing pos,
+// which is the last one starting at or before it, or 0 if there is
+// none.
+func (w *walker) parent(pos token.Pos) uint32 {
+	p := w.in.Fset.Position(pos)
+	var parent uint32
+	var line, col uint32
+	for i, u := range w.units[:w.nblocks] {
+		if u.StLine > uint32(p.Line) || u.StLine == uint32(p.Line) && u.StCol > uint32(p.Column) {
+			continue
+		}
+		if parent == 0 || u.StLine > line || u.StLine == line && u.StCol >= col {
+			parent = uint32(i) + 1
+			line, col = u.StLine, u.StCol
+		}
+	}
+	return parent
+}
+
+func (w *walker) offset(pos token.Pos) int {
+	return w.in.Fset.Position(pos).Offset
+}
+
+// isLogical reports whether x, without parentheses, is a && or ||
+// expression.
+func isLogical(x ast.Node) bool {
+	for {
+		p, ok := x.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		x = p.X
+	}
+	b, ok := x.(*ast.BinaryExpr)
+	return ok && (b.Op == token.LAND || b.Op == token.LOR)
+}
+
+// isBoolLit reports whether x is the constant true or false, which
+// has a single outcome.
+func isBoolLit(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && (id.Name == "true" || id.Name == "false")
+}
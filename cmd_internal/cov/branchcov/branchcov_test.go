@@ -0,0 +1,140 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package branchcov_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"compile/cmd_internal/cov/branchcov"
+	"compile/cmd_internal/edit"
+	"compile/src_internal/coverage"
+)
+
+const src = `package p
+
+func f(a, b, c bool, x int) int {
+	if a && (b || !c) {
+		return 1
+	} else if x > 2 {
+		return 2
+	}
+	switch x {
+	case 1:
+		x++
+	case 2, 3:
+		x--
+	}
+	select {
+	default:
+	}
+	g := func() bool { return a || b }
+	const k = true && false
+	_ = g
+	return x
+}
+`
+
+func TestFunc(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &branchcov.Instrumenter{
+		Fset: fset,
+		Edit: edit.NewBuffer([]byte(src)),
+		Counter: func(unit int) string {
+			return fmt.Sprintf("ctr[%d]++", unit)
+		},
+		Cond: func(t, f int) string {
+			return fmt.Sprintf("cond(&ctr[%d], &ctr[%d], ", t, f)
+		},
+	}
+	blocks := []coverage.CoverableUnit{
+		{StLine: 3, StCol: 34, EnLine: 4, EnCol: 20, NxStmts: 1},
+		{StLine: 9, StCol: 2, EnLine: 9, EnCol: 10, NxStmts: 1},
+	}
+	units := in.Func(f.Decls[0].(*ast.FuncDecl).Body, blocks)
+
+	out := in.Edit.String()
+	if out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+	// The result, with the helper, must type check.
+	helper := `
+var ctr [16]uint32
+
+func cond[T ~bool](t, f *uint32, b T) T {
+	if b {
+		*t++
+	} else {
+		*f++
+	}
+	return b
+}
+`
+	fset = token.NewFileSet()
+	f, err = parser.ParseFile(fset, "p.go", out+helper, 0)
+	if err != nil {
+		t.Fatalf("parsing instrumented source: %v", err)
+	}
+	if _, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("type checking instrumented source: %v", err)
+	}
+
+	var got []string
+	for _, u := range units[len(blocks):] {
+		got = append(got, fmt.Sprintf("%d:%d,%d:%d %v %d %d", u.StLine, u.StCol, u.EnLine, u.EnCol, u.Kind, u.Decision, u.Parent))
+	}
+	wantUnits := []string{
+		"4:20,6:3 branch 1 1",
+		"6:9,8:3 branch 1 1",
+		"4:5,4:6 condtrue 2 1",
+		"4:5,4:6 condfalse 2 1",
+		"4:11,4:12 condtrue 3 1",
+		"4:11,4:12 condfalse 3 1",
+		"4:16,4:18 condtrue 4 1",
+		"4:16,4:18 condfalse 4 1",
+		"6:18,8:3 branch 5 1",
+		"8:3,8:3 branch 5 1",
+		"10:2,11:6 branch 6 2",
+		"12:2,13:6 branch 6 2",
+		"14:3,14:3 branch 6 2",
+		"16:2,16:10 branch 7 2",
+	}
+	if strings.Join(got, "\n") != strings.Join(wantUnits, "\n") {
+		t.Errorf("got units:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(wantUnits, "\n"))
+	}
+}
+
+const want = `package p
+
+func f(a, b, c bool, x int) int {
+	if cond(&ctr[2], &ctr[3], cond(&ctr[4], &ctr[5], a) && (cond(&ctr[6], &ctr[7], b) || cond(&ctr[8], &ctr[9], !c))) {
+		return 1
+	} else if cond(&ctr[10], &ctr[11], x > 2) {
+		return 2
+	}
+	switch x {
+	case 1:ctr[12]++;
+		x++
+	case 2, 3:ctr[13]++;
+		x--
+	default:ctr[14]++;}
+	select {
+	default:ctr[15]++;
+	}
+	g := func() bool { return a || b }
+	const k = true && false
+	_ = g
+	return x
+}
+`
@@ -29,6 +29,12 @@ type CoverPkgConfig struct {
 	// Instrumentation granularity: one of "perfunc" or "perblock" (default)
 	Granularity string
 
+	// Instrumentation strategy: "normal" (default) or "branch". The
+	// "branch" strategy also counts the outcomes of if, switch and
+	// select statements and of the operands of && and || expressions
+	// (see package branchcov), and requires "perblock" granularity.
+	Strategy string
+
 	// Module path for this package (empty if no go.mod in use)
 	ModulePath string
 
@@ -64,10 +70,11 @@ type CoverFixupConfig struct {
 	// Hash computed by cmd/cover of the meta-data.
 	MetaHash string
 
-	// Instrumentation strategy. For now this is always set to
-	// "normal", but in the future we may add new values (for example,
-	// if panic paths are instrumented, or if the instrumenter
-	// eliminates redundant counters).
+	// Instrumentation strategy: "normal", or "branch" if branch and
+	// condition units were added to the meta-data (see the Strategy
+	// field of CoverPkgConfig). In the future we may add new values
+	// (for example, if panic paths are instrumented, or if the
+	// instrumenter eliminates redundant counters).
 	Strategy string
 
 	// Prefix assigned to the names of counter variables generated
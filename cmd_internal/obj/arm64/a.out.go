@@ -146,6 +146,72 @@ const (
 	REG_RSP = REG_V31 + 32 // to differentiate ZR/SP, REG_RSP&0x1f = 31
 )
 
+// SVE scalable vector registers Z0-Z31 and predicate registers P0-P15.
+const (
+	REG_Z0 = REG_RSP + 1 + iota
+	REG_Z1
+	REG_Z2
+	REG_Z3
+	REG_Z4
+	REG_Z5
+	REG_Z6
+	REG_Z7
+	REG_Z8
+	REG_Z9
+	REG_Z10
+	REG_Z11
+	REG_Z12
+	REG_Z13
+	REG_Z14
+	REG_Z15
+	REG_Z16
+	REG_Z17
+	REG_Z18
+	REG_Z19
+	REG_Z20
+	REG_Z21
+	REG_Z22
+	REG_Z23
+	REG_Z24
+	REG_Z25
+	REG_Z26
+	REG_Z27
+	REG_Z28
+	REG_Z29
+	REG_Z30
+	REG_Z31
+	REG_P0
+	REG_P1
+	REG_P2
+	REG_P3
+	REG_P4
+	REG_P5
+	REG_P6
+	REG_P7
+	REG_P8
+	REG_P9
+	REG_P10
+	REG_P11
+	REG_P12
+	REG_P13
+	REG_P14
+	REG_P15
+)
+
+// SVE governing predicates with a zeroing (Pg/Z) or merging (Pg/M)
+// qualifier, bits 0-3 indicate the predicate register.
+const (
+	REG_PZ = obj.RBaseARM64 + 176 + iota<<4 // Pg/Z
+	REG_PM                                  // Pg/M
+)
+
+// bits 0-4 indicates register: Zn or Pn
+// bits 5-6 indicates element size: <T>, log2 of the element size in bytes
+const (
+	REG_ZARNG = obj.RBaseARM64 + 256 + iota<<7 // Zn.<T>
+	REG_PARNG                                  // Pn.<T>
+)
+
 // bits 0-4 indicates register: Vn
 // bits 5-8 indicates arrangement: <T>
 const (
@@ -356,6 +422,12 @@ const (
 	C_ARNG              // Vn.<T>
 	C_ELEM              // Vn.<T>[index]
 	C_LIST              // [V1, V2, V3]
+	C_ZVREG             // Z0..Z31
+	C_ZARNG             // Zn.<T>
+	C_PREG              // P0..P15
+	C_PARNG             // Pn.<T>
+	C_PREGZ             // Pg/Z
+	C_PREGM             // Pg/M
 
 	C_ZCON     // $0
 	C_ABCON0   // could be C_ADDCON0 or C_BITCON
@@ -572,6 +644,10 @@ const (
 	ACMPW
 	ACNEG
 	ACNEGW
+	ACNTB
+	ACNTD
+	ACNTH
+	ACNTW
 	ACRC32B
 	ACRC32CB
 	ACRC32CH
@@ -690,6 +766,10 @@ const (
 	AHLT
 	AHVC
 	AIC
+	AINCB
+	AINCD
+	AINCH
+	AINCW
 	AISB
 	ALDADDAB
 	ALDADDAD
@@ -824,6 +904,7 @@ const (
 	AORRW
 	APRFM
 	APRFUM
+	APTRUE
 	ARBIT
 	ARBITW
 	AREM
@@ -1016,8 +1097,45 @@ const (
 	AVZIP2
 	AWFE
 	AWFI
+	AWHILEGE
+	AWHILEGT
+	AWHILEHI
+	AWHILEHS
+	AWHILELE
+	AWHILELO
+	AWHILELS
+	AWHILELT
 	AWORD
 	AYIELD
+	AZADD
+	AZAND
+	AZBCAX
+	AZCMPEQ
+	AZCMPGE
+	AZCMPGT
+	AZCMPHI
+	AZCMPHS
+	AZCMPNE
+	AZDUP
+	AZEOR
+	AZEOR3
+	AZFADD
+	AZFMLA
+	AZFMUL
+	AZFSUB
+	AZLD1B
+	AZLD1D
+	AZLD1H
+	AZLD1W
+	AZLDR
+	AZMUL
+	AZORR
+	AZST1B
+	AZST1D
+	AZST1H
+	AZST1W
+	AZSTR
+	AZSUB
 	ALAST
 	AB  = obj.AJMP
 	ABL = obj.ACALL
@@ -90,6 +90,10 @@ var Anames = []string{
 	"CMPW",
 	"CNEG",
 	"CNEGW",
+	"CNTB",
+	"CNTD",
+	"CNTH",
+	"CNTW",
 	"CRC32B",
 	"CRC32CB",
 	"CRC32CH",
@@ -208,6 +212,10 @@ var Anames = []string{
 	"HLT",
 	"HVC",
 	"IC",
+	"INCB",
+	"INCD",
+	"INCH",
+	"INCW",
 	"ISB",
 	"LDADDAB",
 	"LDADDAD",
@@ -342,6 +350,7 @@ var Anames = []string{
 	"ORRW",
 	"PRFM",
 	"PRFUM",
+	"PTRUE",
 	"RBIT",
 	"RBITW",
 	"REM",
@@ -534,7 +543,44 @@ var Anames = []string{
 	"VZIP2",
 	"WFE",
 	"WFI",
+	"WHILEGE",
+	"WHILEGT",
+	"WHILEHI",
+	"WHILEHS",
+	"WHILELE",
+	"WHILELO",
+	"WHILELS",
+	"WHILELT",
 	"WORD",
 	"YIELD",
+	"ZADD",
+	"ZAND",
+	"ZBCAX",
+	"ZCMPEQ",
+	"ZCMPGE",
+	"ZCMPGT",
+	"ZCMPHI",
+	"ZCMPHS",
+	"ZCMPNE",
+	"ZDUP",
+	"ZEOR",
+	"ZEOR3",
+	"ZFADD",
+	"ZFMLA",
+	"ZFMUL",
+	"ZFSUB",
+	"ZLD1B",
+	"ZLD1D",
+	"ZLD1H",
+	"ZLD1W",
+	"ZLDR",
+	"ZMUL",
+	"ZORR",
+	"ZST1B",
+	"ZST1D",
+	"ZST1H",
+	"ZST1W",
+	"ZSTR",
+	"ZSUB",
 	"LAST",
 }
@@ -22,6 +22,12 @@ var cnames7 = []string{
 	"ARNG",
 	"ELEM",
 	"LIST",
+	"ZVREG",
+	"ZARNG",
+	"PREG",
+	"PARNG",
+	"PREGZ",
+	"PREGM",
 	"ZCON",
 	"ABCON0",
 	"ADDCON0",
@@ -882,6 +882,34 @@ var optab = []Optab{
 	{AVPMULL, C_ARNG, C_ARNG, C_NONE, C_ARNG, C_NONE, 93, 4, 0, 0, 0},
 	{AVEOR3, C_ARNG, C_ARNG, C_ARNG, C_ARNG, C_NONE, 103, 4, 0, 0, 0},
 	{AVXAR, C_VCON, C_ARNG, C_ARNG, C_ARNG, C_NONE, 104, 4, 0, 0, 0},
+
+	/* SVE and SVE2 instructions */
+	{AZADD, C_ZARNG, C_ZARNG, C_NONE, C_ZARNG, C_NONE, 108, 4, 0, 0, 0},
+	{AZADD, C_ZARNG, C_PREGM, C_NONE, C_ZARNG, C_NONE, 109, 4, 0, 0, 0},
+	{AZAND, C_ZARNG, C_ZARNG, C_NONE, C_ZARNG, C_NONE, 108, 4, 0, 0, 0},
+	{AZFADD, C_ZARNG, C_ZARNG, C_NONE, C_ZARNG, C_NONE, 108, 4, 0, 0, 0},
+	{AZFMLA, C_ZARNG, C_ZARNG, C_PREGM, C_ZARNG, C_NONE, 110, 4, 0, 0, 0},
+	{AZCMPEQ, C_ZARNG, C_ZARNG, C_PREGZ, C_PARNG, C_NONE, 110, 4, 0, 0, 0},
+	{AZEOR3, C_ZARNG, C_ZARNG, C_NONE, C_ZARNG, C_NONE, 111, 4, 0, 0, 0},
+	{AZDUP, C_VCON, C_NONE, C_NONE, C_ZARNG, C_NONE, 112, 4, 0, 0, 0},
+	{AZLD1B, C_NSOREG, C_PREGZ, C_NONE, C_ZARNG, C_NONE, 113, 4, 0, 0, 0},
+	{AZLD1B, C_PSOREG, C_PREGZ, C_NONE, C_ZARNG, C_NONE, 113, 4, 0, 0, 0},
+	{AZST1B, C_ZARNG, C_PREG, C_NONE, C_NSOREG, C_NONE, 114, 4, 0, 0, 0},
+	{AZST1B, C_ZARNG, C_PREG, C_NONE, C_PSOREG, C_NONE, 114, 4, 0, 0, 0},
+	{AZLDR, C_NSOREG, C_NONE, C_NONE, C_ZVREG, C_NONE, 115, 4, 0, 0, 0},
+	{AZLDR, C_PSOREG, C_NONE, C_NONE, C_ZVREG, C_NONE, 115, 4, 0, 0, 0},
+	{AZLDR, C_NSOREG, C_NONE, C_NONE, C_PREG, C_NONE, 115, 4, 0, 0, 0},
+	{AZLDR, C_PSOREG, C_NONE, C_NONE, C_PREG, C_NONE, 115, 4, 0, 0, 0},
+	{AZSTR, C_ZVREG, C_NONE, C_NONE, C_NSOREG, C_NONE, 116, 4, 0, 0, 0},
+	{AZSTR, C_ZVREG, C_NONE, C_NONE, C_PSOREG, C_NONE, 116, 4, 0, 0, 0},
+	{AZSTR, C_PREG, C_NONE, C_NONE, C_NSOREG, C_NONE, 116, 4, 0, 0, 0},
+	{AZSTR, C_PREG, C_NONE, C_NONE, C_PSOREG, C_NONE, 116, 4, 0, 0, 0},
+	{AWHILELT, C_ZREG, C_ZREG, C_NONE, C_PARNG, C_NONE, 117, 4, 0, 0, 0},
+	{APTRUE, C_NONE, C_NONE, C_NONE, C_PARNG, C_NONE, 118, 4, 0, 0, 0},
+	{APTRUE, C_ADDCON0, C_NONE, C_NONE, C_PARNG, C_NONE, 118, 4, 0, 0, 0},
+	{ACNTB, C_NONE, C_NONE, C_NONE, C_ZREG, C_NONE, 119, 4, 0, 0, 0},
+	{ACNTB, C_ADDCON0, C_NONE, C_NONE, C_ZREG, C_NONE, 119, 4, 0, 0, 0},
+
 	{obj.AUNDEF, C_NONE, C_NONE, C_NONE, C_NONE, C_NONE, 90, 4, 0, 0, 0},
 	{obj.APCDATA, C_VCON, C_NONE, C_NONE, C_VCON, C_NONE, 0, 0, 0, 0, 0},
 	{obj.AFUNCDATA, C_VCON, C_NONE, C_NONE, C_ADDR, C_NONE, 0, 0, 0, 0, 0},
@@ -1848,6 +1876,18 @@ func rclass(r int16) int {
 		return C_VREG
 	case r == REGSP:
 		return C_RSP
+	case REG_Z0 <= r && r <= REG_Z31:
+		return C_ZVREG
+	case REG_P0 <= r && r <= REG_P15:
+		return C_PREG
+	case r >= REG_PZ && r < REG_PM:
+		return C_PREGZ
+	case r >= REG_PM && r < REG_PM+16:
+		return C_PREGM
+	case r >= REG_ZARNG && r < REG_PARNG:
+		return C_ZARNG
+	case r >= REG_PARNG && r < REG_PARNG+1<<7:
+		return C_PARNG
 	case r >= REG_ARNG && r < REG_ELEM:
 		return C_ARNG
 	case r >= REG_ELEM && r < REG_ELEM_END:
@@ -3298,6 +3338,56 @@ func buildop(ctxt *obj.Link) {
 		case AVTBL:
 			oprangeset(AVTBX, t)
 
+		case AZADD:
+			oprangeset(AZSUB, t)
+			oprangeset(AZMUL, t)
+
+		case AZAND:
+			oprangeset(AZORR, t)
+			oprangeset(AZEOR, t)
+
+		case AZFADD:
+			oprangeset(AZFSUB, t)
+			oprangeset(AZFMUL, t)
+
+		case AZCMPEQ:
+			oprangeset(AZCMPNE, t)
+			oprangeset(AZCMPGE, t)
+			oprangeset(AZCMPGT, t)
+			oprangeset(AZCMPHS, t)
+			oprangeset(AZCMPHI, t)
+
+		case AZEOR3:
+			oprangeset(AZBCAX, t)
+
+		case AZLD1B:
+			oprangeset(AZLD1H, t)
+			oprangeset(AZLD1W, t)
+			oprangeset(AZLD1D, t)
+
+		case AZST1B:
+			oprangeset(AZST1H, t)
+			oprangeset(AZST1W, t)
+			oprangeset(AZST1D, t)
+
+		case AWHILELT:
+			oprangeset(AWHILELE, t)
+			oprangeset(AWHILELO, t)
+			oprangeset(AWHILELS, t)
+			oprangeset(AWHILEGE, t)
+			oprangeset(AWHILEGT, t)
+			oprangeset(AWHILEHS, t)
+			oprangeset(AWHILEHI, t)
+
+		case ACNTB:
+			oprangeset(ACNTH, t)
+			oprangeset(ACNTW, t)
+			oprangeset(ACNTD, t)
+			oprangeset(AINCB, t)
+			oprangeset(AINCH, t)
+			oprangeset(AINCW, t)
+			oprangeset(AINCD, t)
+
 		case AVCNT,
 			AVMOV,
 			AVLD1,
@@ -3309,7 +3399,12 @@ func buildop(ctxt *obj.Link) {
 			AVMOVI,
 			APRFM,
 			AVEXT,
-			AVXAR:
+			AVXAR,
+			AZFMLA,
+			AZDUP,
+			AZLDR,
+			AZSTR,
+			APTRUE:
 			break
 
 		case obj.ANOP,
@@ -5904,6 +5999,140 @@ func (c *ctxt7) asmout(p *obj.Prog, o *Optab, out []uint32) {
 			o1 |= uint32(0x1F)
 		}
 		o1 |= uint32(SYSARG4(int(op.op1), int(op.cn), int(op.cm), int(op.op2)))
+
+	case 108: /* zadd Zm.<T>, Zn.<T>, Zd.<T> */
+		size := sveSize(p.To.Reg)
+		if sveSize(p.From.Reg) != size || sveSize(p.Reg) != size {
+			c.ctxt.Diag("invalid arrangement: %v", p)
+			break
+		}
+		switch p.As {
+		case AZAND, AZORR, AZEOR:
+			if size != 3 {
+				c.ctxt.Diag("invalid arrangement, should be D: %v", p)
+			}
+			size = 0
+		case AZFADD, AZFSUB, AZFMUL:
+			if size == 0 {
+				c.ctxt.Diag("invalid arrangement: %v", p)
+			}
+		}
+		o1 = c.oprrr(p, p.As)
+		o1 |= size<<22 | uint32(p.From.Reg&31)<<16 | uint32(p.Reg&31)<<5 | uint32(p.To.Reg&31)
+
+	case 109: /* zadd Zm.<T>, Pg/M, Zdn.<T> */
+		size := sveSize(p.To.Reg)
+		if sveSize(p.From.Reg) != size {
+			c.ctxt.Diag("invalid arrangement: %v", p)
+			break
+		}
+		pg := c.svePredicate(p, p.Reg)
+		o1 = c.opzpred(p, p.As)
+		o1 |= size<<22 | pg<<10 | uint32(p.From.Reg&31)<<5 | uint32(p.To.Reg&31)
+
+	case 110: /* zfmla Zm.<T>, Zn.<T>, Pg/M, Zda.<T>; zcmpeq Zm.<T>, Zn.<T>, Pg/Z, Pd.<T> */
+		size := sveSize(p.To.Reg)
+		if sveSize(p.From.Reg) != size || sveSize(p.Reg) != size {
+			c.ctxt.Diag("invalid arrangement: %v", p)
+			break
+		}
+		if p.As == AZFMLA && size == 0 {
+			c.ctxt.Diag("invalid arrangement: %v", p)
+		}
+		pg := c.svePredicate(p, p.GetFrom3().Reg)
+		o1 = c.oprrr(p, p.As)
+		o1 |= size<<22 | uint32(p.From.Reg&31)<<16 | pg<<10 | uint32(p.Reg&31)<<5 | uint32(p.To.Reg&31)
+
+	case 111: /* zeor3 Zk.D, Zm.D, Zdn.D */
+		if sveSize(p.From.Reg) != 3 || sveSize(p.Reg) != 3 || sveSize(p.To.Reg) != 3 {
+			c.ctxt.Diag("invalid arrangement, should be D: %v", p)
+			break
+		}
+		o1 = c.oprrr(p, p.As)
+		o1 |= uint32(p.Reg&31)<<16 | uint32(p.From.Reg&31)<<5 | uint32(p.To.Reg&31)
+
+	case 112: /* zdup $imm, Zd.<T> */
+		size := sveSize(p.To.Reg)
+		v := p.From.Offset
+		var sh uint32
+		if (v < -128 || v > 127) && size != 0 && v&0xff == 0 {
+			// a multiple of 256, encoded with LSL #8
+			v >>= 8
+			sh = 1
+		}
+		if v < -128 || v > 127 {
+			c.ctxt.Diag("immediate out of range: %v", p)
+			break
+		}
+		o1 = c.opirr(p, p.As)
+		o1 |= size<<22 | sh<<13 | uint32(v&0xff)<<5 | uint32(p.To.Reg&31)
+
+	case 113: /* zld1d imm(Rn), Pg/Z, Zt.D */
+		if sveSize(p.To.Reg) != sveLoadStoreSize(p.As) {
+			c.ctxt.Diag("invalid arrangement: %v", p)
+			break
+		}
+		v := p.From.Offset
+		if v < -8 || v > 7 {
+			c.ctxt.Diag("offset out of range, should be in [-8, 7]: %v", p)
+			break
+		}
+		pg := c.svePredicate(p, p.Reg)
+		o1 = c.opload(p, p.As)
+		o1 |= uint32(v&15)<<16 | pg<<10 | uint32(p.From.Reg&31)<<5 | uint32(p.To.Reg&31)
+
+	case 114: /* zst1d Zt.D, Pg, imm(Rn) */
+		if sveSize(p.From.Reg) != sveLoadStoreSize(p.As) {
+			c.ctxt.Diag("invalid arrangement: %v", p)
+			break
+		}
+		v := p.To.Offset
+		if v < -8 || v > 7 {
+			c.ctxt.Diag("offset out of range, should be in [-8, 7]: %v", p)
+			break
+		}
+		pg := c.svePredicate(p, p.Reg)
+		o1 = c.opstore(p, p.As)
+		o1 |= uint32(v&15)<<16 | pg<<10 | uint32(p.To.Reg&31)<<5 | uint32(p.From.Reg&31)
+
+	case 115, 116: /* zldr imm(Rn), Zt; zstr Zt, imm(Rn) */
+		var mem *obj.Addr
+		var rt int16
+		if o.type_ == 115 {
+			mem, rt = &p.From, p.To.Reg
+			o1 = c.opload(p, p.As)
+		} else {
+			mem, rt = &p.To, p.From.Reg
+			o1 = c.opstore(p, p.As)
+		}
+		if mem.Offset < -256 || mem.Offset > 255 {
+			c.ctxt.Diag("offset out of range, should be in [-256, 255]: %v", p)
+			break
+		}
+		if REG_Z0 <= rt && rt <= REG_Z31 {
+			o1 |= 1 << 14
+		}
+		v := uint32(mem.Offset)
+		o1 |= (v>>3)&0x3f<<16 | (v&7)<<10 | uint32(mem.Reg&31)<<5 | uint32(rt&31)
+
+	case 117: /* whilelt Rm, Rn, Pd.<T> */
+		o1 = c.oprrr(p, p.As)
+		o1 |= sveSize(p.To.Reg)<<22 | uint32(p.From.Reg&31)<<16 | uint32(p.Reg&31)<<5 | uint32(p.To.Reg&15)
+
+	case 118, 119: /* ptrue [$pattern,] Pd.<T>; cntd [$pattern,] Rd */
+		pattern := int64(31) // ALL
+		if p.From.Type == obj.TYPE_CONST {
+			pattern = p.From.Offset
+		}
+		if pattern < 0 || pattern > 31 {
+			c.ctxt.Diag("illegal pattern: %v", p)
+			break
+		}
+		o1 = c.opirr(p, p.As)
+		if o.type_ == 118 {
+			o1 |= sveSize(p.To.Reg) << 22
+		}
+		o1 |= uint32(pattern)<<5 | uint32(p.To.Reg&31)
 	}
 	out[0] = o1
 	out[1] = o2
@@ -6593,6 +6822,84 @@ func (c *ctxt7) oprrr(p *obj.Prog, a obj.As) uint32 {
 
 	case AVTRN2:
 		return 7<<25 | 1<<14 | 5<<11
+
+	case AZADD:
+		return 4<<24 | 1<<21
+
+	case AZSUB:
+		return 4<<24 | 1<<21 | 1<<10
+
+	case AZMUL:
+		return 4<<24 | 1<<21 | 3<<13
+
+	case AZAND:
+		return 4<<24 | 1<<21 | 3<<12
+
+	case AZORR:
+		return 4<<24 | 3<<21 | 3<<12
+
+	case AZEOR:
+		return 4<<24 | 5<<21 | 3<<12
+
+	case AZEOR3:
+		return 4<<24 | 1<<21 | 7<<11
+
+	case AZBCAX:
+		return 4<<24 | 3<<21 | 7<<11
+
+	case AZFADD:
+		return 0x65 << 24
+
+	case AZFSUB:
+		return 0x65<<24 | 1<<10
+
+	case AZFMUL:
+		return 0x65<<24 | 2<<10
+
+	case AZFMLA:
+		return 0x65<<24 | 1<<21
+
+	case AZCMPEQ:
+		return 0x24<<24 | 5<<13
+
+	case AZCMPNE:
+		return 0x24<<24 | 5<<13 | 1<<4
+
+	case AZCMPGE:
+		return 0x24<<24 | 4<<13
+
+	case AZCMPGT:
+		return 0x24<<24 | 4<<13 | 1<<4
+
+	case AZCMPHS:
+		return 0x24 << 24
+
+	case AZCMPHI:
+		return 0x24<<24 | 1<<4
+
+	case AWHILEGE:
+		return 0x25<<24 | 1<<21 | 4<<10
+
+	case AWHILEGT:
+		return 0x25<<24 | 1<<21 | 4<<10 | 1<<4
+
+	case AWHILEHS:
+		return 0x25<<24 | 1<<21 | 6<<10
+
+	case AWHILEHI:
+		return 0x25<<24 | 1<<21 | 6<<10 | 1<<4
+
+	case AWHILELT:
+		return 0x25<<24 | 1<<21 | 5<<10
+
+	case AWHILELE:
+		return 0x25<<24 | 1<<21 | 5<<10 | 1<<4
+
+	case AWHILELO:
+		return 0x25<<24 | 1<<21 | 7<<10
+
+	case AWHILELS:
+		return 0x25<<24 | 1<<21 | 7<<10 | 1<<4
 	}
 
 	c.ctxt.Diag("%v: bad rrr %d %v", p, a, a)
@@ -6809,6 +7116,36 @@ func (c *ctxt7) opirr(p *obj.Prog, a obj.As) uint32 {
 
 	case APRFM:
 		return 0xf9<<24 | 2<<22
+
+	case AZDUP:
+		return 0x25<<24 | 0x38<<16 | 3<<14
+
+	case APTRUE:
+		return 0x25<<24 | 0x18<<16 | 7<<13
+
+	case ACNTB:
+		return 4<<24 | 0x20<<16 | 7<<13
+
+	case ACNTH:
+		return 4<<24 | 0x60<<16 | 7<<13
+
+	case ACNTW:
+		return 4<<24 | 0xa0<<16 | 7<<13
+
+	case ACNTD:
+		return 4<<24 | 0xe0<<16 | 7<<13
+
+	case AINCB:
+		return 4<<24 | 0x30<<16 | 7<<13
+
+	case AINCH:
+		return 4<<24 | 0x70<<16 | 7<<13
+
+	case AINCW:
+		return 4<<24 | 0xb0<<16 | 7<<13
+
+	case AINCD:
+		return 4<<24 | 0xf0<<16 | 7<<13
 	}
 
 	c.ctxt.Diag("%v: bad irr %v", p, a)
@@ -7134,6 +7471,21 @@ func (c *ctxt7) opload(p *obj.Prog, a obj.As) uint32 {
 
 	case ALDXPW:
 		return LDSTX(2, 0, 1, 1, 0)
+
+	case AZLD1B:
+		return 0xa4<<24 | 5<<13
+
+	case AZLD1H:
+		return 0xa4<<24 | 0xa0<<16 | 5<<13
+
+	case AZLD1W:
+		return 0xa5<<24 | 0x40<<16 | 5<<13
+
+	case AZLD1D:
+		return 0xa5<<24 | 0xe0<<16 | 5<<13
+
+	case AZLDR:
+		return 0x85<<24 | 0x80<<16
 	}
 
 	c.ctxt.Diag("bad opload %v\n%v", a, p)
@@ -7189,12 +7541,75 @@ func (c *ctxt7) opstore(p *obj.Prog, a obj.As) uint32 {
 
 	case ASTXRW:
 		return LDSTX(2, 0, 0, 0, 0) | 0x1F<<10
+
+	case AZST1B:
+		return 0xe4<<24 | 7<<13
+
+	case AZST1H:
+		return 0xe4<<24 | 0xa0<<16 | 7<<13
+
+	case AZST1W:
+		return 0xe5<<24 | 0x40<<16 | 7<<13
+
+	case AZST1D:
+		return 0xe5<<24 | 0xe0<<16 | 7<<13
+
+	case AZSTR:
+		return 0xe5<<24 | 0x80<<16
 	}
 
 	c.ctxt.Diag("bad opstore %v\n%v", a, p)
 	return 0
 }
 
+// opzpred returns the encoding of the predicated, merging form of
+// the SVE integer arithmetic instruction a.
+func (c *ctxt7) opzpred(p *obj.Prog, a obj.As) uint32 {
+	switch a {
+	case AZADD:
+		return 4 << 24
+
+	case AZSUB:
+		return 4<<24 | 1<<16
+
+	case AZMUL:
+		return 4<<24 | 1<<20
+	}
+
+	c.ctxt.Diag("bad opzpred %v\n%v", a, p)
+	return 0
+}
+
+// svePredicate returns the number of the governing predicate register r,
+// which is restricted to P0-P7.
+func (c *ctxt7) svePredicate(p *obj.Prog, r int16) uint32 {
+	n := uint32(r & 15)
+	if n > 7 {
+		c.ctxt.Diag("governing predicate should be in P0-P7: %v", p)
+	}
+	return n & 7
+}
+
+// sveSize returns the element size field, log2 of the element size in
+// bytes, of the SVE vector or predicate register r.
+func sveSize(r int16) uint32 {
+	return uint32(r>>5) & 3
+}
+
+// sveLoadStoreSize returns the element size accessed by the SVE
+// contiguous load or store instruction a.
+func sveLoadStoreSize(a obj.As) uint32 {
+	switch a {
+	case AZLD1H, AZST1H:
+		return 1
+	case AZLD1W, AZST1W:
+		return 2
+	case AZLD1D, AZST1D:
+		return 3
+	}
+	return 0
+}
+
 /*
  * load/store register (scaled 12-bit unsigned immediate) C3.3.13
  *	these produce 64-bit values (when there's an option)
@@ -6,6 +6,7 @@ package arm64
 
 import (
 	"bytes"
+	"compile/cmd_internal/obj"
 	"compile/src_internal/testenv"
 	"fmt"
 	"os"
@@ -333,3 +334,136 @@ func TestMOVK(t *testing.T) {
 		t.Errorf("Got %x want %x\n", x, want)
 	}
 }
+
+func sveZ(n, size int16) obj.Addr {
+	return obj.Addr{Type: obj.TYPE_REG, Reg: REG_ZARNG + size<<5 + n}
+}
+
+func sveP(n, size int16) obj.Addr {
+	return obj.Addr{Type: obj.TYPE_REG, Reg: REG_PARNG + size<<5 + n}
+}
+
+func sveReg(r int16) obj.Addr {
+	return obj.Addr{Type: obj.TYPE_REG, Reg: r}
+}
+
+func sveMem(r int16, off int64) obj.Addr {
+	return obj.Addr{Type: obj.TYPE_MEM, Reg: r, Offset: off}
+}
+
+func sveCon(v int64) obj.Addr {
+	return obj.Addr{Type: obj.TYPE_CONST, Offset: v}
+}
+
+type sveTest struct {
+	as    obj.As
+	from  obj.Addr
+	reg   obj.Addr
+	from3 obj.Addr
+	to    obj.Addr
+	want  uint32
+}
+
+func (test *sveTest) prog(ctxt *obj.Link) *obj.Prog {
+	p := &obj.Prog{Ctxt: ctxt, As: test.as, From: test.from, Reg: test.reg.Reg, To: test.to}
+	if test.from3.Type != obj.TYPE_NONE {
+		p.AddRestSource(test.from3)
+	}
+	return p
+}
+
+// TestSVEEncoding checks the encodings of SVE and SVE2 instructions
+// against the output of the GNU and LLVM assemblers.
+func TestSVEEncoding(t *testing.T) {
+	const B, H, S, D = 0, 1, 2, 3
+	var none obj.Addr
+	tests := []sveTest{
+		{AZADD, sveZ(2, S), sveZ(1, S), none, sveZ(0, S), 0x04a20020},                  // ZADD Z2.S, Z1.S, Z0.S
+		{AZSUB, sveZ(3, D), sveZ(4, D), none, sveZ(5, D), 0x04e30485},                  // ZSUB Z3.D, Z4.D, Z5.D
+		{AZMUL, sveZ(1, H), sveZ(2, H), none, sveZ(3, H), 0x04616043},                  // ZMUL Z1.H, Z2.H, Z3.H
+		{AZADD, sveZ(1, B), sveReg(REG_PM + 2), none, sveZ(0, B), 0x04000820},          // ZADD Z1.B, P2/M, Z0.B
+		{AZMUL, sveZ(7, D), sveReg(REG_PM + 7), none, sveZ(6, D), 0x04d01ce6},          // ZMUL Z7.D, P7/M, Z6.D
+		{AZSUB, sveZ(7, H), sveReg(REG_PM + 1), none, sveZ(6, H), 0x044104e6},          // ZSUB Z7.H, P1/M, Z6.H
+		{AZAND, sveZ(1, D), sveZ(2, D), none, sveZ(3, D), 0x04213043},                  // ZAND Z1.D, Z2.D, Z3.D
+		{AZEOR, sveZ(1, D), sveZ(2, D), none, sveZ(3, D), 0x04a13043},                  // ZEOR Z1.D, Z2.D, Z3.D
+		{AZORR, sveZ(1, D), sveZ(2, D), none, sveZ(3, D), 0x04613043},                  // ZORR Z1.D, Z2.D, Z3.D
+		{AZFADD, sveZ(1, S), sveZ(2, S), none, sveZ(3, S), 0x65810043},                 // ZFADD Z1.S, Z2.S, Z3.S
+		{AZFSUB, sveZ(1, H), sveZ(2, H), none, sveZ(3, H), 0x65410443},                 // ZFSUB Z1.H, Z2.H, Z3.H
+		{AZFMUL, sveZ(1, D), sveZ(2, D), none, sveZ(3, D), 0x65c10843},                 // ZFMUL Z1.D, Z2.D, Z3.D
+		{AZFMLA, sveZ(1, D), sveZ(2, D), sveReg(REG_PM + 3), sveZ(4, D), 0x65e10c44},   // ZFMLA Z1.D, Z2.D, P3/M, Z4.D
+		{AZCMPEQ, sveZ(1, S), sveZ(2, S), sveReg(REG_PZ + 3), sveP(4, S), 0x2481ac44},  // ZCMPEQ Z1.S, Z2.S, P3/Z, P4.S
+		{AZCMPHI, sveZ(1, B), sveZ(2, B), sveReg(REG_PZ + 0), sveP(15, B), 0x2401005f}, // ZCMPHI Z1.B, Z2.B, P0/Z, P15.B
+		{AZCMPGE, sveZ(1, D), sveZ(2, D), sveReg(REG_PZ + 7), sveP(1, D), 0x24c19c41},  // ZCMPGE Z1.D, Z2.D, P7/Z, P1.D
+		{AZEOR3, sveZ(1, D), sveZ(2, D), none, sveZ(3, D), 0x04223823},                 // ZEOR3 Z1.D, Z2.D, Z3.D
+		{AZBCAX, sveZ(1, D), sveZ(2, D), none, sveZ(3, D), 0x04623823},                 // ZBCAX Z1.D, Z2.D, Z3.D
+		{AZDUP, sveCon(-1), none, none, sveZ(0, S), 0x25b8dfe0},                        // ZDUP $-1, Z0.S
+		{AZDUP, sveCon(512), none, none, sveZ(1, H), 0x2578e041},                       // ZDUP $512, Z1.H
+		{AZLD1D, sveMem(REG_R1, 2), sveReg(REG_PZ + 1), none, sveZ(2, D), 0xa5e2a422},  // ZLD1D 2(R1), P1/Z, Z2.D
+		{AZLD1B, sveMem(REG_R0, -8), sveReg(REG_PZ + 0), none, sveZ(0, B), 0xa408a000}, // ZLD1B -8(R0), P0/Z, Z0.B
+		{AZST1W, sveZ(3, S), sveReg(REG_P2), none, sveMem(REGSP, 7), 0xe547ebe3},       // ZST1W Z3.S, P2, 7(RSP)
+		{AZST1H, sveZ(3, H), sveReg(REG_P2), none, sveMem(REG_R4, 0), 0xe4a0e883},      // ZST1H Z3.H, P2, (R4)
+		{AZLDR, sveMem(REG_R2, 17), none, none, sveReg(REG_Z5), 0x85824445},            // ZLDR 17(R2), Z5
+		{AZLDR, sveMem(REG_R2, -256), none, none, sveReg(REG_P5), 0x85a00045},          // ZLDR -256(R2), P5
+		{AZSTR, sveReg(REG_Z31), none, none, sveMem(REGSP, 255), 0xe59f5fff},           // ZSTR Z31, 255(RSP)
+		{AZSTR, sveReg(REG_P1), none, none, sveMem(REG_R3, -1), 0xe5bf1c61},            // ZSTR P1, -1(R3)
+		{AWHILELT, sveReg(REG_R1), sveReg(REG_R2), none, sveP(3, S), 0x25a11443},       // WHILELT R1, R2, P3.S
+		{AWHILELO, sveReg(REG_R1), sveReg(REG_R2), none, sveP(3, D), 0x25e11c43},       // WHILELO R1, R2, P3.D
+		{AWHILEHI, sveReg(REG_R1), sveReg(REG_R2), none, sveP(3, B), 0x25211853},       // WHILEHI R1, R2, P3.B
+		{AWHILEGE, sveReg(REG_R1), sveReg(REG_R2), none, sveP(3, H), 0x25611043},       // WHILEGE R1, R2, P3.H
+		{APTRUE, none, none, none, sveP(1, D), 0x25d8e3e1},                             // PTRUE P1.D
+		{APTRUE, sveCon(8), none, none, sveP(2, B), 0x2518e102},                        // PTRUE $8, P2.B
+		{ACNTD, none, none, none, sveReg(REG_R1), 0x04e0e3e1},                          // CNTD R1
+		{AINCW, none, none, none, sveReg(REG_R2), 0x04b0e3e2},                          // INCW R2
+		{ACNTB, sveCon(1), none, none, sveReg(REG_R3), 0x0420e023},                     // CNTB $1, R3
+	}
+
+	ctxt := obj.Linknew(&Linkarm64)
+	ctxt.DiagFunc = func(format string, args ...interface{}) {
+		t.Errorf(format, args...)
+	}
+	buildop(ctxt)
+	c := &ctxt7{ctxt: ctxt}
+	for _, test := range tests {
+		p := test.prog(ctxt)
+		var out [5]uint32
+		c.asmout(p, c.oplook(p), out[:])
+		if out[0] != test.want {
+			t.Errorf("%v: got 0x%08x, want 0x%08x", p, out[0], test.want)
+		}
+	}
+}
+
+// TestSVEEncodingErrors checks that invalid SVE operands are diagnosed.
+func TestSVEEncodingErrors(t *testing.T) {
+	const B, S, D = 0, 2, 3
+	var none obj.Addr
+	tests := []sveTest{
+		{as: AZADD, from: sveZ(2, S), reg: sveZ(1, D), to: sveZ(0, S)},                 // mismatched element sizes
+		{as: AZAND, from: sveZ(1, S), reg: sveZ(2, S), to: sveZ(3, S)},                 // logical operations only take D
+		{as: AZFADD, from: sveZ(1, B), reg: sveZ(2, B), to: sveZ(3, B)},                // no byte floating point
+		{as: AZADD, from: sveZ(1, B), reg: sveReg(REG_PM + 8), to: sveZ(0, B)},         // governing predicate out of range
+		{as: AZLD1D, from: sveMem(REG_R1, 8), reg: sveReg(REG_PZ + 1), to: sveZ(2, D)}, // offset out of range
+		{as: AZLD1D, from: sveMem(REG_R1, 0), reg: sveReg(REG_PZ + 1), to: sveZ(2, S)}, // element size mismatch
+		{as: AZLDR, from: sveMem(REG_R2, 256), reg: none, to: sveReg(REG_Z5)},          // offset out of range
+		{as: AZLDR, from: sveMem(REG_R2, -257), reg: none, to: sveReg(REG_P5)},         // offset out of range
+		{as: AZSTR, from: sveReg(REG_Z31), reg: none, to: sveMem(REGSP, 1024)},         // offset out of range
+		{as: AZDUP, from: sveCon(200), reg: none, to: sveZ(0, S)},                      // immediate out of range
+		{as: APTRUE, from: sveCon(32), reg: none, to: sveP(0, B)},                      // pattern out of range
+	}
+
+	ctxt := obj.Linknew(&Linkarm64)
+	buildop(ctxt)
+	c := &ctxt7{ctxt: ctxt}
+	for _, test := range tests {
+		errors := 0
+		ctxt.DiagFunc = func(format string, args ...interface{}) {
+			errors++
+		}
+		p := test.prog(ctxt)
+		var out [5]uint32
+		c.asmout(p, c.oplook(p), out[:])
+		if errors == 0 {
+			t.Errorf("%v: expected an error", p)
+		}
+	}
+}
@@ -139,6 +139,35 @@ Examples:
 	LDAXRB (R19), R16       <=>      ldaxrb w16, [x19]
 	NOOP                    <=>      nop
 
+9. SVE and SVE2 instructions.
+
+Go adds a Z prefix to SVE and SVE2 instructions that operate on scalable vector registers, like ZADD,
+ZLD1D and ZCMPEQ. Instructions that only write predicate or general-purpose registers keep their
+names: PTRUE, WHILELT, CNTD and INCD. The 32-bit forms of WHILE are not supported.
+
+The immediate offset of ZLD1B/H/W/D, ZST1B/H/W/D, ZLDR and ZSTR is in multiples of the vector length,
+like the "MUL VL" form of the Arm syntax. ZLD1 and ZST1 accept offsets in the range -8 to 7, ZLDR and
+ZSTR accept offsets in the range -256 to 255. ZLD1 and ZST1 only support the forms whose element size
+equals the memory access size.
+
+PTRUE, CNTB/H/W/D and INCB/H/W/D take an optional $pattern operand, the default is ALL (31).
+
+Examples:
+
+	ZADD Z2.S, Z1.S, Z0.S              <=>      add z0.s, z1.s, z2.s
+	ZADD Z1.B, P2/M, Z0.B              <=>      add z0.b, p2/m, z0.b, z1.b
+	ZFMLA Z1.D, Z2.D, P3/M, Z4.D       <=>      fmla z4.d, p3/m, z2.d, z1.d
+	ZCMPEQ Z1.S, Z2.S, P3/Z, P4.S      <=>      cmpeq p4.s, p3/z, z2.s, z1.s
+	ZEOR3 Z1.D, Z2.D, Z3.D             <=>      eor3 z3.d, z3.d, z2.d, z1.d
+	ZDUP $-1, Z0.S                     <=>      mov z0.s, #-1
+	ZLD1D 2(R1), P1/Z, Z2.D            <=>      ld1d {z2.d}, p1/z, [x1, #2, mul vl]
+	ZST1W Z3.S, P2, 7(RSP)             <=>      st1w {z3.s}, p2, [sp, #7, mul vl]
+	ZLDR 17(R2), Z5                    <=>      ldr z5, [x2, #17, mul vl]
+	ZSTR P1, -1(R3)                    <=>      str p1, [x3, #-1, mul vl]
+	WHILELT R1, R2, P3.S               <=>      whilelt p3.s, x2, x1
+	PTRUE P1.D                         <=>      ptrue p1.d
+	CNTD R1                            <=>      cntd x1
+
 # Register mapping rules
 
 1. All basic register names are written as Rn.
@@ -148,6 +177,10 @@ Examples:
 3. Bn, Hn, Dn, Sn and Qn instructions are written as Fn in floating-point instructions and as Vn
 in SIMD instructions.
 
+4. SVE scalable vector registers are written as Zn, predicate registers as Pn. Element sizes are
+written as Zn.<T> and Pn.<T>, where <T> is one of B, H, S and D. Governing predicates with a
+zeroing or merging qualifier are written as Pg/Z and Pg/M.
+
 # Argument mapping rules
 
 1. The operands appear in left-to-right assignment order.
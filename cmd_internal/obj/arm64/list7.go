@@ -111,6 +111,18 @@ func rconv(r int) string {
 		return fmt.Sprintf("V%d", r-REG_V0)
 	case r == REGSP:
 		return "RSP"
+	case REG_Z0 <= r && r <= REG_Z31:
+		return fmt.Sprintf("Z%d", r-REG_Z0)
+	case REG_P0 <= r && r <= REG_P15:
+		return fmt.Sprintf("P%d", r-REG_P0)
+	case REG_PZ <= r && r < REG_PM:
+		return fmt.Sprintf("P%d/Z", r&15)
+	case REG_PM <= r && r < REG_PM+16:
+		return fmt.Sprintf("P%d/M", r&15)
+	case REG_ZARNG <= r && r < REG_PARNG:
+		return fmt.Sprintf("Z%d.%s", r&31, arrange(ARNG_B+(r>>5)&3))
+	case REG_PARNG <= r && r < REG_PARNG+1<<7:
+		return fmt.Sprintf("P%d.%s", r&15, arrange(ARNG_B+(r>>5)&3))
 	case REG_UXTB <= r && r < REG_UXTH:
 		if ext != 0 {
 			return fmt.Sprintf("%s.UXTB<<%d", regname(r), ext)
@@ -480,6 +480,7 @@ type FuncInfo struct {
 	Pcln      Pcln
 	InlMarks  []InlMark
 	spills    []RegSpill
+	CFIHashes []uint32 // signature hashes placed at entry for -cfi checks
 
 	dwarfInfoSym       *LSym
 	dwarfLocSym        *LSym
@@ -837,6 +838,15 @@ const (
 	// PkgInit indicates this is a compiler-generated package init func.
 	AttrPkgInit
 
+	// Indirect indicates the function may be the target of an indirect
+	// call or jump, and so needs a landing pad when Link.IBT is set
+	// and its signature hashes when Link.CFI is set.
+	AttrIndirect
+
+	// Cold indicates the function is rarely executed, so that
+	// branches leading to calls to it are laid out as unlikely.
+	AttrCold
+
 	// attrABIBase is the value at which the ABI is encoded in
 	// Attribute. This must be last; all bits after this are
 	// assumed to be an ABI value.
@@ -866,6 +876,8 @@ func (a *Attribute) ContentAddressable() bool { return a.load()&AttrContentAddre
 func (a *Attribute) ABIWrapper() bool         { return a.load()&AttrABIWrapper != 0 }
 func (a *Attribute) IsPcdata() bool           { return a.load()&AttrPcdata != 0 }
 func (a *Attribute) IsPkgInit() bool          { return a.load()&AttrPkgInit != 0 }
+func (a *Attribute) Indirect() bool           { return a.load()&AttrIndirect != 0 }
+func (a *Attribute) IsCold() bool             { return a.load()&AttrCold != 0 }
 
 func (a *Attribute) Set(flag Attribute, value bool) {
 	for {
@@ -915,6 +927,8 @@ var textAttrStrings = [...]struct {
 	{bit: AttrContentAddressable, s: ""},
 	{bit: AttrABIWrapper, s: "ABIWRAPPER"},
 	{bit: AttrPkgInit, s: "PKGINIT"},
+	{bit: AttrIndirect, s: "INDIRECT"},
+	{bit: AttrCold, s: "COLD"},
 }
 
 // String formats a for printing in as part of a TEXT prog.
@@ -1019,9 +1033,12 @@ type Link struct {
 	Flag_dynlink       bool
 	Flag_linkshared    bool
 	Flag_optimize      bool
+	Flag_optsize       bool // favor code size over speed, e.g. by omitting alignment padding
 	Flag_locationlists bool
 	Flag_noRefName     bool   // do not include referenced symbol names in object file
 	Retpoline          bool   // emit use of retpoline stubs for indirect jmp/call
+	IBT                bool   // emit landing pads at indirect branch targets
+	CFI                bool   // emit signature hashes at indirect branch targets
 	Flag_maymorestack  string // If not "", call this function before stack checks
 	Bso                *bufio.Writer
 	Pathname           string
@@ -1077,6 +1094,12 @@ func (ctxt *Link) Logf(format string, args ...interface{}) {
 	ctxt.Bso.Flush()
 }
 
+// TrackIndirect reports whether functions that may be the target of
+// an indirect call or jump must be marked with AttrIndirect.
+func (ctxt *Link) TrackIndirect() bool {
+	return ctxt.IBT || ctxt.CFI
+}
+
 // SpillRegisterArgs emits the code to spill register args into whatever
 // locations the spill records specify.
 func (fi *FuncInfo) SpillRegisterArgs(last *Prog, pa ProgAlloc) *Prog {
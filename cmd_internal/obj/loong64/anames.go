@@ -134,5 +134,81 @@ var Anames = []string{
 	"RDTIMELW",
 	"RDTIMEHW",
 	"RDTIMED",
+	"VMOVQ",
+	"XVMOVQ",
+	"VADDB",
+	"VADDH",
+	"VADDW",
+	"VADDV",
+	"VADDQ",
+	"VSUBB",
+	"VSUBH",
+	"VSUBW",
+	"VSUBV",
+	"VSUBQ",
+	"VMULB",
+	"VMULH",
+	"VMULW",
+	"VMULV",
+	"XVADDB",
+	"XVADDH",
+	"XVADDW",
+	"XVADDV",
+	"XVADDQ",
+	"XVSUBB",
+	"XVSUBH",
+	"XVSUBW",
+	"XVSUBV",
+	"XVSUBQ",
+	"XVMULB",
+	"XVMULH",
+	"XVMULW",
+	"XVMULV",
+	"VANDV",
+	"VORV",
+	"VXORV",
+	"VNORV",
+	"VANDNV",
+	"VORNV",
+	"XVANDV",
+	"XVORV",
+	"XVXORV",
+	"XVNORV",
+	"XVANDNV",
+	"XVORNV",
+	"VSEQB",
+	"VSEQH",
+	"VSEQW",
+	"VSEQV",
+	"VSLTB",
+	"VSLTH",
+	"VSLTW",
+	"VSLTV",
+	"VSLTBU",
+	"VSLTHU",
+	"VSLTWU",
+	"VSLTVU",
+	"XVSEQB",
+	"XVSEQH",
+	"XVSEQW",
+	"XVSEQV",
+	"XVSLTB",
+	"XVSLTH",
+	"XVSLTW",
+	"XVSLTV",
+	"XVSLTBU",
+	"XVSLTHU",
+	"XVSLTWU",
+	"XVSLTVU",
+	"VSHUFB",
+	"VSHUF4IB",
+	"VSHUF4IH",
+	"VSHUF4IW",
+	"VSHUF4IV",
+	"XVSHUFB",
+	"XVSHUF4IB",
+	"XVSHUF4IH",
+	"XVSHUF4IW",
+	"XVSHUF4IV",
 	"LAST",
 }
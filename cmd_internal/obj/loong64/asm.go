@@ -363,6 +363,30 @@ var optab = []Optab{
 	{ARDTIMEHW, C_NONE, C_NONE, C_NONE, C_REG, C_REG, 62, 4, 0, 0},
 	{ARDTIMED, C_NONE, C_NONE, C_NONE, C_REG, C_REG, 62, 4, 0, 0},
 
+	{AVMOVQ, C_VREG, C_NONE, C_NONE, C_SEXT, C_NONE, 7, 4, 0, 0},
+	{AVMOVQ, C_VREG, C_NONE, C_NONE, C_SAUTO, C_NONE, 7, 4, REGSP, 0},
+	{AVMOVQ, C_VREG, C_NONE, C_NONE, C_SOREG, C_NONE, 7, 4, REGZERO, 0},
+	{AXVMOVQ, C_XREG, C_NONE, C_NONE, C_SEXT, C_NONE, 7, 4, 0, 0},
+	{AXVMOVQ, C_XREG, C_NONE, C_NONE, C_SAUTO, C_NONE, 7, 4, REGSP, 0},
+	{AXVMOVQ, C_XREG, C_NONE, C_NONE, C_SOREG, C_NONE, 7, 4, REGZERO, 0},
+	{AVMOVQ, C_SEXT, C_NONE, C_NONE, C_VREG, C_NONE, 8, 4, 0, 0},
+	{AVMOVQ, C_SAUTO, C_NONE, C_NONE, C_VREG, C_NONE, 8, 4, REGSP, 0},
+	{AVMOVQ, C_SOREG, C_NONE, C_NONE, C_VREG, C_NONE, 8, 4, REGZERO, 0},
+	{AXVMOVQ, C_SEXT, C_NONE, C_NONE, C_XREG, C_NONE, 8, 4, 0, 0},
+	{AXVMOVQ, C_SAUTO, C_NONE, C_NONE, C_XREG, C_NONE, 8, 4, REGSP, 0},
+	{AXVMOVQ, C_SOREG, C_NONE, C_NONE, C_XREG, C_NONE, 8, 4, REGZERO, 0},
+
+	{AVSEQB, C_VREG, C_VREG, C_NONE, C_VREG, C_NONE, 2, 4, 0, 0},
+	{AVSEQB, C_VREG, C_NONE, C_NONE, C_VREG, C_NONE, 2, 4, 0, 0},
+	{AXVSEQB, C_XREG, C_XREG, C_NONE, C_XREG, C_NONE, 2, 4, 0, 0},
+	{AXVSEQB, C_XREG, C_NONE, C_NONE, C_XREG, C_NONE, 2, 4, 0, 0},
+	{AVSHUFB, C_VREG, C_VREG, C_VREG, C_VREG, C_NONE, 66, 4, 0, 0},
+	{AXVSHUFB, C_XREG, C_XREG, C_XREG, C_XREG, C_NONE, 66, 4, 0, 0},
+	{AVSHUF4IB, C_ANDCON, C_VREG, C_NONE, C_VREG, C_NONE, 67, 4, 0, 0},
+	{AVSHUF4IB, C_ANDCON, C_NONE, C_NONE, C_VREG, C_NONE, 67, 4, 0, 0},
+	{AXVSHUF4IB, C_ANDCON, C_XREG, C_NONE, C_XREG, C_NONE, 67, 4, 0, 0},
+	{AXVSHUF4IB, C_ANDCON, C_NONE, C_NONE, C_XREG, C_NONE, 67, 4, 0, 0},
+
 	{obj.AUNDEF, C_NONE, C_NONE, C_NONE, C_NONE, C_NONE, 49, 4, 0, 0},
 	{obj.APCALIGN, C_SCON, C_NONE, C_NONE, C_NONE, C_NONE, 0, 0, 0, 0},
 	{obj.APCDATA, C_LCON, C_NONE, C_NONE, C_LCON, C_NONE, 0, 0, 0, 0},
@@ -623,6 +647,12 @@ func (c *ctxt0) aclass(a *obj.Addr) int {
 		if REG_FCC0 <= a.Reg && a.Reg <= REG_FCC31 {
 			return C_FCCREG
 		}
+		if REG_V0 <= a.Reg && a.Reg <= REG_V31 {
+			return C_VREG
+		}
+		if REG_X0 <= a.Reg && a.Reg <= REG_X31 {
+			return C_XREG
+		}
 		return C_GOK
 
 	case obj.TYPE_MEM:
@@ -819,6 +849,11 @@ func (c *ctxt0) oplook(p *obj.Prog) *Optab {
 	a2 := C_NONE
 	if p.Reg != 0 {
 		a2 = C_REG
+		if REG_V0 <= p.Reg && p.Reg <= REG_V31 {
+			a2 = C_VREG
+		} else if REG_X0 <= p.Reg && p.Reg <= REG_X31 {
+			a2 = C_XREG
+		}
 	}
 
 	// 2nd destination operand
@@ -1154,6 +1189,10 @@ func buildop(ctxt *obj.Link) {
 			ARDTIMELW,
 			ARDTIMEHW,
 			ARDTIMED,
+			AVMOVQ,
+			AXVMOVQ,
+			AVSHUFB,
+			AXVSHUFB,
 			obj.ANOP,
 			obj.ATEXT,
 			obj.AUNDEF,
@@ -1172,6 +1211,82 @@ func buildop(ctxt *obj.Link) {
 
 		case AMASKEQZ:
 			opset(AMASKNEZ, r0)
+
+		case AVSEQB:
+			opset(AVSEQH, r0)
+			opset(AVSEQW, r0)
+			opset(AVSEQV, r0)
+			opset(AVSLTB, r0)
+			opset(AVSLTH, r0)
+			opset(AVSLTW, r0)
+			opset(AVSLTV, r0)
+			opset(AVSLTBU, r0)
+			opset(AVSLTHU, r0)
+			opset(AVSLTWU, r0)
+			opset(AVSLTVU, r0)
+			opset(AVADDB, r0)
+			opset(AVADDH, r0)
+			opset(AVADDW, r0)
+			opset(AVADDV, r0)
+			opset(AVADDQ, r0)
+			opset(AVSUBB, r0)
+			opset(AVSUBH, r0)
+			opset(AVSUBW, r0)
+			opset(AVSUBV, r0)
+			opset(AVSUBQ, r0)
+			opset(AVMULB, r0)
+			opset(AVMULH, r0)
+			opset(AVMULW, r0)
+			opset(AVMULV, r0)
+			opset(AVANDV, r0)
+			opset(AVORV, r0)
+			opset(AVXORV, r0)
+			opset(AVNORV, r0)
+			opset(AVANDNV, r0)
+			opset(AVORNV, r0)
+
+		case AXVSEQB:
+			opset(AXVSEQH, r0)
+			opset(AXVSEQW, r0)
+			opset(AXVSEQV, r0)
+			opset(AXVSLTB, r0)
+			opset(AXVSLTH, r0)
+			opset(AXVSLTW, r0)
+			opset(AXVSLTV, r0)
+			opset(AXVSLTBU, r0)
+			opset(AXVSLTHU, r0)
+			opset(AXVSLTWU, r0)
+			opset(AXVSLTVU, r0)
+			opset(AXVADDB, r0)
+			opset(AXVADDH, r0)
+			opset(AXVADDW, r0)
+			opset(AXVADDV, r0)
+			opset(AXVADDQ, r0)
+			opset(AXVSUBB, r0)
+			opset(AXVSUBH, r0)
+			opset(AXVSUBW, r0)
+			opset(AXVSUBV, r0)
+			opset(AXVSUBQ, r0)
+			opset(AXVMULB, r0)
+			opset(AXVMULH, r0)
+			opset(AXVMULW, r0)
+			opset(AXVMULV, r0)
+			opset(AXVANDV, r0)
+			opset(AXVORV, r0)
+			opset(AXVXORV, r0)
+			opset(AXVNORV, r0)
+			opset(AXVANDNV, r0)
+			opset(AXVORNV, r0)
+
+		case AVSHUF4IB:
+			opset(AVSHUF4IH, r0)
+			opset(AVSHUF4IW, r0)
+			opset(AVSHUF4IV, r0)
+
+		case AXVSHUF4IB:
+			opset(AXVSHUF4IH, r0)
+			opset(AXVSHUF4IW, r0)
+			opset(AXVSHUF4IV, r0)
 		}
 	}
 }
@@ -1201,6 +1316,14 @@ func OP_RR(op uint32, r2 uint32, r3 uint32) uint32 {
 	return op | (r2&0x1F)<<5 | (r3&0x1F)<<0
 }
 
+// r1 -> ra
+// r2 -> rk
+// r3 -> rj
+// r4 -> rd
+func OP_RRRR(op uint32, r1 uint32, r2 uint32, r3 uint32, r4 uint32) uint32 {
+	return op | (r1&0x1F)<<15 | (r2&0x1F)<<10 | (r3&0x1F)<<5 | (r4 & 0x1F)
+}
+
 func OP_16IR_5I(op uint32, i uint32, r2 uint32) uint32 {
 	return op | (i&0xFFFF)<<10 | (r2&0x1F)<<5 | ((i >> 16) & 0x1F)
 }
@@ -1213,6 +1336,10 @@ func OP_12IRR(op uint32, i uint32, r2 uint32, r3 uint32) uint32 {
 	return op | (i&0xFFF)<<10 | (r2&0x1F)<<5 | (r3&0x1F)<<0
 }
 
+func OP_8IRR(op uint32, i uint32, r2 uint32, r3 uint32) uint32 {
+	return op | (i&0xFF)<<10 | (r2&0x1F)<<5 | (r3&0x1F)<<0
+}
+
 func OP_IR(op uint32, i uint32, r2 uint32) uint32 {
 	return op | (i&0xFFFFF)<<5 | (r2&0x1F)<<0 // ui20, rd5
 }
@@ -1797,6 +1924,20 @@ func (c *ctxt0) asmout(p *obj.Prog, o *Optab, out []uint32) {
 		rel2.Sym = p.From.Sym
 		rel2.Type = objabi.R_LOONG64_GOT_LO
 		rel2.Add = 0x0
+
+	case 66: // vshuf.b va, vk, vj, vd
+		o1 = OP_RRRR(c.oprrr(p.As), uint32(p.From.Reg), uint32(p.Reg), uint32(p.GetFrom3().Reg), uint32(p.To.Reg))
+
+	case 67: // vshuf4i.b $ui8, [vj], vd
+		v := c.regoff(&p.From)
+		if v < 0 || v > 0xff {
+			c.ctxt.Diag("immediate out of range 0 to 255\n%v", p)
+		}
+		r := int(p.Reg)
+		if r == 0 {
+			r = int(p.To.Reg)
+		}
+		o1 = OP_8IRR(c.opirr(p.As), uint32(v), uint32(r), uint32(p.To.Reg))
 	}
 
 	out[0] = o1
@@ -1988,6 +2129,139 @@ func (c *ctxt0) oprrr(a obj.As) uint32 {
 	case ANOOP:
 		// andi r0, r0, 0
 		return 0x03400000
+
+	case AVSEQB:
+		return 0xe000 << 15
+	case AVSEQH:
+		return 0xe001 << 15
+	case AVSEQW:
+		return 0xe002 << 15
+	case AVSEQV:
+		return 0xe003 << 15
+	case AVSLTB:
+		return 0xe00c << 15
+	case AVSLTH:
+		return 0xe00d << 15
+	case AVSLTW:
+		return 0xe00e << 15
+	case AVSLTV:
+		return 0xe00f << 15
+	case AVSLTBU:
+		return 0xe010 << 15
+	case AVSLTHU:
+		return 0xe011 << 15
+	case AVSLTWU:
+		return 0xe012 << 15
+	case AVSLTVU:
+		return 0xe013 << 15
+	case AVADDB:
+		return 0xe014 << 15
+	case AVADDH:
+		return 0xe015 << 15
+	case AVADDW:
+		return 0xe016 << 15
+	case AVADDV:
+		return 0xe017 << 15
+	case AVSUBB:
+		return 0xe018 << 15
+	case AVSUBH:
+		return 0xe019 << 15
+	case AVSUBW:
+		return 0xe01a << 15
+	case AVSUBV:
+		return 0xe01b << 15
+	case AVMULB:
+		return 0xe108 << 15
+	case AVMULH:
+		return 0xe109 << 15
+	case AVMULW:
+		return 0xe10a << 15
+	case AVMULV:
+		return 0xe10b << 15
+	case AVANDV:
+		return 0xe24c << 15
+	case AVORV:
+		return 0xe24d << 15
+	case AVXORV:
+		return 0xe24e << 15
+	case AVNORV:
+		return 0xe24f << 15
+	case AVANDNV:
+		return 0xe250 << 15
+	case AVORNV:
+		return 0xe251 << 15
+	case AVADDQ:
+		return 0xe25a << 15
+	case AVSUBQ:
+		return 0xe25b << 15
+	case AXVSEQB:
+		return 0xe800 << 15
+	case AXVSEQH:
+		return 0xe801 << 15
+	case AXVSEQW:
+		return 0xe802 << 15
+	case AXVSEQV:
+		return 0xe803 << 15
+	case AXVSLTB:
+		return 0xe80c << 15
+	case AXVSLTH:
+		return 0xe80d << 15
+	case AXVSLTW:
+		return 0xe80e << 15
+	case AXVSLTV:
+		return 0xe80f << 15
+	case AXVSLTBU:
+		return 0xe810 << 15
+	case AXVSLTHU:
+		return 0xe811 << 15
+	case AXVSLTWU:
+		return 0xe812 << 15
+	case AXVSLTVU:
+		return 0xe813 << 15
+	case AXVADDB:
+		return 0xe814 << 15
+	case AXVADDH:
+		return 0xe815 << 15
+	case AXVADDW:
+		return 0xe816 << 15
+	case AXVADDV:
+		return 0xe817 << 15
+	case AXVSUBB:
+		return 0xe818 << 15
+	case AXVSUBH:
+		return 0xe819 << 15
+	case AXVSUBW:
+		return 0xe81a << 15
+	case AXVSUBV:
+		return 0xe81b << 15
+	case AXVMULB:
+		return 0xe908 << 15
+	case AXVMULH:
+		return 0xe909 << 15
+	case AXVMULW:
+		return 0xe90a << 15
+	case AXVMULV:
+		return 0xe90b << 15
+	case AXVANDV:
+		return 0xea4c << 15
+	case AXVORV:
+		return 0xea4d << 15
+	case AXVXORV:
+		return 0xea4e << 15
+	case AXVNORV:
+		return 0xea4f << 15
+	case AXVANDNV:
+		return 0xea50 << 15
+	case AXVORNV:
+		return 0xea51 << 15
+	case AXVADDQ:
+		return 0xea5a << 15
+	case AXVSUBQ:
+		return 0xea5b << 15
+	case AVSHUFB:
+		return 0x0d5 << 20
+	case AXVSHUFB:
+		return 0x0d6 << 20
 	}
 
 	if a < 0 {
@@ -2163,6 +2437,31 @@ func (c *ctxt0) opirr(a obj.As) uint32 {
 		return 0x021 << 24
 	case ASCV:
 		return 0x023 << 24
+
+	case AVMOVQ:
+		return 0x0b1 << 22 // vst
+	case AXVMOVQ:
+		return 0x0b3 << 22 // xvst
+	case -AVMOVQ:
+		return 0x0b0 << 22 // vld
+	case -AXVMOVQ:
+		return 0x0b2 << 22 // xvld
+	case AVSHUF4IB:
+		return 0x1ce4 << 18
+	case AVSHUF4IH:
+		return 0x1ce5 << 18
+	case AVSHUF4IW:
+		return 0x1ce6 << 18
+	case AVSHUF4IV:
+		return 0x1ce7 << 18
+	case AXVSHUF4IB:
+		return 0x1de4 << 18
+	case AXVSHUF4IH:
+		return 0x1de5 << 18
+	case AXVSHUF4IW:
+		return 0x1de6 << 18
+	case AXVSHUF4IV:
+		return 0x1de7 << 18
 	}
 
 	if a < 0 {
@@ -0,0 +1,92 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loong64
+
+import (
+	"compile/cmd_internal/obj"
+	"testing"
+)
+
+func regAddr(r int16) obj.Addr {
+	return obj.Addr{Type: obj.TYPE_REG, Reg: r}
+}
+
+func memAddr(r int16, off int64) obj.Addr {
+	return obj.Addr{Type: obj.TYPE_MEM, Reg: r, Offset: off}
+}
+
+func constAddr(v int64) obj.Addr {
+	return obj.Addr{Type: obj.TYPE_CONST, Offset: v}
+}
+
+type encodingTest struct {
+	as    obj.As
+	from  obj.Addr
+	reg   int16
+	from3 obj.Addr
+	to    obj.Addr
+	want  uint32
+}
+
+func (test *encodingTest) prog(ctxt *obj.Link) *obj.Prog {
+	p := &obj.Prog{Ctxt: ctxt, As: test.as, From: test.from, Reg: test.reg, To: test.to}
+	if test.from3.Type != obj.TYPE_NONE {
+		p.AddRestSource(test.from3)
+	}
+	return p
+}
+
+// TestVectorEncoding checks the encodings of LSX and LASX instructions.
+func TestVectorEncoding(t *testing.T) {
+	var none obj.Addr
+	tests := []encodingTest{
+		{AVMOVQ, memAddr(REG_R4, 0), 0, none, regAddr(REG_V2), 0x2c000082},                // VMOVQ (R4), V2
+		{AVMOVQ, memAddr(REG_R4, -2044), 0, none, regAddr(REG_V2), 0x2c201082},            // VMOVQ -2044(R4), V2
+		{AVMOVQ, regAddr(REG_V2), 0, none, memAddr(REG_R4, 2040), 0x2c5fe082},             // VMOVQ V2, 2040(R4)
+		{AXVMOVQ, memAddr(REG_R4, 3), 0, none, regAddr(REG_X3), 0x2c800c83},               // XVMOVQ 3(R4), X3
+		{AXVMOVQ, regAddr(REG_X5), 0, none, memAddr(REG_R4, -2040), 0x2ce02085},           // XVMOVQ X5, -2040(R4)
+		{AVADDB, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x700a0443},              // VADDB V1, V2, V3
+		{AVADDV, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x700b8443},              // VADDV V1, V2, V3
+		{AVADDQ, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x712d0443},              // VADDQ V1, V2, V3
+		{AVSUBQ, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x712d8443},              // VSUBQ V1, V2, V3
+		{AVSUBW, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x700d0443},              // VSUBW V1, V2, V3
+		{AVMULH, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x70848443},              // VMULH V1, V2, V3
+		{AXVADDH, regAddr(REG_X3), REG_X2, none, regAddr(REG_X1), 0x740a8c41},             // XVADDH X3, X2, X1
+		{AXVSUBQ, regAddr(REG_X3), REG_X2, none, regAddr(REG_X1), 0x752d8c41},             // XVSUBQ X3, X2, X1
+		{AXVMULV, regAddr(REG_X3), REG_X2, none, regAddr(REG_X1), 0x74858c41},             // XVMULV X3, X2, X1
+		{AVANDV, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x71260443},              // VANDV V1, V2, V3
+		{AVNORV, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x71278443},              // VNORV V1, V2, V3
+		{AVORNV, regAddr(REG_V1), 0, none, regAddr(REG_V2), 0x71288442},                   // VORNV V1, V2
+		{AXVXORV, regAddr(REG_X1), REG_X2, none, regAddr(REG_X3), 0x75270443},             // XVXORV X1, X2, X3
+		{AXVANDNV, regAddr(REG_X1), REG_X2, none, regAddr(REG_X3), 0x75280443},            // XVANDNV X1, X2, X3
+		{AVSEQB, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x70000443},              // VSEQB V1, V2, V3
+		{AVSEQV, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x70018443},              // VSEQV V1, V2, V3
+		{AXVSEQH, regAddr(REG_X3), REG_X2, none, regAddr(REG_X4), 0x74008c44},             // XVSEQH X3, X2, X4
+		{AVSLTW, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x70070443},              // VSLTW V1, V2, V3
+		{AVSLTHU, regAddr(REG_V1), REG_V2, none, regAddr(REG_V3), 0x70088443},             // VSLTHU V1, V2, V3
+		{AXVSLTVU, regAddr(REG_X1), REG_X2, none, regAddr(REG_X3), 0x74098443},            // XVSLTVU X1, X2, X3
+		{AVSHUFB, regAddr(REG_V1), REG_V2, regAddr(REG_V3), regAddr(REG_V4), 0x0d508864},  // VSHUFB V1, V2, V3, V4
+		{AXVSHUFB, regAddr(REG_X1), REG_X2, regAddr(REG_X3), regAddr(REG_X4), 0x0d608864}, // XVSHUFB X1, X2, X3, X4
+		{AVSHUF4IB, constAddr(255), REG_V2, none, regAddr(REG_V1), 0x7393fc41},            // VSHUF4IB $255, V2, V1
+		{AVSHUF4IH, constAddr(128), REG_V2, none, regAddr(REG_V1), 0x73960041},            // VSHUF4IH $128, V2, V1
+		{AVSHUF4IW, constAddr(96), REG_V2, none, regAddr(REG_V1), 0x73998041},             // VSHUF4IW $96, V2, V1
+		{AXVSHUF4IV, constAddr(8), REG_X1, none, regAddr(REG_X2), 0x779c2022},             // XVSHUF4IV $8, X1, X2
+	}
+
+	ctxt := obj.Linknew(&Linkloong64)
+	ctxt.DiagFunc = func(format string, args ...interface{}) {
+		t.Errorf(format, args...)
+	}
+	buildop(ctxt)
+	c := &ctxt0{ctxt: ctxt}
+	for _, test := range tests {
+		p := test.prog(ctxt)
+		var out [5]uint32
+		c.asmout(p, c.oplook(p), out[:])
+		if out[0] != test.want {
+			t.Errorf("%v: got 0x%08x, want 0x%08x", p, out[0], test.want)
+		}
+	}
+}
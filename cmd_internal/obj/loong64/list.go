@@ -34,6 +34,12 @@ func rconv(r int) string {
 	if REG_FCC0 <= r && r <= REG_FCC31 {
 		return fmt.Sprintf("FCC%d", r-REG_FCC0)
 	}
+	if REG_V0 <= r && r <= REG_V31 {
+		return fmt.Sprintf("V%d", r-REG_V0)
+	}
+	if REG_X0 <= r && r <= REG_X31 {
+		return fmt.Sprintf("X%d", r-REG_X0)
+	}
 	return fmt.Sprintf("Rgok(%d)", r-obj.RBaseLOONG64)
 }
 
@@ -212,6 +212,9 @@ func (ctxt *Link) InitTextSym(s *LSym, flag int, start src.XPos) {
 	s.Set(AttrNeedCtxt, flag&NEEDCTXT != 0)
 	s.Set(AttrNoFrame, flag&NOFRAME != 0)
 	s.Set(AttrPkgInit, flag&PKGINIT != 0)
+	if flag&INDIRECT != 0 {
+		s.Set(AttrIndirect, true)
+	}
 	s.Type = objabi.STEXT
 	ctxt.Text = append(ctxt.Text, s)
 
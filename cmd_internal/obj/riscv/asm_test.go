@@ -6,6 +6,10 @@ package riscv
 
 import (
 	"bytes"
+	"compile/cmd_internal/obj"
+	"compile/cmd_internal/src"
+	"compile/cmd_internal/sys"
+	"compile/src_internal/buildcfg"
 	"compile/src_internal/testenv"
 	"fmt"
 	"os"
@@ -309,3 +313,193 @@ TEXT _stub(SB),$0-0
 		t.Errorf("PCALIGN test failed - got %s\nwant %s", out, want)
 	}
 }
+
+func TestCompressedEncoding(t *testing.T) {
+	jmp := &obj.Prog{To: obj.Addr{Type: obj.TYPE_CONST}}
+	tests := []struct {
+		ins  *instruction
+		want uint32
+		ok   bool
+	}{
+		{&instruction{as: AADDI, rd: REG_ZERO, rs1: REG_ZERO}, 0x0001, true},          // C.NOP
+		{&instruction{as: AADDI, rd: REG_A0, rs1: REG_ZERO}, 0x4501, true},            // C.LI
+		{&instruction{as: AADDI, rd: REG_SP, rs1: REG_SP, imm: -16}, 0x1141, true},    // C.ADDI
+		{&instruction{as: AADDI, rd: REG_SP, rs1: REG_SP, imm: -64}, 0x7139, true},    // C.ADDI16SP
+		{&instruction{as: AADDI, rd: REG_A0, rs1: REG_SP, imm: 16}, 0x0808, true},     // C.ADDI4SPN
+		{&instruction{as: AADDI, rd: REG_A0, rs1: REG_A1}, 0x852e, true},              // C.MV
+		{&instruction{as: AADDIW, rd: REG_A0, rs1: REG_A0, imm: 1}, 0x2505, true},     // C.ADDIW
+		{&instruction{as: ALUI, rd: REG_A0, imm: 1}, 0x6505, true},                    // C.LUI
+		{&instruction{as: ASLLI, rd: REG_A0, rs1: REG_A0, imm: 3}, 0x050e, true},      // C.SLLI
+		{&instruction{as: ASRLI, rd: REG_A0, rs1: REG_A0, imm: 3}, 0x810d, true},      // C.SRLI
+		{&instruction{as: ASRAI, rd: REG_A0, rs1: REG_A0, imm: 3}, 0x850d, true},      // C.SRAI
+		{&instruction{as: AANDI, rd: REG_A0, rs1: REG_A0, imm: -1}, 0x997d, true},     // C.ANDI
+		{&instruction{as: AADD, rd: REG_A0, rs1: REG_A0, rs2: REG_A1}, 0x952e, true},  // C.ADD
+		{&instruction{as: AADD, rd: REG_A0, rs1: REG_A1, rs2: REG_A0}, 0x952e, true},  // C.ADD
+		{&instruction{as: ASUB, rd: REG_A0, rs1: REG_A0, rs2: REG_A1}, 0x8d0d, true},  // C.SUB
+		{&instruction{as: AAND, rd: REG_A0, rs1: REG_A0, rs2: REG_A1}, 0x8d6d, true},  // C.AND
+		{&instruction{as: AADDW, rd: REG_A0, rs1: REG_A0, rs2: REG_A1}, 0x9d2d, true}, // C.ADDW
+		{&instruction{as: ALW, rd: REG_A0, rs1: REG_A1, imm: 4}, 0x41c8, true},        // C.LW
+		{&instruction{as: ALD, rd: REG_A0, rs1: REG_A1}, 0x6188, true},                // C.LD
+		{&instruction{as: ALD, rd: REG_RA, rs1: REG_SP, imm: 8}, 0x60a2, true},        // C.LDSP
+		{&instruction{as: ASD, rd: REG_A1, rs1: REG_A0, imm: 8}, 0xe588, true},        // C.SD
+		{&instruction{as: ASD, rd: REG_SP, rs1: REG_RA, imm: 8}, 0xe406, true},        // C.SDSP
+		{&instruction{as: AFLD, rd: REG_FA0, rs1: REG_A1, imm: 8}, 0x2588, true},      // C.FLD
+		{&instruction{as: AFSD, rd: REG_SP, rs1: REG_FA0, imm: 8}, 0xa42a, true},      // C.FSDSP
+		{&instruction{as: ABEQ, rs1: REG_ZERO, rs2: REG_A0, imm: 8}, 0xc501, true},    // C.BEQZ
+		{&instruction{as: AJAL, rd: REG_ZERO, p: jmp}, 0xa001, true},                  // C.J
+		{&instruction{as: AJALR, rd: REG_ZERO, rs1: REG_RA}, 0x8082, true},            // C.JR
+		{&instruction{as: AJALR, rd: REG_RA, rs1: REG_A0}, 0x9502, true},              // C.JALR
+		{&instruction{as: AEBREAK}, 0x9002, true},                                     // C.EBREAK
+
+		{&instruction{as: AADDI, rd: REG_A0, rs1: REG_A1, imm: 100}, 0, false},
+		{&instruction{as: ASUB, rd: REG_A0, rs1: REG_A1, rs2: REG_A0}, 0, false},
+		{&instruction{as: ASRLI, rd: REG_X16, rs1: REG_X16, imm: 3}, 0, false},
+		{&instruction{as: ALD, rd: REG_A0, rs1: REG_X16}, 0, false},
+		{&instruction{as: ALD, rd: REG_A0, rs1: REG_A1, imm: 4}, 0, false},
+		{&instruction{as: ABEQ, rs1: REG_ZERO, rs2: REG_A0, imm: 256}, 0, false},
+		{&instruction{as: ABLT, rs1: REG_ZERO, rs2: REG_A0}, 0, false},
+		{&instruction{as: AJAL, rd: REG_RA, p: jmp}, 0, false},
+		{&instruction{as: AJALR, rd: REG_ZERO, rs1: REG_RA, imm: 8}, 0, false},
+	}
+	for _, test := range tests {
+		got, ok := encodeCompressed(test.ins)
+		if ok != test.ok || got != test.want {
+			t.Errorf("encodeCompressed(%v) = %#04x, %v; want %#04x, %v", test.ins.as, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+// TestCompressedMinLC checks that enabling compressed encodings
+// changes the PC quantum of the context only, not of the shared
+// architecture.
+func TestCompressedMinLC(t *testing.T) {
+	defer func(bc, c bool) { buildcfg.GORISCV64.Compressed, compress = bc, c }(buildcfg.GORISCV64.Compressed, compress)
+	buildcfg.GORISCV64.Compressed = true
+
+	ctxt := obj.Linknew(&LinkRISCV64)
+	buildop(ctxt)
+	if got := ctxt.Arch.MinLC; got != 2 {
+		t.Errorf("ctxt.Arch.MinLC = %d, want 2", got)
+	}
+	if got := LinkRISCV64.MinLC; got != 4 {
+		t.Errorf("LinkRISCV64.MinLC = %d, want 4", got)
+	}
+	if got := sys.ArchRISCV64.MinLC; got != 4 {
+		t.Errorf("sys.ArchRISCV64.MinLC = %d, want 4", got)
+	}
+}
+
+// TestCompressedFunction assembles a function with compressed encodings
+// enabled, and checks that branch relaxation expands the branches whose
+// targets are out of range of the compressed forms, that NO_COMPRESS
+// instructions keep their full size and that PCALIGN pads to the
+// requested alignment.
+func TestCompressedFunction(t *testing.T) {
+	defer func(c bool) { compress = c }(compress)
+	compress = true
+
+	ctxt := obj.Linknew(&LinkRISCV64)
+	ctxt.DiagFunc = t.Errorf
+	s := ctxt.Lookup("p.f")
+
+	var progs []*obj.Prog
+	add := func(as obj.As, from obj.Addr, reg int16, to obj.Addr) *obj.Prog {
+		p := ctxt.NewProg()
+		p.As, p.From, p.Reg, p.To = as, from, reg, to
+		p.Ctxt = ctxt
+		if len(progs) > 0 {
+			progs[len(progs)-1].Link = p
+		}
+		progs = append(progs, p)
+		return p
+	}
+	none := obj.Addr{}
+	reg := func(r int16) obj.Addr { return obj.Addr{Type: obj.TYPE_REG, Reg: r} }
+	imm := func(v int64) obj.Addr { return obj.Addr{Type: obj.TYPE_CONST, Offset: v} }
+	branch := obj.Addr{Type: obj.TYPE_BRANCH}
+
+	text := add(obj.ATEXT, obj.Addr{Type: obj.TYPE_MEM, Name: obj.NAME_EXTERN, Sym: s}, 0, obj.Addr{Type: obj.TYPE_TEXTSIZE, Val: int32(0)})
+	far := add(ABEQ, reg(REG_X10), REG_ZERO, branch)  // more than 256 bytes ahead
+	near := add(ABEQ, reg(REG_X10), REG_ZERO, branch) // a few bytes ahead
+	jfar := add(AJAL, reg(REG_ZERO), 0, branch)       // more than 2KiB ahead
+	full := add(AADDI, imm(1), REG_X10, reg(REG_X10)) // compressible, but marked NO_COMPRESS
+	full.Mark |= NO_COMPRESS
+	small := add(AADDI, imm(1), REG_X10, reg(REG_X10)) // C.ADDI
+	add(AADDI, imm(1), REG_X10, reg(REG_X10))
+	align := add(obj.APCALIGN, imm(8), 0, none)
+	aligned := add(AADDI, imm(1), REG_X10, reg(REG_X10))
+	near.To.SetTarget(aligned)
+	for i := 0; i < 200; i++ {
+		add(AADDI, imm(1), REG_X10, reg(REG_X10))
+	}
+	farTarget := add(AADDI, imm(1), REG_X10, reg(REG_X10))
+	far.To.SetTarget(farTarget)
+	for i := 0; i < 1024; i++ {
+		add(AADDI, imm(1), REG_X10, reg(REG_X10))
+	}
+	ret := add(obj.ARET, none, 0, none)
+	jfar.To.SetTarget(ret)
+
+	ctxt.InitTextSym(s, obj.NOSPLIT, src.NoXPos)
+	s.Func().Text = text
+	for _, p := range progs {
+		progedit(ctxt, p, ctxt.NewProg)
+	}
+	preprocess(ctxt, s, ctxt.NewProg)
+	assemble(ctxt, s, ctxt.NewProg)
+
+	// A 32-bit instruction has its two low bits set.
+	size := func(p *obj.Prog) int {
+		if s.P[p.Pc]&3 == 3 {
+			return 4
+		}
+		return 2
+	}
+	for _, test := range []struct {
+		name string
+		p    *obj.Prog
+		want int
+	}{
+		{"far branch", far, 4},
+		{"near branch", near, 2},
+		{"far jump", jfar, 4},
+		{"NO_COMPRESS", full, 4},
+		{"ADDI", small, 2},
+		{"aligned ADDI", aligned, 2},
+	} {
+		if got := size(test.p); got != test.want {
+			t.Errorf("%s: got %d byte encoding, want %d", test.name, got, test.want)
+		}
+	}
+	if aligned.Pc%8 != 0 || aligned.Pc-align.Pc != 6 {
+		t.Errorf("PCALIGN $8: got %d bytes of padding before PC %#x, want 6 before an aligned PC", aligned.Pc-align.Pc, aligned.Pc)
+	}
+	for pc := align.Pc; pc < aligned.Pc; {
+		switch {
+		case bytes.HasPrefix(s.P[pc:], []byte{0x13, 0, 0, 0}):
+			pc += 4
+		case bytes.HasPrefix(s.P[pc:], []byte{0x01, 0}):
+			pc += 2
+		default:
+			t.Fatalf("PCALIGN $8: padding at PC %#x is not a NOP: % x", pc, s.P[align.Pc:aligned.Pc])
+		}
+	}
+
+	// Check that the branches reach their targets.
+	le16 := func(pc int64) uint32 { return uint32(s.P[pc]) | uint32(s.P[pc+1])<<8 }
+	le32 := func(pc int64) uint32 { return le16(pc) | le16(pc+2)<<16 }
+	signExtend := func(v uint32, bits uint) int64 { return int64(int32(v<<(32-bits)) >> (32 - bits)) }
+	for _, test := range []struct {
+		name      string
+		p, target *obj.Prog
+		offset    int64
+	}{
+		{"far branch", far, farTarget, signExtend(le32(far.Pc)>>31<<12|(le32(far.Pc)>>25&0x3f)<<5|(le32(far.Pc)>>8&0xf)<<1|(le32(far.Pc)>>7&1)<<11, 13)},
+		{"near branch", near, aligned, signExtend(le16(near.Pc)>>12&1<<8|(le16(near.Pc)>>10&3)<<3|(le16(near.Pc)>>5&3)<<6|(le16(near.Pc)>>3&3)<<1|(le16(near.Pc)>>2&1)<<5, 9)},
+		{"far jump", jfar, ret, signExtend(le32(jfar.Pc)>>31<<20|(le32(jfar.Pc)>>21&0x3ff)<<1|(le32(jfar.Pc)>>20&1)<<11|(le32(jfar.Pc)>>12&0xff)<<12, 21)},
+	} {
+		if want := test.target.Pc - test.p.Pc; test.offset != want {
+			t.Errorf("%s: encoded offset %d, want %d", test.name, test.offset, want)
+		}
+	}
+}
@@ -278,6 +278,12 @@ const (
 	// it is the first instruction in an AUIPC + S-type pair that needs a
 	// R_RISCV_PCREL_STYPE relocation.
 	NEED_PCREL_STYPE_RELOC
+
+	// NO_COMPRESS is set on instructions that must not be assembled using
+	// compressed encodings, either because a branch target turned out to be
+	// out of range or because the instruction is part of a fixed size
+	// sequence that is patched after instruction addresses are assigned.
+	NO_COMPRESS
 )
 
 // RISC-V mnemonics, as defined in the "opcodes" and "opcodes-pseudo" files
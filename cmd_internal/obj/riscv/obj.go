@@ -25,12 +25,31 @@ import (
 	"compile/cmd_internal/objabi"
 	"compile/cmd_internal/sys"
 	"compile/src_internal/abi"
+	"compile/src_internal/buildcfg"
 	"fmt"
 	"log"
 	"math/bits"
 )
 
-func buildop(ctxt *obj.Link) {}
+// compress reports whether instructions should be assembled using
+// compressed (RVC) encodings where possible. It is set by buildop from
+// the GORISCV64 setting.
+var compress bool
+
+func buildop(ctxt *obj.Link) {
+	compress = buildcfg.GORISCV64.Compressed
+	if compress {
+		// Compressed instructions are only two bytes long, so the
+		// PC-value tables need to record deltas in units of two.
+		// Give ctxt its own copy of the architecture rather than
+		// changing the shared LinkRISCV64 and sys.ArchRISCV64.
+		arch := *ctxt.Arch.Arch
+		arch.MinLC = 2
+		link := *ctxt.Arch
+		link.Arch = &arch
+		ctxt.Arch = &link
+	}
+}
 
 func jalToSym(ctxt *obj.Link, p *obj.Prog, lr int16) {
 	switch p.As {
@@ -645,10 +664,16 @@ func preprocess(ctxt *obj.Link, cursym *obj.LSym, newprog obj.ProgAlloc) {
 					panic("assemble: instruction with branch-like opcode lacks destination")
 				}
 				offset := p.To.Target().Pc - p.Pc
+				if isCompressedBranch(p) && (offset < -256 || 256 <= offset) {
+					// Branch is too long for a compressed encoding.
+					p.Mark |= NO_COMPRESS
+					rescan = true
+				}
 				if offset < -4096 || 4096 <= offset {
 					// Branch is long.  Replace it with a jump.
 					jmp := obj.Appendp(p, newprog)
 					jmp.As = AJAL
+					jmp.Mark |= NO_COMPRESS
 					jmp.From = obj.Addr{Type: obj.TYPE_REG, Reg: REG_ZERO}
 					jmp.To = obj.Addr{Type: obj.TYPE_BRANCH}
 					jmp.To.SetTarget(p.To.Target())
@@ -670,6 +695,7 @@ func preprocess(ctxt *obj.Link, cursym *obj.LSym, newprog obj.ProgAlloc) {
 					// to reach trampolines. Replace with AUIPC+JALR.
 					jmp := obj.Appendp(p, newprog)
 					jmp.As = AJALR
+					jmp.Mark |= NO_COMPRESS
 					jmp.From = p.From
 					jmp.To = obj.Addr{Type: obj.TYPE_REG, Reg: REG_TMP}
 
@@ -684,12 +710,18 @@ func preprocess(ctxt *obj.Link, cursym *obj.LSym, newprog obj.ProgAlloc) {
 					break
 				}
 				offset := p.To.Target().Pc - p.Pc
+				if isCompressedBranch(p) && (offset < -2048 || 2048 <= offset) {
+					// Jump is too long for a compressed encoding.
+					p.Mark |= NO_COMPRESS
+					rescan = true
+				}
 				if offset < -(1<<20) || (1<<20) <= offset {
 					// Replace with 2-instruction sequence. This assumes
 					// that TMP is not live across J instructions, since
 					// it is reserved by SSA.
 					jmp := obj.Appendp(p, newprog)
 					jmp.As = AJALR
+					jmp.Mark |= NO_COMPRESS
 					jmp.From = p.From
 					jmp.To = obj.Addr{Type: obj.TYPE_REG, Reg: REG_TMP}
 
@@ -764,6 +796,13 @@ func pcAlignPadLength(pc int64, alignedValue int64) int {
 	return int(-pc & (alignedValue - 1))
 }
 
+// isCompressedBranch reports whether the branch or jump p will currently
+// be assembled using a compressed encoding.
+func isCompressedBranch(p *obj.Prog) bool {
+	inss := instructionsForProg(p)
+	return len(inss) == 1 && inss[0].compressed
+}
+
 func stacksplit(ctxt *obj.Link, p *obj.Prog, cursym *obj.LSym, newprog obj.ProgAlloc, framesize int64) *obj.Prog {
 	// Leaf function with no frame is effectively NOSPLIT.
 	if framesize == 0 {
@@ -1397,6 +1436,327 @@ func encodeCJImmediate(imm uint32) uint32 {
 	return bits << 2
 }
 
+// isCReg reports whether r is one of the integer registers X8-X15 or float
+// registers F8-F15, which are the only ones addressable from the three bit
+// register fields of CIW, CL, CS, CA and CB-type instructions.
+func isCReg(r uint32) bool {
+	return (REG_X8 <= r && r <= REG_X15) || (REG_F8 <= r && r <= REG_F15)
+}
+
+// isCIntReg reports whether r is an integer register usable with CIW, CL, CS,
+// CA and CB-type instructions.
+func isCIntReg(r uint32) bool {
+	return REG_X8 <= r && r <= REG_X15
+}
+
+// isIntReg reports whether r is an integer register.
+func isIntReg(r uint32) bool {
+	return REG_X0 <= r && r <= REG_X31
+}
+
+// isFloatReg reports whether r is a float register.
+func isFloatReg(r uint32) bool {
+	return REG_F0 <= r && r <= REG_F31
+}
+
+// regN returns the five bit encoding of an integer or float register.
+func regN(r uint32) uint32 {
+	if isFloatReg(r) {
+		return regF(r)
+	}
+	return regI(r)
+}
+
+// regC returns the three bit register encoding of r used by CIW, CL, CS, CA
+// and CB-type instructions.
+func regC(r uint32) uint32 {
+	if isFloatReg(r) {
+		return regVal(r, REG_F8, REG_F15)
+	}
+	return regVal(r, REG_X8, REG_X15)
+}
+
+// encodeCR encodes a CR-type RISC-V instruction.
+func encodeCR(funct4, rd, rs2, op uint32) uint32 {
+	return funct4<<12 | regI(rd)<<7 | regI(rs2)<<2 | op
+}
+
+// encodeCI encodes a CI-type RISC-V instruction with a six bit signed
+// immediate, as used by C.ADDI, C.ADDIW, C.LI, C.LUI and C.SLLI.
+func encodeCI(funct3, rd uint32, imm int64, op uint32) uint32 {
+	bits := uint32(imm)
+	return funct3<<13 | ((bits>>5)&1)<<12 | regI(rd)<<7 | (bits&0x1f)<<2 | op
+}
+
+// encodeCSS encodes a CSS-type RISC-V instruction, with the immediate
+// already in its encoded bit order.
+func encodeCSS(funct3, bits, rs2, op uint32) uint32 {
+	return funct3<<13 | bits<<7 | rs2<<2 | op
+}
+
+// encodeCLS encodes a CL or CS-type RISC-V instruction, with the immediate
+// already split into its three high and two low encoded bits.
+func encodeCLS(funct3, hi, rs1, lo, rds2, op uint32) uint32 {
+	return funct3<<13 | hi<<10 | regC(rs1)<<7 | lo<<5 | regC(rds2)<<2 | op
+}
+
+// encodeCA encodes a CA-type RISC-V instruction.
+func encodeCA(funct6, rd, funct2, rs2, op uint32) uint32 {
+	return funct6<<10 | regC(rd)<<7 | funct2<<5 | regC(rs2)<<2 | op
+}
+
+// encodeCBALU encodes the CB-type shift and AND immediate instructions.
+func encodeCBALU(funct2, rd uint32, imm int64) uint32 {
+	bits := uint32(imm)
+	return 0b100<<13 | ((bits>>5)&1)<<12 | funct2<<10 | regC(rd)<<7 | (bits&0x1f)<<2 | 0b01
+}
+
+// immCFits reports whether the immediate value x fits in nbits bits as a
+// signed integer.
+func immCFits(x int64, nbits uint) bool {
+	return immIFits(x, nbits) == nil
+}
+
+// immCScaled reports whether x is a non-negative multiple of scale that is
+// less than limit, as required by the scaled unsigned offsets of compressed
+// loads and stores.
+func immCScaled(x, scale, limit int64) bool {
+	return x >= 0 && x < limit && x%scale == 0
+}
+
+// encodeCompressed returns the 16-bit compressed (RVC) encoding for ins, if
+// one exists. Branch and jump offsets are zero until instruction addresses
+// have been assigned - the branch relaxation in preprocess marks those that
+// turn out to be out of range with NO_COMPRESS.
+func encodeCompressed(ins *instruction) (uint32, bool) {
+	rd, rs1, rs2, imm := ins.rd, ins.rs1, ins.rs2, ins.imm
+
+	switch ins.as {
+	case AADDI:
+		if !isIntReg(rd) || !isIntReg(rs1) {
+			return 0, false
+		}
+		switch {
+		case rd == REG_ZERO && rs1 == REG_ZERO && imm == 0:
+			// C.NOP
+			return 0x0001, true
+		case rd == REG_ZERO:
+			return 0, false
+		case rs1 == REG_ZERO && immCFits(imm, 6):
+			// C.LI
+			return encodeCI(0b010, rd, imm, 0b01), true
+		case rd == rs1 && imm != 0 && immCFits(imm, 6):
+			// C.ADDI
+			return encodeCI(0b000, rd, imm, 0b01), true
+		case rd == REG_SP && rs1 == REG_SP && imm != 0 && imm%16 == 0 && immCFits(imm, 10):
+			// C.ADDI16SP - nzimm[9|4|6|8:7|5]
+			bits := uint32(imm)
+			enc := ((bits>>9)&1)<<12 | ((bits>>4)&1)<<6 | ((bits>>6)&1)<<5 | ((bits>>7)&3)<<3 | ((bits>>5)&1)<<2
+			return 0b011<<13 | enc | regI(REG_SP)<<7 | 0b01, true
+		case rs1 == REG_SP && isCIntReg(rd) && imm != 0 && immCScaled(imm, 4, 1024):
+			// C.ADDI4SPN - nzuimm[5:4|9:6|2|3]
+			bits := uint32(imm)
+			enc := ((bits>>4)&3)<<6 | ((bits>>6)&0xf)<<2 | ((bits>>2)&1)<<1 | (bits>>3)&1
+			return enc<<5 | regC(rd)<<2 | 0b00, true
+		case rs1 != REG_ZERO && imm == 0:
+			// C.MV
+			return encodeCR(0b1000, rd, rs1, 0b10), true
+		}
+
+	case AADDIW:
+		if isIntReg(rd) && rd != REG_ZERO && rd == rs1 && immCFits(imm, 6) {
+			// C.ADDIW
+			return encodeCI(0b001, rd, imm, 0b01), true
+		}
+
+	case ALUI:
+		if isIntReg(rd) && rd != REG_ZERO && rd != REG_SP && imm != 0 && immCFits(imm, 6) {
+			// C.LUI
+			return encodeCI(0b011, rd, imm, 0b01), true
+		}
+
+	case ASLLI:
+		if isIntReg(rd) && rd != REG_ZERO && rd == rs1 && imm > 0 && imm < 64 {
+			// C.SLLI
+			return encodeCI(0b000, rd, imm, 0b10), true
+		}
+
+	case ASRLI, ASRAI:
+		if isCIntReg(rd) && rd == rs1 && imm > 0 && imm < 64 {
+			// C.SRLI, C.SRAI
+			funct2 := uint32(0b00)
+			if ins.as == ASRAI {
+				funct2 = 0b01
+			}
+			return encodeCBALU(funct2, rd, imm), true
+		}
+
+	case AANDI:
+		if isCIntReg(rd) && rd == rs1 && immCFits(imm, 6) {
+			// C.ANDI
+			return encodeCBALU(0b10, rd, imm), true
+		}
+
+	case AADD:
+		if !isIntReg(rd) || !isIntReg(rs1) || !isIntReg(rs2) || rd == REG_ZERO {
+			return 0, false
+		}
+		switch {
+		case rs1 == REG_ZERO && rs2 != REG_ZERO:
+			// C.MV
+			return encodeCR(0b1000, rd, rs2, 0b10), true
+		case rd == rs1 && rs2 != REG_ZERO:
+			// C.ADD
+			return encodeCR(0b1001, rd, rs2, 0b10), true
+		case rd == rs2 && rs1 != REG_ZERO:
+			// C.ADD, with the operands commuted.
+			return encodeCR(0b1001, rd, rs1, 0b10), true
+		}
+
+	case ASUB, AXOR, AOR, AAND, ASUBW, AADDW:
+		var funct6, funct2 uint32
+		commutative := true
+		switch ins.as {
+		case ASUB:
+			funct6, funct2, commutative = 0b100011, 0b00, false
+		case AXOR:
+			funct6, funct2 = 0b100011, 0b01
+		case AOR:
+			funct6, funct2 = 0b100011, 0b10
+		case AAND:
+			funct6, funct2 = 0b100011, 0b11
+		case ASUBW:
+			funct6, funct2, commutative = 0b100111, 0b00, false
+		case AADDW:
+			funct6, funct2 = 0b100111, 0b01
+		}
+		if !isCIntReg(rd) {
+			return 0, false
+		}
+		switch {
+		case rd == rs1 && isCIntReg(rs2):
+			return encodeCA(funct6, rd, funct2, rs2, 0b01), true
+		case commutative && rd == rs2 && isCIntReg(rs1):
+			return encodeCA(funct6, rd, funct2, rs1, 0b01), true
+		}
+
+	case ALW, ALD, AFLD:
+		// <load> imm(rs1), rd
+		if ins.as == AFLD && !isFloatReg(rd) || ins.as != AFLD && !isIntReg(rd) || !isIntReg(rs1) {
+			return 0, false
+		}
+		bits := uint32(imm)
+		if rs1 == REG_SP {
+			switch {
+			case ins.as == ALW && rd != REG_ZERO && immCScaled(imm, 4, 256):
+				// C.LWSP - uimm[5|4:2|7:6]
+				enc := ((bits>>5)&1)<<12 | ((bits>>2)&7)<<4 | ((bits>>6)&3)<<2
+				return 0b010<<13 | enc | regN(rd)<<7 | 0b10, true
+			case ins.as == ALD && rd != REG_ZERO && immCScaled(imm, 8, 512):
+				// C.LDSP - uimm[5|4:3|8:6]
+				enc := ((bits>>5)&1)<<12 | ((bits>>3)&3)<<5 | ((bits>>6)&7)<<2
+				return 0b011<<13 | enc | regN(rd)<<7 | 0b10, true
+			case ins.as == AFLD && immCScaled(imm, 8, 512):
+				// C.FLDSP - uimm[5|4:3|8:6]
+				enc := ((bits>>5)&1)<<12 | ((bits>>3)&3)<<5 | ((bits>>6)&7)<<2
+				return 0b001<<13 | enc | regN(rd)<<7 | 0b10, true
+			}
+		}
+		if !isCReg(rd) || !isCIntReg(rs1) {
+			return 0, false
+		}
+		switch {
+		case ins.as == ALW && immCScaled(imm, 4, 128):
+			// C.LW - uimm[5:3] and uimm[2|6]
+			return encodeCLS(0b010, (bits>>3)&7, rs1, ((bits>>2)&1)<<1|(bits>>6)&1, rd, 0b00), true
+		case ins.as == ALD && immCScaled(imm, 8, 256):
+			// C.LD - uimm[5:3] and uimm[7:6]
+			return encodeCLS(0b011, (bits>>3)&7, rs1, (bits>>6)&3, rd, 0b00), true
+		case ins.as == AFLD && immCScaled(imm, 8, 256):
+			// C.FLD - uimm[5:3] and uimm[7:6]
+			return encodeCLS(0b001, (bits>>3)&7, rs1, (bits>>6)&3, rd, 0b00), true
+		}
+
+	case ASW, ASD, AFSD:
+		// <store> rs1, imm(rd)
+		if ins.as == AFSD && !isFloatReg(rs1) || ins.as != AFSD && !isIntReg(rs1) || !isIntReg(rd) {
+			return 0, false
+		}
+		bits := uint32(imm)
+		if rd == REG_SP {
+			switch {
+			case ins.as == ASW && immCScaled(imm, 4, 256):
+				// C.SWSP - uimm[5:2|7:6]
+				return encodeCSS(0b110, ((bits>>2)&0xf)<<2|(bits>>6)&3, regN(rs1), 0b10), true
+			case ins.as == ASD && immCScaled(imm, 8, 512):
+				// C.SDSP - uimm[5:3|8:6]
+				return encodeCSS(0b111, ((bits>>3)&7)<<3|(bits>>6)&7, regN(rs1), 0b10), true
+			case ins.as == AFSD && immCScaled(imm, 8, 512):
+				// C.FSDSP - uimm[5:3|8:6]
+				return encodeCSS(0b101, ((bits>>3)&7)<<3|(bits>>6)&7, regN(rs1), 0b10), true
+			}
+		}
+		if !isCReg(rs1) || !isCIntReg(rd) {
+			return 0, false
+		}
+		switch {
+		case ins.as == ASW && immCScaled(imm, 4, 128):
+			// C.SW - uimm[5:3] and uimm[2|6]
+			return encodeCLS(0b110, (bits>>3)&7, rd, ((bits>>2)&1)<<1|(bits>>6)&1, rs1, 0b00), true
+		case ins.as == ASD && immCScaled(imm, 8, 256):
+			// C.SD - uimm[5:3] and uimm[7:6]
+			return encodeCLS(0b111, (bits>>3)&7, rd, (bits>>6)&3, rs1, 0b00), true
+		case ins.as == AFSD && immCScaled(imm, 8, 256):
+			// C.FSD - uimm[5:3] and uimm[7:6]
+			return encodeCLS(0b101, (bits>>3)&7, rd, (bits>>6)&3, rs1, 0b00), true
+		}
+
+	case ABEQ, ABNE:
+		// Note that the operands of B-type instructions are swapped,
+		// see encodeB. Equality is symmetric, so either may be zero.
+		reg := rs2
+		if rs2 == REG_ZERO {
+			reg = rs1
+		} else if rs1 != REG_ZERO {
+			return 0, false
+		}
+		if !isCIntReg(reg) || !immCFits(imm, 9) {
+			return 0, false
+		}
+		// C.BEQZ, C.BNEZ
+		funct3 := uint32(0b110)
+		if ins.as == ABNE {
+			funct3 = 0b111
+		}
+		return funct3<<13 | encodeCBImmediate(uint32(imm)) | regC(reg)<<7 | 0b01, true
+
+	case AJAL:
+		if rd == REG_ZERO && immCFits(imm, 12) && ins.p != nil && (ins.p.To.Type == obj.TYPE_BRANCH || ins.p.To.Type == obj.TYPE_CONST) {
+			// C.J
+			return 0b101<<13 | encodeCJImmediate(uint32(imm)) | 0b01, true
+		}
+
+	case AJALR:
+		if imm != 0 || !isIntReg(rs1) || rs1 == REG_ZERO {
+			return 0, false
+		}
+		switch rd {
+		case REG_ZERO:
+			// C.JR
+			return encodeCR(0b1000, rs1, REG_ZERO, 0b10), true
+		case REG_RA:
+			// C.JALR
+			return encodeCR(0b1001, rs1, REG_ZERO, 0b10), true
+		}
+
+	case AEBREAK:
+		// C.EBREAK
+		return 0x9002, true
+	}
+
+	return 0, false
+}
+
 func encodeRawIns(ins *instruction) uint32 {
 	// Treat the raw value specially as a 32-bit unsigned integer.
 	// Nobody wants to enter negative machine code.
@@ -1757,6 +2117,8 @@ type instruction struct {
 	imm    int64     // Immediate
 	funct3 uint32    // Function 3
 	funct7 uint32    // Function 7 (or Function 2)
+
+	compressed bool // Use the 16-bit compressed encoding
 }
 
 func (ins *instruction) String() string {
@@ -1778,6 +2140,12 @@ func (ins *instruction) encode() (uint32, error) {
 	if enc.length <= 0 {
 		return 0, fmt.Errorf("%v: encoding called for a pseudo instruction", ins.as)
 	}
+	if ins.compressed {
+		if ic, ok := encodeCompressed(ins); ok {
+			return ic, nil
+		}
+		return 0, fmt.Errorf("%v: no compressed encoding", ins)
+	}
 	return enc.encode(ins), nil
 }
 
@@ -1786,6 +2154,9 @@ func (ins *instruction) length() int {
 	if err != nil {
 		return 0
 	}
+	if ins.compressed {
+		return 2
+	}
 	return enc.length
 }
 
@@ -2373,6 +2744,16 @@ func instructionsForProg(p *obj.Prog) []*instruction {
 		ins.p = p
 	}
 
+	// Instructions that form part of a relocated sequence must retain
+	// their full size encodings, as must those that are patched once
+	// instruction addresses are known.
+	const noCompress = NO_COMPRESS | NEED_JAL_RELOC | NEED_CALL_RELOC | NEED_PCREL_ITYPE_RELOC | NEED_PCREL_STYPE_RELOC
+	if compress && p.Mark&noCompress == 0 {
+		for _, ins := range inss {
+			_, ins.compressed = encodeCompressed(ins)
+		}
+	}
+
 	return inss
 }
 
@@ -2456,6 +2837,10 @@ func assemble(ctxt *obj.Link, cursym *obj.LSym, newprog obj.ProgAlloc) {
 				cursym.WriteBytes(ctxt, offset, []byte{0x13, 0, 0, 0})
 				offset += 4
 			}
+			if v == 2 {
+				// C.NOP
+				cursym.WriteBytes(ctxt, offset, []byte{0x01, 0})
+			}
 			continue
 		}
 
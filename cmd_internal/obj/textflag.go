@@ -58,4 +58,9 @@ const (
 
 	// Function is a compiler-generated package init function.
 	PKGINIT = 8192
+
+	// Function may be the target of an indirect call or jump.
+	// When indirect branch tracking is enabled, the assembler
+	// emits a landing pad at its entry.
+	INDIRECT = 16384
 )
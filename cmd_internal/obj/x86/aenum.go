@@ -212,6 +212,7 @@ const (
 	ADPPD
 	ADPPS
 	AEMMS
+	AENDBR64
 	AENTER
 	AEXTRACTPS
 	AF2XM1
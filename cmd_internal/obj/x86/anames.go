@@ -210,6 +210,7 @@ var Anames = []string{
 	"DPPD",
 	"DPPS",
 	"EMMS",
+	"ENDBR64",
 	"ENTER",
 	"EXTRACTPS",
 	"F2XM1",
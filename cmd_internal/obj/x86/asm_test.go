@@ -5,9 +5,12 @@
 package x86
 
 import (
+	"bytes"
 	"compile/cmd_internal/obj"
 	"compile/cmd_internal/objabi"
+	"compile/cmd_internal/src"
 	"compile/src_internal/testenv"
+	"encoding/binary"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -340,3 +343,83 @@ func TestPCALIGN(t *testing.T) {
 		}
 	}
 }
+
+// TestENDBR64 checks that ENDBR64 is emitted at the entry of indirect
+// branch targets when indirect branch tracking is enabled.
+func TestENDBR64(t *testing.T) {
+	endbr64 := []byte{0xf3, 0x0f, 0x1e, 0xfa}
+	tests := []struct {
+		ibt      bool
+		flag     int
+		framesz  int64
+		wantPad  bool
+		wantSize int
+	}{
+		{ibt: false, flag: obj.INDIRECT, wantPad: false, wantSize: 1},
+		{ibt: true, flag: 0, wantPad: false, wantSize: 1},
+		{ibt: true, flag: obj.INDIRECT, wantPad: true, wantSize: 5},
+		{ibt: true, flag: obj.INDIRECT | obj.NOSPLIT, framesz: 16, wantPad: true},
+		{ibt: true, flag: obj.INDIRECT, framesz: 1 << 12, wantPad: true},
+	}
+	for _, test := range tests {
+		ctxt := obj.Linknew(&Linkamd64)
+		ctxt.IBT = test.ibt
+		ctxt.DiagFunc = t.Errorf
+
+		s := ctxt.Lookup("p.f")
+		text := ctxt.NewProg()
+		text.As = obj.ATEXT
+		text.From = obj.Addr{Type: obj.TYPE_MEM, Name: obj.NAME_EXTERN, Sym: s}
+		text.To = obj.Addr{Type: obj.TYPE_TEXTSIZE, Offset: test.framesz, Val: int32(0)}
+		ret := ctxt.NewProg()
+		ret.As = obj.ARET
+		text.Link = ret
+		ctxt.InitTextSym(s, test.flag, src.NoXPos)
+		s.Func().Text = text
+		preprocess(ctxt, s, ctxt.NewProg)
+		span6(ctxt, s, ctxt.NewProg)
+
+		if got := bytes.HasPrefix(s.P, endbr64); got != test.wantPad {
+			t.Errorf("ibt=%v flag=%#x framesize=%d: got ENDBR64 %v, want %v (code % x)", test.ibt, test.flag, test.framesz, got, test.wantPad, s.P)
+		}
+		if test.wantSize != 0 && len(s.P) != test.wantSize {
+			t.Errorf("ibt=%v flag=%#x framesize=%d: got %d bytes, want %d (code % x)", test.ibt, test.flag, test.framesz, len(s.P), test.wantSize, s.P)
+		}
+	}
+}
+
+// TestCFIHashes checks that the signature hashes are placed at the
+// offsets reported by CFIHashOffset.
+func TestCFIHashes(t *testing.T) {
+	hashes := []uint32{0x12345678, 0xdeadbeef, 0}
+	for _, ibt := range []bool{false, true} {
+		ctxt := obj.Linknew(&Linkamd64)
+		ctxt.IBT = ibt
+		ctxt.CFI = true
+		ctxt.DiagFunc = t.Errorf
+
+		s := ctxt.Lookup("p.f")
+		text := ctxt.NewProg()
+		text.As = obj.ATEXT
+		text.From = obj.Addr{Type: obj.TYPE_MEM, Name: obj.NAME_EXTERN, Sym: s}
+		text.To = obj.Addr{Type: obj.TYPE_TEXTSIZE, Val: int32(0)}
+		ret := ctxt.NewProg()
+		ret.As = obj.ARET
+		text.Link = ret
+		ctxt.InitTextSym(s, obj.INDIRECT, src.NoXPos)
+		s.Func().Text = text
+		s.Func().CFIHashes = hashes
+		preprocess(ctxt, s, ctxt.NewProg)
+		span6(ctxt, s, ctxt.NewProg)
+
+		for i, want := range hashes {
+			off := CFIHashOffset(ctxt, i)
+			if off+4 > int64(len(s.P)) {
+				t.Fatalf("ibt=%v: hash %d at offset %d beyond end of code (% x)", ibt, i, off, s.P)
+			}
+			if got := binary.LittleEndian.Uint32(s.P[off:]); got != want {
+				t.Errorf("ibt=%v: hash %d at offset %d is %#x, want %#x (code % x)", ibt, i, off, got, want, s.P)
+			}
+		}
+	}
+}
@@ -675,11 +675,35 @@ func preprocess(ctxt *obj.Link, cursym *obj.LSym, newprog obj.ProgAlloc) {
 		regEntryTmp0, regEntryTmp1 = REG_BX, REG_DI
 	}
 
+	if ctxt.IBT && ctxt.Arch.Family == sys.AMD64 && cursym.Indirect() {
+		// With indirect branch tracking, an indirect call or jump
+		// must land on an ENDBR64. It has to be the very first
+		// instruction, ahead of the stack split check; the retry
+		// jump from morestack goes directly past it.
+		p = obj.Appendp(p, newprog)
+		p.As = AENDBR64
+	}
+
+	if ctxt.CFI && ctxt.Arch.Family == sys.AMD64 && cursym.Indirect() {
+		// Place the signature hashes where the checks at indirect
+		// call sites expect them (see CFIHashOffset). Each is the
+		// immediate of a MOVL to a scratch register, so executing
+		// them is harmless.
+		for _, h := range cursym.Func().CFIHashes {
+			p = obj.Appendp(p, newprog)
+			p.As = AMOVL
+			p.From.Type = obj.TYPE_CONST
+			p.From.Offset = int64(int32(h))
+			p.To.Type = obj.TYPE_REG
+			p.To.Reg = regEntryTmp0
+		}
+	}
+
 	var regg int16
-	if !p.From.Sym.NoSplit() {
+	if !cursym.NoSplit() {
 		// Emit split check and load G register
 		p, regg = stacksplit(ctxt, cursym, p, newprog, autoffset, int32(textarg))
-	} else if p.From.Sym.Wrapper() {
+	} else if cursym.Wrapper() {
 		// Load G register for the wrapper code
 		p, regg = loadG(ctxt, cursym, p, newprog)
 	}
@@ -961,6 +985,16 @@ func preprocess(ctxt *obj.Link, cursym *obj.LSym, newprog obj.ProgAlloc) {
 	}
 }
 
+// CFIHashOffset returns the offset from a function's entry of the i'th
+// signature hash that preprocess places there when ctxt.CFI is set.
+func CFIHashOffset(ctxt *obj.Link, i int) int64 {
+	off := int64(2 + 6*i) // MOVL $hash, R12 encodes as 41 BC imm32
+	if ctxt.IBT {
+		off += 4 // ENDBR64
+	}
+	return off
+}
+
 func isZeroArgRuntimeCall(s *obj.LSym) bool {
 	if s == nil {
 		return false
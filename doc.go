@@ -96,6 +96,16 @@ Flags:
 		Disallow local (relative) imports.
 	-o file
 		Write object to file (default file.o or, with -pack, file.a).
+	-optdebug
+		Optimize, but keep the result easy to debug: user variables stay
+		in memory and hold their current value at every statement, basic
+		blocks stay in source order, and optimizations that move code
+		across statements or delete stores to variables are disabled.
+		Ignored with -N.
+	-optsize
+		Optimize for code size rather than speed: inline less, call
+		runtime helpers rather than expanding them inline, omit
+		alignment padding, and use jump tables only for dense switches.
 	-p path
 		Set expected package import path for the code being compiled,
 		and diagnose imports that would cause a circular dependency.
@@ -231,6 +241,22 @@ It specifies that calls to the function should not be inlined, overriding
 the compiler's usual optimization rules. This is typically only needed
 for special runtime functions or when debugging the compiler.
 
+	//go:inline
+
+The //go:inline directive must be followed by a function declaration.
+It specifies that calls to the function must be inlined regardless of
+the inlining budget, including calls from other packages. It is an
+error if the function cannot be inlined at all, for instance because it
+contains a defer or recover; the error gives the reason. Calls are still
+not inlined when inlining is disabled with -l.
+
+	//go:cold
+
+The //go:cold directive must be followed by a function declaration.
+It specifies that the function is rarely called, as for error reporting.
+The function is never inlined, and branches leading to calls to it are
+laid out as unlikely.
+
 	//go:norace
 
 The //go:norace directive must be followed by a function declaration.
@@ -245,6 +271,21 @@ It specifies that the function must omit its usual stack overflow check.
 This is most commonly used by low-level runtime code invoked
 at times when it is unsafe for the calling goroutine to be preempted.
 
+	//go:tailcall
+
+The //go:tailcall directive must be followed by a function declaration.
+It specifies that calls in tail position in the function, those whose
+results are returned as is or that are followed only by a return, must
+not grow the stack. A call to the function itself becomes a jump back to
+its start, and a call to another function jumps to it in place of
+returning. It is an error if a call in tail position cannot be compiled
+this way, for instance because the function has defers, takes the address
+of a local variable, calls a function whose arguments do not all fit
+in registers, or calls a func value or interface method. Calls in tail
+position in the function are not inlined. Without the directive,
+self-recursive calls in tail position are still turned into loops when
+optimizing; -m reports those that are not.
+
 	//go:linkname localname [importpath.name]
 
 The //go:linkname directive conventionally precedes the var or func
@@ -0,0 +1,135 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// The compile trace (-compiletrace) records how long each function
+// and each SSA pass takes to compile, in the Trace Event Format read
+// by chrome://tracing and Perfetto.
+//
+// The phases recorded by Timer appear on thread 0, and the functions
+// compiled by each backend worker on a thread of their own, numbered
+// from 1. Each event is a complete ("X") event.
+
+type traceEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	TS   float64                `json:"ts"`  // microseconds since the start of the trace
+	Dur  float64                `json:"dur"` // required in complete events, even if zero
+	PID  int                    `json:"pid"`
+	TID  int                    `json:"tid"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// A traceMetadata is a metadata ("M") event, which has no time.
+type traceMetadata struct {
+	Name string                 `json:"name"`
+	Ph   string                 `json:"ph"`
+	PID  int                    `json:"pid"`
+	TID  int                    `json:"tid"`
+	Args map[string]interface{} `json:"args"`
+}
+
+var compileTrace struct {
+	sync.Mutex
+	enabled bool
+	start   time.Time
+	events  []traceEvent
+}
+
+// StartCompileTrace starts recording the compile trace.
+func StartCompileTrace() {
+	compileTrace.enabled = true
+	compileTrace.start = time.Now()
+	if len(Timer.list) > 0 {
+		compileTrace.start = Timer.list[0].time
+	}
+}
+
+// CompileTraceEnabled reports whether the compile trace is being recorded.
+func CompileTraceEnabled() bool {
+	return compileTrace.enabled
+}
+
+func traceTime(t time.Time) float64 {
+	return float64(t.Sub(compileTrace.start).Nanoseconds()) / 1e3
+}
+
+// TraceSpan records a span from start to end in the compile trace,
+// on the thread of the given backend worker. It may be called
+// concurrently.
+func TraceSpan(worker int, cat, name string, start, end time.Time, args map[string]interface{}) {
+	if !compileTrace.enabled {
+		return
+	}
+	ev := traceEvent{
+		Name: name,
+		Cat:  cat,
+		Ph:   "X",
+		TS:   traceTime(start),
+		Dur:  traceTime(end) - traceTime(start),
+		TID:  worker + 1,
+		Args: args,
+	}
+	compileTrace.Lock()
+	compileTrace.events = append(compileTrace.events, ev)
+	compileTrace.Unlock()
+}
+
+// WriteCompileTrace writes the compile trace to w.
+func WriteCompileTrace(w io.Writer) error {
+	compileTrace.Lock()
+	defer compileTrace.Unlock()
+
+	events := []interface{}{threadName(0, "phases")}
+	workers := make(map[int]bool)
+	for _, ev := range compileTrace.events {
+		if !workers[ev.TID] {
+			workers[ev.TID] = true
+			events = append(events, threadName(ev.TID, fmt.Sprintf("worker %d", ev.TID-1)))
+		}
+	}
+
+	// Timer phases run from one timestamp to the next.
+	list := Timer.list
+	for i := 0; i+1 < len(list); i++ {
+		if list[i].label == "" {
+			continue
+		}
+		events = append(events, traceEvent{
+			Name: list[i].label,
+			Cat:  "phase",
+			Ph:   "X",
+			TS:   traceTime(list[i].time),
+			Dur:  traceTime(list[i+1].time) - traceTime(list[i].time),
+		})
+	}
+	for _, ev := range compileTrace.events {
+		events = append(events, ev)
+	}
+
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []interface{} `json:"traceEvents"`
+		DisplayTimeUnit string        `json:"displayTimeUnit"`
+	}{events, "ms"})
+}
+
+// threadName returns the metadata event naming thread tid.
+func threadName(tid int, name string) traceMetadata {
+	return traceMetadata{
+		Name: "thread_name",
+		Ph:   "M",
+		TID:  tid,
+		Args: map[string]interface{}{"name": name},
+	}
+}
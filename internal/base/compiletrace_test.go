@@ -0,0 +1,93 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCompileTrace(t *testing.T) {
+	StartCompileTrace()
+	defer func() { compileTrace.enabled = false }()
+
+	start := compileTrace.start.Add(10 * time.Microsecond)
+	TraceSpan(1, "func", "f", start, start.Add(5*time.Microsecond), map[string]interface{}{"irNodes": 3})
+
+	var buf bytes.Buffer
+	if err := WriteCompileTrace(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []traceEvent
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatal(err)
+	}
+
+	var names, spans int
+	for _, ev := range trace.TraceEvents {
+		switch ev.Ph {
+		case "M":
+			names++
+		case "X":
+			if ev.Name != "f" {
+				continue
+			}
+			spans++
+			if ev.TID != 2 || ev.TS != 10 || ev.Dur != 5 || ev.Args["irNodes"] != 3.0 {
+				t.Errorf("got span %+v, want tid 2, ts 10, dur 5 and 3 irNodes", ev)
+			}
+		}
+	}
+	if names != 2 || spans != 1 {
+		t.Errorf("got %d thread names and %d spans of f, want 2 and 1", names, spans)
+	}
+}
+
+func TestCompileTraceZeroDuration(t *testing.T) {
+	StartCompileTrace()
+	defer func() { compileTrace.enabled = false }()
+
+	start := compileTrace.start.Add(10 * time.Microsecond)
+	TraceSpan(0, "func", "empty", start, start, nil)
+
+	var buf bytes.Buffer
+	if err := WriteCompileTrace(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []map[string]interface{}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatal(err)
+	}
+	var spans int
+	for _, ev := range trace.TraceEvents {
+		dur, ok := ev["dur"]
+		switch ev["ph"] {
+		case "M":
+			if ok {
+				t.Errorf("metadata event %v has a duration", ev)
+			}
+		case "X":
+			// Trace viewers reject complete events without a duration.
+			if !ok {
+				t.Errorf("complete event %v has no duration", ev)
+			}
+			if ev["name"] == "empty" {
+				spans++
+				if dur != 0.0 {
+					t.Errorf("span of empty has duration %v, want 0", dur)
+				}
+			}
+		}
+	}
+	if spans != 1 {
+		t.Errorf("got %d spans of empty, want 1", spans)
+	}
+}
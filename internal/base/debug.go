@@ -30,6 +30,8 @@ type DebugFlags struct {
 	EscapeMutationsCalls  int    `help:"print extra escape analysis diagnostics about mutations and calls" concurrent:"ok"`
 	Export                int    `help:"print export data"`
 	Fmahash               string `help:"hash value for use in debugging platform-dependent multiply-add use" concurrent:"ok"`
+	FrameLayout           int    `help:"print stack frame layout of each function\n1: as text\n2: as JSON"`
+	FrameSizeWarn         int    `help:"warn about stack frames larger than this many bytes"`
 	GCAdjust              int    `help:"log adjustments to GOGC" concurrent:"ok"`
 	GCCheck               int    `help:"check heap/gc use by compiler" concurrent:"ok"`
 	GCProg                int    `help:"print dump of GC programs"`
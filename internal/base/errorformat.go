@@ -0,0 +1,341 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"compile/cmd_internal/src"
+)
+
+// Structured error output (-errorformat=json and -errorformat=sarif).
+//
+// In json mode, each diagnostic is printed as a single line holding a
+// JSON object, as soon as the errors are flushed. In sarif mode, the
+// diagnostics are collected and printed as a single SARIF 2.1.0 log
+// when the compiler exits.
+
+// ErrorDetails holds additional information about an error, beyond
+// its position and message.
+type ErrorDetails struct {
+	End     src.XPos // end of the offending source range, if known
+	Related []RelatedInfo
+	Fixes   []SuggestedFix
+}
+
+// RelatedInfo describes a source position related to an error.
+type RelatedInfo struct {
+	Pos src.XPos
+	Msg string
+}
+
+// A SuggestedFix is a source change that resolves an error.
+type SuggestedFix struct {
+	Msg   string
+	Edits []TextEdit
+}
+
+// A TextEdit replaces the source text from Pos up to (but not
+// including) End with New.
+type TextEdit struct {
+	Pos, End src.XPos
+	New      string
+}
+
+func validErrorFormat(format string) bool {
+	switch format {
+	case "text", "json", "sarif":
+		return true
+	}
+	return false
+}
+
+type jsonPos struct {
+	File string `json:"file,omitempty"`
+	Line uint   `json:"line,omitempty"`
+	Col  uint   `json:"col,omitempty"`
+}
+
+type jsonError struct {
+	jsonPos
+	EndLine  uint          `json:"endLine,omitempty"`
+	EndCol   uint          `json:"endCol,omitempty"`
+	Severity string        `json:"severity"`
+	Code     int           `json:"code,omitempty"`
+	CodeName string        `json:"codeName,omitempty"`
+	Message  string        `json:"message"`
+	Related  []jsonRelated `json:"related,omitempty"`
+	Fixes    []jsonFix     `json:"fixes,omitempty"`
+}
+
+type jsonRelated struct {
+	jsonPos
+	Message string `json:"message"`
+}
+
+type jsonFix struct {
+	Message string     `json:"message"`
+	Edits   []jsonEdit `json:"edits"`
+}
+
+type jsonEdit struct {
+	jsonPos
+	EndLine uint   `json:"endLine"`
+	EndCol  uint   `json:"endCol"`
+	New     string `json:"new"`
+}
+
+// relPos returns pos as it is reported in error messages,
+// that is, relative to any line directives.
+func relPos(pos src.XPos) jsonPos {
+	if !pos.IsKnown() {
+		return jsonPos{}
+	}
+	p := Ctxt.OutermostPos(pos)
+	return jsonPos{p.RelFilename(), p.RelLine(), p.RelCol()}
+}
+
+// filePos returns pos as a position in its file, ignoring line
+// directives. Edits are given this way, as they apply to the
+// file's contents.
+func filePos(pos src.XPos) jsonPos {
+	p := Ctxt.PosTable.Pos(pos)
+	return jsonPos{p.Filename(), p.Line(), p.Col()}
+}
+
+// fixes returns the fixes of details, omitting any whose edits
+// lack a position.
+func (d *ErrorDetails) fixes() []jsonFix {
+	var fixes []jsonFix
+fixLoop:
+	for _, fix := range d.Fixes {
+		f := jsonFix{Message: fix.Msg}
+		for _, e := range fix.Edits {
+			if !e.Pos.IsKnown() || !e.End.IsKnown() {
+				continue fixLoop
+			}
+			end := filePos(e.End)
+			f.Edits = append(f.Edits, jsonEdit{filePos(e.Pos), end.Line, end.Col, e.New})
+		}
+		fixes = append(fixes, f)
+	}
+	return fixes
+}
+
+func (err *errorMsg) json() *jsonError {
+	j := &jsonError{
+		jsonPos:  relPos(err.pos),
+		Severity: "error",
+		Message:  err.text,
+	}
+	if err.warning {
+		j.Severity = "warning"
+	}
+	if err.code != 0 {
+		j.Code = int(err.code)
+		j.CodeName = err.code.String()
+	}
+	if d := err.details; d != nil {
+		if end := relPos(d.End); end.File == j.File {
+			j.EndLine, j.EndCol = end.Line, end.Col
+		}
+		if len(d.Related) > 0 {
+			// The related information is also part of the text
+			// message, on lines of its own.
+			j.Message, _, _ = strings.Cut(j.Message, "\n")
+		}
+		for _, r := range d.Related {
+			j.Related = append(j.Related, jsonRelated{relPos(r.Pos), r.Msg})
+		}
+		j.Fixes = d.fixes()
+	}
+	return j
+}
+
+func printJSONError(err *errorMsg) {
+	b, e := json.Marshal(err.json())
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "compile: marshaling error: %v\n", e)
+		return
+	}
+	fmt.Printf("%s\n", b)
+}
+
+// SARIF 2.1.0 log, as far as it is used here.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID      string `json:"id"`
+	HelpURI string `json:"helpUri"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   uint `json:"startLine"`
+	StartColumn uint `json:"startColumn,omitempty"`
+	EndLine     uint `json:"endLine,omitempty"`
+	EndColumn   uint `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+var sarif struct {
+	results []sarifResult
+	rules   map[string]bool
+	written bool
+}
+
+func sarifLocationOf(pos jsonPos, endLine, endCol uint) sarifPhysicalLocation {
+	return sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{pos.File},
+		Region:           sarifRegion{pos.Line, pos.Col, endLine, endCol},
+	}
+}
+
+func addSARIFResult(err *errorMsg) {
+	j := err.json()
+	r := sarifResult{
+		RuleID:  j.CodeName,
+		Level:   j.Severity,
+		Message: sarifMessage{j.Message},
+	}
+	if j.Line != 0 {
+		r.Locations = []sarifLocation{{PhysicalLocation: sarifLocationOf(j.jsonPos, j.EndLine, j.EndCol)}}
+	}
+	for i, rel := range j.Related {
+		if rel.Line == 0 {
+			continue
+		}
+		r.RelatedLocations = append(r.RelatedLocations, sarifLocation{
+			ID:               i + 1,
+			PhysicalLocation: sarifLocationOf(rel.jsonPos, 0, 0),
+			Message:          &sarifMessage{rel.Message},
+		})
+	}
+	for _, fix := range j.Fixes {
+		f := sarifFix{Description: sarifMessage{fix.Message}}
+		for _, e := range fix.Edits {
+			// Group edits by file, keeping them in order.
+			if n := len(f.ArtifactChanges); n == 0 || f.ArtifactChanges[n-1].ArtifactLocation.URI != e.File {
+				f.ArtifactChanges = append(f.ArtifactChanges, sarifArtifactChange{ArtifactLocation: sarifArtifactLocation{e.File}})
+			}
+			c := &f.ArtifactChanges[len(f.ArtifactChanges)-1]
+			c.Replacements = append(c.Replacements, sarifReplacement{
+				DeletedRegion:   sarifRegion{e.Line, e.Col, e.EndLine, e.EndCol},
+				InsertedContent: sarifMessage{e.New},
+			})
+		}
+		r.Fixes = append(r.Fixes, f)
+	}
+	if r.RuleID != "" {
+		if sarif.rules == nil {
+			sarif.rules = make(map[string]bool)
+		}
+		sarif.rules[r.RuleID] = true
+	}
+	sarif.results = append(sarif.results, r)
+}
+
+// writeSARIF prints the SARIF log of all errors reported so far,
+// if -errorformat=sarif is set. It does nothing if called again.
+func writeSARIF() {
+	if Flag.ErrorFormat != "sarif" || sarif.written {
+		return
+	}
+	sarif.written = true
+
+	driver := sarifDriver{
+		Name:           "compile",
+		InformationURI: "https://pkg.go.dev/cmd/compile",
+	}
+	var ids []string
+	for id := range sarif.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		driver.Rules = append(driver.Rules, sarifRule{id, "https://pkg.go.dev/internal/types/errors#" + id})
+	}
+	results := sarif.results
+	if results == nil {
+		results = []sarifResult{} // "results": [] means there were no errors
+	}
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{{Tool: sarifTool{driver}, Results: results}},
+	}
+	b, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "compile: marshaling SARIF log: %v\n", err)
+		return
+	}
+	fmt.Printf("%s\n", b)
+}
@@ -0,0 +1,168 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package base
+
+import (
+	"compile/cmd_internal/obj"
+	"compile/cmd_internal/obj/x86"
+	"compile/cmd_internal/src"
+	"compile/src_internal/types/errors"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+)
+
+// testError returns an error with related information and suggested
+// fixes, reported at a position moved by a line directive. It sets
+// Ctxt, which the caller must restore.
+func testError() errorMsg {
+	Ctxt = obj.Linknew(&x86.Linkamd64)
+
+	fileBase := src.NewFileBase("a.go", "/src/a.go")
+	pos := func(line, col uint) src.XPos {
+		return Ctxt.PosTable.XPos(src.MakePos(fileBase, line, col))
+	}
+	// a line directive moves the reported position, but not the edits
+	lineBase := src.NewLinePragmaBase(src.MakePos(fileBase, 10, 1), "b.go", "/src/b.go", 100, 1)
+	at := Ctxt.PosTable.XPos(src.MakePos(lineBase, 10, 2))
+
+	return errorMsg{
+		pos:  at,
+		text: "x redeclared in this block\n\tother declaration of x",
+		code: errors.DuplicateDecl,
+		details: &ErrorDetails{
+			End:     Ctxt.PosTable.XPos(src.MakePos(lineBase, 10, 3)),
+			Related: []RelatedInfo{{pos(3, 2), "other declaration of x"}},
+			Fixes: []SuggestedFix{
+				{"rename x", []TextEdit{{at, Ctxt.PosTable.XPos(src.MakePos(lineBase, 10, 3)), "y"}}},
+				{"unknown position", []TextEdit{{src.NoXPos, src.NoXPos, ""}}},
+			},
+		},
+	}
+}
+
+func TestJSONError(t *testing.T) {
+	defer func(ctxt *obj.Link) { Ctxt = ctxt }(Ctxt)
+	err := testError()
+	got, e := json.Marshal(err.json())
+	if e != nil {
+		t.Fatal(e)
+	}
+	const want = `{"file":"b.go","line":100,"col":2,"endLine":100,"endCol":3,` +
+		`"severity":"error","code":10,"codeName":"DuplicateDecl","message":"x redeclared in this block",` +
+		`"related":[{"file":"a.go","line":3,"col":2,"message":"other declaration of x"}],` +
+		`"fixes":[{"message":"rename x","edits":[{"file":"a.go","line":10,"col":2,"endLine":10,"endCol":3,"new":"y"}]}]}`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestSARIFLog(t *testing.T) {
+	defer func(ctxt *obj.Link) { Ctxt = ctxt }(Ctxt)
+	defer func(format string) { Flag.ErrorFormat = format }(Flag.ErrorFormat)
+	defer func(saved []sarifResult, rules map[string]bool, written bool) {
+		sarif.results, sarif.rules, sarif.written = saved, rules, written
+	}(sarif.results, sarif.rules, sarif.written)
+	Flag.ErrorFormat = "sarif"
+	sarif.results, sarif.rules, sarif.written = nil, nil, false
+
+	err := testError()
+	addSARIFResult(&err)
+	warning := errorMsg{pos: err.pos, text: "unused variable", warning: true}
+	addSARIFResult(&warning)
+
+	// Capture the log, which is written to standard output.
+	r, w, e := os.Pipe()
+	if e != nil {
+		t.Fatal(e)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	writeSARIF()
+	os.Stdout = stdout
+	w.Close()
+	out, e := io.ReadAll(r)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	type region struct {
+		StartLine, StartColumn, EndLine, EndColumn uint
+	}
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct{ URI string }
+			Region           region
+		}
+		Message *struct{ Text string }
+	}
+	var log struct {
+		Version string
+		Schema  string `json:"$schema"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID           string
+				Level            string
+				Message          struct{ Text string }
+				Locations        []location
+				RelatedLocations []location
+			}
+		}
+	}
+	if e := json.Unmarshal(out, &log); e != nil {
+		t.Fatalf("decoding SARIF log: %v\n%s", e, out)
+	}
+	if log.Version != "2.1.0" || log.Schema != "https://json.schemastore.org/sarif-2.1.0.json" {
+		t.Errorf("version %q, schema %q; want 2.1.0 and the SARIF 2.1.0 schema", log.Version, log.Schema)
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("got %d runs, want 1\n%s", len(log.Runs), out)
+	}
+	run := log.Runs[0]
+	if d := run.Tool.Driver; d.Name != "compile" || len(d.Rules) != 1 || d.Rules[0].ID != "DuplicateDecl" {
+		t.Errorf("driver %+v, want compile with the single rule DuplicateDecl", d)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("got %d results, want 2\n%s", len(run.Results), out)
+	}
+
+	res := run.Results[0]
+	if res.RuleID != "DuplicateDecl" || res.Level != "error" || res.Message.Text != "x redeclared in this block" {
+		t.Errorf("result 0 is %q, %q, %q; want DuplicateDecl, error, x redeclared in this block", res.RuleID, res.Level, res.Message.Text)
+	}
+	if len(res.Locations) != 1 {
+		t.Fatalf("result 0 has %d locations, want 1", len(res.Locations))
+	}
+	loc := res.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "b.go" || loc.Region != (region{100, 2, 100, 3}) {
+		t.Errorf("result 0 at %s %+v, want b.go {100 2 100 3}", loc.ArtifactLocation.URI, loc.Region)
+	}
+	if len(res.RelatedLocations) != 1 {
+		t.Fatalf("result 0 has %d related locations, want 1", len(res.RelatedLocations))
+	}
+	rel := res.RelatedLocations[0]
+	if got, want := rel.PhysicalLocation.Region, (region{StartLine: 3, StartColumn: 2}); rel.PhysicalLocation.ArtifactLocation.URI != "a.go" || got != want || rel.Message == nil || rel.Message.Text != "other declaration of x" {
+		t.Errorf("related location %+v, want a.go %+v with message other declaration of x", rel, want)
+	}
+
+	res = run.Results[1]
+	if res.RuleID != "" || res.Level != "warning" {
+		t.Errorf("result 1 is %q, %q; want no rule, warning", res.RuleID, res.Level)
+	}
+	if len(res.Locations) != 1 {
+		t.Fatalf("result 1 has %d locations, want 1", len(res.Locations))
+	}
+	loc = res.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "b.go" || loc.Region != (region{StartLine: 100, StartColumn: 2}) {
+		t.Errorf("result 1 at %s %+v, want b.go {100 2 0 0}", loc.ArtifactLocation.URI, loc.Region)
+	}
+}
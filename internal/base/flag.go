@@ -89,6 +89,7 @@ type CmdFlags struct {
 	Bench              string       "help:\"append benchmark times to `file`\""
 	BlockProfile       string       "help:\"write block profile to `file`\""
 	BuildID            string       "help:\"record `id` as the build id in the export metadata\""
+	CFI                *bool        "help:\"check function signatures at indirect call sites (amd64 only)\"" // &Ctxt.CFI, set below
 	CPUProfile         string       "help:\"write cpu profile to `file`\""
 	Complete           bool         "help:\"compiling complete package (no C or assembly)\""
 	ClobberDead        bool         "help:\"clobber dead stack slots (for debugging)\""
@@ -101,9 +102,12 @@ type CmdFlags struct {
 	Env                func(string) "help:\"add `definition` of the form key=value to environment\""
 	GenDwarfInl        int          "help:\"generate DWARF inline info records\"" // 0=disabled, 1=funcs, 2=funcs+formals/locals
 	GoVersion          string       "help:\"required version of the runtime\""
+	IBT                *bool        "help:\"emit ENDBR64 landing pads for indirect branch tracking (amd64 only)\"" // &Ctxt.IBT, set below
 	ImportCfg          func(string) "help:\"read import configuration from `file`\""
 	InstallSuffix      string       "help:\"set pkg directory `suffix`\""
 	JSON               string       "help:\"version,file for JSON compiler/optimizer detail output\""
+	SARIF              string       "help:\"write SARIF log of compiler/optimizer details to `directory`\""
+	SizeReport         string       "help:\"write JSON report attributing code and data size of each function to `file`\""
 	Lang               string       "help:\"Go language version source code expects\""
 	LinkObj            string       "help:\"write linker-specific object to `file`\""
 	LinkShared         *bool        "help:\"generate code that will be linked against Go shared libraries\"" // &Ctxt.Flag_linkshared, set below
@@ -113,6 +117,8 @@ type CmdFlags struct {
 	MemProfileRate     int          "help:\"set runtime.MemProfileRate to `rate`\""
 	MutexProfile       string       "help:\"write mutex profile to `file`\""
 	NoLocalImports     bool         "help:\"reject local (relative) imports\""
+	OptDebug           bool         "help:\"optimize, but keep variables and stepping intact for debuggers\""
+	OptSize            bool         "help:\"optimize for code size rather than speed\""
 	CoverageCfg        func(string) "help:\"read coverage configuration from `file`\""
 	Pack               bool         "help:\"write to file.a instead of file.o\""
 	Race               bool         "help:\"enable race detector\""
@@ -122,10 +128,12 @@ type CmdFlags struct {
 	Std                bool         "help:\"compiling standard library\""
 	SymABIs            string       "help:\"read symbol ABIs from `file`\""
 	TraceProfile       string       "help:\"write an execution trace to `file`\""
+	CompileTrace       string       "help:\"write a Chrome trace of the time spent compiling each function to `file`\""
 	TrimPath           string       "help:\"remove `prefix` from recorded source file paths\""
 	WB                 bool         "help:\"enable write barrier\"" // TODO: remove
 	PgoProfile         string       "help:\"read profile from `file`\""
 	ErrorURL           bool         "help:\"print explanatory URL with error message if applicable\""
+	ErrorFormat        string       "help:\"print errors in `format` (text, json, sarif)\""
 
 	// Configuration derived from flags; not a flag itself.
 	Cfg struct {
@@ -170,10 +178,13 @@ func ParseFlags() {
 	Flag.Env = addEnv
 	Flag.GenDwarfInl = 2
 	Flag.ImportCfg = readImportCfg
+	Flag.CFI = &Ctxt.CFI
 	Flag.CoverageCfg = readCoverageCfg
+	Flag.IBT = &Ctxt.IBT
 	Flag.LinkShared = &Ctxt.Flag_linkshared
 	Flag.Shared = &Ctxt.Flag_shared
 	Flag.WB = true
+	Flag.ErrorFormat = "text"
 
 	Debug.ConcurrentOk = true
 	Debug.MaxShapeLen = 500
@@ -274,9 +285,29 @@ func ParseFlags() {
 		log.Fatalf("%s/%s does not support -shared", buildcfg.GOOS, buildcfg.GOARCH)
 	}
 	parseSpectre(Flag.Spectre) // left as string for RecordFlags
+	if Ctxt.IBT && buildcfg.GOARCH != "amd64" {
+		log.Fatalf("GOARCH=%s does not support -ibt", buildcfg.GOARCH)
+	}
+	if Ctxt.CFI && buildcfg.GOARCH != "amd64" {
+		log.Fatalf("GOARCH=%s does not support -cfi", buildcfg.GOARCH)
+	}
+	if !validErrorFormat(Flag.ErrorFormat) {
+		log.Fatalf("invalid -errorformat %q; must be text, json, or sarif", Flag.ErrorFormat)
+	}
+	if Flag.ErrorFormat == "sarif" {
+		AtExit(writeSARIF)
+	}
+	if Flag.OptDebug && Flag.OptSize {
+		log.Fatalf("cannot use -optdebug with -optsize")
+	}
+	if Flag.N != 0 {
+		// -N already keeps everything visible.
+		Flag.OptDebug = false
+	}
 
 	Ctxt.Flag_shared = Ctxt.Flag_dynlink || Ctxt.Flag_shared
 	Ctxt.Flag_optimize = Flag.N == 0
+	Ctxt.Flag_optsize = Flag.OptSize
 	Ctxt.Debugasm = int(Flag.S)
 	Ctxt.Flag_maymorestack = Debug.MayMoreStack
 	Ctxt.Flag_noRefName = Debug.NoRefName != 0
@@ -339,6 +370,7 @@ func ParseFlags() {
 		// It is not possible to build the runtime with no optimizations,
 		// because the compiler cannot eliminate enough write barriers.
 		Flag.N = 0
+		Flag.OptDebug = false
 		Ctxt.Flag_optimize = true
 
 		// Runtime can't use -d=checkptr, at least not yet.
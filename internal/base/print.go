@@ -18,9 +18,12 @@ import (
 
 // An errorMsg is a queued error message, waiting to be printed.
 type errorMsg struct {
-	pos  src.XPos
-	msg  string
-	code errors.Code
+	pos     src.XPos
+	msg     string // message as printed, including position
+	text    string // message without position
+	code    errors.Code
+	warning bool
+	details *ErrorDetails
 }
 
 // Pos is the current source position being processed,
@@ -45,17 +48,21 @@ func SyntaxErrors() int {
 }
 
 // addErrorMsg adds a new errorMsg (which may be a warning) to errorMsgs.
-func addErrorMsg(pos src.XPos, code errors.Code, format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
+func addErrorMsg(pos src.XPos, code errors.Code, warning bool, details *ErrorDetails, format string, args ...interface{}) {
+	text := fmt.Sprintf(format, args...)
+	msg := text
 	// Only add the position if know the position.
 	// See issue golang.org/issue/11361.
 	if pos.IsKnown() {
 		msg = fmt.Sprintf("%v: %s", FmtPos(pos), msg)
 	}
 	errorMsgs = append(errorMsgs, errorMsg{
-		pos:  pos,
-		msg:  msg + "\n",
-		code: code,
+		pos:     pos,
+		msg:     msg + "\n",
+		text:    text,
+		code:    code,
+		warning: warning,
+		details: details,
 	})
 }
 
@@ -86,7 +93,14 @@ func FlushErrors() {
 	sort.Stable(byPos(errorMsgs))
 	for i, err := range errorMsgs {
 		if i == 0 || err.msg != errorMsgs[i-1].msg {
-			fmt.Print(err.msg)
+			switch Flag.ErrorFormat {
+			case "json":
+				printJSONError(&err)
+			case "sarif":
+				addSARIFResult(&err)
+			default:
+				fmt.Print(err.msg)
+			}
 		}
 	}
 	errorMsgs = errorMsgs[:0]
@@ -114,6 +128,12 @@ func Errorf(format string, args ...interface{}) {
 
 // ErrorfAt reports a formatted error message at pos.
 func ErrorfAt(pos src.XPos, code errors.Code, format string, args ...interface{}) {
+	ErrorfAtDetails(pos, code, nil, format, args...)
+}
+
+// ErrorfAtDetails is like ErrorfAt, but also records details, if
+// non-nil, for the -errorformat=json and sarif output.
+func ErrorfAtDetails(pos src.XPos, code errors.Code, details *ErrorDetails, format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
 
 	if strings.HasPrefix(msg, "syntax error") {
@@ -136,13 +156,15 @@ func ErrorfAt(pos src.XPos, code errors.Code, format string, args ...interface{}
 		lasterror.msg = msg
 	}
 
-	addErrorMsg(pos, code, "%s", msg)
+	addErrorMsg(pos, code, false, details, "%s", msg)
 	numErrors++
 
 	hcrash()
 	if numErrors >= 10 && Flag.LowerE == 0 {
 		FlushErrors()
-		fmt.Printf("%v: too many errors\n", FmtPos(pos))
+		if Flag.ErrorFormat == "text" {
+			fmt.Printf("%v: too many errors\n", FmtPos(pos))
+		}
 		ErrorExit()
 	}
 }
@@ -156,7 +178,8 @@ func UpdateErrorDot(line string, name, expr string) {
 	}
 	e := &errorMsgs[len(errorMsgs)-1]
 	if strings.HasPrefix(e.msg, line) && e.msg == fmt.Sprintf("%v: undefined: %v\n", line, name) {
-		e.msg = fmt.Sprintf("%v: undefined: %v in %v\n", line, name, expr)
+		e.text = fmt.Sprintf("undefined: %v in %v", name, expr)
+		e.msg = fmt.Sprintf("%v: %s\n", line, e.text)
 	}
 }
 
@@ -173,7 +196,7 @@ func Warn(format string, args ...interface{}) {
 // so this should be used only when the user has opted in
 // to additional output by setting a particular flag.
 func WarnfAt(pos src.XPos, format string, args ...interface{}) {
-	addErrorMsg(pos, 0, format, args...)
+	addErrorMsg(pos, 0, true, nil, format, args...)
 	if Flag.LowerM != 0 {
 		FlushErrors()
 	}
@@ -268,6 +291,7 @@ func hcrash() {
 // It flushes any pending errors, removes the output file, and exits.
 func ErrorExit() {
 	FlushErrors()
+	writeSARIF()
 	if Flag.LowerO != "" {
 		os.Remove(Flag.LowerO)
 	}
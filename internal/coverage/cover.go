@@ -85,6 +85,19 @@ func Fixup() {
 		base.Fatalf("bad setting %q for covermode in coveragecfg:",
 			counterMode)
 	}
+	switch strategy := base.Flag.Cfg.CoverageInfo.Strategy; strategy {
+	case "", "normal":
+	case "branch":
+		// Branch and condition counters live in the same counter
+		// variables as block counters, one per unit.
+		if counterGran != "perblock" {
+			base.Fatalf("covergranularity %q in coveragecfg can't be used with strategy %q",
+				counterGran, strategy)
+		}
+	default:
+		base.Fatalf("bad setting %q for strategy in coveragecfg:",
+			strategy)
+	}
 	var cg coverage.CounterGranularity
 	switch counterGran {
 	case "perblock":
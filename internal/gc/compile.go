@@ -107,6 +107,43 @@ func prepareFunc(fn *ir.Func) {
 	ir.CurFunc = fn
 	walk.Walk(fn)
 	ir.CurFunc = nil // enforce no further uses of CurFunc
+
+	if base.Ctxt.TrackIndirect() {
+		markIndirectTargets(fn)
+	}
+}
+
+// markIndirectTargets marks each function whose address is taken
+// within fn as a possible indirect branch target. This has to happen
+// before any function is assembled, as the backend only creates the
+// function value symbols while compiling functions concurrently.
+func markIndirectTargets(fn *ir.Func) {
+	var do func(ir.Node) bool
+	do = func(n ir.Node) bool {
+		switch n.Op() {
+		case ir.OCALLFUNC:
+			n := n.(*ir.CallExpr)
+			if name, ok := n.Fun.(*ir.Name); ok && name.Class == ir.PFUNC {
+				// A direct call does not need a landing pad.
+				for _, x := range n.Init() {
+					do(x)
+				}
+				for _, x := range n.Args {
+					do(x)
+				}
+				return false
+			}
+		case ir.ONAME:
+			n := n.(*ir.Name)
+			if n.Class == ir.PFUNC {
+				n.Linksym().Set(obj.AttrIndirect, true)
+			}
+		}
+		return ir.DoChildren(n, do)
+	}
+	for _, n := range fn.Body {
+		do(n)
+	}
 }
 
 // compileFunctions compiles all functions in compilequeue.
@@ -174,6 +174,9 @@ func Main(archInit func(*ssagen.ArchInfo)) {
 	if base.Flag.JSON != "" { // parse version,destination from json logging optimization.
 		logopt.LogJsonOption(base.Flag.JSON)
 	}
+	if base.Flag.SARIF != "" {
+		logopt.LogSarifOption(base.Flag.SARIF)
+	}
 
 	ir.EscFmt = escape.Fmt
 	ir.IsIntrinsicCall = ssagen.IsIntrinsicCall
@@ -207,6 +210,14 @@ func Main(archInit func(*ssagen.ArchInfo)) {
 
 	dwarfgen.RecordPackageName()
 
+	if base.Ctxt.IBT {
+		// Other packages may take the address of the functions
+		// used as values in inline bodies or generic functions.
+		for _, sym := range typecheck.Target.FuncValues {
+			sym.LinksymABI(obj.ABIInternal).Set(obj.AttrIndirect, true)
+		}
+	}
+
 	// Prepare for backend processing.
 	ssagen.InitConfig()
 
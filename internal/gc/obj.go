@@ -42,6 +42,9 @@ const (
 )
 
 func dumpobj() {
+	if base.Flag.SizeReport != "" {
+		writeSizeReport(base.Flag.SizeReport)
+	}
 	if base.Flag.LinkObj == "" {
 		dumpobj1(base.Flag.LowerO, modeCompilerObj|modeLinkerObj)
 		return
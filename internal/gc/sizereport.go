@@ -0,0 +1,218 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"compile/cmd_internal/obj"
+	"compile/cmd_internal/objabi"
+	"compile/internal/base"
+)
+
+// A pkgSizes is the -sizereport output for a package.
+type pkgSizes struct {
+	Package  string           `json:"package"`
+	Optimize string           `json:"optimize"`           // "speed", or "size" with -optsize
+	Text     int64            `json:"text"`               // total code size
+	Data     int64            `json:"data"`               // total size of funcdata and pc tables
+	Types    int64            `json:"types"`              // total size of type descriptors and dictionaries
+	Rodata   int64            `json:"rodata"`             // total size of read-only data other than funcdata, including types, strings and static composite literals
+	Inlined  map[string]int64 `json:"inlined,omitempty"`  // code bytes from each inlined function, over all functions
+	Generics map[string]int64 `json:"generics,omitempty"` // code bytes of the instantiations of each generic function
+	Funcs    []funcSizes      `json:"funcs"`
+}
+
+// A funcSizes attributes the size of an emitted function.
+type funcSizes struct {
+	Name    string           `json:"name"`
+	Text    int64            `json:"text"`              // code size
+	Inlined map[string]int64 `json:"inlined,omitempty"` // code bytes from each inlined function, including from the functions inlined into it
+	Generic string           `json:"generic,omitempty"` // the generic function instantiated, if any
+	Shape   string           `json:"shape,omitempty"`   // the shape of the instantiation
+	Data    int64            `json:"data"`              // size of funcdata and pc tables
+	Types   int64            `json:"types"`             // size of the type descriptors and dictionaries it refers to
+	Rodata  int64            `json:"rodata"`            // size of the read-only data it refers to, including Types
+}
+
+// writeSizeReport writes the -sizereport output, attributing the
+// code and data of each function emitted into the object file.
+func writeSizeReport(file string) {
+	pkg := pkgSizes{
+		Package:  base.Ctxt.Pkgpath,
+		Optimize: "speed",
+		Inlined:  make(map[string]int64),
+		Generics: make(map[string]int64),
+		Funcs:    []funcSizes{},
+	}
+	if base.Flag.OptSize {
+		pkg.Optimize = "size"
+	}
+	funcData := make(map[*obj.LSym]bool)
+	for _, s := range base.Ctxt.Text {
+		if s.Func() == nil {
+			continue
+		}
+		for _, x := range funcDataSyms(s.Func()) {
+			funcData[x] = true
+		}
+		f := newFuncSizes(s)
+		pkg.Text += f.Text
+		pkg.Data += f.Data
+		for name, n := range f.Inlined {
+			pkg.Inlined[name] += n
+		}
+		if f.Generic != "" {
+			pkg.Generics[f.Generic] += f.Text
+		}
+		pkg.Funcs = append(pkg.Funcs, f)
+	}
+	for _, s := range base.Ctxt.Data {
+		if isTypeData(s) {
+			pkg.Types += s.Size
+		}
+		if s.Type == objabi.SRODATA && !funcData[s] {
+			pkg.Rodata += s.Size
+		}
+	}
+	sort.Slice(pkg.Funcs, func(i, j int) bool { return pkg.Funcs[i].Name < pkg.Funcs[j].Name })
+
+	w, err := os.Create(file)
+	if err != nil {
+		base.Fatalf("-sizereport: %v", err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(pkg); err != nil {
+		base.Fatalf("-sizereport: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		base.Fatalf("-sizereport: %v", err)
+	}
+}
+
+func newFuncSizes(s *obj.LSym) funcSizes {
+	fn := s.Func()
+	f := funcSizes{Name: s.Name, Text: s.Size}
+	f.Generic, f.Shape = splitShape(s.Name)
+
+	// Attribute the code of each run of instructions from an inlined
+	// body to all the functions on its inlining stack.
+	if pcinline := fn.Pcln.Pcinline; pcinline != nil {
+		pcValues(pcinline.P, func(pc, next int64, val int32) {
+			if val < 0 {
+				return
+			}
+			seen := make(map[string]bool)
+			fn.Pcln.InlTree.AllParents(int(val), func(call obj.InlinedCall) {
+				name := call.Name
+				if call.Func != nil {
+					name = call.Func.Name
+				}
+				if !seen[name] {
+					seen[name] = true
+					if f.Inlined == nil {
+						f.Inlined = make(map[string]int64)
+					}
+					f.Inlined[name] += next - pc
+				}
+			})
+		})
+	}
+
+	for _, x := range funcDataSyms(fn) {
+		f.Data += int64(len(x.P))
+	}
+	for _, jt := range fn.JumpTables {
+		f.Data += int64(len(jt.Targets) * base.Ctxt.Arch.PtrSize)
+	}
+
+	seen := make(map[*obj.LSym]bool)
+	for _, r := range s.R {
+		if r.Sym == nil || seen[r.Sym] {
+			continue
+		}
+		seen[r.Sym] = true
+		if isTypeData(r.Sym) {
+			f.Types += r.Sym.Size
+		}
+		if r.Sym.Type == objabi.SRODATA {
+			f.Rodata += r.Sym.Size
+		}
+	}
+	return f
+}
+
+// funcDataSyms returns the funcdata and pc table symbols of fn.
+func funcDataSyms(fn *obj.FuncInfo) []*obj.LSym {
+	all := []*obj.LSym{fn.GCArgs, fn.GCLocals, fn.StackObjects, fn.OpenCodedDeferInfo, fn.ArgInfo, fn.ArgLiveInfo, fn.WrapInfo}
+	all = append(all, fn.Pcln.Pcsp, fn.Pcln.Pcfile, fn.Pcln.Pcline, fn.Pcln.Pcinline)
+	all = append(all, fn.Pcln.Pcdata...)
+	var syms []*obj.LSym
+	for _, x := range all {
+		if x != nil {
+			syms = append(syms, x)
+		}
+	}
+	return syms
+}
+
+// isTypeData reports whether s is a type descriptor or dictionary.
+func isTypeData(s *obj.LSym) bool {
+	return strings.HasPrefix(s.Name, "type:") || strings.Contains(s.Name, objabi.GlobalDictPrefix+".")
+}
+
+// splitShape splits the name of a shaped instantiation of a generic
+// function or method, such as "p.F[go.shape.int]", into the name of
+// the generic function, "p.F", and its shape, "[go.shape.int]".
+// It returns "", "" if name is not that of an instantiation.
+func splitShape(name string) (generic, shape string) {
+	i := strings.Index(name, "[go.shape.")
+	if i < 0 {
+		return "", ""
+	}
+	depth := 0
+	for j := i; j < len(name); j++ {
+		switch name[j] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return name[:i] + name[j+1:], name[i : j+1]
+			}
+		}
+	}
+	return "", ""
+}
+
+// pcValues calls f for each run of pcs [pc, next) of the function in
+// the pc-value table p, with the value val that applies to it.
+// See obj.funcpctab for the encoding.
+func pcValues(p []byte, f func(pc, next int64, val int32)) {
+	quantum := int64(base.Ctxt.Arch.MinLC)
+	val := int32(-1)
+	pc := int64(0)
+	for first := true; ; first = false {
+		dv, n := binary.Varint(p)
+		if n <= 0 || dv == 0 && !first {
+			return
+		}
+		p = p[n:]
+		val += int32(dv)
+		dpc, n := binary.Uvarint(p)
+		if n <= 0 {
+			return
+		}
+		p = p[n:]
+		next := pc + int64(dpc)*quantum
+		f(pc, next, val)
+		pc = next
+	}
+}
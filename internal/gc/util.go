@@ -114,4 +114,17 @@ func startProfile() {
 		}
 		base.AtExit(tracepkg.Stop)
 	}
+	if base.Flag.CompileTrace != "" {
+		f, err := os.Create(profileName(base.Flag.CompileTrace, ".json"))
+		if err != nil {
+			base.Fatalf("%v", err)
+		}
+		base.StartCompileTrace()
+		base.AtExit(func() {
+			if err := base.WriteCompileTrace(f); err != nil {
+				base.Fatalf("%v", err)
+			}
+			f.Close()
+		})
+	}
 }
@@ -30,6 +30,7 @@ import (
 	"compile/src_internal/buildcfg"
 	"fmt"
 	"go/constant"
+	"math"
 	"strconv"
 
 	"compile/cmd_internal/obj"
@@ -53,8 +54,23 @@ const (
 
 	inlineBigFunctionNodes   = 5000 // Functions with this many nodes are considered "big".
 	inlineBigFunctionMaxCost = 20   // Max cost of inlinee when inlining into a "big" function.
+
+	// With -optsize, inline only functions whose body is about as
+	// small as the call, and restrict inlining into big functions
+	// further.
+	inlineSizeMaxBudget          = 40
+	inlineSizeBigFunctionNodes   = 2000
+	inlineSizeBigFunctionMaxCost = 10
 )
 
+// maxBudget returns the maximum inlining budget.
+func maxBudget() int32 {
+	if base.Flag.OptSize {
+		return inlineSizeMaxBudget
+	}
+	return inlineMaxBudget
+}
+
 var (
 	// List of all hot callee nodes.
 	// TODO(prattmic): Make this non-global.
@@ -168,6 +184,9 @@ func CanInlineSCC(funcs []*ir.Func, recursive bool, profile *pgo.Profile) {
 			if base.Flag.LowerM > 1 && fn.OClosure == nil {
 				fmt.Printf("%v: cannot inline %v: recursive\n", ir.Line(fn), fn.Nname)
 			}
+			if fn.Pragma&ir.Forceinline != 0 {
+				base.ErrorfAt(fn.Pos(), 0, "cannot inline %v marked go:inline: recursive", fn.Nname)
+			}
 		}
 		if inlheur.Enabled() {
 			analyzeFuncProps(fn, profile)
@@ -235,8 +254,9 @@ func GarbageCollectUnreferencedHiddenClosures() {
 // we boost the budget due to PGO.
 func inlineBudget(fn *ir.Func, profile *pgo.Profile, relaxed bool, verbose bool) int32 {
 	// Update the budget for profile-guided inlining.
-	budget := int32(inlineMaxBudget)
-	if profile != nil {
+	// Hot functions are not given more budget when optimizing for size.
+	budget := maxBudget()
+	if profile != nil && !base.Flag.OptSize {
 		if n, ok := profile.WeightedCG.IRNodes[ir.LinkFuncName(fn)]; ok {
 			if _, ok := candHotCalleeMap[n]; ok {
 				budget = int32(inlineHotMaxBudget)
@@ -247,7 +267,7 @@ func inlineBudget(fn *ir.Func, profile *pgo.Profile, relaxed bool, verbose bool)
 		}
 	}
 	if relaxed {
-		budget += inlheur.BudgetExpansion(inlineMaxBudget)
+		budget += inlheur.BudgetExpansion(maxBudget())
 	}
 	return budget
 }
@@ -273,6 +293,13 @@ func CanInline(fn *ir.Func, profile *pgo.Profile) {
 			}
 		}()
 	}
+	if fn.Pragma&ir.Forceinline != 0 {
+		defer func() {
+			if reason != "" {
+				base.ErrorfAt(fn.Pos(), 0, "cannot inline %v marked go:inline: %s", fn.Nname, reason)
+			}
+		}()
+	}
 
 	reason = InlineImpossible(fn)
 	if reason != "" {
@@ -298,6 +325,10 @@ func CanInline(fn *ir.Func, profile *pgo.Profile) {
 
 	// Compute the inline budget for this func.
 	budget := inlineBudget(fn, profile, relaxed, base.Debug.PGODebug > 0)
+	if fn.Pragma&ir.Forceinline != 0 {
+		// Cost is no object for go:inline functions, only hairiness.
+		budget = math.MaxInt32
+	}
 
 	// At this point in the game the function we're looking at may
 	// have "stale" autos, vars that still appear in the Dcl list, but
@@ -362,6 +393,12 @@ func InlineImpossible(fn *ir.Func) string {
 		return reason
 	}
 
+	// If marked "go:cold", keep it out of line so its callers stay small.
+	if fn.Pragma&ir.Cold != 0 {
+		reason = "marked go:cold"
+		return reason
+	}
+
 	// If marked "go:norace" and -race compilation, don't inline.
 	if base.Flag.Race && fn.Pragma&ir.Norace != 0 {
 		reason = "marked go:norace with -race compilation"
@@ -760,6 +797,9 @@ opSwitch:
 // Note: The criteria for "big" is heuristic and subject to change.
 func IsBigFunc(fn *ir.Func) bool {
 	budget := inlineBigFunctionNodes
+	if base.Flag.OptSize {
+		budget = inlineSizeBigFunctionNodes
+	}
 	return ir.Any(fn, func(n ir.Node) bool {
 		// See logic in hairyVisitor.doNode, explaining unified IR's
 		// handling of "a, b = f()" assignments.
@@ -864,11 +904,18 @@ var InlineCall = func(callerfn *ir.Func, call *ir.CallExpr, fn *ir.Func, inlInde
 // cost" limit used to make the decision (which may differ depending
 // on func size), and the score assigned to this specific callsite.
 func inlineCostOK(n *ir.CallExpr, caller, callee *ir.Func, bigCaller bool) (bool, int32, int32) {
-	maxCost := int32(inlineMaxBudget)
+	if callee.Pragma&ir.Forceinline != 0 {
+		return true, 0, callee.Inl.Cost
+	}
+
+	maxCost := maxBudget()
 	if bigCaller {
 		// We use this to restrict inlining into very big functions.
 		// See issue 26546 and 17566.
 		maxCost = inlineBigFunctionMaxCost
+		if base.Flag.OptSize {
+			maxCost = inlineSizeBigFunctionMaxCost
+		}
 	}
 
 	metric := callee.Inl.Cost
@@ -889,8 +936,8 @@ func inlineCostOK(n *ir.CallExpr, caller, callee *ir.Func, bigCaller bool) (bool
 
 	lineOffset := pgo.NodeLineOffset(n, caller)
 	csi := pgo.CallSiteInfo{LineOffset: lineOffset, Caller: caller}
-	if _, ok := candHotEdgeMap[csi]; !ok {
-		// Cold
+	if _, ok := candHotEdgeMap[csi]; !ok || base.Flag.OptSize {
+		// Cold, or optimizing for size
 		return false, maxCost, metric
 	}
 
@@ -1213,5 +1260,5 @@ func analyzeFuncProps(fn *ir.Func, p *pgo.Profile) {
 	budgetForFunc := func(fn *ir.Func) int32 {
 		return inlineBudget(fn, p, true, false)
 	}
-	inlheur.AnalyzeFunc(fn, canInline, budgetForFunc, inlineMaxBudget)
+	inlheur.AnalyzeFunc(fn, canInline, budgetForFunc, int(maxBudget()))
 }
@@ -7,6 +7,7 @@
 package interleaved
 
 import (
+	"compile/cmd_internal/src"
 	"compile/internal/base"
 	"compile/internal/devirtualize"
 	"compile/internal/inline"
@@ -89,6 +90,16 @@ func DevirtualizeAndInlineFunc(fn *ir.Func, profile *pgo.Profile) {
 			}
 		}
 
+		if fn.Pragma&ir.Tailcall != 0 {
+			// Leave the calls in tail position for walk, which
+			// must turn each of them into a jump or report why
+			// it cannot.
+			ir.EditTailCalls(fn, func(pos src.XPos, call *ir.CallExpr, init []ir.Node) ir.Node {
+				call.NoInline = true
+				return nil
+			})
+		}
+
 		bigCaller := base.Flag.LowerL != 0 && inline.IsBigFunc(fn)
 		if bigCaller && base.Flag.LowerM > 1 {
 			fmt.Printf("%v: function %v considered 'big'; reducing max cost of inlinees\n", ir.Line(fn), fn)
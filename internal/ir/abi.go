@@ -7,6 +7,7 @@ package ir
 import (
 	"compile/cmd_internal/obj"
 	"compile/internal/base"
+	"compile/internal/types"
 )
 
 // InitLSym defines f's obj.LSym and initializes it based on the
@@ -27,6 +28,9 @@ func InitLSym(f *Func, hasBody bool) {
 		if f.Pragma&Systemstack != 0 {
 			f.LSym.Set(obj.AttrCFunc, true)
 		}
+		if f.Pragma&Cold != 0 {
+			f.LSym.Set(obj.AttrCold, true)
+		}
 	}
 	if hasBody {
 		setupTextLSym(f, 0)
@@ -53,6 +57,9 @@ func setupTextLSym(f *Func, flag int) {
 	if f.IsPackageInit() {
 		flag |= obj.PKGINIT
 	}
+	if base.Ctxt.TrackIndirect() && mayBeCalledIndirectly(f) {
+		flag |= obj.INDIRECT
+	}
 
 	// Clumsy but important.
 	// For functions that could be on the path of invoking a deferred
@@ -76,3 +83,31 @@ func setupTextLSym(f *Func, flag int) {
 
 	base.Ctxt.InitTextSym(f.LSym, flag, f.Pos())
 }
+
+// mayBeCalledIndirectly reports whether f's entry point may be the
+// target of an indirect call or jump, independent of any uses of f
+// as a value in the package's source (those are marked up front, see
+// Package.FuncValues) or in code the compiler generates (those are
+// marked as they are found; see staticdata.FuncLinksym).
+func mayBeCalledIndirectly(f *Func) bool {
+	switch {
+	case f.OClosure != nil:
+		// Closures are only ever called through a func value.
+		return true
+	case f.Type().Recv() != nil:
+		// Methods are reachable through itabs, method values,
+		// and reflection.
+		return true
+	case f.Wrapper(), f.ABIWrapper(), f.IsPackageInit():
+		return true
+	case f.Dupok():
+		// Instantiations and other DUPOK functions may be compiled
+		// by several packages, which all need to agree, as the
+		// linker keeps only one of the copies.
+		return true
+	}
+	// Other packages may take the address of exported and
+	// linknamed functions.
+	sym := f.Sym()
+	return types.IsExported(sym.Name) || sym.Linkname != ""
+}
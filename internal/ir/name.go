@@ -35,9 +35,9 @@ func (n *Ident) Sym() *types.Sym { return n.sym }
 // Name 包含仅由命名节点（ ONAME, OTYPE 和某些 OLITERAL）使用的 Node 字段。
 type Name struct {
 	miniExpr
-	BuiltinOp Op         // uint8
-	Class     Class      // uint8
-	pragma    PragmaFlag // int16
+	BuiltinOp Op     // uint8
+	Class     Class  // uint8
+	pragma    uint16 // PragmaFlag of an OTYPE; type pragmas fit in 16 bits
 	flags     bitset16
 	DictIndex uint16 // index of the dictionary entry describing the type of this variable declaration plus 1: 描述此变量声明类型加 1 的字典条目的索引
 	sym       *types.Sym
@@ -176,10 +176,15 @@ func (*Name) CanBeAnSSASym() {}
 func (*Name) CanBeAnSSAAux() {}
 
 // Pragma returns the PragmaFlag for p, which must be for an OTYPE.
-func (n *Name) Pragma() PragmaFlag { return n.pragma }
+func (n *Name) Pragma() PragmaFlag { return PragmaFlag(n.pragma) }
 
 // SetPragma sets the PragmaFlag for p, which must be for an OTYPE.
-func (n *Name) SetPragma(flag PragmaFlag) { n.pragma = flag }
+func (n *Name) SetPragma(flag PragmaFlag) {
+	if flag != PragmaFlag(uint16(flag)) {
+		base.Fatalf("pragma flags %#x do not fit in Name", flag)
+	}
+	n.pragma = uint16(flag)
+}
 
 // Alias reports whether p, which must be for an OTYPE, is a type alias.
 func (n *Name) Alias() bool { return n.flags&nameAlias != 0 }
@@ -39,4 +39,10 @@ type Package struct {
 	// for -buildmode=plugin (i.e., compiling package main and -dynlink
 	// is set).
 	PluginExports []*Name
+
+	// FuncValues holds the package-level functions that are used
+	// other than in direct calls anywhere in the package's source,
+	// including in generic functions and inline bodies that other
+	// packages may compile. It's only populated when -ibt is set.
+	FuncValues []*types.Sym
 }
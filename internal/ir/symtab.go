@@ -42,6 +42,7 @@ type symsStruct struct {
 	Panicshift        *obj.LSym
 	PanicdottypeE     *obj.LSym
 	PanicdottypeI     *obj.LSym
+	Panicindirectcall *obj.LSym
 	Panicnildottype   *obj.LSym
 	Panicoverflow     *obj.LSym
 	Racefuncenter     *obj.LSym
@@ -0,0 +1,184 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ir
+
+import (
+	"compile/cmd_internal/src"
+	"compile/internal/types"
+)
+
+// EditTailCalls calls edit for each call in tail position in fn,
+// along with the position of the statement it is in and the
+// statements that must run before it. If edit returns a non-nil
+// statement, it replaces that statement.
+//
+// A call is in tail position if it is the operand of a return
+// statement and its results are returned as is, or if it is a call
+// statement in a function without results that is followed by a
+// return statement or ends the function body.
+func EditTailCalls(fn *Func, edit func(pos src.XPos, call *CallExpr, init []Node) Node) {
+	e := tailCallEditor{void: fn.Type().NumResults() == 0, edit: edit}
+	e.stmts(fn.Body, true)
+}
+
+type tailCallEditor struct {
+	void bool // the function has no results
+	edit func(pos src.XPos, call *CallExpr, init []Node) Node
+}
+
+// stmts edits the calls in tail position in list and the statements
+// nested in it. last reports whether list is the function body.
+func (e *tailCallEditor) stmts(list Nodes, last bool) {
+	for i, n := range list {
+		switch n := n.(type) {
+		case *ReturnStmt:
+			if call, init := tailCallOf(n); call != nil {
+				if repl := e.edit(n.Pos(), call, init); repl != nil {
+					list[i] = repl
+				}
+			}
+			continue
+		case *CallExpr:
+			if e.void && isCall(n) && (i == len(list)-1 && last || i+1 < len(list) && isBareReturn(list[i+1])) {
+				if repl := e.edit(n.Pos(), n, nil); repl != nil {
+					list[i] = repl
+				}
+				continue
+			}
+		}
+		e.nested(n)
+	}
+}
+
+// nested edits the calls in tail position in the statement lists
+// nested within n.
+func (e *tailCallEditor) nested(n Node) {
+	switch n := n.(type) {
+	case *BlockStmt:
+		e.stmts(n.List, false)
+	case *IfStmt:
+		e.stmts(n.Body, false)
+		e.stmts(n.Else, false)
+	case *ForStmt:
+		e.stmts(n.Body, false)
+	case *RangeStmt:
+		e.stmts(n.Body, false)
+	case *SwitchStmt:
+		for _, cas := range n.Cases {
+			e.stmts(cas.Body, false)
+		}
+	case *SelectStmt:
+		for _, cas := range n.Cases {
+			e.stmts(cas.Body, false)
+		}
+	}
+}
+
+// isCall reports whether n is a call to a function or an interface
+// method, rather than a call to a builtin.
+func isCall(n Node) bool {
+	switch n.Op() {
+	case OCALLFUNC, OCALLINTER:
+		return true
+	}
+	return false
+}
+
+// isBareReturn reports whether n is a return statement without results.
+func isBareReturn(n Node) bool {
+	ret, ok := n.(*ReturnStmt)
+	return ok && len(ret.Results) == 0 && len(ret.Init()) == 0
+}
+
+// tailCallOf returns the call whose results ret returns as is, along
+// with the statements that must run before it, or nil if there is no
+// such call.
+func tailCallOf(ret *ReturnStmt) (*CallExpr, []Node) {
+	switch len(ret.Results) {
+	case 0:
+		return nil, nil
+	case 1:
+		// return f(...)
+		call, ok := ret.Results[0].(*CallExpr)
+		if !ok || !isCall(call) {
+			return nil, nil
+		}
+		return call, ret.Init()
+	}
+
+	// return f(...) with multiple results was rewritten by the
+	// noder into
+	//
+	//	var tmp1, ..., tmpN
+	//	tmp1, ..., tmpN = f(...)
+	//	return tmp1, ..., tmpN
+	//
+	// where the assignment may go through another set of
+	// temporaries, and the statements are in the inits of the
+	// return statement, its first result, and the assignments.
+	results := ret.Results
+	init := ret.Init()
+	if conv, ok := results[0].(*ConvExpr); ok && conv.Op() == OCONVNOP && types.Identical(conv.Type(), conv.X.Type()) {
+		results = append([]Node{conv.X}, results[1:]...)
+		init = append(append([]Node(nil), init...), conv.Init()...)
+	}
+
+	m := multiCall{}
+	if !m.stmts(init) || m.call == nil {
+		return nil, nil
+	}
+	for i, res := range results {
+		if m.lhs[i] != res {
+			return nil, nil
+		}
+	}
+	return m.call, m.pre
+}
+
+// multiCall matches the statements that assign the results of a call
+// returning multiple values to temporaries.
+type multiCall struct {
+	pre  []Node    // statements before the call
+	call *CallExpr // the call, once found
+	lhs  []Node    // the temporaries now holding its results
+}
+
+// stmts matches list, reporting whether it has the expected shape.
+func (m *multiCall) stmts(list []Node) bool {
+	for _, n := range list {
+		switch n.Op() {
+		case ODCL:
+			continue
+		case OAS2FUNC, OAS2:
+			as := n.(*AssignListStmt)
+			if !m.stmts(as.Init()) {
+				return false
+			}
+			if n.Op() == OAS2FUNC {
+				call, ok := as.Rhs[0].(*CallExpr)
+				if m.call != nil || !ok || !isCall(call) {
+					return false
+				}
+				m.call, m.lhs = call, as.Lhs
+				continue
+			}
+			if m.call == nil || len(as.Rhs) != len(m.lhs) {
+				return false
+			}
+			for i, rhs := range as.Rhs {
+				if rhs != m.lhs[i] {
+					return false
+				}
+			}
+			m.lhs = as.Lhs
+		default:
+			if m.call != nil {
+				return false
+			}
+			m.pre = append(m.pre, n)
+		}
+	}
+	return true
+}
@@ -334,7 +334,7 @@ func NewLoggedOpt(pos, lastPos src.XPos, what, pass, funcName string, args ...in
 // Pos is the source position (including inlining), what is the message, pass is which pass created the message,
 // funcName is the name of the function.
 func LogOpt(pos src.XPos, what, pass, funcName string, args ...interface{}) {
-	if Format == None {
+	if !Enabled() {
 		return
 	}
 	lo := NewLoggedOpt(pos, pos, what, pass, funcName, args...)
@@ -347,7 +347,7 @@ func LogOpt(pos src.XPos, what, pass, funcName string, args ...interface{}) {
 // LogOptRange is the same as LogOpt, but includes the ability to express a range of positions,
 // not just a point.
 func LogOptRange(pos, lastPos src.XPos, what, pass, funcName string, args ...interface{}) {
-	if Format == None {
+	if !Enabled() {
 		return
 	}
 	lo := NewLoggedOpt(pos, lastPos, what, pass, funcName, args...)
@@ -361,7 +361,7 @@ func LogOptRange(pos, lastPos src.XPos, what, pass, funcName string, args ...int
 func Enabled() bool {
 	switch Format {
 	case None:
-		return false
+		return sarifDest != ""
 	case Json0:
 		return true
 	}
@@ -430,11 +430,14 @@ func uprootedPath(filename string) string {
 
 // FlushLoggedOpts flushes all the accumulated optimization log entries.
 func FlushLoggedOpts(ctxt *obj.Link, slashPkgPath string) {
-	if Format == None {
+	if !Enabled() {
 		return
 	}
 
 	sort.Stable(byPos{ctxt, loggedOpts}) // Stable is necessary to preserve the per-function order, which is repeatable.
+	if sarifDest != "" {
+		writeSarif(ctxt, slashPkgPath, loggedOpts)
+	}
 	switch Format {
 
 	case Json0: // LSP 3.15
@@ -5,7 +5,12 @@
 package logopt
 
 import (
+	"bytes"
+	"compile/cmd_internal/obj"
+	"compile/cmd_internal/obj/x86"
+	"compile/cmd_internal/src"
 	"compile/src_internal/testenv"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -81,6 +86,49 @@ func TestPathStuff(t *testing.T) {
 	}
 }
 
+func TestSarif(t *testing.T) {
+	ctxt := obj.Linknew(&x86.Linkamd64)
+	fileBase := src.NewFileBase("/tmp/file.go", "/tmp/file.go")
+	pos := func(base *src.PosBase, line, col uint) src.XPos {
+		return ctxt.PosTable.XPos(src.MakePos(base, line, col))
+	}
+	// a nil check at 4:11 in bar, inlined at 9:13
+	inl := ctxt.InlTree.Add(-1, pos(fileBase, 9, 13), nil, "bar")
+	nilcheck := pos(src.NewInliningBase(fileBase, inl), 4, 11)
+
+	opts := []*LoggedOpt{
+		NewLoggedOpt(pos(fileBase, 7, 6), pos(fileBase, 7, 6), "canInlineFunction", "inline", "foo", "cost: 35"),
+		NewLoggedOpt(nilcheck, nilcheck, "nilcheck", "genssa", "foo"),
+		NewLoggedOpt(pos(fileBase, 11, 6), pos(fileBase, 11, 6), "isInBounds", "checkbce", "foo"),
+	}
+	var buf bytes.Buffer
+	if err := encodeSarif(&buf, newSarifLog(ctxt, "x", opts)); err != nil {
+		t.Fatal(err)
+	}
+	out := compact(t, buf.String())
+	t.Logf("%s", out)
+
+	want(t, out, `"rules":[{"id":"canInlineFunction","shortDescription":{"text":"Function can be inlined"}},`+
+		`{"id":"isInBounds","shortDescription":{"text":"Bounds check"}},{"id":"nilcheck","shortDescription":{"text":"Nil check"}}]`)
+	want(t, out, `{"ruleId":"canInlineFunction","ruleIndex":0,"level":"note","message":{"text":"cost: 35"},`+
+		`"locations":[{"physicalLocation":{"artifactLocation":{"uri":"file:///tmp/file.go"},"region":{"startLine":7,"startColumn":6,"endLine":7,"endColumn":6}}}],`+
+		`"properties":{"function":"foo","pass":"inline"}}`)
+	want(t, out, `{"ruleId":"nilcheck","ruleIndex":2,"level":"note","message":{"text":"nilcheck"},`+
+		`"locations":[{"physicalLocation":{"artifactLocation":{"uri":"file:///tmp/file.go"},"region":{"startLine":9,"startColumn":13,"endLine":9,"endColumn":13}}}],`+
+		`"codeFlows":[{"message":{"text":"inlining"},"threadFlows":[{"locations":[`+
+		`{"location":{"physicalLocation":{"artifactLocation":{"uri":"file:///tmp/file.go"},"region":{"startLine":9,"startColumn":13,"endLine":9,"endColumn":13}}}},`+
+		`{"location":{"physicalLocation":{"artifactLocation":{"uri":"file:///tmp/file.go"},"region":{"startLine":4,"startColumn":11,"endLine":4,"endColumn":11}},"message":{"text":"inlineLoc"}},"nestingLevel":1}]}]}]`)
+}
+
+// compact removes the indentation from the JSON in s.
+func compact(t *testing.T, s string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
 func TestLogOpt(t *testing.T) {
 	t.Parallel()
 
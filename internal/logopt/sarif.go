@@ -0,0 +1,303 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logopt
+
+import (
+	"compile/cmd_internal/obj"
+	"compile/cmd_internal/src"
+	"compile/src_internal/buildcfg"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// This implements SARIF 2.1.0 output of the same optimization log for
+// the -sarif option to the Go compiler. The option is -sarif <destination>,
+// where <destination> is a directory specified as for -json.
+//
+// For each package pkg compiled, a url.PathEscape(pkg)+".sarif"-named
+// file is created in the destination directory, holding a single run
+// with one result per logged optimization. (If the package string is
+// empty, it is replaced with string(0), as for -json.)
+//
+// Results are mapped from the logged optimizations as follows:
+// RuleID: the (missed) optimization, e.g. "nilcheck", "cannotInlineFunction", "isInBounds", "escape".
+//    Each rule used appears in the run's tool.driver.rules.
+// Level: (always) "note".
+// Message: the additional information, e.g. the reason a function cannot be inlined,
+//    or the rule ID if there is none.
+// Locations: the outermost source position, with the range up to the last position.
+// CodeFlows: if the optimization occurred in an inlined function, a code flow
+//    with the sequence of inlined locations, from outermost to innermost.
+//    In the case of escape analysis explanations, a further code flow with
+//    the lines of the explanation, each followed by its inlined locations
+//    (if any) at increasing nesting levels.
+// Properties: the compiler pass and function name.
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool         `json:"tool"`
+	Results    []sarifResult     `json:"results"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	RuleIndex  int               `json:"ruleIndex"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	CodeFlows  []sarifCodeFlow   `json:"codeFlows,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI DocumentURI `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   uint `json:"startLine"`
+	StartColumn uint `json:"startColumn"`
+	EndLine     uint `json:"endLine"`
+	EndColumn   uint `json:"endColumn"`
+}
+
+type sarifCodeFlow struct {
+	Message     *sarifMessage     `json:"message,omitempty"`
+	ThreadFlows []sarifThreadFlow `json:"threadFlows"`
+}
+
+type sarifThreadFlow struct {
+	Locations []sarifThreadFlowLocation `json:"locations"`
+}
+
+type sarifThreadFlowLocation struct {
+	Location     sarifLocation `json:"location"`
+	NestingLevel int           `json:"nestingLevel,omitempty"`
+}
+
+// ruleDescriptions describes the kinds of logged optimizations known
+// to be reported by the compiler.
+var ruleDescriptions = map[string]string{
+	"canInlineFunction":    "Function can be inlined",
+	"cannotInlineCall":     "Call was not inlined",
+	"cannotInlineFunction": "Function cannot be inlined",
+	"copy":                 "Large copy",
+	"escape":               "Value escapes to the heap",
+	"escapes":              "Value escapes to the heap",
+	"isInBounds":           "Bounds check",
+	"isSliceInBounds":      "Slice bounds check",
+	"leak":                 "Parameter leaks",
+	"nilcheck":             "Nil check",
+}
+
+var sarifDest string
+
+// LogSarifOption parses and validates the destination directory
+// attached to the -sarif compiler flag.
+func LogSarifOption(destination string) {
+	if sarifDest != "" {
+		log.Fatal("Cannot repeat -sarif flag")
+	}
+	sarifDest = checkLogPath(destination)
+}
+
+// newRegion returns the Region for the compiler source range from p to last.
+func newRegion(p, last src.Pos) sarifRegion {
+	return sarifRegion{p.Line(), p.Col(), last.Line(), last.Col()}
+}
+
+// newSarifLocation returns the Location for the compiler source range
+// from p to last, with optional message msg.
+func newSarifLocation(p, last src.Pos, msg string) sarifLocation {
+	loc := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{uriIfy(uprootedPath(p.Filename()))},
+			Region:           newRegion(p, last),
+		},
+	}
+	if msg != "" {
+		loc.Message = &sarifMessage{msg}
+	}
+	return loc
+}
+
+// appendInlinedFlow appends the inlining locations in posTmp and
+// lastTmp, other than the outermost, to flow, starting at nesting level.
+func appendInlinedFlow(flow []sarifThreadFlowLocation, posTmp, lastTmp []src.Pos, level int) []sarifThreadFlowLocation {
+	for i := 1; i < len(posTmp); i++ {
+		loc := newSarifLocation(posTmp[i], lastTmp[i], "inlineLoc")
+		flow = append(flow, sarifThreadFlowLocation{loc, level + i - 1})
+	}
+	return flow
+}
+
+// newSarifLog returns the SARIF log for the optimizations in opts,
+// which must be sorted by position.
+func newSarifLog(ctxt *obj.Link, slashPkgPath string, opts []*LoggedOpt) *sarifLog {
+	var posTmp, lastTmp []src.Pos
+
+	ruleIndex := make(map[string]int)
+	var rules []string
+	for _, x := range opts {
+		if _, ok := ruleIndex[x.what]; !ok {
+			ruleIndex[x.what] = 0
+			rules = append(rules, x.what)
+		}
+	}
+	sort.Strings(rules)
+	driver := sarifDriver{
+		Name:           "go compiler",
+		Version:        buildcfg.Version,
+		InformationURI: "https://pkg.go.dev/cmd/compile",
+		Rules:          []sarifRule{},
+	}
+	for i, id := range rules {
+		ruleIndex[id] = i
+		desc := ruleDescriptions[id]
+		if desc == "" {
+			desc = id
+		}
+		driver.Rules = append(driver.Rules, sarifRule{id, sarifMessage{desc}})
+	}
+
+	results := []sarifResult{}
+	for _, x := range opts {
+		posTmp, p0 := parsePos(ctxt, x.pos, posTmp)
+		lastTmp, l0 := parsePos(ctxt, x.lastPos, lastTmp)
+
+		// The first "target" is the most important one.
+		msg := x.what
+		if len(x.target) > 0 {
+			if target := fmt.Sprint(x.target[0]); target != "" {
+				msg = target
+			}
+		}
+		r := sarifResult{
+			RuleID:    x.what,
+			RuleIndex: ruleIndex[x.what],
+			Level:     "note",
+			Message:   sarifMessage{msg},
+			Locations: []sarifLocation{newSarifLocation(p0, l0, "")},
+			Properties: map[string]string{
+				"pass":     x.compilerPass,
+				"function": x.functionName,
+			},
+		}
+
+		if len(posTmp) > 1 {
+			flow := []sarifThreadFlowLocation{{Location: newSarifLocation(p0, l0, "")}}
+			flow = appendInlinedFlow(flow, posTmp, lastTmp, 1)
+			r.CodeFlows = append(r.CodeFlows, sarifCodeFlow{
+				Message:     &sarifMessage{"inlining"},
+				ThreadFlows: []sarifThreadFlow{{flow}},
+			})
+		}
+
+		// Diagnostic explanation is a code flow of its own.
+		if len(x.target) > 1 {
+			switch y := x.target[1].(type) {
+			case []*LoggedOpt:
+				var flow []sarifThreadFlowLocation
+				for _, z := range y {
+					posTmp, p0 := parsePos(ctxt, z.pos, posTmp)
+					lastTmp, l0 := parsePos(ctxt, z.lastPos, lastTmp)
+					msg := z.what
+					if len(z.target) > 0 {
+						msg = msg + ": " + fmt.Sprint(z.target[0])
+					}
+					flow = append(flow, sarifThreadFlowLocation{Location: newSarifLocation(p0, l0, msg)})
+					flow = appendInlinedFlow(flow, posTmp, lastTmp, 1)
+				}
+				if len(flow) > 0 {
+					r.CodeFlows = append(r.CodeFlows, sarifCodeFlow{
+						Message:     &sarifMessage{"explanation"},
+						ThreadFlows: []sarifThreadFlow{{flow}},
+					})
+				}
+			}
+		}
+		results = append(results, r)
+	}
+
+	return &sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{driver},
+			Results: results,
+			Properties: map[string]string{
+				"package": slashPkgPath,
+				"goos":    buildcfg.GOOS,
+				"goarch":  buildcfg.GOARCH,
+			},
+		}},
+	}
+}
+
+// writeSarif writes the SARIF log for the optimizations in opts,
+// which must be sorted by position, to the -sarif destination.
+func writeSarif(ctxt *obj.Link, slashPkgPath string, opts []*LoggedOpt) {
+	if slashPkgPath == "" {
+		slashPkgPath = "\000"
+	}
+	p := filepath.Join(sarifDest, url.PathEscape(slashPkgPath)+".sarif")
+	w, err := os.Create(p)
+	if err != nil {
+		log.Fatalf("Could not create file %s for logging optimizer actions, %v", p, err)
+	}
+	if err := encodeSarif(w, newSarifLog(ctxt, slashPkgPath, opts)); err != nil {
+		log.Fatalf("Could not write file %s for logging optimizer actions, %v", p, err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatalf("Could not write file %s for logging optimizer actions, %v", p, err)
+	}
+}
+
+func encodeSarif(w io.Writer, l *sarifLog) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(l)
+}
@@ -15,6 +15,7 @@ import (
 	"compile/internal/base"
 	"compile/internal/rangefunc"
 	"compile/internal/syntax"
+	"compile/internal/types"
 	"compile/internal/types2"
 )
 
@@ -85,7 +86,11 @@ func checkFiles(m posMap, noders []*noder) (*types2.Package, *types2.Info) {
 				msg = fmt.Sprintf("%s (-lang was set to %s; check go.mod)", msg, base.Flag.Lang)
 			}
 		}
-		base.ErrorfAt(m.makeXPos(terr.Pos), terr.Code, "%s", msg)
+		var details *base.ErrorDetails
+		if base.Flag.ErrorFormat != "text" {
+			details = m.errorDetails(terr)
+		}
+		base.ErrorfAtDetails(m.makeXPos(terr.Pos), terr.Code, details, "%s", msg)
 	}
 
 	pkg, err := conf.Check(base.Ctxt.Pkgpath, files, info)
@@ -239,3 +244,53 @@ func (f *cycleFinder) visit(typ0 types2.Type) bool {
 		}
 	}
 }
+
+// errorDetails translates the structured information of a types2
+// error for the -errorformat output.
+func (m *posMap) errorDetails(terr types2.Error) *base.ErrorDetails {
+	d := &base.ErrorDetails{End: m.makeXPos(terr.End)}
+	for _, r := range terr.Related {
+		d.Related = append(d.Related, base.RelatedInfo{Pos: m.makeXPos(r.Pos), Msg: r.Msg})
+	}
+	for _, fix := range terr.Fixes {
+		f := base.SuggestedFix{Msg: fix.Msg}
+		for _, e := range fix.Edits {
+			f.Edits = append(f.Edits, base.TextEdit{Pos: m.makeXPos(e.Pos), End: m.makeXPos(e.End), New: e.New})
+		}
+		d.Fixes = append(d.Fixes, f)
+	}
+	return d
+}
+
+// funcValues returns the package-level functions of pkg that the
+// files use other than by calling them directly. The function bodies
+// of the package may be exported for inlining or instantiation, so
+// other packages may take the address of any of these functions.
+func funcValues(pkg *types2.Package, info *types2.Info, noders []*noder) []*types.Sym {
+	var syms []*types.Sym
+	seen := make(map[*types2.Func]bool)
+	var visit func(n syntax.Node) bool
+	visit = func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.CallExpr:
+			if _, ok := syntax.Unparen(n.Fun).(*syntax.Name); ok {
+				// A direct call is not an indirect branch.
+				for _, arg := range n.ArgList {
+					syntax.Inspect(arg, visit)
+				}
+				return false
+			}
+		case *syntax.Name:
+			fn, ok := info.Uses[n].(*types2.Func)
+			if ok && fn.Pkg() == pkg && fn.Parent() == pkg.Scope() && !seen[fn] {
+				seen[fn] = true
+				syms = append(syms, types.LocalPkg.Lookup(fn.Name()))
+			}
+		}
+		return true
+	}
+	for _, p := range noders {
+		syntax.Inspect(p.file, visit)
+	}
+	return syms
+}
@@ -27,6 +27,9 @@ const (
 		ir.Norace |
 		ir.Nosplit |
 		ir.Noinline |
+		ir.Forceinline |
+		ir.Cold |
+		ir.Tailcall |
 		ir.NoCheckPtr |
 		ir.RegisterParams | // TODO(register args) remove after register abi is working
 		ir.CgoUnsafeArgs |
@@ -54,6 +57,12 @@ func pragmaFlag(verb string) ir.PragmaFlag {
 		return ir.Nosplit | ir.NoCheckPtr // implies NoCheckPtr (see #34972)
 	case "go:noinline":
 		return ir.Noinline
+	case "go:inline":
+		return ir.Forceinline
+	case "go:cold":
+		return ir.Cold
+	case "go:tailcall":
+		return ir.Tailcall
 	case "go:nocheckptr":
 		return ir.NoCheckPtr
 	case "go:systemstack":
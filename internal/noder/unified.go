@@ -310,6 +310,10 @@ func readBodies(target *ir.Package, duringInlining bool) {
 func writePkgStub(m posMap, noders []*noder) string {
 	pkg, info := checkFiles(m, noders)
 
+	if base.Ctxt.IBT {
+		typecheck.Target.FuncValues = funcValues(pkg, info, noders)
+	}
+
 	pw := newPkgWriter(m, pkg, info)
 
 	pw.collectDecls(noders)
@@ -1019,6 +1019,9 @@ func (w *writer) funcExt(obj *types2.Func) {
 	if pragma&ir.Systemstack != 0 && pragma&ir.Nosplit != 0 {
 		w.p.errorf(decl, "go:nosplit and go:systemstack cannot be combined")
 	}
+	if pragma&ir.Forceinline != 0 && pragma&(ir.Noinline|ir.Cold) != 0 {
+		w.p.errorf(decl, "go:inline cannot be combined with go:noinline or go:cold")
+	}
 	wi := asWasmImport(decl.Pragma)
 
 	if decl.Body != nil {
@@ -114,7 +114,12 @@ func MakeTask() {
 				continue
 			}
 		}
-		fns = append(fns, fn.Nname.Linksym())
+		lsym := fn.Nname.Linksym()
+		if base.Ctxt.TrackIndirect() {
+			// The runtime calls init functions through the inittask.
+			lsym.Set(obj.AttrIndirect, true)
+		}
+		fns = append(fns, lsym)
 	}
 
 	if len(deps) == 0 && len(fns) == 0 && types.LocalPkg.Path != "main" && types.LocalPkg.Path != "runtime" {
@@ -1057,7 +1057,7 @@ func ssaGenValue(s *ssagen.State, v *ssa.Value) {
 
 			// Don't generate padding for
 			// loops with few iterations.
-			if ctr > 3 {
+			if ctr > 3 && !base.Flag.OptSize {
 				p = s.Prog(obj.APCALIGN)
 				p.From.Type = obj.TYPE_CONST
 				p.From.Offset = 16
@@ -1269,7 +1269,7 @@ func ssaGenValue(s *ssagen.State, v *ssa.Value) {
 
 			// Don't add padding for alignment
 			// with few loop iterations.
-			if ctr > 3 {
+			if ctr > 3 && !base.Flag.OptSize {
 				p = s.Prog(obj.APCALIGN)
 				p.From.Type = obj.TYPE_CONST
 				p.From.Offset = 16
@@ -1429,7 +1429,7 @@ func ssaGenValue(s *ssagen.State, v *ssa.Value) {
 			// Don't adding padding for
 			// alignment with small iteration
 			// counts.
-			if ctr > 3 {
+			if ctr > 3 && !base.Flag.OptSize {
 				p = s.Prog(obj.APCALIGN)
 				p.From.Type = obj.TYPE_CONST
 				p.From.Offset = 16
@@ -1651,9 +1651,11 @@ func ssaGenValue(s *ssagen.State, v *ssa.Value) {
 			p.To.Type = obj.TYPE_REG
 			p.To.Reg = ppc64.REG_CTR
 
-			p = s.Prog(obj.APCALIGN)
-			p.From.Type = obj.TYPE_CONST
-			p.From.Offset = 16
+			if !base.Flag.OptSize {
+				p = s.Prog(obj.APCALIGN)
+				p.From.Type = obj.TYPE_CONST
+				p.From.Offset = 16
+			}
 
 			// Generate 16 byte loads and stores.
 			p = s.Prog(ppc64.ALXV)
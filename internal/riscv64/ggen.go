@@ -11,6 +11,7 @@ import (
 	"compile/internal/ir"
 	"compile/internal/objw"
 	"compile/internal/types"
+	"compile/src_internal/buildcfg"
 )
 
 func zeroRange(pp *objw.Progs, p *obj.Prog, off, cnt int64, _ *uint32) *obj.Prog {
@@ -28,7 +29,7 @@ func zeroRange(pp *objw.Progs, p *obj.Prog, off, cnt int64, _ *uint32) *obj.Prog
 		return p
 	}
 
-	if cnt <= int64(128*types.PtrSize) {
+	if cnt <= int64(128*types.PtrSize) && !buildcfg.GORISCV64.Compressed {
 		p = pp.Append(p, riscv.AADDI, obj.TYPE_CONST, 0, off, obj.TYPE_REG, riscv.REG_X25, 0)
 		p.Reg = riscv.REG_SP
 		p = pp.Append(p, obj.ADUFFZERO, obj.TYPE_NONE, 0, 0, obj.TYPE_MEM, 0, 0)
@@ -6,6 +6,7 @@ package ssa
 
 import (
 	"compile/cmd_internal/src"
+	"compile/internal/base"
 	"compile/src_internal/buildcfg"
 	"fmt"
 	"hash/crc32"
@@ -71,6 +72,9 @@ func Compile(f *Func) {
 		if !f.Config.optimize && !p.required || p.disabled {
 			continue
 		}
+		if p.stepping && base.Flag.OptDebug {
+			continue
+		}
 		f.pass = &p
 		phaseName = p.name
 		if f.Log() {
@@ -96,6 +100,17 @@ func Compile(f *Func) {
 		tStart := time.Now()
 		p.fn(f)
 		tEnd := time.Now()
+		if base.CompileTraceEnabled() {
+			nValues := 0
+			for _, b := range f.Blocks {
+				nValues += len(b.Values)
+			}
+			base.TraceSpan(f.Worker, "pass", p.name, tStart, tEnd, map[string]interface{}{
+				"func":   f.Name,
+				"blocks": len(f.Blocks),
+				"values": nValues,
+			})
+		}
 
 		// Need something less crude than "Log the whole intermediate result".
 		if f.Log() || f.HTMLWriter != nil {
@@ -202,6 +217,7 @@ type pass struct {
 	fn       func(*Func)
 	required bool
 	disabled bool
+	stepping bool            // pass can break stepping or drop user variables; skipped under -optdebug
 	time     bool            // report time to run pass
 	mem      bool            // report mem stats to run pass
 	stats    int             // pass reports own "stats" (e.g., branches removed)
@@ -476,14 +492,14 @@ var passes = [...]pass{
 	{name: "decompose builtin", fn: postExpandCallsDecompose, required: true},
 	{name: "softfloat", fn: softfloat, required: true},
 	{name: "late opt", fn: opt, required: true}, // TODO: split required rules and optimizing rules
-	{name: "dead auto elim", fn: elimDeadAutosGeneric},
+	{name: "dead auto elim", fn: elimDeadAutosGeneric, stepping: true},
 	{name: "sccp", fn: sccp},
 	{name: "generic deadcode", fn: deadcode, required: true}, // remove dead stores, which otherwise mess up store chain
 	{name: "check bce", fn: checkbce},
-	{name: "branchelim", fn: branchelim},
+	{name: "branchelim", fn: branchelim, stepping: true},
 	{name: "late fuse", fn: fuseLate},
-	{name: "dse", fn: dse},
-	{name: "memcombine", fn: memcombine},
+	{name: "dse", fn: dse, stepping: true},
+	{name: "memcombine", fn: memcombine, stepping: true},
 	{name: "writebarrier", fn: writebarrier, required: true}, // expand write barrier ops
 	{name: "insert resched checks", fn: insertLoopReschedChecks,
 		disabled: !buildcfg.Experiment.PreemptibleLoops}, // insert resched checks in loops.
@@ -492,7 +508,7 @@ var passes = [...]pass{
 	{name: "late lower", fn: lateLower, required: true},
 	{name: "lowered deadcode for cse", fn: deadcode}, // deadcode immediately before CSE avoids CSE making dead values live again
 	{name: "lowered cse", fn: cse},
-	{name: "elim unread autos", fn: elimUnreadAutos},
+	{name: "elim unread autos", fn: elimUnreadAutos, stepping: true},
 	{name: "tighten tuple selectors", fn: tightenTupleSelectors, required: true},
 	{name: "lowered deadcode", fn: deadcode, required: true},
 	{name: "checkLower", fn: checkLower, required: true},
@@ -500,15 +516,15 @@ var passes = [...]pass{
 	{name: "late copyelim", fn: copyelim},
 	{name: "tighten", fn: tighten, required: true}, // move values closer to their uses
 	{name: "late deadcode", fn: deadcode},
-	{name: "critical", fn: critical, required: true}, // remove critical edges
-	{name: "phi tighten", fn: phiTighten},            // place rematerializable phi args near uses to reduce value lifetimes
+	{name: "critical", fn: critical, required: true},      // remove critical edges
+	{name: "phi tighten", fn: phiTighten, stepping: true}, // place rematerializable phi args near uses to reduce value lifetimes
 	{name: "likelyadjust", fn: likelyadjust},
 	{name: "layout", fn: layout, required: true},     // schedule blocks
 	{name: "schedule", fn: schedule, required: true}, // schedule values
 	{name: "late nilcheck", fn: nilcheckelim2},
 	{name: "flagalloc", fn: flagalloc, required: true}, // allocate flags register
 	{name: "regalloc", fn: regalloc, required: true},   // allocate int & float registers + stack slots
-	{name: "loop rotate", fn: loopRotate},
+	{name: "loop rotate", fn: loopRotate, stepping: true},
 	{name: "trim", fn: trim}, // remove empty blocks
 }
 
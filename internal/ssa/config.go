@@ -335,6 +335,9 @@ func NewConfig(arch string, types Types, ctxt *obj.Link, optimize, softfloat boo
 		c.floatParamRegs = paramFloatRegRISCV64
 		c.FPReg = framepointerRegRISCV64
 		c.hasGReg = true
+		// The Duff's device offsets assume fixed size instructions,
+		// which is not the case once compressed encodings are used.
+		c.noDuffDevice = buildcfg.GORISCV64.Compressed
 	case "wasm":
 		c.PtrSize = 8
 		c.RegSize = 8
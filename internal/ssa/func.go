@@ -23,6 +23,7 @@ import (
 type Func struct {
 	Config *Config     // architecture information
 	Cache  *Cache      // re-usable cache
+	Worker int         // backend worker compiling this function, for the compile trace
 	fe     Frontend    // frontend state associated with this Func, callbacks into compiler frontend
 	pass   *pass       // current pass information (name, options, etc.)
 	Name   string      // e.g. NewFunc or (*Func).NumBlocks (no package prefix)
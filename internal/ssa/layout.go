@@ -4,10 +4,18 @@
 
 package ssa
 
+import "compile/internal/base"
+
 // layout orders basic blocks in f with the goal of minimizing control flow instructions.
 // After this phase returns, the order of f.Blocks matters and is the order
 // in which those blocks will appear in the assembly output.
+//
+// With -optdebug the blocks keep the order in which they were built,
+// which follows the source, so stepping does not jump back and forth.
 func layout(f *Func) {
+	if base.Flag.OptDebug {
+		return
+	}
 	f.Blocks = layoutOrder(f)
 }
 
@@ -92,10 +92,17 @@ const (
 	blMin     = blDEFAULT
 	blCALL    = 1
 	blRET     = 2
-	blEXIT    = 3
+	blCOLD    = 3
+	blEXIT    = 4
 )
 
-var bllikelies = [4]string{"default", "call", "ret", "exit"}
+var bllikelies = [5]string{"default", "call", "ret", "cold", "exit"}
+
+// isColdCall reports whether v is a call to a function marked go:cold.
+func isColdCall(v *Value) bool {
+	aux, ok := v.Aux.(*AuxCall)
+	return ok && aux.Fn != nil && aux.Fn.IsCold()
+}
 
 func describePredictionAgrees(b *Block, prediction BranchPrediction) string {
 	s := ""
@@ -220,13 +227,19 @@ func likelyadjust(f *Func) {
 				}
 			}
 			// Look for calls in the block.  If there is one, make this block unlikely.
+			// A call to a cold function makes it more unlikely still.
 			for _, v := range b.Values {
 				if opcodeTable[v.Op].call {
 					local[b.ID] = blCALL
-					certain[b.ID] = max8(blCALL, certain[b.Succs[0].b.ID])
-					break
+					if isColdCall(v) {
+						local[b.ID] = blCOLD
+						break
+					}
 				}
 			}
+			if local[b.ID] != blDEFAULT {
+				certain[b.ID] = max8(local[b.ID], certain[b.Succs[0].b.ID])
+			}
 		}
 		if f.pass.debug > 2 {
 			f.Warnl(b.Pos, "BP: Block %s, local=%s, certain=%s", b, bllikelies[local[b.ID]-blMin], bllikelies[certain[b.ID]-blMin])
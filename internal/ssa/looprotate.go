@@ -4,6 +4,8 @@
 
 package ssa
 
+import "compile/internal/base"
+
 // loopRotate converts loops with a check-loop-condition-at-beginning
 // to loops with a check-loop-condition-at-end.
 // This helps loops avoid extra unnecessary jumps.
@@ -21,7 +23,12 @@ package ssa
 //	entry:
 //	  CMPQ ...
 //	  JLT loop
+//
+// Loops are left alone when optimizing for size.
 func loopRotate(f *Func) {
+	if base.Flag.OptSize {
+		return
+	}
 	loopnest := f.loopnest()
 	if loopnest.hasIrreducible {
 		return
@@ -329,6 +329,42 @@ func makeABIWrapper(f *ir.Func, wrapperABI obj.ABI) {
 	ir.CurFunc = savedcurfn
 }
 
+// TailCallBlocker returns a non-empty reason if a call from caller to
+// callee cannot be compiled as a tail call, which jumps to callee in
+// place of returning and lets it reuse caller's incoming argument area.
+func TailCallBlocker(caller, callee *ir.Func) string {
+	switch {
+	case base.Ctxt.Arch.Name == "wasm":
+		return "not supported on wasm"
+	case base.Ctxt.Arch.Name == "ppc64le" && base.Ctxt.Flag_dynlink:
+		// R2 must be restored after the call.
+		return "not supported on ppc64le with dynamic linking"
+	case !buildcfg.Experiment.RegabiArgs:
+		return "register ABI is disabled"
+	case caller.ABI != obj.ABIInternal || callee.ABI != obj.ABIInternal:
+		return fmt.Sprintf("%v is not ABIInternal", callee.Nname)
+	case callee.OClosure != nil || callee.Needctxt():
+		return fmt.Sprintf("%v is a closure", callee.Nname)
+	}
+
+	calleeInfo := ssaConfig.ABI1.ABIAnalyzeFuncType(callee.Type())
+	for _, p := range calleeInfo.InParams() {
+		if len(p.Registers) == 0 && p.Type.Size() != 0 {
+			return "stack-allocated arguments"
+		}
+	}
+	for _, p := range calleeInfo.OutParams() {
+		if len(p.Registers) == 0 && p.Type.Size() != 0 {
+			return "stack-allocated results"
+		}
+	}
+	callerInfo := ssaConfig.ABI1.ABIAnalyzeFuncType(caller.Type())
+	if calleeInfo.ArgWidth() > callerInfo.ArgWidth() {
+		return fmt.Sprintf("%v needs a larger argument area than %v", callee.Nname, caller.Nname)
+	}
+	return ""
+}
+
 // CreateWasmImportWrapper creates a wrapper for imported WASM functions to
 // adapt them to the Go calling convention. The body for this function is
 // generated in cmd/cmd_internal/obj/wasm/wasmobj.go
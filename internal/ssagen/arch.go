@@ -48,4 +48,8 @@ type ArchInfo struct {
 
 	// SpillArgReg emits instructions that spill reg to n+off.
 	SpillArgReg func(pp *objw.Progs, p *obj.Prog, f *ssa.Func, t *types.Type, reg int16, n *ir.Name, off int64) *obj.Prog
+
+	// CFIHashOffset returns the offset from a function's entry of
+	// its i'th signature hash, for -cfi. Nil if -cfi is unsupported.
+	CFIHashOffset func(ctxt *obj.Link, i int) int64
 }
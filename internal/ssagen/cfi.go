@@ -0,0 +1,95 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssagen
+
+import (
+	"compile/cmd_internal/obj"
+	"compile/internal/base"
+	"compile/internal/ir"
+	"compile/internal/ssa"
+	"compile/internal/typecheck"
+	"compile/internal/types"
+)
+
+// Control-flow integrity checks for indirect calls (-cfi).
+//
+// Every function that may be the target of an indirect call carries
+// hashes of its signature at its entry (see obj.FuncInfo.CFIHashes),
+// and every indirect call checks the hash of its target before
+// jumping to it.
+//
+// The first hash is of the function's type with any receiver as the
+// first parameter. This is what calls through a func value expect,
+// since a method expression refers to the method itself. Methods
+// carry a second hash of their type without the receiver, which is
+// what calls through an itab expect, as the receiver's type is not
+// known at the call site.
+const (
+	cfiFuncSlot   = 0 // checked by calls through func values
+	cfiMethodSlot = 1 // checked by interface method calls
+)
+
+// cfiHash returns the signature hash of a function of type sig, with
+// recv (if non-nil) as its first parameter.
+func cfiHash(sig, recv *types.Type) uint32 {
+	return types.TypeHash(typecheck.NewMethodType(sig, recv))
+}
+
+// cfiHashes returns the signature hashes to place at fn's entry.
+func cfiHashes(fn *ir.Func) []uint32 {
+	sig := fn.Type()
+	if recv := sig.Recv(); recv != nil {
+		return []uint32{cfiHash(sig, recv.Type), cfiHash(sig, nil)}
+	}
+	return []uint32{cfiHash(sig, nil)}
+}
+
+// cfiPanicFunc declares the function that indirect calls call when
+// the hash of their target does not match, and returns its symbol.
+// The runtime has no such function, so each package compiled with
+// -cfi gets its own, which panics with a fixed message.
+func cfiPanicFunc() *obj.LSym {
+	pos := base.AutogeneratedPos
+	sym := typecheck.Lookup(".panicindirectcall")
+	fn := ir.NewFunc(pos, pos, sym, types.NewSignature(nil, nil, nil))
+	sym.Def = fn.Nname
+	fn.Pragma |= ir.Noinline
+
+	typecheck.DeclFunc(fn)
+	fn.Body.Append(ir.NewUnaryExpr(pos, ir.OPANIC, ir.NewString(pos, cfiPanicMessage)))
+	typecheck.FinishFuncBody()
+
+	ir.WithFunc(fn, func() {
+		typecheck.Stmts(fn.Body)
+	})
+	return fn.Linksym()
+}
+
+// cfiPanicMessage is the value that a failed check panics with.
+const cfiPanicMessage = "indirect call of function with wrong signature"
+
+// checkIndirectCall checks that the function at codeptr has the
+// signature hash of sig in the given slot, and panics if not.
+//
+// Calls whose signature involves shape types, as in the shaped bodies
+// of generic functions, are not checked. A shape type stands for all
+// the types with the same underlying type, so the signature does not
+// determine the hash of the function called.
+func (s *state) checkIndirectCall(codeptr *ssa.Value, slot int, sig *types.Type) {
+	if Arch.CFIHashOffset == nil {
+		s.Fatalf("-cfi not supported on %s", base.Ctxt.Arch.Name)
+	}
+	if sig.HasShape() {
+		return
+	}
+	want := cfiHash(sig, nil)
+	u32 := types.Types[types.TUINT32]
+	addr := s.newValue1I(ssa.OpOffPtr, u32.PtrTo(), Arch.CFIHashOffset(base.Ctxt, slot), codeptr)
+	// rawLoad because the load must not be instrumented; the call's
+	// arguments have already been set up.
+	got := s.rawLoad(u32, addr)
+	cmp := s.newValue2(ssa.OpEq32, types.Types[types.TBOOL], got, s.constInt32(u32, int32(want)))
+	s.check(cmp, ir.Syms.Panicindirectcall)
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssagen
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"compile/internal/base"
+	"compile/internal/ir"
+	"compile/internal/ssa"
+)
+
+// A frameLayout describes the stack frame of a function,
+// as reported by -d=framelayout.
+type frameLayout struct {
+	Func         string      `json:"func"`
+	Pos          string      `json:"pos"`
+	FrameSize    int64       `json:"frameSize"`    // total frame size, locals + outgoing args
+	Locals       int64       `json:"locals"`       // size of the locals area
+	OutgoingArgs int64       `json:"outgoingArgs"` // size of the callee argument area
+	ArgSize      int64       `json:"argSize"`      // size of the function's own arguments and results
+	Slots        []frameSlot `json:"slots"`
+}
+
+// A frameSlot is a variable with a stack slot.
+type frameSlot struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Size   int64  `json:"size"`
+	Offset int64  `json:"offset"` // from the top of the locals area for autos and spills, from the start of the argument area otherwise
+	Kind   string `json:"kind"`   // "auto", "spill", "arg" or "result"
+}
+
+// newFrameLayout returns the frame layout of fn, once its frame has
+// been allocated and its code generated with the given frame size.
+func newFrameLayout(fn *ir.Func, f *ssa.Func, frame int64) *frameLayout {
+	locals := f.Frontend().(*ssafn).stksize
+	l := &frameLayout{
+		Func:         ir.FuncName(fn),
+		Pos:          base.FmtPos(fn.Pos()),
+		FrameSize:    frame,
+		Locals:       locals,
+		OutgoingArgs: frame - locals,
+		ArgSize:      f.OwnAux.ArgWidth(),
+		Slots:        []frameSlot{},
+	}
+
+	// Values placed in a stack slot by register allocation are spills.
+	spilled := make(map[*ir.Name]bool)
+	for _, loc := range f.RegAlloc {
+		if ls, ok := loc.(ssa.LocalSlot); ok {
+			spilled[ls.N] = true
+		}
+	}
+
+	for _, n := range fn.Dcl {
+		var kind string
+		switch n.Class {
+		case ir.PAUTO:
+			kind = "auto"
+			if spilled[n] {
+				kind = "spill"
+			}
+		case ir.PPARAM:
+			kind = "arg"
+		case ir.PPARAMOUT:
+			kind = "result"
+			if n.IsOutputParamInRegisters() {
+				// allocated like an auto
+				kind = "spill"
+			}
+		default:
+			continue
+		}
+		l.Slots = append(l.Slots, frameSlot{
+			Name:   n.Sym().Name,
+			Type:   n.Type().String(),
+			Size:   n.Type().Size(),
+			Offset: n.FrameOffset(),
+			Kind:   kind,
+		})
+	}
+	return l
+}
+
+// String returns the frame layout as printed by -d=framelayout=1.
+func (l *frameLayout) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "frame layout of %s: %d bytes (%d locals, %d outgoing args), %d bytes args",
+		l.Func, l.FrameSize, l.Locals, l.OutgoingArgs, l.ArgSize)
+	for _, s := range l.Slots {
+		fmt.Fprintf(&b, "\n\t%-6s %s %s size=%d offset=%d", s.Kind, s.Name, s.Type, s.Size, s.Offset)
+	}
+	return b.String()
+}
+
+// reportFrame reports the frame layout of fn for -d=framelayout,
+// and warns if its frame is larger than -d=framesizewarn.
+func reportFrame(fn *ir.Func, f *ssa.Func, frame int64) {
+	if base.Debug.FrameLayout != 0 {
+		l := newFrameLayout(fn, f, frame)
+		switch base.Debug.FrameLayout {
+		case 1:
+			base.WarnfAt(fn.Pos(), "%s", l)
+		default:
+			b, err := json.Marshal(l)
+			if err != nil {
+				base.Fatalf("marshaling frame layout: %v", err)
+			}
+			fmt.Fprintf(base.Ctxt.Bso, "%s\n", b)
+		}
+	}
+	if limit := int64(base.Debug.FrameSizeWarn); limit > 0 && frame > limit {
+		base.WarnfAt(fn.Pos(), "stack frame of %s is %d bytes, larger than %d", ir.FuncName(fn), frame, limit)
+	}
+}
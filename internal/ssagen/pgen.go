@@ -10,6 +10,7 @@ import (
 	"os"
 	"sort"
 	"sync"
+	"time"
 
 	"compile/cmd_internal/obj"
 	"compile/cmd_internal/objabi"
@@ -213,9 +214,15 @@ const maxStackSize = 1 << 30
 // and flushes that plist to machine code.
 // worker indicates which of the backend workers is doing the processing.
 func Compile(fn *ir.Func, worker int) {
+	if base.CompileTraceEnabled() {
+		defer traceCompile(fn, worker, time.Now(), irSize(fn))
+	}
 	f := buildssa(fn, worker)
 	// Note: check arg size to fix issue 25507.
 	if f.Frontend().(*ssafn).stksize >= maxStackSize || f.OwnAux.ArgWidth() >= maxStackSize {
+		// No code is generated, so the frame is reported as just
+		// its locals.
+		reportFrame(fn, f, f.Frontend().(*ssafn).stksize)
 		largeStackFramesMu.Lock()
 		largeStackFrames = append(largeStackFrames, largeStack{locals: f.Frontend().(*ssafn).stksize, args: f.OwnAux.ArgWidth(), pos: fn.Pos()})
 		largeStackFramesMu.Unlock()
@@ -224,12 +231,16 @@ func Compile(fn *ir.Func, worker int) {
 	pp := objw.NewProgs(fn, worker)
 	defer pp.Free()
 	genssa(f, pp)
+	if base.Ctxt.CFI {
+		fn.LSym.Func().CFIHashes = cfiHashes(fn)
+	}
 	// Check frame size again.
 	// The check above included only the space needed for local variables.
 	// After genssa, the space needed includes local variables and the callee arg region.
 	// We must do this check prior to calling pp.Flush.
 	// If there are any oversized stack frames,
 	// the assembler may emit inscrutable complaints about invalid instructions.
+	reportFrame(fn, f, pp.Text.To.Offset)
 	if pp.Text.To.Offset >= maxStackSize {
 		largeStackFramesMu.Lock()
 		locals := f.Frontend().(*ssafn).stksize
@@ -251,6 +262,23 @@ func Compile(fn *ir.Func, worker int) {
 	fieldtrack(pp.Text.From.Sym, fn.FieldTrack)
 }
 
+// irSize returns the number of IR nodes in fn's body.
+func irSize(fn *ir.Func) int {
+	n := 0
+	ir.VisitList(fn.Body, func(ir.Node) { n++ })
+	return n
+}
+
+// traceCompile records the compilation of fn, which started at start,
+// in the compile trace.
+func traceCompile(fn *ir.Func, worker int, start time.Time, irNodes int) {
+	args := map[string]interface{}{"irNodes": irNodes}
+	if fn.LSym != nil {
+		args["text"] = fn.LSym.Size
+	}
+	base.TraceSpan(worker, "func", ir.FuncName(fn), start, time.Now(), args)
+}
+
 // globalMapInitLsyms records the LSym of each map.init.NNN outlined
 // map initializer function created by the compiler.
 var globalMapInitLsyms map[*obj.LSym]struct{}
@@ -68,6 +68,10 @@ func InitEnv() {
 	}
 }
 
+// loopDeferSlotsTypes[n] is the type of the closure slots of an
+// open-coded defer in a loop that records n executions.
+var loopDeferSlotsTypes [9]*types.Type
+
 func InitConfig() {
 	types_ := ssa.NewTypes()
 
@@ -89,6 +93,11 @@ func InitConfig() {
 	_ = types.NewPtr(types.ErrorType)                                       // *error
 	_ = types.NewPtr(reflectdata.MapType())                                 // *runtime.hmap
 	_ = types.NewPtr(deferstruct())                                         // *runtime._defer
+	for n := 1; n < len(loopDeferSlotsTypes); n++ {
+		t := types.NewArray(types.Types[types.TUNSAFEPTR], int64(n))
+		types.CalcSize(t)
+		loopDeferSlotsTypes[n] = t
+	}
 	types.NewPtrCacheEnabled = false
 	ssaConfig = ssa.NewConfig(base.Ctxt.Arch.Name, *types_, base.Ctxt, base.Flag.N == 0, Arch.SoftFloat)
 	ssaConfig.Race = base.Flag.Race
@@ -133,6 +142,9 @@ func InitConfig() {
 	ir.Syms.Panicnildottype = typecheck.LookupRuntimeFunc("panicnildottype")
 	ir.Syms.Panicoverflow = typecheck.LookupRuntimeFunc("panicoverflow")
 	ir.Syms.Panicshift = typecheck.LookupRuntimeFunc("panicshift")
+	if base.Ctxt.CFI {
+		ir.Syms.Panicindirectcall = cfiPanicFunc()
+	}
 	ir.Syms.Racefuncenter = typecheck.LookupRuntimeFunc("racefuncenter")
 	ir.Syms.Racefuncexit = typecheck.LookupRuntimeFunc("racefuncexit")
 	ir.Syms.Raceread = typecheck.LookupRuntimeFunc("raceread")
@@ -271,11 +283,11 @@ func (s *state) emitOpenDeferInfo() {
 	firstOffset := s.openDefers[0].closureNode.FrameOffset()
 
 	// Verify that cmpstackvarlt laid out the slots in order.
-	for i, r := range s.openDefers {
+	for _, r := range s.openDefers {
 		have := r.closureNode.FrameOffset()
-		want := firstOffset + int64(i)*int64(types.PtrSize)
+		want := firstOffset + int64(r.bit)*int64(types.PtrSize)
 		if have != want {
-			base.FatalfAt(s.curfn.Pos(), "unexpected frame offset for open-coded defer slot #%v: have %v, want %v", i, have, want)
+			base.FatalfAt(s.curfn.Pos(), "unexpected frame offset for open-coded defer slot #%v: have %v, want %v", r.bit, have, want)
 		}
 	}
 
@@ -354,6 +366,7 @@ func buildssa(fn *ir.Func, worker int) *ssa.Func {
 	cache.Reset()
 
 	s.f = ssaConfig.NewFunc(&fe, cache)
+	s.f.Worker = worker
 	s.config = ssaConfig
 	s.f.Type = fn.Type()
 	s.f.Name = name
@@ -417,8 +430,13 @@ func buildssa(fn *ir.Func, worker int) *ssa.Func {
 			}
 		}
 	}
+	maxOpenDefers := int32(15)
+	if base.Flag.OptSize {
+		// Each open-coded defer is repeated at every exit.
+		maxOpenDefers = 1
+	}
 	if s.hasOpenDefers &&
-		s.curfn.NumReturns*s.curfn.NumDefers > 15 {
+		s.curfn.NumReturns*s.curfn.NumDefers > maxOpenDefers {
 		// Since we are generating defer calls at every exit for
 		// open-coded defers, skip doing open-coded defers if there are
 		// too many returns (especially if there are multiple defers).
@@ -426,6 +444,11 @@ func buildssa(fn *ir.Func, worker int) *ssa.Func {
 		// for smaller functions (which don't have many returns).
 		s.hasOpenDefers = false
 	}
+	if s.hasOpenDefers && s.curfn.OpenCodedLoopDefer() {
+		// The defer in a loop gets the deferBits bits not used
+		// by the other defers.
+		s.loopDeferSlots = 8 - int(s.curfn.NumDefers-1)
+	}
 
 	s.sp = s.entryNewValue0(ssa.OpSP, types.Types[types.TUINTPTR]) // TODO: use generic pointer type (unsafe.Pointer?) instead
 	s.sb = s.entryNewValue0(ssa.OpSB, types.Types[types.TUINTPTR])
@@ -450,6 +473,9 @@ func buildssa(fn *ir.Func, worker int) *ssa.Func {
 		// eliminated, because the defer statements were all
 		// unconditional.
 		s.vars[memVar] = s.newValue1Apos(ssa.OpVarLive, types.TypeMem, deferBitsTemp, s.mem(), false)
+		if s.loopDeferSlots > 0 {
+			s.vars[loopDeferCountVar] = s.constInt(types.Types[types.TINT], 0)
+		}
 	}
 
 	var params *abi.ABIParamResultInfo
@@ -834,6 +860,13 @@ type openDeferInfo struct {
 	// function, method, or interface call, to store a closure that panic
 	// processing can use for this defer.
 	closureNode *ir.Name
+	// The index of the defer's bit in deferBits, which is also the index
+	// of its closure slot among those of all open-coded defers.
+	bit int
+	// For a defer in a loop, the number of its executions that can be
+	// recorded at once. closureNode is then an array of that many
+	// closure slots, which use consecutive bits starting at bit.
+	slots int
 }
 
 type state struct {
@@ -901,6 +934,9 @@ type state struct {
 	// scanning order. Hence, at exit we should run these defers in reverse
 	// order of this list
 	openDefers []*openDeferInfo
+	// If doing open-coded defers and there is a defer in a loop, the
+	// number of its executions recorded in open-coded defer slots.
+	loopDeferSlots int
 	// For open-coded defers, this is the beginning and end blocks of the last
 	// defer exit code that we have generated so far. We use these to share
 	// code between exits if the shareDeferExits option (disabled by default)
@@ -957,7 +993,10 @@ var (
 	typVar       = ssaMarker("typ")
 	okVar        = ssaMarker("ok")
 	deferBitsVar = ssaMarker("deferBits")
-	hashVar      = ssaMarker("hash")
+
+	loopDeferCountVar = ssaMarker("loopDeferCount")
+	loopDeferIndexVar = ssaMarker("loopDeferIndex")
+	hashVar           = ssaMarker("hash")
 )
 
 // startBlock sets the current block we're generating code in to b.
@@ -1485,7 +1524,7 @@ func (s *state) stmt(n ir.Node) {
 			base.WarnfAt(n.Pos(), "%s defer", defertype)
 		}
 		if s.hasOpenDefers {
-			s.openDeferRecord(n.Call.(*ir.CallExpr))
+			s.openDeferRecord(n.Call.(*ir.CallExpr), n.Esc() != ir.EscNever)
 		} else {
 			d := callDefer
 			if n.Esc() == ir.EscNever && n.DeferAt == nil {
@@ -2006,7 +2045,7 @@ func (s *state) stmt(n ir.Node) {
 
 		// Check the cache first.
 		var merge *ssa.Block
-		if base.Flag.N == 0 && rtabi.UseInterfaceSwitchCache(Arch.LinkArch.Name) {
+		if useInterfaceSwitchCache() {
 			// Note: we can only use the cache if we have the right atomic load instruction.
 			// Double-check that here.
 			if _, ok := intrinsics[intrinsicKey{Arch.LinkArch.Arch, "runtime/cmd_internal/atomic", "Loadp"}]; !ok {
@@ -5132,34 +5171,196 @@ func (s *state) intrinsicArgs(n *ir.CallExpr) []*ssa.Value {
 // exit paths. n is the sub-node of the defer node that is the actual function
 // call. We will also record funcdata information on where the function is stored
 // (as well as the deferBits variable), and this will enable us to run the proper
-// defer calls during panics.
-func (s *state) openDeferRecord(n *ir.CallExpr) {
+// defer calls during panics. loop reports whether the defer is in a loop.
+func (s *state) openDeferRecord(n *ir.CallExpr, loop bool) {
 	if len(n.Args) != 0 || n.Op() != ir.OCALLFUNC || n.Fun.Type().NumResults() != 0 {
 		s.Fatalf("defer call with arguments or results: %v", n)
 	}
 
 	opendefer := &openDeferInfo{
-		n: n,
+		n:   n,
+		bit: s.nextOpenDeferBit(),
 	}
 	fn := n.Fun
 	// We must always store the function value in a stack slot for the
 	// runtime panic code to use. But in the defer exit code, we will
 	// call the function directly if it is a static function.
 	closureVal := s.expr(fn)
+	if loop {
+		s.openDeferRecordLoop(opendefer, closureVal)
+		return
+	}
 	closure := s.openDeferSave(fn.Type(), closureVal)
 	opendefer.closureNode = closure.Aux.(*ir.Name)
 	if !(fn.Op() == ir.ONAME && fn.(*ir.Name).Class == ir.PFUNC) {
 		opendefer.closure = closure
 	}
-	index := len(s.openDefers)
 	s.openDefers = append(s.openDefers, opendefer)
 
 	// Update deferBits only after evaluation and storage to stack of
 	// the function is successful.
-	bitvalue := s.constInt8(types.Types[types.TUINT8], 1<<uint(index))
+	bitvalue := s.constInt8(types.Types[types.TUINT8], 1<<uint(opendefer.bit))
+	newDeferBits := s.newValue2(ssa.OpOr8, types.Types[types.TUINT8], s.variable(deferBitsVar, types.Types[types.TUINT8]), bitvalue)
+	s.vars[deferBitsVar] = newDeferBits
+	s.store(types.Types[types.TUINT8], s.deferBitsAddr, newDeferBits)
+}
+
+// openDeferRecordLoop records an execution of the open-coded defer r,
+// which is in a loop and calls closureVal.
+//
+// The first s.loopDeferSlots executions are stored in consecutive
+// closure slots, each with its own bit in deferBits, so they run in
+// reverse order at exit or during a panic like other open-coded defers.
+// Once the slots are full, all the defers recorded in deferBits are moved
+// onto the runtime's defer chain in the order they executed, and this and
+// later executions of r are added to it as well. Since r is the only
+// defer in a loop, the defers recorded in deferBits after that executed
+// later, and run first; deferreturn then runs those on the chain.
+func (s *state) openDeferRecordLoop(r *openDeferInfo, closureVal *ssa.Value) {
+	r.slots = s.loopDeferSlots
+	slots := s.openDeferSlot(closureVal.Pos, loopDeferSlotsTypes[r.slots])
+	r.closureNode = slots.Aux.(*ir.Name)
+	r.closure = slots
+	s.openDefers = append(s.openDefers, r)
+
+	count := s.variable(loopDeferCountVar, types.Types[types.TINT])
+	nslots := s.constInt(types.Types[types.TINT], int64(r.slots))
+	bRecord := s.f.NewBlock(ssa.BlockPlain)
+	bFull := s.f.NewBlock(ssa.BlockPlain)
+	bSpill := s.f.NewBlock(ssa.BlockPlain)
+	bChain := s.f.NewBlock(ssa.BlockPlain)
+	bEnd := s.f.NewBlock(ssa.BlockPlain)
+
+	free := s.newValue2(s.ssaOp(ir.OLT, types.Types[types.TINT]), types.Types[types.TBOOL], count, nslots)
+	b := s.endBlock()
+	b.Kind = ssa.BlockIf
+	b.SetControl(free)
+	b.AddEdgeTo(bRecord)
+	b.AddEdgeTo(bFull)
+	b.Likely = ssa.BranchLikely
+
+	// Store the closure in the next free slot, then set its bit.
+	s.startBlock(bRecord)
+	addr := s.newValue2(ssa.OpPtrIndex, types.NewPtr(closureVal.Type), slots, count)
+	s.store(closureVal.Type, addr, closureVal)
+	bitvalue := s.newValue2(s.ssaShiftOp(ir.OLSH, types.Types[types.TUINT8], types.Types[types.TUINT]), types.Types[types.TUINT8], s.constInt8(types.Types[types.TUINT8], 1<<uint(r.bit)), count)
 	newDeferBits := s.newValue2(ssa.OpOr8, types.Types[types.TUINT8], s.variable(deferBitsVar, types.Types[types.TUINT8]), bitvalue)
 	s.vars[deferBitsVar] = newDeferBits
 	s.store(types.Types[types.TUINT8], s.deferBitsAddr, newDeferBits)
+	s.vars[loopDeferCountVar] = s.newValue2(s.ssaOp(ir.OADD, types.Types[types.TINT]), types.Types[types.TINT], count, s.constInt(types.Types[types.TINT], 1))
+	s.endBlock().AddEdgeTo(bEnd)
+
+	// The first time the slots are found full, spill them.
+	s.startBlock(bFull)
+	full := s.newValue2(s.ssaOp(ir.OEQ, types.Types[types.TINT]), types.Types[types.TBOOL], count, nslots)
+	b = s.endBlock()
+	b.Kind = ssa.BlockIf
+	b.SetControl(full)
+	b.AddEdgeTo(bSpill)
+	b.AddEdgeTo(bChain)
+
+	s.startBlock(bSpill)
+	s.vars[loopDeferCountVar] = s.constInt(types.Types[types.TINT], int64(r.slots+1))
+	s.openDeferSpill(r)
+	s.endBlock().AddEdgeTo(bChain)
+
+	s.startBlock(bChain)
+	s.deferClosure(closureVal)
+	s.endBlock().AddEdgeTo(bEnd)
+
+	s.startBlock(bEnd)
+}
+
+// openDeferSpill moves the defers recorded in deferBits onto the
+// runtime's defer chain, oldest first, and clears deferBits. loop is the
+// open-coded defer in a loop, whose slots are all in use; the defers
+// after it have not executed yet.
+func (s *state) openDeferSpill(loop *openDeferInfo) {
+	for _, r := range s.openDefers {
+		if r == loop {
+			break
+		}
+		bSpill := s.f.NewBlock(ssa.BlockPlain)
+		bEnd := s.f.NewBlock(ssa.BlockPlain)
+
+		deferBits := s.variable(deferBitsVar, types.Types[types.TUINT8])
+		bitval := s.constInt8(types.Types[types.TUINT8], 1<<uint(r.bit))
+		andval := s.newValue2(ssa.OpAnd8, types.Types[types.TUINT8], deferBits, bitval)
+		eqVal := s.newValue2(ssa.OpEq8, types.Types[types.TBOOL], andval, s.constInt8(types.Types[types.TUINT8], 0))
+		b := s.endBlock()
+		b.Kind = ssa.BlockIf
+		b.SetControl(eqVal)
+		b.AddEdgeTo(bEnd)
+		b.AddEdgeTo(bSpill)
+
+		s.startBlock(bSpill)
+		addr := s.newValue2Apos(ssa.OpLocalAddr, types.NewPtr(r.closureNode.Type()), r.closureNode, s.sp, s.mem(), false)
+		s.deferClosure(s.load(r.closureNode.Type(), addr))
+		s.endBlock().AddEdgeTo(bEnd)
+		s.startBlock(bEnd)
+	}
+
+	bHead := s.f.NewBlock(ssa.BlockPlain)
+	bBody := s.f.NewBlock(ssa.BlockPlain)
+	bEnd := s.f.NewBlock(ssa.BlockPlain)
+
+	s.vars[loopDeferIndexVar] = s.constInt(types.Types[types.TINT], 0)
+	s.endBlock().AddEdgeTo(bHead)
+
+	s.startBlock(bHead)
+	i := s.variable(loopDeferIndexVar, types.Types[types.TINT])
+	more := s.newValue2(s.ssaOp(ir.OLT, types.Types[types.TINT]), types.Types[types.TBOOL], i, s.constInt(types.Types[types.TINT], int64(loop.slots)))
+	b := s.endBlock()
+	b.Kind = ssa.BlockIf
+	b.SetControl(more)
+	b.AddEdgeTo(bBody)
+	b.AddEdgeTo(bEnd)
+
+	s.startBlock(bBody)
+	ft := loop.n.Fun.Type()
+	s.deferClosure(s.load(ft, s.newValue2(ssa.OpPtrIndex, types.NewPtr(ft), loop.closure, i)))
+	s.vars[loopDeferIndexVar] = s.newValue2(s.ssaOp(ir.OADD, types.Types[types.TINT]), types.Types[types.TINT], i, s.constInt(types.Types[types.TINT], 1))
+	s.endBlock().AddEdgeTo(bHead)
+
+	s.startBlock(bEnd)
+	zeroval := s.constInt8(types.Types[types.TUINT8], 0)
+	s.vars[deferBitsVar] = zeroval
+	s.store(types.Types[types.TUINT8], s.deferBitsAddr, zeroval)
+}
+
+// deferClosure adds closure to the runtime's defer chain for the
+// current frame, as a defer statement does when not open-coded.
+func (s *state) deferClosure(closure *ssa.Value) {
+	aux := ssa.StaticAuxCall(ir.Syms.Deferproc, s.f.ABIDefault.ABIAnalyzeTypes([]*types.Type{types.Types[types.TUINTPTR]}, nil))
+	call := s.newValue0A(ssa.OpStaticLECall, aux.LateExpansionResultType(), aux)
+	call.AddArgs(closure, s.mem())
+	call.AuxInt = int64(types.PtrSize)
+	s.vars[memVar] = s.newValue1I(ssa.OpSelectN, types.TypeMem, 0, call)
+
+	b := s.endBlock()
+	b.Kind = ssa.BlockDefer
+	b.SetControl(call)
+	bNext := s.f.NewBlock(ssa.BlockPlain)
+	b.AddEdgeTo(bNext)
+	// Add recover edge to exit code. The panic may have run some of
+	// the open-coded defers, so reload deferBits.
+	r := s.f.NewBlock(ssa.BlockPlain)
+	s.startBlock(r)
+	s.vars[deferBitsVar] = s.load(types.Types[types.TUINT8], s.deferBitsAddr)
+	s.exit()
+	b.AddEdgeTo(r)
+	b.Likely = ssa.BranchLikely
+	s.startBlock(bNext)
+}
+
+// nextOpenDeferBit returns the index of the deferBits bit, and of the
+// closure slot, for the next open-coded defer.
+func (s *state) nextOpenDeferBit() int {
+	if len(s.openDefers) == 0 {
+		return 0
+	}
+	r := s.openDefers[len(s.openDefers)-1]
+	return r.bit + max(r.slots, 1)
 }
 
 // openDeferSave generates SSA nodes to store a value (with type t) for an
@@ -5174,10 +5375,21 @@ func (s *state) openDeferSave(t *types.Type, val *ssa.Value) *ssa.Value {
 	if !t.HasPointers() {
 		s.Fatalf("openDeferSave of pointerless type %v val=%v", t, val)
 	}
-	pos := val.Pos
+	addrTemp := s.openDeferSlot(val.Pos, t)
+	// We are storing to the stack, hence we can avoid the full checks in
+	// storeType() (no write barrier) and do a simple store().
+	s.store(t, addrTemp, val)
+	return addrTemp
+}
+
+// openDeferSlot generates SSA nodes to allocate the autotmp location on
+// the stack, with type t, where the next open-coded defer stores its
+// closures. The function returns an SSA value representing a pointer to
+// the autotmp location.
+func (s *state) openDeferSlot(pos src.XPos, t *types.Type) *ssa.Value {
 	temp := typecheck.TempAt(pos.WithNotStmt(), s.curfn, t)
 	temp.SetOpenDeferSlot(true)
-	temp.SetFrameOffset(int64(len(s.openDefers))) // so cmpstackvarlt can order them
+	temp.SetFrameOffset(int64(s.nextOpenDeferBit())) // so cmpstackvarlt can order them
 	var addrTemp *ssa.Value
 	// Use OpVarLive to make sure stack slot for the closure is not removed by
 	// dead-store elimination
@@ -5206,9 +5418,6 @@ func (s *state) openDeferSave(t *types.Type, val *ssa.Value) *ssa.Value {
 	// block if it contains pointers, else GC may wrongly follow an
 	// uninitialized pointer value.
 	temp.SetNeedzero(true)
-	// We are storing to the stack, hence we can avoid the full checks in
-	// storeType() (no write barrier) and do a simple store().
-	s.store(t, addrTemp, val)
 	return addrTemp
 }
 
@@ -5223,16 +5432,22 @@ func (s *state) openDeferExit() {
 	s.lastDeferExit = deferExit
 	s.lastDeferCount = len(s.openDefers)
 	zeroval := s.constInt8(types.Types[types.TUINT8], 0)
+	var loop *openDeferInfo
 	// Test for and run defers in reverse order
 	for i := len(s.openDefers) - 1; i >= 0; i-- {
 		r := s.openDefers[i]
+		if r.slots > 0 {
+			loop = r
+			s.openDeferExitLoop(r)
+			continue
+		}
 		bCond := s.f.NewBlock(ssa.BlockPlain)
 		bEnd := s.f.NewBlock(ssa.BlockPlain)
 
 		deferBits := s.variable(deferBitsVar, types.Types[types.TUINT8])
 		// Generate code to check if the bit associated with the current
 		// defer is set.
-		bitval := s.constInt8(types.Types[types.TUINT8], 1<<uint(i))
+		bitval := s.constInt8(types.Types[types.TUINT8], 1<<uint(r.bit))
 		andval := s.newValue2(ssa.OpAnd8, types.Types[types.TUINT8], deferBits, bitval)
 		eqVal := s.newValue2(ssa.OpEq8, types.Types[types.TBOOL], andval, zeroval)
 		b := s.endBlock()
@@ -5252,38 +5467,119 @@ func (s *state) openDeferExit() {
 		// bits cleared.
 		s.vars[deferBitsVar] = maskedval
 
-		// Generate code to call the function call of the defer, using the
-		// closure that were stored in argtmps at the point of the defer
-		// statement.
-		fn := r.n.Fun
-		stksize := fn.Type().ArgWidth()
-		var callArgs []*ssa.Value
-		var call *ssa.Value
-		if r.closure != nil {
-			v := s.load(r.closure.Type.Elem(), r.closure)
-			s.maybeNilCheckClosure(v, callDefer)
-			codeptr := s.rawLoad(types.Types[types.TUINTPTR], v)
-			aux := ssa.ClosureAuxCall(s.f.ABIDefault.ABIAnalyzeTypes(nil, nil))
-			call = s.newValue2A(ssa.OpClosureLECall, aux.LateExpansionResultType(), aux, codeptr, v)
-		} else {
-			aux := ssa.StaticAuxCall(fn.(*ir.Name).Linksym(), s.f.ABIDefault.ABIAnalyzeTypes(nil, nil))
-			call = s.newValue0A(ssa.OpStaticLECall, aux.LateExpansionResultType(), aux)
-		}
-		callArgs = append(callArgs, s.mem())
-		call.AddArgs(callArgs...)
-		call.AuxInt = stksize
-		s.vars[memVar] = s.newValue1I(ssa.OpSelectN, types.TypeMem, 0, call)
-		// Make sure that the stack slots with pointers are kept live
-		// through the call (which is a pre-emption point). Also, we will
-		// use the first call of the last defer exit to compute liveness
-		// for the deferreturn, so we want all stack slots to be live.
-		if r.closureNode != nil {
-			s.vars[memVar] = s.newValue1Apos(ssa.OpVarLive, types.TypeMem, r.closureNode, s.mem(), false)
-		}
+		s.openDeferCall(r, r.closure)
 
 		s.endBlock()
 		s.startBlock(bEnd)
 	}
+
+	if loop != nil {
+		// If the loop overflowed its slots, run the defers that
+		// were moved onto the defer chain and return.
+		//
+		// The linker takes the first call to deferreturn in the
+		// function to be the one in the stub that genssa emits at
+		// its end, where a recovered panic resumes. So rather than
+		// calling deferreturn here, genssa makes this call a jump
+		// to the stub, which calls deferreturn, loads the results
+		// and returns, as an exit would. The results are already
+		// in memory, since they are not SSA'd in a function with
+		// defers (see canSSAName).
+		bChain := s.f.NewBlock(ssa.BlockPlain)
+		bEnd := s.f.NewBlock(ssa.BlockPlain)
+		count := s.variable(loopDeferCountVar, types.Types[types.TINT])
+		spilled := s.newValue2(s.ssaOp(ir.OLT, types.Types[types.TINT]), types.Types[types.TBOOL], s.constInt(types.Types[types.TINT], int64(loop.slots)), count)
+		b := s.endBlock()
+		b.Kind = ssa.BlockIf
+		b.SetControl(spilled)
+		b.AddEdgeTo(bChain)
+		b.AddEdgeTo(bEnd)
+		b.Likely = ssa.BranchUnlikely
+
+		s.startBlock(bChain)
+		s.rtcall(ir.Syms.Deferreturn, false, nil)
+		s.startBlock(bEnd)
+	}
+}
+
+// openDeferExitLoop generates SSA for running the executions of the
+// open-coded defer r in a loop that are recorded in its slots, most
+// recent first.
+func (s *state) openDeferExitLoop(r *openDeferInfo) {
+	bHead := s.f.NewBlock(ssa.BlockPlain)
+	bTest := s.f.NewBlock(ssa.BlockPlain)
+	bCall := s.f.NewBlock(ssa.BlockPlain)
+	bEnd := s.f.NewBlock(ssa.BlockPlain)
+
+	s.vars[loopDeferIndexVar] = s.constInt(types.Types[types.TINT], int64(r.slots))
+	s.endBlock().AddEdgeTo(bHead)
+
+	s.startBlock(bHead)
+	i := s.variable(loopDeferIndexVar, types.Types[types.TINT])
+	more := s.newValue2(s.ssaOp(ir.OLT, types.Types[types.TINT]), types.Types[types.TBOOL], s.constInt(types.Types[types.TINT], 0), i)
+	b := s.endBlock()
+	b.Kind = ssa.BlockIf
+	b.SetControl(more)
+	b.AddEdgeTo(bTest)
+	b.AddEdgeTo(bEnd)
+
+	s.startBlock(bTest)
+	i = s.newValue2(s.ssaOp(ir.OSUB, types.Types[types.TINT]), types.Types[types.TINT], i, s.constInt(types.Types[types.TINT], 1))
+	s.vars[loopDeferIndexVar] = i
+	deferBits := s.variable(deferBitsVar, types.Types[types.TUINT8])
+	bitval := s.newValue2(s.ssaShiftOp(ir.OLSH, types.Types[types.TUINT8], types.Types[types.TUINT]), types.Types[types.TUINT8], s.constInt8(types.Types[types.TUINT8], 1<<uint(r.bit)), i)
+	andval := s.newValue2(ssa.OpAnd8, types.Types[types.TUINT8], deferBits, bitval)
+	eqVal := s.newValue2(ssa.OpEq8, types.Types[types.TBOOL], andval, s.constInt8(types.Types[types.TUINT8], 0))
+	b = s.endBlock()
+	b.Kind = ssa.BlockIf
+	b.SetControl(eqVal)
+	b.AddEdgeTo(bHead)
+	b.AddEdgeTo(bCall)
+
+	// Clear the bit before the call, as for other open-coded defers.
+	s.startBlock(bCall)
+	nbitval := s.newValue1(ssa.OpCom8, types.Types[types.TUINT8], bitval)
+	maskedval := s.newValue2(ssa.OpAnd8, types.Types[types.TUINT8], deferBits, nbitval)
+	s.store(types.Types[types.TUINT8], s.deferBitsAddr, maskedval)
+	s.vars[deferBitsVar] = maskedval
+	s.openDeferCall(r, s.newValue2(ssa.OpPtrIndex, types.NewPtr(r.n.Fun.Type()), r.closure, i))
+	s.endBlock().AddEdgeTo(bHead)
+
+	s.startBlock(bEnd)
+}
+
+// openDeferCall generates SSA for calling the function of the open-coded
+// defer r. closure is the address of the slot holding its closure, or nil
+// if r calls a static function.
+func (s *state) openDeferCall(r *openDeferInfo, closure *ssa.Value) {
+	// Generate code to call the function call of the defer, using the
+	// closure that were stored in argtmps at the point of the defer
+	// statement.
+	fn := r.n.Fun
+	stksize := fn.Type().ArgWidth()
+	var callArgs []*ssa.Value
+	var call *ssa.Value
+	if closure != nil {
+		v := s.load(closure.Type.Elem(), closure)
+		s.maybeNilCheckClosure(v, callDefer)
+		codeptr := s.rawLoad(types.Types[types.TUINTPTR], v)
+		aux := ssa.ClosureAuxCall(s.f.ABIDefault.ABIAnalyzeTypes(nil, nil))
+		call = s.newValue2A(ssa.OpClosureLECall, aux.LateExpansionResultType(), aux, codeptr, v)
+	} else {
+		aux := ssa.StaticAuxCall(fn.(*ir.Name).Linksym(), s.f.ABIDefault.ABIAnalyzeTypes(nil, nil))
+		call = s.newValue0A(ssa.OpStaticLECall, aux.LateExpansionResultType(), aux)
+	}
+	callArgs = append(callArgs, s.mem())
+	call.AddArgs(callArgs...)
+	call.AuxInt = stksize
+	s.vars[memVar] = s.newValue1I(ssa.OpSelectN, types.TypeMem, 0, call)
+	// Make sure that the stack slots with pointers are kept live
+	// through the call (which is a pre-emption point). Also, we will
+	// use the first call of the last defer exit to compute liveness
+	// for the deferreturn, so we want all stack slots to be live.
+	if r.closureNode != nil {
+		s.vars[memVar] = s.newValue1Apos(ssa.OpVarLive, types.TypeMem, r.closureNode, s.mem(), false)
+	}
 }
 
 func (s *state) callResult(n *ir.CallExpr, k callKind) *ssa.Value {
@@ -5460,9 +5756,15 @@ func (s *state) call(n *ir.CallExpr, k callKind, returnResultAddr bool, deferExt
 			// critical that we not clobber any arguments already
 			// stored onto the stack.
 			codeptr = s.rawLoad(types.Types[types.TUINTPTR], closure)
+			if base.Ctxt.CFI {
+				s.checkIndirectCall(codeptr, cfiFuncSlot, n.Fun.Type())
+			}
 			aux := ssa.ClosureAuxCall(callABI.ABIAnalyzeTypes(ACArgs, ACResults))
 			call = s.newValue2A(ssa.OpClosureLECall, aux.LateExpansionResultType(), aux, codeptr, closure)
 		case codeptr != nil:
+			if base.Ctxt.CFI {
+				s.checkIndirectCall(codeptr, cfiMethodSlot, n.Fun.Type())
+			}
 			// Note that the "receiver" parameter is nil because the actual receiver is the first input parameter.
 			aux := ssa.InterfaceAuxCall(params)
 			call = s.newValue1A(ssa.OpInterLECall, aux.LateExpansionResultType(), aux, codeptr)
@@ -5701,6 +6003,11 @@ func (s *state) canSSAName(name *ir.Name) bool {
 	if name.Addrtaken() || !name.OnStack() {
 		return false
 	}
+	if base.Flag.OptDebug && !name.AutoTemp() && !ir.IsBlank(name) {
+		// Keep user variables in their stack slots so a debugger
+		// sees their current value at every statement.
+		return false
+	}
 	switch name.Class {
 	case ir.PPARAMOUT:
 		if s.hasdefer {
@@ -6529,6 +6836,13 @@ func (s *state) dynamicDottype(n *ir.DynamicTypeAssertExpr, commaok bool) (res,
 	return s.dottype1(n.Pos(), n.X.Type(), n.Type(), iface, source, target, targetItab, commaok, nil)
 }
 
+// useInterfaceSwitchCache reports whether type assertions and
+// interface switches should probe their cache inline before calling
+// into the runtime. The probe is skipped when optimizing for size.
+func useInterfaceSwitchCache() bool {
+	return base.Flag.N == 0 && !base.Flag.OptSize && rtabi.UseInterfaceSwitchCache(Arch.LinkArch.Name)
+}
+
 // dottype1 implements a x.(T) operation. iface is the argument (x), dst is the type we're asserting to (T)
 // and src is the type we're asserting from.
 // source is the *runtime._type of src
@@ -6651,7 +6965,7 @@ func (s *state) dottype1(pos src.XPos, src, dst *types.Type, iface, source, targ
 		var d *ssa.Value
 		if descriptor != nil {
 			d = s.newValue1A(ssa.OpAddr, byteptr, descriptor, s.sb)
-			if base.Flag.N == 0 && rtabi.UseInterfaceSwitchCache(Arch.LinkArch.Name) {
+			if useInterfaceSwitchCache() {
 				// Note: we can only use the cache if we have the right atomic load instruction.
 				// Double-check that here.
 				if _, ok := intrinsics[intrinsicKey{Arch.LinkArch.Arch, "runtime/cmd_internal/atomic", "Loadp"}]; !ok {
@@ -7220,6 +7534,12 @@ func EmitArgInfo(f *ir.Func, abiInfo *abi.ABIParamResultInfo) *obj.LSym {
 	return x
 }
 
+// isDeferReturnCall reports whether v is a call to runtime.deferreturn.
+func isDeferReturnCall(v *ssa.Value) bool {
+	aux, ok := v.Aux.(*ssa.AuxCall)
+	return ok && v.Op.IsCall() && aux.Fn == ir.Syms.Deferreturn
+}
+
 // for wrapper, emit info of wrapped function.
 func emitWrappedFuncInfo(e *ssafn, pp *objw.Progs) {
 	if base.Ctxt.Flag_linkshared {
@@ -7260,6 +7580,7 @@ func genssa(f *ssa.Func, pp *objw.Progs) {
 	argLiveBlockMap, argLiveValueMap := liveness.ArgLiveness(e.curfn, f, pp)
 
 	openDeferInfo := e.curfn.LSym.Func().OpenCodedDeferInfo
+	var deferReturnJumps []*obj.Prog // jumps to the deferreturn stub
 	if openDeferInfo != nil {
 		// This function uses open-coded defers -- write out the funcdata
 		// info that we computed at the end of genssa.
@@ -7381,6 +7702,15 @@ func genssa(f *ssa.Func, pp *objw.Progs) {
 					s.SetPos(firstPos)
 					firstPos = src.NoXPos
 				}
+				if openDeferInfo != nil && isDeferReturnCall(v) {
+					// Jump to the deferreturn stub below rather
+					// than calling deferreturn; see openDeferExit.
+					p := s.pp.Prog(obj.AJMP)
+					p.To.Type = obj.TYPE_BRANCH
+					deferReturnJumps = append(deferReturnJumps, p)
+					break
+				}
+
 				// Attach this safe point to the next
 				// instruction.
 				s.pp.NextLive = s.livenessMap.Get(v)
@@ -7428,8 +7758,8 @@ func genssa(f *ssa.Func, pp *objw.Progs) {
 
 		// Emit control flow instructions for block
 		var next *ssa.Block
-		if i < len(f.Blocks)-1 && base.Flag.N == 0 {
-			// If -N, leave next==nil so every block with successors
+		if i < len(f.Blocks)-1 && base.Flag.N == 0 && !base.Flag.OptDebug {
+			// If -N or -optdebug, leave next==nil so every block with successors
 			// ends in a JMP (except call blocks - plive doesn't like
 			// select{send,recv} followed by a JMP call).  Helps keep
 			// line numbers for otherwise empty blocks.
@@ -7460,6 +7790,9 @@ func genssa(f *ssa.Func, pp *objw.Progs) {
 		p.To.Type = obj.TYPE_MEM
 		p.To.Name = obj.NAME_EXTERN
 		p.To.Sym = ir.Syms.Deferreturn
+		for _, j := range deferReturnJumps {
+			j.To.SetTarget(p)
+		}
 
 		// Load results into registers. So when a deferred function
 		// recovers a panic, it will return to caller with right results.
@@ -8287,7 +8620,12 @@ func callTargetLSym(callee *ir.Name) *obj.LSym {
 		return callee.Linksym()
 	}
 
-	return callee.LinksymABI(callee.Func.ABI)
+	lsym := callee.LinksymABI(callee.Func.ABI)
+	if callee.Func.Pragma&ir.Cold != 0 {
+		// Let likelyadjust see calls to imported cold functions.
+		lsym.Set(obj.AttrCold, true)
+	}
+	return lsym
 }
 
 func min8(a, b int8) int8 {
@@ -253,6 +253,11 @@ func FuncLinksym(n *ir.Name) *obj.LSym {
 	}
 	funcsymsmu.Unlock()
 
+	if base.Ctxt.TrackIndirect() {
+		// The function value calls n indirectly.
+		n.Linksym().Set(obj.AttrIndirect, true)
+	}
+
 	return sf.Linksym()
 }
 
@@ -0,0 +1,160 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"compile/src_internal/testenv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestCFICallSites checks that with -cfi the signature hash checked at
+// each indirect call site is one of the hashes at the entry of the
+// function called there, and that call sites in shaped generic
+// function bodies are not checked.
+func TestCFICallSites(t *testing.T) {
+	const src = `package p
+
+func Apply[T any](f func(T) T, x T) T { return f(x) }
+
+func inc(x int) int { return x + 1 }
+
+func F() int { return Apply(inc, 1) }
+
+func Call(f func(int) int) int { return f(1) }
+
+func H() int { return Call(inc) }
+
+type S struct{ n int }
+
+func (s S) Add(x int) int { return s.n + x }
+
+func MethodValue(s S) int {
+	f := s.Add
+	return f(2)
+}
+
+func MethodExpr(s S) int {
+	f := S.Add
+	return f(s, 3)
+}
+
+type I interface{ Add(int) int }
+
+func Iface(i I) int { return i.Add(4) }
+`
+	out, err := compileSrc(t, src, "-cfi", "-l", "-S")
+	if err != nil {
+		t.Fatalf("compile failed: %v\n%s", err, out)
+	}
+
+	// Collect the hashes at each function's entry and the hashes
+	// checked in each function.
+	textRx := regexp.MustCompile(`\tTEXT\t(.*)\(SB\)`)
+	entryRx := regexp.MustCompile(`\tMOVL\t\$(-?\d+), R12$`)
+	checkRx := regexp.MustCompile(`\tCMPL\t\d+\(\w+\), \$(-?\d+)$`)
+	entry := make(map[string][]string)
+	checks := make(map[string][]string)
+	fn := ""
+	atEntry := false
+	for _, line := range strings.Split(out, "\n") {
+		if m := textRx.FindStringSubmatch(line); m != nil {
+			fn, atEntry = m[1], true
+			continue
+		}
+		if m := entryRx.FindStringSubmatch(line); m != nil && atEntry {
+			entry[fn] = append(entry[fn], m[1])
+			continue
+		}
+		atEntry = false
+		if m := checkRx.FindStringSubmatch(line); m != nil {
+			checks[fn] = append(checks[fn], m[1])
+		}
+	}
+
+	for _, tc := range []struct {
+		caller, callee string
+		slot           int
+	}{
+		{"p.Call", "p.inc", 0},
+		{"p.MethodValue", "p.S.Add-fm", 0},
+		{"p.MethodExpr", "p.S.Add", 0},
+		{"p.Iface", "p.S.Add", 1},
+		{"p.Iface", "p.(*S).Add", 1},
+	} {
+		hashes := entry[tc.callee]
+		if len(hashes) <= tc.slot {
+			t.Errorf("%s has entry hashes %v, want at least %d", tc.callee, hashes, tc.slot+1)
+			continue
+		}
+		if !slices.Contains(checks[tc.caller], hashes[tc.slot]) {
+			t.Errorf("%s checks hashes %v, want %s (%s slot %d)", tc.caller, checks[tc.caller], hashes[tc.slot], tc.callee, tc.slot)
+		}
+	}
+	if c := checks["p.Apply[go.shape.int]"]; len(c) != 0 {
+		t.Errorf("shaped p.Apply checks hashes %v, want none", c)
+	}
+	if t.Failed() {
+		t.Logf("compiler output:\n%s", out)
+	}
+}
+
+const cfiSrc = `
+package main
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+func inc(x int) int { return x + 1 }
+
+//go:noinline
+func call(f func(int) int) (r int, err any) {
+	defer func() { err = recover() }()
+	return f(1), nil
+}
+
+func main() {
+	fmt.Println(call(inc))
+	length := func(s string) int { return len(s) }
+	fmt.Println(call(*(*func(int) int)(unsafe.Pointer(&length))))
+}
+`
+
+// TestCFILink checks that a program built with -cfi links, that its
+// indirect calls work, and that an indirect call of a function with
+// the wrong signature panics.
+func TestCFILink(t *testing.T) {
+	if testing.Short() {
+		// This test rebuilds the runtime with -cfi, which takes a
+		// while.
+		t.Skip("skip in short mode")
+	}
+	if runtime.GOARCH != "amd64" {
+		t.Skip("-cfi is only supported on amd64")
+	}
+	testenv.MustHaveGoRun(t)
+	t.Parallel()
+
+	src := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(src, []byte(cfiSrc), 0644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	cmd := testenv.Command(t, testenv.GoToolPath(t), "run", "-gcflags=all=-cfi", src)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+	const want = "2 <nil>\n0 indirect call of function with wrong signature\n"
+	if string(out) != want {
+		t.Errorf("wrong output: got %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"compile/src_internal/testenv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// compileSrc compiles src as the package p with the given compiler
+// flags, for GOOS=linux GOARCH=amd64, and returns the compiler's
+// output.
+func compileSrc(t *testing.T, src string, flags ...string) (string, error) {
+	t.Helper()
+	testenv.MustHaveGoBuild(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "p.go")
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := append([]string{"tool", "compile", "-p=p", "-o", filepath.Join(dir, "p.o")}, flags...)
+	cmd := testenv.Command(t, testenv.GoToolPath(t), append(args, file)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+var (
+	errorRx     = regexp.MustCompile(`// ERROR (.*)`)
+	errQuotesRx = regexp.MustCompile(`"([^"]*)"`)
+)
+
+// errorCheck compiles src with the given flags and checks that the
+// messages the compiler reports match the "// ERROR" comments in src,
+// as for the errorcheck tests in GOROOT/test. Each comment holds one
+// or more double-quoted regular expressions, each of which must match
+// a message reported on that line, and every message must be matched.
+func errorCheck(t *testing.T, src string, flags ...string) {
+	t.Helper()
+	out, _ := compileSrc(t, src, flags...)
+
+	want := make(map[int][]*regexp.Regexp)
+	for i, line := range strings.Split(src, "\n") {
+		m := errorRx.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, q := range errQuotesRx.FindAllStringSubmatch(m[1], -1) {
+			want[i+1] = append(want[i+1], regexp.MustCompile(q[1]))
+		}
+	}
+
+	msgRx := regexp.MustCompile(`^.*p\.go:(\d+):\d+: (.*)$`)
+	for _, line := range strings.Split(out, "\n") {
+		m := msgRx.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		res := want[n]
+		matched := false
+		for i, re := range res {
+			if re.MatchString(m[2]) {
+				want[n] = append(res[:i:i], res[i+1:]...)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			t.Errorf("line %d: unexpected message %q", n, m[2])
+		}
+	}
+	for n, res := range want {
+		for _, re := range res {
+			t.Errorf("line %d: missing message matching %q", n, re)
+		}
+	}
+	if t.Failed() {
+		t.Logf("compiler output:\n%s", out)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"regexp"
+	"testing"
+)
+
+// TestDeferLoopDeferreturn checks that a function with an open-coded
+// defer in a loop calls deferreturn only from the stub at its end, and
+// that the exit path that drains the defer chain jumps to that stub.
+// The linker records the first call to deferreturn as the PC where a
+// recovered panic resumes, so no other call may come before the stub.
+func TestDeferLoopDeferreturn(t *testing.T) {
+	const src = `package p
+
+var out []int
+
+func record(i int) { out = append(out, i) }
+
+func F(n int) int {
+	for i := 0; i < n; i++ {
+		defer record(i) // ERROR "open-coded defer"
+	}
+	return n
+}
+`
+	errorCheck(t, src, "-d=defer")
+
+	out, err := compileSrc(t, src, "-S")
+	if err != nil {
+		t.Fatalf("compile failed: %v\n%s", err, out)
+	}
+	fn := regexp.MustCompile(`(?s)\np\.F STEXT.*?\n(\S|$)`).FindString(out)
+	calls := regexp.MustCompile(`\t0x[0-9a-f]+ 0*(\d+) \(.*\)\tCALL\truntime\.deferreturn\(SB\)\n`).FindAllStringSubmatch(fn, -1)
+	if len(calls) != 1 {
+		t.Fatalf("found %d calls to runtime.deferreturn in p.F, want 1\ncompiler output:\n%s", len(calls), out)
+	}
+	if !regexp.MustCompile(`\tJMP\t` + calls[0][1] + `\n`).MatchString(fn) {
+		t.Errorf("no jump to the deferreturn stub at %s in p.F\ncompiler output:\n%s", calls[0][1], out)
+	}
+}
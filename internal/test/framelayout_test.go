@@ -0,0 +1,114 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const frameLayoutSrc = `package p
+
+//go:noinline
+func g(p *[4]int64) {}
+
+func F(a int64, b [3]int32) int64 { // ERROR "frame layout of F: 40 bytes \(32 locals, 8 outgoing args\), 24 bytes args" "stack frame of F is 40 bytes, larger than 16"
+	var x [4]int64
+	x[0] = a
+	g(&x)
+	return x[1] + int64(b[2])
+}
+`
+
+// TestFrameLayout checks the frame layouts reported by -d=framelayout=2.
+func TestFrameLayout(t *testing.T) {
+	out, err := compileSrc(t, frameLayoutSrc, "-d=framelayout=2")
+	if err != nil {
+		t.Fatalf("compile failed: %v\n%s", err, out)
+	}
+
+	type slot struct {
+		Name   string
+		Type   string
+		Size   int64
+		Offset int64
+		Kind   string
+	}
+	type layout struct {
+		Func         string
+		FrameSize    int64
+		Locals       int64
+		OutgoingArgs int64
+		ArgSize      int64
+		Slots        []slot
+	}
+	want := map[string]layout{
+		"g": {
+			Func:    "g",
+			ArgSize: 8,
+			Slots: []slot{
+				{"p", "*[4]int64", 8, 0, "arg"},
+			},
+		},
+		"F": {
+			Func:         "F",
+			FrameSize:    40,
+			Locals:       32,
+			OutgoingArgs: 8,
+			ArgSize:      24,
+			Slots: []slot{
+				{"b", "[3]int32", 12, 0, "arg"},
+				{"a", "int64", 8, 16, "arg"},
+				{"x", "[4]int64", 32, -32, "auto"},
+			},
+		},
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var got layout
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("bad frame layout %q: %v", line, err)
+		}
+		w, ok := want[got.Func]
+		if !ok {
+			t.Errorf("unexpected frame layout of %s", got.Func)
+			continue
+		}
+		delete(want, got.Func)
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("frame layout of %s:\ngot  %+v\nwant %+v", got.Func, got, w)
+		}
+	}
+	for fn := range want {
+		t.Errorf("no frame layout of %s", fn)
+	}
+}
+
+// TestFrameLayoutText checks the summary line printed by
+// -d=framelayout=1 and the warning printed by -d=framesizewarn.
+func TestFrameLayoutText(t *testing.T) {
+	src := strings.Replace(frameLayoutSrc, "func g(p *[4]int64) {}",
+		`func g(p *[4]int64) {} // ERROR "frame layout of g: 0 bytes \(0 locals, 0 outgoing args\), 8 bytes args"`, 1)
+	errorCheck(t, src, "-d=framelayout=1", "-d=framesizewarn=16")
+}
+
+// TestFrameLayoutTooLarge checks that frames too large to compile are
+// still reported by -d=framelayout and -d=framesizewarn.
+func TestFrameLayoutTooLarge(t *testing.T) {
+	const src = `package p
+
+func Args(a [1 << 30]byte) byte { return a[0] } // ERROR "frame layout of Args: 0 bytes \(0 locals, 0 outgoing args\), 1073741824 bytes args" "stack frame too large"
+
+//go:noinline
+func callee(a [1 << 30]byte) {} // ERROR "frame layout of callee" "stack frame too large"
+
+func Callee() { // ERROR "frame layout of Callee: 1073741824 bytes \(0 locals, 1073741824 outgoing args\), 0 bytes args" "stack frame of Callee is 1073741824 bytes, larger than 1024" "stack frame too large"
+	callee([1 << 30]byte{})
+}
+`
+	errorCheck(t, src, "-d=framelayout=1", "-d=framesizewarn=1024")
+}
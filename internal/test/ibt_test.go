@@ -0,0 +1,53 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"regexp"
+	"testing"
+)
+
+// TestIBTFuncValues checks that with -ibt the functions that are used
+// as values are marked INDIRECT and begin with an ENDBR64 instruction, even when the only
+// use is in a generic function that another package may instantiate,
+// and that functions that are only called directly do not.
+func TestIBTFuncValues(t *testing.T) {
+	const src = `package p
+
+func inc(x int) int { return x + 1 }
+
+func dec(x int) int { return x - 1 }
+
+func direct(x int) int { return x * 2 }
+
+func Apply(x int) int {
+	f := inc
+	return f(direct(x))
+}
+
+func G[T any]() func(int) int { return dec }
+`
+	out, err := compileSrc(t, src, "-ibt", "-S")
+	if err != nil {
+		t.Fatalf("compile failed: %v\n%s", err, out)
+	}
+	for _, tc := range []struct {
+		fn       string
+		indirect bool
+	}{
+		{"inc", true},
+		{"dec", true},
+		{"Apply", true},
+		{"direct", false},
+	} {
+		rx := regexp.MustCompile(`\tTEXT\tp\.` + tc.fn + `\(SB\), .*INDIRECT.*\n.*\tENDBR64\n`)
+		if got := rx.MatchString(out); got != tc.indirect {
+			t.Errorf("p.%s is an indirect branch target = %v, want %v", tc.fn, got, tc.indirect)
+		}
+	}
+	if t.Failed() {
+		t.Logf("compiler output:\n%s", out)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestOptDebug checks that with -optdebug the user variables of a
+// function live in their stack slots, so they need no DWARF location
+// lists, and that every statement has code, in source order.
+func TestOptDebug(t *testing.T) {
+	const src = `package p
+
+//go:noinline
+func g(int) {}
+
+func F(n int) int {
+	sum := 0
+	for i := 0; i < n; i++ {
+		sq := i * i
+		sum += sq
+	}
+	g(sum)
+	return sum
+}
+`
+	// Print the location lists and the generated code of F.
+	t.Setenv("GOSSAFUNC", "F+")
+
+	vars := []string{"sum", "i", "sq"}
+	stmts := []int{7, 8, 9, 10, 12, 13}
+	for _, optdebug := range []bool{false, true} {
+		flags := []string{"-d=locationlists=1"}
+		if optdebug {
+			flags = append(flags, "-optdebug")
+		}
+		out, err := compileSrc(t, src, flags...)
+		if err != nil {
+			t.Fatalf("compile failed: %v\n%s", err, out)
+		}
+		_, lists, ok := strings.Cut(out, "\nlocation lists:\n")
+		if !ok {
+			t.Fatalf("no location lists in output:\n%s", out)
+		}
+		lists, prog, _ := strings.Cut(lists, "\n# ")
+
+		for _, v := range vars {
+			has := regexp.MustCompile(`(?m)^\t` + v + ` : `).MatchString(lists)
+			if has == optdebug {
+				t.Errorf("-optdebug=%v: %s has location list = %v, want %v", optdebug, v, has, !optdebug)
+			}
+		}
+
+		// The lines of the instructions, in the order they first appear.
+		var lines []int
+		for _, m := range regexp.MustCompile(`\t\d+ \((\d+)\)\t`).FindAllStringSubmatch(prog, -1) {
+			n, _ := strconv.Atoi(m[1])
+			if !slices.Contains(lines, n) {
+				lines = append(lines, n)
+			}
+		}
+		if optdebug {
+			if want := append([]int{6}, stmts...); !slices.Equal(lines, want) {
+				t.Errorf("-optdebug: code for lines %v, want %v", lines, want)
+			}
+		} else if slices.Contains(lines, 7) {
+			t.Errorf("code for line 7 without -optdebug; test is not testing anything")
+		}
+		if t.Failed() {
+			t.Logf("compiler output:\n%s", out)
+			return
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestOptSizeInline checks that -optsize lowers the inlining budget.
+func TestOptSizeInline(t *testing.T) {
+	const src = `package p
+
+func medium(a, b, c int) int { // ERROR %q
+	x := a*b + c
+	y := b*c - a
+	z := (x + y) * (x - y)
+	if z > 100 {
+		z = z/3 + x*y
+	}
+	if z < -100 {
+		z = -z + a + b
+	}
+	return z + x*y
+}
+
+func Use() int { return medium(1, 2, 3) } // ERROR %s
+`
+	t.Run("speed", func(t *testing.T) {
+		errorCheck(t, fmt.Sprintf(src, "can inline medium with cost 60",
+			`"can inline Use with cost" "inlining call to medium"`), "-m=2")
+	})
+	t.Run("size", func(t *testing.T) {
+		errorCheck(t, fmt.Sprintf(src, "cannot inline medium: function too complex: cost 60 exceeds budget 40",
+			`"cannot inline Use: function too complex: cost [0-9]+ exceeds budget 40"`), "-m=2", "-optsize")
+	})
+}
+
+// TestOptSizeDefer checks that with -optsize defers are open-coded
+// only in functions with a single return.
+func TestOptSizeDefer(t *testing.T) {
+	const src = `package p
+
+var sink int
+
+func unlock() { sink++ }
+
+func TwoReturns(x int) int {
+	defer unlock() // ERROR %q
+	if x > 0 {
+		return 1
+	}
+	return 2
+}
+
+func OneReturn(x int) int {
+	defer unlock() // ERROR "open-coded defer"
+	return x
+}
+
+func TwoDefers(x int) int {
+	defer unlock() // ERROR %[2]q
+	defer unlock() // ERROR %[2]q
+	return x
+}
+`
+	t.Run("speed", func(t *testing.T) {
+		errorCheck(t, fmt.Sprintf(src, "open-coded defer", "open-coded defer"), "-d=defer")
+	})
+	t.Run("size", func(t *testing.T) {
+		errorCheck(t, fmt.Sprintf(src, "stack-allocated defer", "stack-allocated defer"), "-d=defer", "-optsize")
+	})
+}
+
+// TestOptSizeJumpTable checks that with -optsize a switch uses a jump
+// table only if it uses at least half of the table's entries.
+func TestOptSizeJumpTable(t *testing.T) {
+	const src = `package p
+
+func Switch(x int) int {
+	switch x {
+	case 0:
+		return 10
+	case %d:
+		return 11
+	case 2*%[1]d:
+		return 12
+	case 3*%[1]d:
+		return 13
+	case 4*%[1]d:
+		return 14
+	case 5*%[1]d:
+		return 15
+	case 6*%[1]d:
+		return 16
+	case 7*%[1]d:
+		return 17
+	}
+	return 0
+}
+`
+	for _, tc := range []struct {
+		stride  int // 8 cases spread over 7*stride+1 entries
+		optsize bool
+		want    bool
+	}{
+		{2, false, true},
+		{2, true, true},
+		{3, false, true},
+		{3, true, false},
+		{5, false, false},
+	} {
+		flags := []string{"-S"}
+		if tc.optsize {
+			flags = append(flags, "-optsize")
+		}
+		out, err := compileSrc(t, fmt.Sprintf(src, tc.stride), flags...)
+		if err != nil {
+			t.Fatalf("compile failed: %v\n%s", err, out)
+		}
+		if got := strings.Contains(out, "p.Switch.jump"); got != tc.want {
+			t.Errorf("stride %d, -optsize=%v: jump table = %v, want %v", tc.stride, tc.optsize, got, tc.want)
+		}
+	}
+}
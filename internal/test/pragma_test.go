@@ -0,0 +1,165 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"compile/src_internal/testenv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestForceInline checks that calls to go:inline functions are inlined
+// whatever their cost, and that the functions that cannot be inlined
+// are reported.
+func TestForceInline(t *testing.T) {
+	errorCheck(t, `package p
+
+//go:inline
+func big(x int) int { // ERROR "can inline big"
+	s := 0
+	for i := 0; i < x; i++ {
+		s += i * x
+		s ^= s >> 3
+		s += i*i + x*x
+		s -= i ^ x
+		s *= 3
+		s += x / (i + 1)
+	}
+	return s
+}
+
+//go:inline
+func rec(x int) int { // ERROR "cannot inline rec marked go:inline: recursive"
+	if x == 0 {
+		return 0
+	}
+	return rec(x - 1) + 1
+}
+
+//go:inline
+func deferred(f func()) { // ERROR "cannot inline deferred marked go:inline: unhandled op DEFER" "f does not escape"
+	defer f()
+}
+
+func Use(x int) int { // ERROR "can inline Use"
+	return big(x) // ERROR "inlining call to big"
+}
+`, "-m")
+}
+
+// TestForceInlineConflict checks that go:inline cannot be combined
+// with go:noinline or go:cold.
+func TestForceInlineConflict(t *testing.T) {
+	errorCheck(t, `package p
+
+//go:inline
+//go:noinline
+func f() {} // ERROR "go:inline cannot be combined with go:noinline or go:cold"
+
+//go:cold
+//go:inline
+func g() {} // ERROR "go:inline cannot be combined with go:noinline or go:cold"
+`)
+}
+
+// TestForceInlineImported checks that calls to go:inline functions in
+// other packages are inlined whatever their cost.
+func TestForceInlineImported(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+	const a = `package a
+
+//go:inline
+func Big(x int) int {
+	s := 0
+	for i := 0; i < x; i++ {
+		s += i * x
+		s ^= s >> 3
+		s += i*i + x*x
+		s -= i ^ x
+		s *= 3
+		s += x / (i + 1)
+		s += i * x
+		s ^= s >> 3
+		s += i*i + x*x
+		s -= i ^ x
+		s *= 5
+		s += x / (i + 1)
+	}
+	return s
+}
+
+func NotForced(x int) int {
+	s := 0
+	for i := 0; i < x; i++ {
+		s += i * x
+		s ^= s >> 3
+		s += i*i + x*x
+		s -= i ^ x
+		s *= 3
+		s += x / (i + 1)
+		s += i * x
+		s ^= s >> 3
+		s += i*i + x*x
+		s -= i ^ x
+		s *= 5
+		s += x / (i + 1)
+	}
+	return s
+}
+`
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.go")
+	obj := filepath.Join(dir, "a.o")
+	if err := os.WriteFile(src, []byte(a), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := testenv.Command(t, testenv.GoToolPath(t), "tool", "compile", "-p=a", "-o", obj, src)
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("compiling a: %v\n%s", err, out)
+	}
+	importcfg := filepath.Join(dir, "importcfg")
+	if err := os.WriteFile(importcfg, []byte(fmt.Sprintf("packagefile a=%s\n", obj)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	errorCheck(t, `package p
+
+import "a"
+
+func Use(x int) int {
+	return a.Big(x) + a.NotForced(x) // ERROR "inlining call to a.Big"
+}
+`, "-m", "-importcfg="+importcfg)
+}
+
+// TestColdBranch checks that likelyadjust predicts that branches
+// leading to calls to go:cold functions are not taken.
+func TestColdBranch(t *testing.T) {
+	errorCheck(t, `package p
+
+//go:cold
+func fail(x int) {
+	panic(x)
+}
+
+//go:noinline
+func warn(x int) {}
+
+var sink int
+
+func F(x int) {
+	if x < 0 { // ERROR "Branch prediction rule .* < cold"
+		fail(x)
+	}
+	if x > 100 { // ERROR "Branch prediction rule default < call"
+		warn(x)
+	}
+	sink++
+}
+`, "-d=ssa/likelyadjust/debug=1")
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestSizeReport checks that the per-function sizes in the -sizereport
+// output agree with the symbols printed by -S, and that the package
+// totals are the sums of the per-function sizes.
+func TestSizeReport(t *testing.T) {
+	const src = `package p
+
+func add(a, b int) int { return a + b }
+
+func Sum(xs []int) int {
+	s := 0
+	for _, x := range xs {
+		s = add(s, x)
+	}
+	return s
+}
+
+//go:noinline
+func Max[T int | float64](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func UseMax() (int, float64) { return Max(1, 2), Max(1.5, 2.5) }
+
+type T struct{ a, b int }
+
+func NewT() any { return &T{1, 2} }
+
+func Msg() string { return "hello, size report" }
+
+func Lookup(i int) int { return [...]int{3, 1, 4, 1, 5, 9, 2, 6, 5, 3}[i] }
+`
+	for _, optsize := range []bool{false, true} {
+		report := filepath.Join(t.TempDir(), "sizes.json")
+		flags := []string{"-S", "-sizereport=" + report}
+		want := "speed"
+		if optsize {
+			flags = append(flags, "-optsize")
+			want = "size"
+		}
+		out, err := compileSrc(t, src, flags...)
+		if err != nil {
+			t.Fatalf("compile failed: %v\n%s", err, out)
+		}
+		data, err := os.ReadFile(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pkg struct {
+			Package  string
+			Optimize string
+			Text     int64
+			Data     int64
+			Types    int64
+			Rodata   int64
+			Inlined  map[string]int64
+			Generics map[string]int64
+			Funcs    []struct {
+				Name    string
+				Text    int64
+				Inlined map[string]int64
+				Generic string
+				Shape   string
+				Data    int64
+				Types   int64
+				Rodata  int64
+			}
+		}
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			t.Fatalf("bad size report: %v\n%s", err, data)
+		}
+		if pkg.Package != "p" || pkg.Optimize != want {
+			t.Errorf("package %q optimized for %q, want %q for %q", pkg.Package, pkg.Optimize, "p", want)
+		}
+
+		// Sizes of the symbols, from the -S output.
+		textRx := regexp.MustCompile(`(?m)^(\S+) STEXT .*size=(\d+)`)
+		typeRx := regexp.MustCompile(`(?m)^(type:\S+|\S+\.\.dict\.\S+) SRODATA .*size=(\d+)`)
+		textSize := make(map[string]int64)
+		for _, m := range textRx.FindAllStringSubmatch(out, -1) {
+			textSize[m[1]], _ = strconv.ParseInt(m[2], 10, 64)
+		}
+		var types int64
+		for _, m := range typeRx.FindAllStringSubmatch(out, -1) {
+			n, _ := strconv.ParseInt(m[2], 10, 64)
+			types += n
+		}
+		// All read-only data but funcdata.
+		rodataRx := regexp.MustCompile(`(?m)^(.+) SRODATA .*size=(\d+)`)
+		funcDataRx := regexp.MustCompile(`^gclocals·|\.(arginfo\d+|argliveinfo|stkobj|opendefer|wrapinfo)$`)
+		var rodata int64
+		for _, m := range rodataRx.FindAllStringSubmatch(out, -1) {
+			if !funcDataRx.MatchString(m[1]) {
+				n, _ := strconv.ParseInt(m[2], 10, 64)
+				rodata += n
+			}
+		}
+
+		var text, fdata int64
+		inlined := make(map[string]int64)
+		generics := make(map[string]int64)
+		for _, f := range pkg.Funcs {
+			size, ok := textSize[f.Name]
+			if !ok {
+				t.Errorf("size report has unknown function %s", f.Name)
+			} else if f.Text != size {
+				t.Errorf("%s: text size %d, want %d", f.Name, f.Text, size)
+			}
+			delete(textSize, f.Name)
+			if f.Data <= 0 {
+				t.Errorf("%s: data size %d, want > 0", f.Name, f.Data)
+			}
+			text += f.Text
+			fdata += f.Data
+			for name, n := range f.Inlined {
+				inlined[name] += n
+			}
+			if f.Generic != "" {
+				generics[f.Generic] += f.Text
+				if f.Generic+f.Shape != f.Name {
+					t.Errorf("%s: generic %s shape %s", f.Name, f.Generic, f.Shape)
+				}
+			}
+			switch f.Name {
+			case "p.Sum":
+				if n := f.Inlined["p.add"]; n <= 0 || n > f.Text {
+					t.Errorf("p.Sum: %d bytes from inlined p.add, want between 1 and %d", n, f.Text)
+				}
+			case "p.NewT":
+				// The type descriptors of T and *T.
+				if f.Types < 144+56 {
+					t.Errorf("p.NewT: %d bytes of type data, want at least %d", f.Types, 144+56)
+				}
+				if f.Rodata < f.Types {
+					t.Errorf("p.NewT: %d bytes of read-only data, want at least its %d bytes of type data", f.Rodata, f.Types)
+				}
+			case "p.Msg":
+				// The string literal.
+				if f.Rodata != 18 {
+					t.Errorf("p.Msg: %d bytes of read-only data, want 18", f.Rodata)
+				}
+			case "p.Lookup":
+				// The static array.
+				if f.Rodata != 80 {
+					t.Errorf("p.Lookup: %d bytes of read-only data, want 80", f.Rodata)
+				}
+			}
+		}
+		for name := range textSize {
+			t.Errorf("size report has no function %s", name)
+		}
+
+		if pkg.Text != text {
+			t.Errorf("package text size %d, want %d", pkg.Text, text)
+		}
+		if pkg.Data != fdata {
+			t.Errorf("package data size %d, want %d", pkg.Data, fdata)
+		}
+		if pkg.Types != types {
+			t.Errorf("package type data size %d, want %d", pkg.Types, types)
+		}
+		if pkg.Rodata != rodata {
+			t.Errorf("package read-only data size %d, want %d", pkg.Rodata, rodata)
+		}
+		if !maps.Equal(pkg.Inlined, inlined) || len(inlined) != 1 {
+			t.Errorf("package inlined sizes %v, want %v for p.add only", pkg.Inlined, inlined)
+		}
+		if !maps.Equal(pkg.Generics, generics) || len(generics) != 1 {
+			t.Errorf("package generic sizes %v, want %v for p.Max only", pkg.Generics, generics)
+		}
+		if t.Failed() {
+			t.Logf("size report:\n%s", data)
+			t.Logf("compiler output:\n%s", strings.Join(textRx.FindAllString(out, -1), "\n"))
+			return
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestTailCallPragma checks the tail calls reported by -m and the
+// errors for calls in tail position in go:tailcall functions that
+// cannot be compiled as jumps. Inlining is enabled, and must not hide
+// the calls in tail position.
+func TestTailCallPragma(t *testing.T) {
+	errorCheck(t, `package p
+
+func many(a, b, c, d, e, f, g, h, i, j, k int) int { return a + k } // ERROR "can inline many"
+
+//go:tailcall
+func CallMany(x int) int { // ERROR "can inline CallMany"
+	return many(x, x, x, x, x, x, x, x, x, x, x) // ERROR "cannot tail call many in go:tailcall function: stack-allocated arguments"
+}
+
+//go:tailcall
+func CallClosure(x int) int { // ERROR "can inline CallClosure"
+	f := func(y int) int { return y + 1 } // ERROR "can inline CallClosure.func1" "func literal does not escape"
+	return f(x) // ERROR "cannot tail call f in go:tailcall function: indirect call"
+}
+
+type I interface{ M() int }
+
+//go:tailcall
+func CallIface(i I) int { // ERROR "can inline CallIface" "leaking param: i"
+	return i.M() // ERROR "cannot tail call i.M in go:tailcall function: indirect call"
+}
+
+//go:tailcall
+func CallDefer(x int) int {
+	defer func() {}() // ERROR "can inline CallDefer.func1" "func literal does not escape"
+	return small(x) // ERROR "cannot tail call small in go:tailcall function: function has defers"
+}
+
+func small(x int) int { return x * 2 } // ERROR "can inline small"
+
+//go:tailcall
+func CallSmall(x int) int { // ERROR "can inline CallSmall"
+	return small(x) // ERROR "tail call to small"
+}
+
+//go:tailcall
+func CallVoid(x int) { // ERROR "can inline CallVoid"
+	if x > 0 {
+		sink(x) // ERROR "tail call to sink"
+		return
+	}
+	sink(-x) // ERROR "tail call to sink"
+}
+
+func sink(x int) {} // ERROR "can inline sink"
+
+func count(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return count(n - 1) // ERROR "converted tail call to count into loop"
+}
+`, "-m")
+}
+
+// TestTailCallMutual checks that mutually recursive go:tailcall
+// functions jump to each other rather than calling each other, even
+// though one could be inlined into the other.
+func TestTailCallMutual(t *testing.T) {
+	const src = `package p
+
+//go:tailcall
+func Even(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return Odd(n - 1)
+}
+
+//go:tailcall
+func Odd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return Even(n - 1)
+}
+`
+	out, err := compileSrc(t, src, "-S")
+	if err != nil {
+		t.Fatalf("compile failed: %v\n%s", err, out)
+	}
+	if m := regexp.MustCompile(`\tCALL\tp\.(Even|Odd)\(SB\)`).FindString(out); m != "" {
+		t.Errorf("found %q, want only jumps between p.Even and p.Odd", m)
+	}
+	for _, jmp := range []string{"\tJMP\tp.Odd(SB)", "\tJMP\tp.Even(SB)"} {
+		if !strings.Contains(out, jmp) {
+			t.Errorf("missing %q", jmp)
+		}
+	}
+	if t.Failed() {
+		t.Logf("compiler output:\n%s", out)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// deferLoop_test.go tests open-coded defers in loops, including
+// loops that run more times than there are slots for them.
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+var deferLoopOut []int
+
+func deferLoopRecord(i int) { deferLoopOut = append(deferLoopOut, i) }
+
+//go:noinline
+func deferLoop(n int) int {
+	defer deferLoopRecord(-1)
+	for i := 0; i < n; i++ {
+		defer deferLoopRecord(i)
+	}
+	return n
+}
+
+//go:noinline
+func deferLoopPanic(n int) {
+	defer deferLoopRecord(-1)
+	for i := 0; i < n; i++ {
+		defer deferLoopRecord(i)
+	}
+	panic("deferLoopPanic")
+}
+
+//go:noinline
+func deferLoopRecover(n int) (r int, err any) {
+	defer func() {
+		err = recover()
+	}()
+	for i := 0; i < n; i++ {
+		defer deferLoopRecord(i)
+	}
+	r = n
+	panic("deferLoopRecover")
+}
+
+// deferLoopWant returns the order in which deferLoop(n) runs its
+// defers.
+func deferLoopWant(n int, outer bool) []int {
+	var want []int
+	for i := n - 1; i >= 0; i-- {
+		want = append(want, i)
+	}
+	if outer {
+		want = append(want, -1)
+	}
+	return want
+}
+
+// deferLoopSizes are the loop lengths tested: none, fewer than the 7
+// slots left by the outer defer, exactly as many, and more, which moves
+// the defers onto the runtime's defer chain.
+var deferLoopSizes = []int{0, 1, 6, 7, 8, 9, 20}
+
+func TestDeferLoop(t *testing.T) {
+	for _, n := range deferLoopSizes {
+		deferLoopOut = nil
+		if got := deferLoop(n); got != n {
+			t.Errorf("deferLoop(%d) = %d, want %d", n, got, n)
+		}
+		if want := deferLoopWant(n, true); !slices.Equal(deferLoopOut, want) {
+			t.Errorf("deferLoop(%d) ran defers %v, want %v", n, deferLoopOut, want)
+		}
+	}
+}
+
+func TestDeferLoopPanic(t *testing.T) {
+	for _, n := range deferLoopSizes {
+		deferLoopOut = nil
+		func() {
+			defer func() {
+				if e := recover(); e != "deferLoopPanic" {
+					t.Errorf("deferLoopPanic(%d) panicked with %v, want deferLoopPanic", n, e)
+				}
+			}()
+			deferLoopPanic(n)
+		}()
+		if want := deferLoopWant(n, true); !slices.Equal(deferLoopOut, want) {
+			t.Errorf("deferLoopPanic(%d) ran defers %v, want %v", n, deferLoopOut, want)
+		}
+	}
+}
+
+func TestDeferLoopRecover(t *testing.T) {
+	for _, n := range deferLoopSizes {
+		deferLoopOut = nil
+		r, err := deferLoopRecover(n)
+		if r != n || err != "deferLoopRecover" {
+			t.Errorf("deferLoopRecover(%d) = %d, %v, want %d, deferLoopRecover", n, r, err, n)
+		}
+		if want := deferLoopWant(n, false); !slices.Equal(deferLoopOut, want) {
+			t.Errorf("deferLoopRecover(%d) ran defers %v, want %v", n, deferLoopOut, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// tailcall_test.go tests that calls in tail position in go:tailcall
+// functions do not grow the stack.
+package main
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+//go:tailcall
+func tailEven(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return tailOdd(n - 1)
+}
+
+//go:tailcall
+func tailOdd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return tailEven(n - 1)
+}
+
+//go:tailcall
+func tailSum(n, acc int) int {
+	if n == 0 {
+		return acc
+	}
+	return tailSum(n-1, acc+n)
+}
+
+// TestTailCall tests that deep self and mutual recursion through
+// calls in tail position runs in a small stack.
+func TestTailCall(t *testing.T) {
+	// Without tail calls, these need hundreds of megabytes of stack.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	const n = 10_000_001
+	if got := tailEven(n); got {
+		t.Errorf("tailEven(%d) = %v, want false", n, got)
+	}
+	if got := tailOdd(n); !got {
+		t.Errorf("tailOdd(%d) = %v, want true", n, got)
+	}
+	if got, want := tailSum(n, 0), n*(n+1)/2; got != want {
+		t.Errorf("tailSum(%d, 0) = %d, want %d", n, got, want)
+	}
+}
@@ -41,6 +41,30 @@ type Error struct {
 	Full string     // full error message, for debugging (may contain cmd_internal details)
 	Soft bool       // if set, error is "soft"
 	Code Code       // error code
+
+	End     syntax.Pos     // approximate end of the offending source range, if known
+	Related []RelatedInfo  // related positions mentioned in Msg, such as other declarations
+	Fixes   []SuggestedFix // suggested fixes, if known
+}
+
+// RelatedInfo describes a source position related to an error,
+// such as the other declaration in a redeclaration error.
+type RelatedInfo struct {
+	Pos syntax.Pos
+	Msg string
+}
+
+// A SuggestedFix is a source change that resolves an error.
+type SuggestedFix struct {
+	Msg   string // description of the fix, such as "remove import"
+	Edits []TextEdit
+}
+
+// A TextEdit replaces the source text from Pos up to (but not
+// including) End with New. If Pos == End, New is inserted at Pos.
+type TextEdit struct {
+	Pos, End syntax.Pos
+	New      string
 }
 
 // Error returns an error string formatted as follows:
@@ -2937,3 +2937,88 @@ func TestFileVersions(t *testing.T) {
 		}
 	}
 }
+
+// applyFix applies the edits of fix to src, which must be the source
+// of the file in which the edits are located.
+func applyFix(src string, fix SuggestedFix) string {
+	offset := func(pos syntax.Pos) int {
+		off := 0
+		for line := uint(1); line < pos.Line(); line++ {
+			off += strings.IndexByte(src[off:], '\n') + 1
+		}
+		return off + int(pos.Col()) - 1
+	}
+	// edits are applied back to front so that offsets remain valid
+	edits := append([]TextEdit(nil), fix.Edits...)
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Pos.Cmp(edits[j].Pos) > 0 })
+	for _, e := range edits {
+		src = src[:offset(e.Pos)] + e.New + src[offset(e.End):]
+	}
+	return src
+}
+
+func TestErrorFixes(t *testing.T) {
+	lib := NewPackage("example.com/lib", "lib")
+	lib.MarkComplete()
+	imports := testImporter{"example.com/lib": lib}
+
+	for _, test := range []struct {
+		src, want string
+	}{
+		{"package p; func _() { x := 1 }", "package p; func _() { _ = 1 }"},
+		{"package p; func _() { x, y := 1, 2; _ = y }", "package p; func _() { _, y := 1, 2; _ = y }"},
+		{"package p; func _() { var x int }", "package p; func _() { var _ int }"},
+		{"package p; func _() { var a int; a := 1; _ = a }", "package p; func _() { var a int; a = 1; _ = a }"},
+		{"package p; func _(v any) { switch x := v.(type) {} }", "package p; func _(v any) { switch v.(type) {} }"},
+		{"package p; import \"example.com/lib\"", "package p; import _ \"example.com/lib\""},
+		{"package p; import l \"example.com/lib\"", "package p; import _ \"example.com/lib\""},
+		{"package p\nimport (\n\t\"example.com/lib\"\n)", "package p\nimport (\n\t\n)"},
+	} {
+		var errs []Error
+		conf := Config{
+			Importer: imports,
+			Error:    func(err error) { errs = append(errs, err.(Error)) },
+		}
+		typecheck(test.src, &conf, nil)
+		if len(errs) != 1 || len(errs[0].Fixes) != 1 {
+			t.Errorf("%s: got errors %v, want one error with one fix", test.src, errs)
+			continue
+		}
+		if got := applyFix(test.src, errs[0].Fixes[0]); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestErrorImportFix(t *testing.T) {
+	lib := NewPackage("example.com/lib", "lib")
+	lib.MarkComplete()
+	const (
+		srcA = `package p; import l "example.com/lib"; var _ = l.X`
+		srcB = `package p; var _ = l.Y`
+	)
+	fileA, _ := syntax.Parse(syntax.NewFileBase("a.go"), strings.NewReader(srcA), nil, nil, 0)
+	fileB, _ := syntax.Parse(syntax.NewFileBase("b.go"), strings.NewReader(srcB), nil, nil, 0)
+
+	var errs []Error
+	conf := Config{
+		Importer: testImporter{"example.com/lib": lib},
+		Error:    func(err error) { errs = append(errs, err.(Error)) },
+	}
+	conf.Check("p", []*syntax.File{fileA, fileB}, nil)
+
+	for _, err := range errs {
+		if err.Msg != "undefined: l" {
+			continue
+		}
+		if len(err.Fixes) != 1 {
+			t.Fatalf("%v: got %d fixes, want 1", err, len(err.Fixes))
+		}
+		const want = "package p\n\nimport l \"example.com/lib\"; var _ = l.Y"
+		if got := applyFix(srcB, err.Fixes[0]); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		return
+	}
+	t.Errorf("no undeclared name error in %v", errs)
+}
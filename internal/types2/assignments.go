@@ -559,10 +559,26 @@ func (check *Checker) shortVarDecl(pos syntax.Pos, lhs, rhs []syntax.Expr) {
 	check.processDelayed(top)
 
 	if len(newVars) == 0 && !hasErr {
-		check.softErrorf(pos, NoNewVar, "no new variables on left side of :=")
+		var err error_
+		err.code = NoNewVar
+		err.soft = true
+		err.errorf(pos, "no new variables on left side of :=")
+		err.addFix("use = instead of :=", TextEdit{pos, endOf(pos, ":="), "="})
+		check.report(&err)
 		return
 	}
 
+	// If the declaration introduces a single variable, the fix for
+	// it being unused is to turn the declaration into an assignment
+	// to _; renaming the variable would leave no new variables.
+	if len(lhs) == 1 && len(newVars) == 1 {
+		if check.unusedFixes == nil {
+			check.unusedFixes = make(map[*Var]TextEdit)
+		}
+		v := newVars[0]
+		check.unusedFixes[v] = TextEdit{v.pos, endOf(pos, ":="), "_ ="}
+	}
+
 	// declare new variables
 	// spec: "The scope of a constant or variable identifier declared inside
 	// a function begins at the end of the ConstSpec or VarSpec (ShortVarDecl
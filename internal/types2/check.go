@@ -125,14 +125,16 @@ type Checker struct {
 	// information collected during type-checking of a set of package files
 	// (initialized by Files, valid only for the duration of check.Files;
 	// maps and lists are allocated on demand)
-	files         []*syntax.File              // list of package files
-	versions      map[*syntax.PosBase]string  // maps file bases to version strings (each file has an entry)
-	imports       []*PkgName                  // list of imported packages
-	dotImportMap  map[dotImportKey]*PkgName   // maps dot-imported objects to the package they were dot-imported through
-	recvTParamMap map[*syntax.Name]*TypeParam // maps blank receiver type parameters to their type
-	brokenAliases map[*TypeName]bool          // set of aliases with broken (not yet determined) types
-	unionTypeSets map[*Union]*_TypeSet        // computed type sets for union types
-	mono          monoGraph                   // graph for detecting non-monomorphizable instantiation loops
+	files         []*syntax.File                  // list of package files
+	versions      map[*syntax.PosBase]string      // maps file bases to version strings (each file has an entry)
+	imports       []*PkgName                      // list of imported packages
+	dotImportMap  map[dotImportKey]*PkgName       // maps dot-imported objects to the package they were dot-imported through
+	recvTParamMap map[*syntax.Name]*TypeParam     // maps blank receiver type parameters to their type
+	brokenAliases map[*TypeName]bool              // set of aliases with broken (not yet determined) types
+	unionTypeSets map[*Union]*_TypeSet            // computed type sets for union types
+	mono          monoGraph                       // graph for detecting non-monomorphizable instantiation loops
+	unusedFixes   map[*Var]TextEdit               // edits removing local variables, where renaming them to _ is not enough
+	importDecls   map[*PkgName]*syntax.ImportDecl // import declarations of imported packages
 
 	firstErr error                    // first error encountered
 	methods  map[*TypeName][]*Func    // maps package scope type names to associated non-blank (non-interface) methods
@@ -273,6 +275,8 @@ func (check *Checker) initFiles(files []*syntax.File) {
 	check.files = nil
 	check.imports = nil
 	check.dotImportMap = nil
+	check.importDecls = nil
+	check.unusedFixes = nil
 
 	check.firstErr = nil
 	check.methods = nil
@@ -431,6 +435,8 @@ func (check *Checker) checkFiles(files []*syntax.File) (err error) {
 	// no longer needed - release memory
 	check.imports = nil
 	check.dotImportMap = nil
+	check.importDecls = nil
+	check.unusedFixes = nil
 	check.pkgPathMap = nil
 	check.seenPkgMap = nil
 	check.recvTParamMap = nil
@@ -35,14 +35,16 @@ func unreachable() {
 // An error_ represents a type-checking error.
 // To report an error_, call Checker.report.
 type error_ struct {
-	desc []errorDesc
-	code Code
-	soft bool // TODO(gri) eventually determine this from an error code
+	desc  []errorDesc
+	code  Code
+	soft  bool // TODO(gri) eventually determine this from an error code
+	fixes []SuggestedFix
 }
 
 // An errorDesc describes part of a type-checking error.
 type errorDesc struct {
 	pos    syntax.Pos
+	end    syntax.Pos
 	format string
 	args   []interface{}
 }
@@ -87,7 +89,12 @@ func (err *error_) String() string {
 // errorf adds formatted error information to err.
 // It may be called multiple times to provide additional information.
 func (err *error_) errorf(at poser, format string, args ...interface{}) {
-	err.desc = append(err.desc, errorDesc{atPos(at), format, args})
+	err.desc = append(err.desc, errorDesc{atPos(at), atEnd(at), format, args})
+}
+
+// addFix adds a suggested fix, described by msg, to err.
+func (err *error_) addFix(msg string, edits ...TextEdit) {
+	err.fixes = append(err.fixes, SuggestedFix{msg, edits})
 }
 
 func sprintf(qf Qualifier, tpSubscripts bool, format string, args ...interface{}) string {
@@ -204,7 +211,12 @@ func (check *Checker) report(err *error_) {
 	if err.empty() {
 		panic("no error to report")
 	}
-	check.err(err.pos(), err.code, err.msg(check.qualifier), err.soft)
+	var related []RelatedInfo
+	for _, d := range err.desc[1:] {
+		msg := stripAnnotations(sprintf(check.qualifier, false, d.format, d.args...))
+		related = append(related, RelatedInfo{d.pos, msg})
+	}
+	check.handleError(err.pos(), err.desc[0].end, err.code, err.msg(check.qualifier), err.soft, related, err.fixes)
 }
 
 func (check *Checker) trace(pos syntax.Pos, format string, args ...interface{}) {
@@ -221,6 +233,10 @@ func (check *Checker) dump(format string, args ...interface{}) {
 }
 
 func (check *Checker) err(at poser, code Code, msg string, soft bool) {
+	check.handleError(atPos(at), atEnd(at), code, msg, soft, nil, nil)
+}
+
+func (check *Checker) handleError(pos, end syntax.Pos, code Code, msg string, soft bool, related []RelatedInfo, fixes []SuggestedFix) {
 	switch code {
 	case InvalidSyntaxTree:
 		msg = "invalid syntax tree: " + msg
@@ -237,8 +253,6 @@ func (check *Checker) err(at poser, code Code, msg string, soft bool) {
 		return
 	}
 
-	pos := atPos(at)
-
 	// If we are encountering an error while evaluating an inherited
 	// constant initialization expression, pos is the position of in
 	// the original expression, and not of the currently declared
@@ -248,6 +262,7 @@ func (check *Checker) err(at poser, code Code, msg string, soft bool) {
 	if check.errpos.IsKnown() {
 		assert(check.iota != nil)
 		pos = check.errpos
+		end = nopos
 	}
 
 	// If we have a URL for error codes, add a link to the first line.
@@ -260,7 +275,7 @@ func (check *Checker) err(at poser, code Code, msg string, soft bool) {
 		}
 	}
 
-	err := Error{pos, stripAnnotations(msg), msg, soft, code}
+	err := Error{pos, stripAnnotations(msg), msg, soft, code, end, related, fixes}
 	if check.firstErr == nil {
 		check.firstErr = err
 	}
@@ -316,6 +331,26 @@ func atPos(at poser) syntax.Pos {
 	return at.Pos()
 }
 
+// atEnd reports the approximate end position of at, or nopos if
+// it is not known.
+func atEnd(at poser) syntax.Pos {
+	switch x := at.(type) {
+	case *operand:
+		if x.expr != nil {
+			return syntax.EndPos(x.expr)
+		}
+	case syntax.Node:
+		return syntax.EndPos(x)
+	}
+	return nopos
+}
+
+// endOf returns the position just past text, which must start at pos
+// and not span multiple lines.
+func endOf(pos syntax.Pos, text string) syntax.Pos {
+	return syntax.MakePos(pos.Base(), pos.Line(), pos.Col()+uint(len(text)))
+}
+
 // stripAnnotations removes cmd_internal (type) annotations from s.
 func stripAnnotations(s string) string {
 	var buf strings.Builder
@@ -295,6 +295,10 @@ func (check *Checker) collectObjects() {
 
 				// add import to file scope
 				check.imports = append(check.imports, pkgName)
+				if check.importDecls == nil {
+					check.importDecls = make(map[*PkgName]*syntax.ImportDecl)
+				}
+				check.importDecls[pkgName] = s
 				if name == "." {
 					// dot-import
 					if check.dotImportMap == nil {
@@ -756,10 +760,61 @@ func (check *Checker) errorUnusedPkg(obj *PkgName) {
 	if i := strings.LastIndex(elem, "/"); i >= 0 {
 		elem = elem[i+1:]
 	}
+	var err error_
+	err.code = UnusedImport
+	err.soft = true
 	if obj.name == "" || obj.name == "." || obj.name == elem {
-		check.softErrorf(obj, UnusedImport, "%q imported and not used", path)
+		err.errorf(obj, "%q imported and not used", path)
 	} else {
-		check.softErrorf(obj, UnusedImport, "%q imported as %s and not used", path, obj.name)
+		err.errorf(obj, "%q imported as %s and not used", path, obj.name)
+	}
+	// Grouped imports can be removed outright. Removing an ungrouped
+	// one would leave the import keyword behind, so rename it instead.
+	if s := check.importDecls[obj]; s != nil {
+		switch {
+		case s.Group != nil:
+			err.addFix("remove import", TextEdit{syntax.StartPos(s), syntax.EndPos(s), ""})
+		case s.LocalPkgName != nil:
+			err.addFix("import as _", TextEdit{s.LocalPkgName.Pos(), syntax.EndPos(s.LocalPkgName), "_"})
+		default:
+			err.addFix("import as _", TextEdit{s.Path.Pos(), s.Path.Pos(), "_ "})
+		}
+	}
+	check.report(&err)
+}
+
+// addImportFix adds a fix to err that imports a package named name
+// into the current file, if another file of the package imports one.
+func (check *Checker) addImportFix(err *error_, name string) {
+	var imp *PkgName
+	for _, obj := range check.imports {
+		if obj.name == name {
+			imp = obj
+			break
+		}
+	}
+	if imp == nil {
+		return
+	}
+
+	// find the current file
+	s := check.scope
+	for s != nil && s.parent != check.pkg.scope {
+		s = s.parent
+	}
+	if s == nil {
+		return
+	}
+	for _, file := range check.files {
+		if syntax.StartPos(file) == s.pos {
+			spec := fmt.Sprintf("%q", imp.imported.path)
+			if name != imp.imported.name {
+				spec = name + " " + spec
+			}
+			pos := syntax.EndPos(file.PkgName)
+			err.addFix("import "+spec, TextEdit{pos, pos, "\n\nimport " + spec})
+			return
+		}
 	}
 }
 
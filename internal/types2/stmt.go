@@ -10,6 +10,7 @@ import (
 	"compile/internal/syntax"
 	"compile/src_internal/buildcfg"
 	. "compile/src_internal/types/errors"
+	"fmt"
 	"go/constant"
 	"sort"
 )
@@ -64,7 +65,16 @@ func (check *Checker) usage(scope *Scope) {
 		return cmpPos(unused[i].pos, unused[j].pos) < 0
 	})
 	for _, v := range unused {
-		check.softErrorf(v.pos, UnusedVar, "%s declared and not used", v.name)
+		var err error_
+		err.code = UnusedVar
+		err.soft = true
+		err.errorf(v.pos, "%s declared and not used", v.name)
+		if edit, ok := check.unusedFixes[v]; ok {
+			err.addFix(fmt.Sprintf("remove variable %s", v.name), edit)
+		} else {
+			err.addFix(fmt.Sprintf("rename %s to _", v.name), TextEdit{v.pos, endOf(v.pos, v.name), "_"})
+		}
+		check.report(&err)
 	}
 
 	for _, scope := range scope.children {
@@ -819,7 +829,12 @@ func (check *Checker) typeSwitchStmt(inner stmtContext, s *syntax.SwitchStmt, gu
 			v.used = true // avoid usage error when checking entire function
 		}
 		if !used {
-			check.softErrorf(lhs, UnusedVar, "%s declared and not used", lhs.Value)
+			var err error_
+			err.code = UnusedVar
+			err.soft = true
+			err.errorf(lhs, "%s declared and not used", lhs.Value)
+			err.addFix(fmt.Sprintf("remove variable %s", lhs.Value), TextEdit{lhs.Pos(), syntax.StartPos(guard.X), ""})
+			check.report(&err)
 		}
 	}
 }
@@ -38,7 +38,11 @@ func (check *Checker) ident(x *operand, e *syntax.Name, def *TypeName, wantType
 				check.error(e, InvalidBlank, "cannot use _ as value or type")
 			}
 		} else {
-			check.errorf(e, UndeclaredName, "undefined: %s", e.Value)
+			var err error_
+			err.code = UndeclaredName
+			err.errorf(e, "undefined: %s", e.Value)
+			check.addImportFix(&err, e.Value)
+			check.report(&err)
 		}
 		return
 	case universeAny, universeComparable:
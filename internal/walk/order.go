@@ -766,8 +766,7 @@ func (o *orderState) stmt(n ir.Node) {
 		ir.ODCL,
 		ir.OFALL,
 		ir.OGOTO,
-		ir.OLABEL,
-		ir.OTAILCALL:
+		ir.OLABEL:
 		o.out = append(o.out, n)
 
 	// Special: handle call arguments.
@@ -778,6 +777,13 @@ func (o *orderState) stmt(n ir.Node) {
 		o.out = append(o.out, n)
 		o.popTemp(t)
 
+	case ir.OTAILCALL:
+		n := n.(*ir.TailCallStmt)
+		t := o.markTemp()
+		o.call(n.Call)
+		o.out = append(o.out, n)
+		o.popTemp(t)
+
 	case ir.OINLCALL:
 		n := n.(*ir.InlinedCallExpr)
 		o.stmtList(n.Body)
@@ -113,10 +113,15 @@ func walkStmt(n ir.Node) ir.Node {
 			// Also don't allow if we need to use deferprocat.
 			ir.CurFunc.SetOpenCodedDeferDisallowed(true)
 		}
-		if n.Esc() != ir.EscNever {
-			// If n.Esc is not EscNever, then this defer occurs in a loop,
-			// so open-coded defers cannot be used in this function.
-			ir.CurFunc.SetOpenCodedDeferDisallowed(true)
+		if n.Esc() != ir.EscNever && n.DeferAt == nil {
+			// If n.Esc is not EscNever, then this defer occurs in a loop.
+			// Open-coded defers can still be used if it is the only
+			// one, since its executions are then never interleaved
+			// with those of other defers (see ssagen.openDeferRecord).
+			if ir.CurFunc.OpenCodedLoopDefer() {
+				ir.CurFunc.SetOpenCodedDeferDisallowed(true)
+			}
+			ir.CurFunc.SetOpenCodedLoopDefer(true)
 		}
 		fallthrough
 	case ir.OGO:
@@ -139,7 +144,7 @@ func walkStmt(n ir.Node) ir.Node {
 		n := n.(*ir.TailCallStmt)
 
 		var init ir.Nodes
-		n.Call.Fun = walkExpr(n.Call.Fun, &init)
+		walkCall1(n.Call, &init)
 
 		if len(init) > 0 {
 			init.Append(n)
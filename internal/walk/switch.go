@@ -292,8 +292,13 @@ func (s *exprSwitch) search(cc []exprClause, out *ir.Nodes) {
 
 // Try to implement the clauses with a jump table. Returns true if successful.
 func (s *exprSwitch) tryJumpTable(cc []exprClause, out *ir.Nodes) bool {
-	const minCases = 8   // have at least minCases cases in the switch
-	const minDensity = 4 // use at least 1 out of every minDensity entries
+	const minCases = 8 // have at least minCases cases in the switch
+	minDensity := 4    // use at least 1 out of every minDensity entries
+	if base.Flag.OptSize {
+		// A table entry is about as big as the code for a step of
+		// the binary search, so only dense switches save space.
+		minDensity = 2
+	}
 
 	if base.Flag.N != 0 || !ssagen.Arch.LinkArch.CanJumpTable || base.Ctxt.Retpoline {
 		return false
@@ -310,7 +315,7 @@ func (s *exprSwitch) tryJumpTable(cc []exprClause, out *ir.Nodes) bool {
 	min := cc[0].lo.Val()
 	max := cc[len(cc)-1].hi.Val()
 	width := constant.BinaryOp(constant.BinaryOp(max, token.SUB, min), token.ADD, constant.MakeInt64(1))
-	limit := constant.MakeInt64(int64(len(cc)) * minDensity)
+	limit := constant.MakeInt64(int64(len(cc) * minDensity))
 	if constant.Compare(width, token.GTR, limit) {
 		// We disable jump tables if we use less than a minimum fraction of the entries.
 		// i.e. for switch x {case 0: case 1000: case 2000:} we don't want to use a jump table.
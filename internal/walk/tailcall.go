@@ -0,0 +1,188 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package walk
+
+import (
+	"fmt"
+
+	"compile/cmd_internal/src"
+	"compile/internal/base"
+	"compile/internal/ir"
+	"compile/internal/ssagen"
+	"compile/internal/typecheck"
+	"compile/internal/types"
+)
+
+// tailCalls eliminates calls in tail position in fn, so that they do
+// not grow the stack.
+//
+// A self-recursive call in tail position becomes an assignment of its
+// arguments to fn's parameters and a jump back to the top of fn.
+//
+// In a function marked go:tailcall, a call to another function in tail
+// position becomes an OTAILCALL, which jumps to the callee in place of
+// returning, provided the callee can reuse fn's incoming argument area.
+// Every call in tail position in such a function must be eliminated;
+// any that cannot be is an error. Calls to other functions are not
+// eliminated elsewhere, as the missing frame would be visible to
+// runtime.Caller.
+//
+// See ir.EditTailCalls for which calls are in tail position. The
+// inliner leaves the calls in tail position in a go:tailcall function
+// alone, so that they can be checked here.
+func tailCalls(fn *ir.Func) {
+	required := fn.Pragma&ir.Tailcall != 0
+	if !required && (base.Flag.N != 0 || base.Flag.OptDebug || base.Flag.CompilingRuntime) {
+		return
+	}
+	if fn.Wrapper() || fn.ABIWrapper() {
+		return
+	}
+
+	t := &tailCaller{fn: fn, required: required}
+	ir.EditTailCalls(fn, t.call)
+	if t.top != nil {
+		label := ir.NewLabelStmt(fn.Pos(), t.top)
+		fn.Body.Prepend(label)
+	}
+}
+
+type tailCaller struct {
+	fn       *ir.Func
+	required bool       // fn is marked go:tailcall
+	top      *types.Sym // label at the top of fn, once a call jumps to it
+}
+
+// call returns the statement that replaces the tail position
+// statement at pos, which runs init and then call, or nil if call
+// cannot be eliminated.
+func (t *tailCaller) call(pos src.XPos, call *ir.CallExpr, init []ir.Node) ir.Node {
+	var callee *ir.Func
+	if name := ir.StaticCalleeName(call.Fun); name != nil {
+		callee = name.Func
+	}
+
+	var reason string
+	switch {
+	case callee == t.fn:
+		reason = t.frameBlocker(true)
+		if reason == "" {
+			if base.Flag.LowerM != 0 {
+				base.WarnfAt(pos, "converted tail call to %v into loop", t.fn.Nname)
+			}
+			return t.loop(pos, call, init)
+		}
+	case !t.required:
+		return nil
+	case callee == nil:
+		reason = "indirect call"
+	default:
+		reason = t.frameBlocker(false)
+		if reason == "" {
+			reason = ssagen.TailCallBlocker(t.fn, callee)
+		}
+		if reason == "" {
+			if base.Flag.LowerM != 0 {
+				base.WarnfAt(pos, "tail call to %v", callee.Nname)
+			}
+			tail := ir.NewTailCallStmt(pos, call)
+			tail.PtrInit().Append(init...)
+			tail.PtrInit().Append(ir.TakeInit(call)...)
+			tail.SetTypecheck(1)
+			return tail
+		}
+	}
+
+	if t.required {
+		base.ErrorfAt(pos, 0, "cannot tail call %v in go:tailcall function: %s", call.Fun, reason)
+	} else if base.Flag.LowerM != 0 {
+		base.WarnfAt(pos, "cannot tail call %v: %s", call.Fun, reason)
+	}
+	return nil
+}
+
+// frameBlocker returns a non-empty reason if a call in tail position
+// cannot give up fn's frame, either by reusing it for the next
+// iteration of a loop (self is true) or by jumping to the callee.
+func (t *tailCaller) frameBlocker(self bool) string {
+	if ir.AnyList(t.fn.Body, func(n ir.Node) bool { return n.Op() == ir.ODEFER }) {
+		return "function has defers"
+	}
+	if !self && base.Flag.Cfg.Instrumenting {
+		return "instrumented build"
+	}
+	for _, n := range t.fn.Dcl {
+		if n.Addrtaken() && n.OnStack() {
+			// A pointer to n may have been passed to the callee.
+			return fmt.Sprintf("address of %v is taken", n)
+		}
+		if self && (n.Class == ir.PPARAM || n.Class == ir.PPARAMOUT) && !n.OnStack() {
+			// Each call needs its own copy of n.
+			return fmt.Sprintf("%v escapes to heap", n)
+		}
+	}
+	// Escape analysis did not see the loop, so a stack-allocated
+	// value may still be referenced once its slot is reused, or
+	// may have been passed to the callee.
+	var alloc ir.Node
+	ir.AnyList(t.fn.Body, func(n ir.Node) bool {
+		if isStackAlloc(n) {
+			alloc = n
+			return true
+		}
+		return false
+	})
+	if alloc != nil {
+		return fmt.Sprintf("%v is stack allocated", alloc)
+	}
+	return ""
+}
+
+// isStackAlloc reports whether n allocates memory in the current
+// frame, as escape analysis found that it does not escape.
+func isStackAlloc(n ir.Node) bool {
+	switch n.Op() {
+	case ir.OPTRLIT, ir.ONEW, ir.OSLICELIT, ir.OMAKESLICE, ir.OMAKESLICECOPY, ir.OMAKEMAP,
+		ir.OCLOSURE, ir.OMETHVALUE, ir.OCONVIFACE, ir.OADDSTR,
+		ir.OSTR2BYTES, ir.OSTR2RUNES, ir.OBYTES2STR, ir.ORUNES2STR, ir.ORUNESTR:
+		return n.Esc() == ir.EscNone
+	}
+	return false
+}
+
+// loop returns the statements that replace a self-recursive call:
+// init, an assignment of the call's arguments to fn's parameters,
+// zeroing of fn's results, and a jump back to the top of fn.
+func (t *tailCaller) loop(pos src.XPos, call *ir.CallExpr, init []ir.Node) ir.Node {
+	if t.top == nil {
+		t.top = typecheck.AutoLabel(".tail")
+	}
+
+	var out ir.Nodes
+	out.Append(init...)
+	out.Append(ir.TakeInit(call)...)
+
+	params := t.fn.Type().RecvParams()
+	if len(params) > 0 {
+		lhs := make([]ir.Node, len(params))
+		for i, param := range params {
+			lhs[i] = ir.BlankNode
+			if name, ok := param.Nname.(*ir.Name); ok && !ir.IsBlank(name) {
+				lhs[i] = name
+			}
+		}
+		out.Append(typecheck.Stmt(ir.NewAssignListStmt(pos, ir.OAS2, lhs, call.Args)))
+	}
+
+	// Results start out as zero in every call.
+	for _, result := range t.fn.Type().Results() {
+		if name, ok := result.Nname.(*ir.Name); ok && !ir.IsBlank(name) {
+			out.Append(typecheck.Stmt(ir.NewAssignStmt(pos, name, nil)))
+		}
+	}
+
+	out.Append(ir.NewBranchStmt(pos, ir.OGOTO, t.top))
+	return ir.NewBlockStmt(pos, out)
+}
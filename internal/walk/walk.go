@@ -22,6 +22,10 @@ const tmpstringbufsize = 32
 func Walk(fn *ir.Func) {
 	ir.CurFunc = fn
 	errorsBefore := base.Errors()
+	tailCalls(fn)
+	if base.Errors() > errorsBefore {
+		return
+	}
 	order(fn)
 	if base.Errors() > errorsBefore {
 		return
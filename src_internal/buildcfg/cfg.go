@@ -21,19 +21,20 @@ import (
 )
 
 var (
-	GOROOT   = runtime.GOROOT() // cached for efficiency
-	GOARCH   = envOr("GOARCH", defaultGOARCH)
-	GOOS     = envOr("GOOS", defaultGOOS)
-	GO386    = envOr("GO386", defaultGO386)
-	GOAMD64  = goamd64()
-	GOARM    = goarm()
-	GOMIPS   = gomips()
-	GOMIPS64 = gomips64()
-	GOPPC64  = goppc64()
-	GOWASM   = gowasm()
-	ToolTags = toolTags()
-	GO_LDSO  = defaultGO_LDSO
-	Version  = version
+	GOROOT    = runtime.GOROOT() // cached for efficiency
+	GOARCH    = envOr("GOARCH", defaultGOARCH)
+	GOOS      = envOr("GOOS", defaultGOOS)
+	GO386     = envOr("GO386", defaultGO386)
+	GOAMD64   = goamd64()
+	GOARM     = goarm()
+	GOMIPS    = gomips()
+	GOMIPS64  = gomips64()
+	GOPPC64   = goppc64()
+	GORISCV64 = goriscv64()
+	GOWASM    = gowasm()
+	ToolTags  = toolTags()
+	GO_LDSO   = defaultGO_LDSO
+	Version   = version
 )
 
 // Error is one of the errors found (if any) in the build configuration.
@@ -157,6 +158,38 @@ func goppc64() int {
 	return int(defaultGOPPC64[len("power")] - '0')
 }
 
+type goriscv64Features struct {
+	Profile    int  // RVA profile year, e.g. 20 for rva20u64
+	Compressed bool // use compressed (C extension) instruction encodings
+}
+
+func (g goriscv64Features) String() string {
+	riscvStr := fmt.Sprintf("rva%du64", g.Profile)
+	if g.Compressed {
+		riscvStr += ",compressed"
+	}
+	return riscvStr
+}
+
+func goriscv64() (g goriscv64Features) {
+	const compressedOpt = ",compressed"
+	v := envOr("GORISCV64", defaultGORISCV64)
+	if strings.HasSuffix(v, compressedOpt) {
+		g.Compressed = true
+		v = v[:len(v)-len(compressedOpt)]
+	}
+	switch v {
+	case "rva20u64":
+		g.Profile = 20
+	case "rva22u64":
+		g.Profile = 22
+	default:
+		Error = fmt.Errorf("invalid GORISCV64: must start with rva20u64 or rva22u64, and may optionally end in %q", compressedOpt)
+		g.Profile = 20
+	}
+	return
+}
+
 type gowasmFeatures struct {
 	SatConv bool
 	SignExt bool
@@ -228,6 +261,8 @@ func GOGOARCH() (name, value string) {
 		return "GOMIPS64", GOMIPS64
 	case "ppc64", "ppc64le":
 		return "GOPPC64", fmt.Sprintf("power%d", GOPPC64)
+	case "riscv64":
+		return "GORISCV64", GORISCV64.String()
 	case "wasm":
 		return "GOWASM", GOWASM.String()
 	}
@@ -260,6 +295,15 @@ func gogoarchTags() []string {
 			list = append(list, fmt.Sprintf("%s.power%d", GOARCH, i))
 		}
 		return list
+	case "riscv64":
+		list := []string{GOARCH + ".rva20u64"}
+		if GORISCV64.Profile >= 22 {
+			list = append(list, GOARCH+".rva22u64")
+		}
+		if GORISCV64.Compressed {
+			list = append(list, GOARCH+".compressed")
+		}
+		return list
 	case "wasm":
 		var list []string
 		if GOWASM.SatConv {
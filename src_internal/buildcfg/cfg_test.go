@@ -24,3 +24,21 @@ func TestConfigFlags(t *testing.T) {
 		t.Errorf("Wrong parsing of GOAMD64=1")
 	}
 }
+
+func TestConfigFlagsRISCV64(t *testing.T) {
+	os.Setenv("GORISCV64", "rva22u64")
+	if g := goriscv64(); g.Profile != 22 || g.Compressed {
+		t.Errorf("Wrong parsing of GORISCV64=rva22u64: %v", g)
+	}
+	os.Setenv("GORISCV64", "rva20u64,compressed")
+	if g := goriscv64(); g.Profile != 20 || !g.Compressed {
+		t.Errorf("Wrong parsing of GORISCV64=rva20u64,compressed: %v", g)
+	}
+	Error = nil
+	os.Setenv("GORISCV64", "rv64gc")
+	if goriscv64(); Error == nil {
+		t.Errorf("Wrong parsing of GORISCV64=rv64gc")
+	}
+	Error = nil
+	os.Unsetenv("GORISCV64")
+}
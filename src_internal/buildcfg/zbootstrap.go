@@ -10,6 +10,7 @@ const defaultGOARM = `7`
 const defaultGOMIPS = `hardfloat`
 const defaultGOMIPS64 = `hardfloat`
 const defaultGOPPC64 = `power8`
+const defaultGORISCV64 = `rva20u64`
 const defaultGOEXPERIMENT = ``
 const defaultGO_EXTLINK_ENABLED = ``
 const defaultGO_LDSO = ``
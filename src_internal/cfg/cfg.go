@@ -58,6 +58,7 @@ const KnownEnv = `
 	GOPRIVATE
 	GOPROXY
 	GOROOT
+	GORISCV64
 	GOSUMDB
 	GOTMPDIR
 	GOTOOLCHAIN
@@ -7,6 +7,7 @@ package cformat_test
 import (
 	"compile/src_internal/coverage"
 	"compile/src_internal/coverage/cformat"
+	"fmt"
 	"slices"
 	"strings"
 	"testing"
@@ -153,3 +154,180 @@ func TestEmptyPackages(t *testing.T) {
 		}
 	}
 }
+
+func TestReportFormats(t *testing.T) {
+	fm := cformat.NewFormatter(coverage.CtrModeCount)
+	mku := func(stl, enl, nx uint32) coverage.CoverableUnit {
+		return coverage.CoverableUnit{StLine: stl, EnLine: enl, NxStmts: nx}
+	}
+	fm.SetPackage("my/pack1")
+	fm.AddUnit("my/pack1/p.go", "f1", false, mku(3, 5, 2), 4)
+	fm.AddUnit("my/pack1/p.go", "f1", false, mku(5, 6, 1), 0)
+	fm.AddUnit("my/pack1/p.go", "f1.func1", true, mku(6, 6, 1), 2)
+	fm.AddUnit("my/pack1/p.go", "f2", false, mku(9, 10, 1), 0)
+	fm.SetPackage("my/pack2")
+	fm.AddUnit("my/pack2/q.go", "g", false, mku(1, 2, 1), 1)
+
+	var lcov strings.Builder
+	if err := fm.EmitLCOV(&lcov); err != nil {
+		t.Fatalf("EmitLCOV returned %v", err)
+	}
+	wantLCOV := strings.TrimSpace(`
+TN:
+SF:my/pack1/p.go
+FN:3,f1
+FN:9,f2
+FNDA:4,f1
+FNDA:0,f2
+FNF:2
+FNH:1
+DA:3,4
+DA:4,4
+DA:5,4
+DA:6,2
+DA:9,0
+DA:10,0
+LF:6
+LH:4
+end_of_record
+TN:
+SF:my/pack2/q.go
+FN:1,g
+FNDA:1,g
+FNF:1
+FNH:1
+DA:1,1
+DA:2,1
+LF:2
+LH:2
+end_of_record`)
+	if got := strings.TrimSpace(lcov.String()); got != wantLCOV {
+		t.Errorf("emit LCOV: got:\n%s\nwant:\n%s\n", got, wantLCOV)
+	}
+
+	var cob strings.Builder
+	if err := fm.EmitCobertura(&cob); err != nil {
+		t.Fatalf("EmitCobertura returned %v", err)
+	}
+	for _, want := range []string{
+		`<coverage line-rate="0.75" branch-rate="0" lines-covered="6" lines-valid="8"`,
+		`<package name="my/pack1" line-rate="0.6666666666666666"`,
+		`<class name="p" filename="my/pack1/p.go" line-rate="0.6666666666666666"`,
+		`<method name="f2" signature="" line-rate="0"`,
+		`<line number="6" hits="2" branch="false"></line>`,
+		`<package name="my/pack2" line-rate="1"`,
+	} {
+		if !strings.Contains(cob.String(), want) {
+			t.Errorf("emit Cobertura: missing %q in:\n%s", want, cob.String())
+		}
+	}
+
+	var html strings.Builder
+	src := map[string]string{
+		"my/pack1/p.go": "package p\n\nfunc f1() {\n\tif x < 1 {\n\t}\n\tgo func() {}()\n}\n\nfunc f2() {\n}\n",
+	}
+	readFile := func(file string) ([]byte, error) {
+		s, ok := src[file]
+		if !ok {
+			return nil, fmt.Errorf("no source for %s", file)
+		}
+		return []byte(s), nil
+	}
+	if err := fm.EmitHTML(&html, readFile); err != nil {
+		t.Fatalf("EmitHTML returned %v", err)
+	}
+	for _, want := range []string{
+		`<option value="file0">my/pack1/p.go (60.0%)</option>`,
+		`<option value="file1">my/pack2/q.go (100.0%)</option>`,
+		`<tr class=""><td class="num">1</td><td class="hits"></td><td class="branches"></td><td>package p</td></tr>`,
+		`<tr class="hit"><td class="num">4</td><td class="hits">4</td><td class="branches"></td><td>        if x &lt; 1 {</td></tr>`,
+		`<tr class="miss"><td class="num">9</td><td class="hits">0</td><td class="branches"></td><td>func f2() {</td></tr>`,
+		`source not available: no source for my/pack2/q.go`,
+		`<tr class="hit"><td class="num">2</td><td class="hits">1</td><td class="branches"></td><td></td></tr>`,
+	} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("emit HTML: missing %q in:\n%s", want, html.String())
+		}
+	}
+}
+
+func TestBranchCoverage(t *testing.T) {
+	fm := cformat.NewFormatter(coverage.CtrModeSet)
+	mku := func(stl, stc, enl, enc, nx uint32, kind coverage.UnitKind, d uint32) coverage.CoverableUnit {
+		return coverage.CoverableUnit{StLine: stl, StCol: stc, EnLine: enl, EnCol: enc, NxStmts: nx, Kind: kind, Decision: d}
+	}
+	// func f(a, b bool) {
+	//	if a && b {
+	//		g()
+	//	}
+	// }
+	fm.SetPackage("my/pack")
+	fm.AddUnit("p.go", "f", false, mku(1, 19, 2, 12, 1, coverage.UnitBlock, 0), 1)
+	fm.AddUnit("p.go", "f", false, mku(2, 12, 4, 3, 1, coverage.UnitBlock, 0), 0)
+	fm.AddUnit("p.go", "f", false, mku(2, 12, 4, 3, 0, coverage.UnitBranch, 1), 0)
+	fm.AddUnit("p.go", "f", false, mku(4, 3, 4, 3, 0, coverage.UnitBranch, 1), 1)
+	fm.AddUnit("p.go", "f", false, mku(2, 5, 2, 6, 0, coverage.UnitCondTrue, 2), 0)
+	fm.AddUnit("p.go", "f", false, mku(2, 5, 2, 6, 0, coverage.UnitCondFalse, 2), 1)
+	fm.AddUnit("p.go", "f", false, mku(2, 10, 2, 11, 0, coverage.UnitCondTrue, 3), 0)
+	fm.AddUnit("p.go", "f", false, mku(2, 10, 2, 11, 0, coverage.UnitCondFalse, 3), 0)
+
+	// The legacy format and the percentages only count statements.
+	var b strings.Builder
+	if err := fm.EmitTextual(&b); err != nil {
+		t.Fatalf("EmitTextual returned %v", err)
+	}
+	wantText := "mode: set\np.go:1.19,2.12 1 1\np.go:2.12,4.3 1 0\n"
+	if b.String() != wantText {
+		t.Errorf("emit textual: got:\n%s\nwant:\n%s", b.String(), wantText)
+	}
+	b.Reset()
+	if err := fm.EmitPercent(&b, "", false, true); err != nil {
+		t.Fatalf("EmitPercent returned %v", err)
+	}
+	if want := "coverage: 50.0% of statements\n"; b.String() != want {
+		t.Errorf("emit percent: got %q want %q", b.String(), want)
+	}
+
+	// The decisions are on line 2, in source order: the operands a
+	// and b, and the if statement. b wasn't reached.
+	b.Reset()
+	if err := fm.EmitLCOV(&b); err != nil {
+		t.Fatalf("EmitLCOV returned %v", err)
+	}
+	for _, want := range []string{
+		"BRDA:2,0,0,0\nBRDA:2,0,1,1\n",
+		"BRDA:2,1,0,-\nBRDA:2,1,1,-\n",
+		"BRDA:2,2,0,0\nBRDA:2,2,1,1\n",
+		"BRF:6\nBRH:2\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("emit LCOV: missing %q in:\n%s", want, b.String())
+		}
+	}
+
+	b.Reset()
+	if err := fm.EmitCobertura(&b); err != nil {
+		t.Fatalf("EmitCobertura returned %v", err)
+	}
+	for _, want := range []string{
+		`branch-rate="0.3333333333333333" lines-covered="2" lines-valid="4" branches-covered="2" branches-valid="6"`,
+		`<line number="2" hits="1" branch="true" condition-coverage="33% (2/6)"></line>`,
+		`<line number="3" hits="0" branch="false"></line>`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("emit Cobertura: missing %q in:\n%s", want, b.String())
+		}
+	}
+
+	b.Reset()
+	readFile := func(file string) ([]byte, error) {
+		return nil, fmt.Errorf("no source")
+	}
+	if err := fm.EmitHTML(&b, readFile); err != nil {
+		t.Fatalf("EmitHTML returned %v", err)
+	}
+	want := `<tr class="partial"><td class="num">2</td><td class="hits">1</td><td class="branches">2/6</td><td></td></tr>`
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("emit HTML: missing %q in:\n%s", want, b.String())
+	}
+}
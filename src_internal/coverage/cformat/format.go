@@ -6,8 +6,9 @@ package cformat
 
 // This package provides apis for producing human-readable summaries
 // of coverage data (e.g. a coverage percentage for a given package or
-// set of packages) and for writing data in the legacy test format
-// emitted by "go test -coverprofile=<outfile>".
+// set of packages), for writing data in the legacy test format
+// emitted by "go test -coverprofile=<outfile>", and for writing
+// LCOV, Cobertura XML and HTML reports (see report.go).
 //
 // The model for using these apis is to create a Formatter object,
 // then make a series of calls to SetPackage and AddUnit passing in
@@ -158,7 +159,14 @@ func (p *pstate) sortUnits(units []extcu) {
 		if units[i].EnCol != units[j].EnCol {
 			return units[i].EnCol < units[j].EnCol
 		}
-		return units[i].NxStmts < units[j].NxStmts
+		if units[i].NxStmts != units[j].NxStmts {
+			return units[i].NxStmts < units[j].NxStmts
+		}
+		// Branch and condition units can share a range.
+		if units[i].Kind != units[j].Kind {
+			return units[i].Kind < units[j].Kind
+		}
+		return units[i].Decision < units[j].Decision
 	})
 }
 
@@ -166,7 +174,8 @@ func (p *pstate) sortUnits(units []extcu) {
 // cmd/cover text format to the writer 'w'. We sort the data items by
 // importpath, source file, and line number before emitting (this sorting
 // is not explicitly mandated by the format, but seems like a good idea
-// for repeatable/deterministic dumps).
+// for repeatable/deterministic dumps). Branch and condition units have
+// no representation in this format and are left out.
 func (fm *Formatter) EmitTextual(w io.Writer) error {
 	if fm.cm == coverage.CtrModeInvalid {
 		panic("src_internal error, counter mode unset")
@@ -183,7 +192,9 @@ func (fm *Formatter) EmitTextual(w io.Writer) error {
 		p := fm.pm[importpath]
 		units := make([]extcu, 0, len(p.unitTable))
 		for u := range p.unitTable {
-			units = append(units, u)
+			if u.Kind == coverage.UnitBlock {
+				units = append(units, u)
+			}
 		}
 		p.sortUnits(units)
 		for _, u := range units {
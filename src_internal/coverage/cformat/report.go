@@ -0,0 +1,540 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cformat
+
+// This file contains the emitters for coverage formats used by
+// tools outside the Go distribution: LCOV tracefiles, Cobertura XML
+// reports, and a standalone HTML page with annotated source. These
+// formats are line-based, so the coverable units collected by AddUnit
+// are first mapped onto source lines: a line's count is the largest
+// count of any unit that spans it. Branch and condition units are
+// grouped into decisions instead, which are reported on the line of
+// their first outcome.
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"compile/src_internal/coverage"
+)
+
+// fileCov records line-level coverage for a single source file.
+type fileCov struct {
+	pkg   string
+	file  string
+	lines map[uint32]uint32 // line number to count, for instrumented lines
+	funcs []*funcCov        // named functions, in source order
+
+	decisions []*decisionCov // in order of line
+
+	stmts, covStmts uint64
+}
+
+// decisionCov records the outcome counts of a decision: an if, switch
+// or select statement, or an operand of a && or || expression.
+type decisionCov struct {
+	id        int // unique within the file
+	line, col uint32
+	cond      bool     // whether this is an operand, with true and false outcomes
+	counts    []uint32 // in source order
+}
+
+// taken returns the number of outcomes of d with a nonzero count.
+func (d *decisionCov) taken() int {
+	n := 0
+	for _, c := range d.counts {
+		if c != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// outcomes counts decision outcomes.
+type outcomes struct {
+	taken, total int
+}
+
+// lineOutcomes returns the outcomes of the decisions in ds on each
+// line.
+func lineOutcomes(ds []*decisionCov) map[uint32]outcomes {
+	m := make(map[uint32]outcomes)
+	for _, d := range ds {
+		o := m[d.line]
+		o.taken += d.taken()
+		o.total += len(d.counts)
+		m[d.line] = o
+	}
+	return m
+}
+
+// branchTotals returns the number of outcomes of the decisions in ds
+// and how many of them were taken.
+func branchTotals(ds []*decisionCov) (taken, total int) {
+	for _, d := range ds {
+		taken += d.taken()
+		total += len(d.counts)
+	}
+	return taken, total
+}
+
+// funcCov records line-level coverage for a single function.
+type funcCov struct {
+	name  string
+	line  uint32
+	count uint32 // count of the function's first unit
+	lines map[uint32]uint32
+
+	decisions []*decisionCov
+}
+
+// addLines records count for the lines spanned by u in m.
+func addLines(m map[uint32]uint32, u coverage.CoverableUnit, count uint32) {
+	for l := u.StLine; l <= u.EnLine; l++ {
+		if c, ok := m[l]; !ok || count > c {
+			m[l] = count
+		}
+	}
+}
+
+// sortedLines returns the line numbers in m in increasing order.
+func sortedLines(m map[uint32]uint32) []uint32 {
+	lines := make([]uint32, 0, len(m))
+	for l := range m {
+		lines = append(lines, l)
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// hitLines returns the number of lines in m with a nonzero count.
+func hitLines(m map[uint32]uint32) int {
+	n := 0
+	for _, c := range m {
+		if c != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// fileCoverage maps the accumulated coverage data onto source lines,
+// returning a fileCov for each source file sorted by import path and
+// file name. As with EmitFuncs, function literals are included in
+// the line data of their file but are not reported as functions.
+func (fm *Formatter) fileCoverage() []*fileCov {
+	if fm.cm == coverage.CtrModeInvalid {
+		panic("src_internal error, counter mode unset")
+	}
+	pkgs := make([]string, 0, len(fm.pm))
+	for importpath := range fm.pm {
+		pkgs = append(pkgs, importpath)
+	}
+	sort.Strings(pkgs)
+
+	var files []*fileCov
+	fileTable := make(map[string]*fileCov)
+	for _, importpath := range pkgs {
+		p := fm.pm[importpath]
+		units := make([]extcu, 0, len(p.unitTable))
+		for u := range p.unitTable {
+			units = append(units, u)
+		}
+		p.sortUnits(units)
+		funcTable := make(map[uint32]*funcCov)
+		type decisionKey struct{ fnfid, decision uint32 }
+		decisionTable := make(map[decisionKey]*decisionCov)
+		for _, u := range units {
+			count := p.unitTable[u]
+			fn := p.funcs[u.fnfid]
+			fc, ok := fileTable[fn.file]
+			if !ok {
+				fc = &fileCov{pkg: importpath, file: fn.file, lines: make(map[uint32]uint32)}
+				fileTable[fn.file] = fc
+				files = append(files, fc)
+			}
+			var d *decisionCov
+			if u.Kind == coverage.UnitBlock {
+				addLines(fc.lines, u.CoverableUnit, count)
+				fc.stmts += uint64(u.NxStmts)
+				if count != 0 {
+					fc.covStmts += uint64(u.NxStmts)
+				}
+			} else {
+				key := decisionKey{u.fnfid, u.Decision}
+				d = decisionTable[key]
+				if d == nil {
+					d = &decisionCov{
+						line: u.StLine,
+						col:  u.StCol,
+						cond: u.Kind != coverage.UnitBranch,
+					}
+					decisionTable[key] = d
+					fc.decisions = append(fc.decisions, d)
+				}
+				d.counts = append(d.counts, count)
+			}
+			if fn.lit {
+				continue
+			}
+			f, ok := funcTable[u.fnfid]
+			if !ok {
+				// Units are sorted by position, so this
+				// is the function's entry.
+				f = &funcCov{name: fn.fname, line: u.StLine, count: count, lines: make(map[uint32]uint32)}
+				funcTable[u.fnfid] = f
+				fc.funcs = append(fc.funcs, f)
+			}
+			if d == nil {
+				addLines(f.lines, u.CoverableUnit, count)
+			} else if len(d.counts) == 1 {
+				f.decisions = append(f.decisions, d)
+			}
+		}
+	}
+	for _, fc := range files {
+		sort.SliceStable(fc.decisions, func(i, j int) bool {
+			di, dj := fc.decisions[i], fc.decisions[j]
+			if di.line != dj.line {
+				return di.line < dj.line
+			}
+			return di.col < dj.col
+		})
+		for i, d := range fc.decisions {
+			d.id = i
+		}
+	}
+	return files
+}
+
+// EmitLCOV writes the accumulated coverage data to the writer 'w' as
+// an LCOV tracefile (the format read by genhtml and most CI coverage
+// services), with one record per source file giving function and line
+// hit counts. Decisions are written as LCOV branches, numbered in
+// source order: the outcomes of a && or || operand are its true and
+// false evaluations.
+func (fm *Formatter) EmitLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, fc := range fm.fileCoverage() {
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", fc.file)
+		fnh := 0
+		for _, f := range fc.funcs {
+			fmt.Fprintf(bw, "FN:%d,%s\n", f.line, f.name)
+		}
+		for _, f := range fc.funcs {
+			fmt.Fprintf(bw, "FNDA:%d,%s\n", f.count, f.name)
+			if f.count != 0 {
+				fnh++
+			}
+		}
+		fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", len(fc.funcs), fnh)
+		if len(fc.decisions) != 0 {
+			for _, d := range fc.decisions {
+				executed := d.taken() != 0
+				for i, c := range d.counts {
+					if executed {
+						fmt.Fprintf(bw, "BRDA:%d,%d,%d,%d\n", d.line, d.id, i, c)
+					} else {
+						// LCOV's notation for a decision that
+						// wasn't reached.
+						fmt.Fprintf(bw, "BRDA:%d,%d,%d,-\n", d.line, d.id, i)
+					}
+				}
+			}
+			taken, total := branchTotals(fc.decisions)
+			fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", total, taken)
+		}
+		for _, l := range sortedLines(fc.lines) {
+			fmt.Fprintf(bw, "DA:%d,%d\n", l, fc.lines[l])
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(fc.lines), hitLines(fc.lines))
+	}
+	return bw.Flush()
+}
+
+// Cobertura XML report elements. The branch rates are zero unless
+// the code was instrumented for branch coverage.
+type (
+	cobCoverage struct {
+		XMLName         xml.Name    `xml:"coverage"`
+		LineRate        float64     `xml:"line-rate,attr"`
+		BranchRate      float64     `xml:"branch-rate,attr"`
+		LinesCovered    int         `xml:"lines-covered,attr"`
+		LinesValid      int         `xml:"lines-valid,attr"`
+		BranchesCovered int         `xml:"branches-covered,attr"`
+		BranchesValid   int         `xml:"branches-valid,attr"`
+		Complexity      float64     `xml:"complexity,attr"`
+		Version         string      `xml:"version,attr"`
+		Timestamp       int64       `xml:"timestamp,attr"`
+		Packages        cobPackages `xml:"packages"`
+	}
+	cobPackage struct {
+		Name       string     `xml:"name,attr"`
+		LineRate   float64    `xml:"line-rate,attr"`
+		BranchRate float64    `xml:"branch-rate,attr"`
+		Complexity float64    `xml:"complexity,attr"`
+		Classes    cobClasses `xml:"classes"`
+	}
+	cobClass struct {
+		Name       string     `xml:"name,attr"`
+		Filename   string     `xml:"filename,attr"`
+		LineRate   float64    `xml:"line-rate,attr"`
+		BranchRate float64    `xml:"branch-rate,attr"`
+		Complexity float64    `xml:"complexity,attr"`
+		Methods    cobMethods `xml:"methods"`
+		Lines      cobLines   `xml:"lines"`
+	}
+	cobMethod struct {
+		Name       string   `xml:"name,attr"`
+		Signature  string   `xml:"signature,attr"`
+		LineRate   float64  `xml:"line-rate,attr"`
+		BranchRate float64  `xml:"branch-rate,attr"`
+		Complexity float64  `xml:"complexity,attr"`
+		Lines      cobLines `xml:"lines"`
+	}
+	// The DTD requires the list elements even when they're empty,
+	// which a path like "lines>line" wouldn't write.
+	cobPackages struct {
+		Packages []cobPackage `xml:"package"`
+	}
+	cobClasses struct {
+		Classes []cobClass `xml:"class"`
+	}
+	cobMethods struct {
+		Methods []cobMethod `xml:"method"`
+	}
+	cobLines struct {
+		Lines []cobLine `xml:"line"`
+	}
+	cobLine struct {
+		Number            int    `xml:"number,attr"`
+		Hits              uint32 `xml:"hits,attr"`
+		Branch            bool   `xml:"branch,attr"`
+		ConditionCoverage string `xml:"condition-coverage,attr,omitempty"`
+	}
+)
+
+// makeCobLines returns the lines in m, with the outcomes of the
+// decisions in ds reported on their lines.
+func makeCobLines(m map[uint32]uint32, ds []*decisionCov) cobLines {
+	branches := lineOutcomes(ds)
+	var lines cobLines
+	for _, l := range sortedLines(m) {
+		line := cobLine{Number: int(l), Hits: m[l]}
+		if o, ok := branches[l]; ok {
+			line.Branch = true
+			line.ConditionCoverage = fmt.Sprintf("%d%% (%d/%d)", 100*o.taken/o.total, o.taken, o.total)
+		}
+		lines.Lines = append(lines.Lines, line)
+	}
+	return lines
+}
+
+func rate(covered, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(covered) / float64(total)
+}
+
+// EmitCobertura writes the accumulated coverage data to the writer
+// 'w' as a Cobertura XML report. Each package is reported with one
+// class per source file, and each named function as a method of its
+// file's class. The report's timestamp is zero, so that the output
+// only depends on the coverage data.
+func (fm *Formatter) EmitCobertura(w io.Writer) error {
+	var cov cobCoverage
+	var pkg *cobPackage
+	var pkgCovered, pkgValid, pkgTaken, pkgBranches int
+	finishPkg := func() {
+		if pkg != nil {
+			pkg.LineRate = rate(pkgCovered, pkgValid)
+			pkg.BranchRate = rate(pkgTaken, pkgBranches)
+			cov.Packages.Packages = append(cov.Packages.Packages, *pkg)
+		}
+	}
+	for _, fc := range fm.fileCoverage() {
+		if pkg == nil || pkg.Name != fc.pkg {
+			finishPkg()
+			pkg = &cobPackage{Name: fc.pkg}
+			pkgCovered, pkgValid, pkgTaken, pkgBranches = 0, 0, 0, 0
+		}
+		covered, valid := hitLines(fc.lines), len(fc.lines)
+		taken, branches := branchTotals(fc.decisions)
+		class := cobClass{
+			Name:       strings.TrimSuffix(path.Base(fc.file), ".go"),
+			Filename:   fc.file,
+			LineRate:   rate(covered, valid),
+			BranchRate: rate(taken, branches),
+			Lines:      makeCobLines(fc.lines, fc.decisions),
+		}
+		for _, f := range fc.funcs {
+			class.Methods.Methods = append(class.Methods.Methods, cobMethod{
+				Name:       f.name,
+				LineRate:   rate(hitLines(f.lines), len(f.lines)),
+				BranchRate: rate(branchTotals(f.decisions)),
+				Lines:      makeCobLines(f.lines, f.decisions),
+			})
+		}
+		pkg.Classes.Classes = append(pkg.Classes.Classes, class)
+		pkgCovered += covered
+		pkgValid += valid
+		pkgTaken += taken
+		pkgBranches += branches
+		cov.LinesCovered += covered
+		cov.LinesValid += valid
+		cov.BranchesCovered += taken
+		cov.BranchesValid += branches
+	}
+	finishPkg()
+	cov.LineRate = rate(cov.LinesCovered, cov.LinesValid)
+	cov.BranchRate = rate(cov.BranchesCovered, cov.BranchesValid)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	bw.WriteString(`<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">` + "\n")
+	enc := xml.NewEncoder(bw)
+	enc.Indent("", "\t")
+	if err := enc.Encode(cov); err != nil {
+		return err
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// htmlFile is a source file in the HTML report.
+type htmlFile struct {
+	Name    string
+	Percent float64
+	Err     string
+	Lines   []htmlLine
+}
+
+// htmlLine is a source line in the HTML report. Class is "hit" or
+// "miss" for instrumented lines, or "partial" for lines that were hit
+// but have decision outcomes that weren't taken, and empty otherwise.
+// Branches is the number of outcomes taken out of those of the
+// decisions on the line, if any.
+type htmlLine struct {
+	Num      uint32
+	Hits     string
+	Branches string
+	Class    string
+	Text     string
+}
+
+// EmitHTML writes the accumulated coverage data to the writer 'w' as
+// a self-contained HTML page showing the source of each file with the
+// hit count of every instrumented line, and for code instrumented for
+// branch coverage, the outcomes taken of the decisions on each line.
+// readFile is called with each
+// file name passed to AddUnit to get its source. If it fails, only the
+// instrumented lines are shown, without their text.
+func (fm *Formatter) EmitHTML(w io.Writer, readFile func(file string) ([]byte, error)) error {
+	var files []htmlFile
+	for _, fc := range fm.fileCoverage() {
+		hf := htmlFile{Name: fc.file, Percent: 100}
+		if fc.stmts != 0 {
+			hf.Percent = 100 * float64(fc.covStmts) / float64(fc.stmts)
+		}
+		branches := lineOutcomes(fc.decisions)
+		line := func(n uint32, text string) htmlLine {
+			hl := htmlLine{Num: n, Text: text}
+			if c, ok := fc.lines[n]; ok {
+				hl.Hits = fmt.Sprint(c)
+				hl.Class = "miss"
+				if c != 0 {
+					hl.Class = "hit"
+				}
+			}
+			if o, ok := branches[n]; ok {
+				hl.Branches = fmt.Sprintf("%d/%d", o.taken, o.total)
+				if hl.Class == "hit" && o.taken < o.total {
+					hl.Class = "partial"
+				}
+			}
+			return hl
+		}
+		src, err := readFile(fc.file)
+		if err != nil {
+			hf.Err = err.Error()
+			for _, n := range sortedLines(fc.lines) {
+				hf.Lines = append(hf.Lines, line(n, ""))
+			}
+		} else {
+			text := strings.Split(strings.TrimSuffix(string(src), "\n"), "\n")
+			for i, t := range text {
+				hf.Lines = append(hf.Lines, line(uint32(i+1), strings.ReplaceAll(t, "\t", "        ")))
+			}
+		}
+		files = append(files, hf)
+	}
+	return htmlTemplate.Execute(w, files)
+}
+
+var htmlTemplate = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Go coverage report</title>
+<style>
+body { background: #fff; color: #000; margin: 0; font-family: monospace; }
+#topbar { position: fixed; top: 0; left: 0; right: 0; height: 42px; padding: 8px 12px; box-sizing: border-box; background: #eee; border-bottom: 1px solid #ccc; }
+#legend span { margin: 0 6px; padding: 0 4px; }
+#content { margin-top: 42px; }
+table { border-collapse: collapse; }
+td { padding: 0 6px; vertical-align: top; white-space: pre; }
+td.num, td.hits, td.branches { text-align: right; color: #888; border-right: 1px solid #ddd; }
+.hit { background: #d8f5d8; }
+.miss { background: #f8d8d8; }
+.partial { background: #f8f0c8; }
+.err { color: #c00; padding: 8px 12px; }
+</style>
+</head>
+<body>
+<div id="topbar">
+<select id="files">
+{{range $i, $f := .}}<option value="file{{$i}}">{{$f.Name}} ({{printf "%.1f" $f.Percent}}%)</option>
+{{end}}</select>
+<span id="legend"><span>not tracked</span><span class="miss">not covered</span><span class="partial">partly covered</span><span class="hit">covered</span></span>
+</div>
+<div id="content">
+{{range $i, $f := .}}<div class="file" id="file{{$i}}"{{if $i}} style="display: none"{{end}}>
+{{if $f.Err}}<div class="err">source not available: {{$f.Err}}</div>
+{{end}}<table>
+{{range $f.Lines}}<tr class="{{.Class}}"><td class="num">{{.Num}}</td><td class="hits">{{.Hits}}</td><td class="branches">{{.Branches}}</td><td>{{.Text}}</td></tr>
+{{end}}</table>
+</div>
+{{end}}</div>
+<script>
+(function() {
+	var files = document.getElementById('files');
+	var visible;
+	files.addEventListener('change', function() { select(files.value); }, false);
+	function select(id) {
+		if (visible) {
+			visible.style.display = 'none';
+		}
+		visible = document.getElementById(id);
+		if (visible) {
+			visible.style.display = 'block';
+			window.scrollTo(0, 0);
+		}
+	}
+	if (files.value) {
+		select(files.value);
+	}
+})();
+</script>
+</body>
+</html>
+`))
@@ -5,7 +5,8 @@
 package cmerge
 
 // package cmerge provides a few small utility APIs for helping
-// with merging of counter data for a given function.
+// with merging of counter data for a given function, and for
+// combining the coverage profiles of whole pods (see profile.go).
 
 import (
 	"compile/src_internal/coverage"
@@ -57,6 +58,45 @@ func (m *Merger) MergeCounters(dst, src []uint32) (error, bool) {
 	return nil, ovf
 }
 
+// SubtractCounters takes the counter values in 'src' and clears the
+// counters in 'dst' for the units that 'src' covers, leaving the
+// counters for the units that only 'dst' covers.
+func (m *Merger) SubtractCounters(dst, src []uint32) error {
+	if len(src) != len(dst) {
+		return fmt.Errorf("subtracting counters: len(dst)=%d len(src)=%d", len(dst), len(src))
+	}
+	for i := 0; i < len(src); i++ {
+		if src[i] != 0 {
+			dst[i] = 0
+		}
+	}
+	return nil
+}
+
+// IntersectCounters takes the counter values in 'src' and clears the
+// counters in 'dst' for the units that 'src' doesn't cover. The
+// counters for the units that both cover are merged as with
+// MergeCounters.
+func (m *Merger) IntersectCounters(dst, src []uint32) (error, bool) {
+	if len(src) != len(dst) {
+		return fmt.Errorf("intersecting counters: len(dst)=%d len(src)=%d", len(dst), len(src)), false
+	}
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == 0:
+			dst[i] = 0
+		case dst[i] == 0:
+		case m.cmode == coverage.CtrModeSet:
+			dst[i] = 1
+		default:
+			dst[i] = m.SaturatingAdd(dst[i], src[i])
+		}
+	}
+	ovf := m.overflow
+	m.overflow = false
+	return nil, ovf
+}
+
 // Saturating add does a saturating addition of 'dst' and 'src',
 // returning added value or math.MaxUint32 if there is an overflow.
 // Overflows are recorded in case the client needs to track them.
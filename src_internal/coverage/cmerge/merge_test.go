@@ -116,3 +116,68 @@ func TestBasic(t *testing.T) {
 		}
 	}
 }
+
+func TestSubtractIntersect(t *testing.T) {
+	scenarios := []struct {
+		cmode                coverage.CounterMode
+		src, dst, sub, inter []uint32
+		overflow             bool
+	}{
+		{
+			cmode: coverage.CtrModeSet,
+			src:   []uint32{1, 0, 1, 0},
+			dst:   []uint32{1, 1, 0, 0},
+			sub:   []uint32{0, 1, 0, 0},
+			inter: []uint32{1, 0, 0, 0},
+		},
+		{
+			cmode: coverage.CtrModeCount,
+			src:   []uint32{2, 0, 3, 0},
+			dst:   []uint32{5, 7, 0, 0},
+			sub:   []uint32{0, 7, 0, 0},
+			inter: []uint32{7, 0, 0, 0},
+		},
+		{
+			cmode:    coverage.CtrModeCount,
+			src:      []uint32{4294967200, 1},
+			dst:      []uint32{4294967001, 0},
+			sub:      []uint32{0, 0},
+			inter:    []uint32{4294967295, 0},
+			overflow: true,
+		},
+	}
+
+	for k, scenario := range scenarios {
+		m := &cmerge.Merger{}
+		err := m.SetModeAndGranularity("file", scenario.cmode, coverage.CtrGranularityPerBlock)
+		if err != nil {
+			t.Fatalf("case %d SetModeAndGranularity failed: %v", k, err)
+		}
+		dst := append([]uint32(nil), scenario.dst...)
+		if err := m.SubtractCounters(dst, scenario.src); err != nil {
+			t.Fatalf("case %d unexpected err %v", k, err)
+		}
+		if fmt.Sprint(dst) != fmt.Sprint(scenario.sub) {
+			t.Errorf("case %d: subtract got %v want %v", k, dst, scenario.sub)
+		}
+		dst = append(dst[:0], scenario.dst...)
+		err, ovf := m.IntersectCounters(dst, scenario.src)
+		if err != nil {
+			t.Fatalf("case %d unexpected err %v", k, err)
+		}
+		if ovf != scenario.overflow {
+			t.Errorf("case %d overflow mismatch: got %v want %v", k, ovf, scenario.overflow)
+		}
+		if fmt.Sprint(dst) != fmt.Sprint(scenario.inter) {
+			t.Errorf("case %d: intersect got %v want %v", k, dst, scenario.inter)
+		}
+	}
+
+	m := &cmerge.Merger{}
+	if err := m.SubtractCounters([]uint32{1}, []uint32{1, 2}); err == nil {
+		t.Errorf("expected error for mismatched lengths")
+	}
+	if err, _ := m.IntersectCounters([]uint32{1}, []uint32{1, 2}); err == nil {
+		t.Errorf("expected error for mismatched lengths")
+	}
+}
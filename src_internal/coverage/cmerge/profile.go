@@ -0,0 +1,317 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmerge
+
+// This file provides arithmetic on coverage profiles: the merged
+// counter data of a pod (a meta-data file and the counter data files
+// that refer to it). Profiles can be merged, subtracted and
+// intersected, the coverage of one run can be told apart from that of
+// others (for example, with one run per test, the code that only a
+// given test covers), and a profile can be written back out as a
+// counter data file for the pod's meta-data file, so that the results
+// can be read by the same tools as the original data.
+
+import (
+	"compile/src_internal/coverage"
+	"compile/src_internal/coverage/decodecounter"
+	"compile/src_internal/coverage/decodemeta"
+	"compile/src_internal/coverage/encodecounter"
+	"compile/src_internal/coverage/pods"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// FuncKey identifies a function by the index of its package in a
+// meta-data file and its index within the package, as in counter data
+// files.
+type FuncKey struct {
+	PkgIdx, FuncIdx uint32
+}
+
+// Profile holds the counters for every function described by a
+// meta-data file. Profiles can only be combined if they were read for
+// the same meta-data file, since that's what the function indexes and
+// counters refer to.
+type Profile struct {
+	MetaHash    [16]byte
+	Mode        coverage.CounterMode
+	Granularity coverage.CounterGranularity
+	Counters    map[FuncKey][]uint32
+}
+
+// ReadPod reads the meta-data file of the pod 'p' and merges the
+// counter data from all of its counter data files into a new profile.
+// Functions that were never executed have zero counters.
+func ReadPod(p pods.Pod) (*Profile, error) {
+	prof, err := readMetaFile(p.MetaFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, cdf := range p.CounterDataFiles {
+		if err := prof.addCounterFile(cdf); err != nil {
+			return nil, err
+		}
+	}
+	return prof, nil
+}
+
+// ReadPodByOrigin is like ReadPod, but returns a separate profile for
+// the counter data files from each of the pod's origins (the
+// directories the files were collected from, see pods.Pod). If each
+// test ran with its own coverage directory, this is a profile per
+// test.
+func ReadPodByOrigin(p pods.Pod) (map[int]*Profile, error) {
+	meta, err := readMetaFile(p.MetaFile)
+	if err != nil {
+		return nil, err
+	}
+	profs := make(map[int]*Profile)
+	for k, cdf := range p.CounterDataFiles {
+		prof := profs[p.Origins[k]]
+		if prof == nil {
+			prof = meta.Clone()
+			profs[p.Origins[k]] = prof
+		}
+		if err := prof.addCounterFile(cdf); err != nil {
+			return nil, err
+		}
+	}
+	return profs, nil
+}
+
+// readMetaFile returns a profile with zero counters for the functions
+// in the meta-data file 'mdf'.
+func readMetaFile(mdf string) (*Profile, error) {
+	f, err := os.Open(mdf)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mfr, err := decodemeta.NewCoverageMetaFileReader(f, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decoding meta-file %s: %v", mdf, err)
+	}
+	prof := &Profile{
+		MetaHash:    mfr.FileHash(),
+		Mode:        mfr.CounterMode(),
+		Granularity: mfr.CounterGranularity(),
+		Counters:    make(map[FuncKey][]uint32),
+	}
+	var payload []byte
+	var fd coverage.FuncDesc
+	for pkIdx := uint32(0); pkIdx < uint32(mfr.NumPackages()); pkIdx++ {
+		var pd *decodemeta.CoverageMetaDataDecoder
+		pd, payload, err = mfr.GetPackageDecoder(pkIdx, payload)
+		if err != nil {
+			return nil, fmt.Errorf("reading pkg %d from meta-file %s: %v", pkIdx, mdf, err)
+		}
+		for fnIdx := uint32(0); fnIdx < pd.NumFuncs(); fnIdx++ {
+			if err := pd.ReadFunc(fnIdx, &fd); err != nil {
+				return nil, fmt.Errorf("reading meta-file %s: %v", mdf, err)
+			}
+			n := 1
+			if prof.Granularity == coverage.CtrGranularityPerBlock {
+				n = len(fd.Units)
+			}
+			prof.Counters[FuncKey{pkIdx, fnIdx}] = make([]uint32, n)
+		}
+	}
+	return prof, nil
+}
+
+// addCounterFile merges the counters from all the segments of the
+// counter data file 'cdf' into 'p'.
+func (p *Profile) addCounterFile(cdf string) error {
+	f, err := os.Open(cdf)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cdr, err := decodecounter.NewCounterDataReader(cdf, f)
+	if err != nil {
+		return fmt.Errorf("reading counter data file %s: %v", cdf, err)
+	}
+	m := p.merger()
+	var data decodecounter.FuncPayload
+	for sidx := uint32(0); sidx < cdr.NumSegments(); sidx++ {
+		if sidx != 0 {
+			if ok, err := cdr.BeginNextSegment(); err != nil || !ok {
+				return fmt.Errorf("reading segment %d of counter data file %s: %v", sidx, cdf, err)
+			}
+		}
+		for {
+			ok, err := cdr.NextFunc(&data)
+			if err != nil {
+				return fmt.Errorf("reading counter data file %s: %v", cdf, err)
+			}
+			if !ok {
+				break
+			}
+			ctrs, ok := p.Counters[FuncKey{data.PkgIdx, data.FuncIdx}]
+			if !ok {
+				return fmt.Errorf("counter data file %s: no function %d in package %d", cdf, data.FuncIdx, data.PkgIdx)
+			}
+			if err, _ := m.MergeCounters(ctrs, data.Counters); err != nil {
+				return fmt.Errorf("counter data file %s: %v", cdf, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Profile) merger() *Merger {
+	m := &Merger{}
+	m.SetModeAndGranularity("", p.Mode, p.Granularity)
+	return m
+}
+
+// Clone returns a copy of 'p'.
+func (p *Profile) Clone() *Profile {
+	q := *p
+	q.Counters = make(map[FuncKey][]uint32, len(p.Counters))
+	for k, ctrs := range p.Counters {
+		q.Counters[k] = append([]uint32(nil), ctrs...)
+	}
+	return &q
+}
+
+// combine applies 'op' to the counters of each function in 'p' and 'q'.
+func (p *Profile) combine(q *Profile, what string, op func(dst, src []uint32) error) error {
+	if p.MetaHash != q.MetaHash {
+		return fmt.Errorf("%s: profiles are for different meta-data files (%x and %x)", what, p.MetaHash, q.MetaHash)
+	}
+	for k, dst := range p.Counters {
+		src, ok := q.Counters[k]
+		if !ok {
+			return fmt.Errorf("%s: no counters for function %d in package %d", what, k.FuncIdx, k.PkgIdx)
+		}
+		if err := op(dst, src); err != nil {
+			return fmt.Errorf("%s: %v", what, err)
+		}
+	}
+	return nil
+}
+
+// Merge merges the counters from 'q' into 'p'.
+func (p *Profile) Merge(q *Profile) error {
+	m := p.merger()
+	return p.combine(q, "merge", func(dst, src []uint32) error {
+		err, _ := m.MergeCounters(dst, src)
+		return err
+	})
+}
+
+// Subtract clears the counters in 'p' for the units that 'q' covers,
+// leaving the coverage that only 'p' has.
+func (p *Profile) Subtract(q *Profile) error {
+	return p.combine(q, "subtract", p.merger().SubtractCounters)
+}
+
+// Intersect clears the counters in 'p' for the units that 'q' doesn't
+// cover, leaving the coverage that both have, with merged counts.
+func (p *Profile) Intersect(q *Profile) error {
+	m := p.merger()
+	return p.combine(q, "intersect", func(dst, src []uint32) error {
+		err, _ := m.IntersectCounters(dst, src)
+		return err
+	})
+}
+
+// Uncovered returns a profile for the same meta-data file as 'p' in
+// which the units that 'p' doesn't cover have a count of 1 and all
+// others a count of zero, so that reports for it show the code the
+// runs in 'p' left uncovered as covered.
+func (p *Profile) Uncovered() *Profile {
+	q := p.Clone()
+	for _, ctrs := range q.Counters {
+		for i, c := range ctrs {
+			if c == 0 {
+				ctrs[i] = 1
+			} else {
+				ctrs[i] = 0
+			}
+		}
+	}
+	return q
+}
+
+// Exclusive returns, for each of the profiles 'profs', a profile with
+// its counters for the units that none of the others cover. With one
+// profile per test, these are the units that only that test covers.
+func Exclusive(profs []*Profile) ([]*Profile, error) {
+	if len(profs) == 0 {
+		return nil, nil
+	}
+	// Count the profiles that cover each unit.
+	covered := profs[0].Clone()
+	for _, ctrs := range covered.Counters {
+		clear(ctrs)
+	}
+	for _, p := range profs {
+		err := covered.combine(p, "exclusive", func(dst, src []uint32) error {
+			for i, c := range src {
+				if c != 0 {
+					dst[i]++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	res := make([]*Profile, len(profs))
+	for i, p := range profs {
+		q := p.Clone()
+		for k, ctrs := range q.Counters {
+			n := covered.Counters[k]
+			for j := range ctrs {
+				if n[j] > 1 {
+					ctrs[j] = 0
+				}
+			}
+		}
+		res[i] = q
+	}
+	return res, nil
+}
+
+// WriteCounterData writes the counters of 'p' to 'w' as a counter data
+// file with a single segment whose args section is 'args'. As at run
+// time, only the functions with a nonzero counter are written. To be
+// picked up with the pod's meta-data file, the file should be named as
+// described by coverage.CounterFileTempl.
+func (p *Profile) WriteCounterData(w io.Writer, args map[string]string) error {
+	cfw := encodecounter.NewCoverageDataWriter(w, coverage.CtrULeb128)
+	return cfw.Write(p.MetaHash, args, p)
+}
+
+// VisitFuncs implements encodecounter.CounterVisitor, visiting the
+// functions with a nonzero counter in order.
+func (p *Profile) VisitFuncs(f encodecounter.CounterVisitorFn) error {
+	keys := make([]FuncKey, 0, len(p.Counters))
+	for k, ctrs := range p.Counters {
+		for _, c := range ctrs {
+			if c != 0 {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].PkgIdx != keys[j].PkgIdx {
+			return keys[i].PkgIdx < keys[j].PkgIdx
+		}
+		return keys[i].FuncIdx < keys[j].FuncIdx
+	})
+	for _, k := range keys {
+		if err := f(k.PkgIdx, k.FuncIdx, p.Counters[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmerge_test
+
+import (
+	"compile/src_internal/coverage"
+	"compile/src_internal/coverage/cmerge"
+	"compile/src_internal/coverage/encodecounter"
+	"compile/src_internal/coverage/encodemeta"
+	"compile/src_internal/coverage/pods"
+	"compile/src_internal/coverage/slicewriter"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var metaHash = [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+// writeMetaFile writes a meta-data file to 'dir' for a package with
+// two functions, of two and three units.
+func writeMetaFile(t *testing.T, dir string) {
+	b, err := encodemeta.NewCoverageMetaDataBuilder("my/pkg", "pkg", "my")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range []int{2, 3} {
+		fd := coverage.FuncDesc{Funcname: fmt.Sprintf("f%d", i), Srcfile: "p.go"}
+		for j := 0; j < n; j++ {
+			l := uint32(10*i + j + 1)
+			fd.Units = append(fd.Units, coverage.CoverableUnit{StLine: l, EnLine: l, NxStmts: 1})
+		}
+		b.AddFunc(fd)
+	}
+	ws := &slicewriter.WriteSeeker{}
+	if _, err := b.Emit(ws); err != nil {
+		t.Fatal(err)
+	}
+	mfpath := filepath.Join(dir, fmt.Sprintf("%s.%x", coverage.MetaFilePref, metaHash))
+	f, err := os.Create(mfpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfw := encodemeta.NewCoverageMetaFileWriter(mfpath, f)
+	if err := mfw.Write(metaHash, [][]byte{ws.BytesWritten()}, coverage.CtrModeCount, coverage.CtrGranularityPerBlock); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// counters is an encodecounter.CounterVisitor for the functions of the
+// package written by writeMetaFile.
+type counters [][]uint32
+
+func (c counters) VisitFuncs(f encodecounter.CounterVisitorFn) error {
+	for i, ctrs := range c {
+		if ctrs != nil {
+			if err := f(0, uint32(i), ctrs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeCounterFile(t *testing.T, dir string, pid int, v encodecounter.CounterVisitor) {
+	name := fmt.Sprintf(coverage.CounterFileTempl, coverage.CounterFilePref, metaHash, pid, 1)
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := encodecounter.NewCoverageDataWriter(f, coverage.CtrULeb128)
+	if err := w.Write(metaHash, nil, v); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func collectPod(t *testing.T, dirs ...string) pods.Pod {
+	t.Helper()
+	ps, err := pods.CollectPods(dirs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 1 {
+		t.Fatalf("got %d pods, want 1", len(ps))
+	}
+	return ps[0]
+}
+
+func checkProfile(t *testing.T, what string, p *cmerge.Profile, want string) {
+	t.Helper()
+	got := fmt.Sprint(p.Counters[cmerge.FuncKey{0, 0}], p.Counters[cmerge.FuncKey{0, 1}])
+	if got != want {
+		t.Errorf("%s: got %s, want %s", what, got, want)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	// Two tests, each run in its own directory.
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeMetaFile(t, dir1)
+	writeMetaFile(t, dir2)
+	writeCounterFile(t, dir1, 1, counters{{1, 0}, {2, 0, 0}})
+	writeCounterFile(t, dir1, 2, counters{{1, 1}})
+	writeCounterFile(t, dir2, 3, counters{{3, 0}, {0, 0, 4}})
+	pod := collectPod(t, dir1, dir2)
+
+	all, err := cmerge.ReadPod(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProfile(t, "ReadPod", all, "[5 1] [2 0 4]")
+	byOrigin, err := cmerge.ReadPodByOrigin(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byOrigin) != 2 {
+		t.Fatalf("ReadPodByOrigin: got %d profiles, want 2", len(byOrigin))
+	}
+	t1, t2 := byOrigin[0], byOrigin[1]
+	checkProfile(t, "test 1", t1, "[2 1] [2 0 0]")
+	checkProfile(t, "test 2", t2, "[3 0] [0 0 4]")
+
+	sub := t1.Clone()
+	if err := sub.Subtract(t2); err != nil {
+		t.Fatal(err)
+	}
+	checkProfile(t, "Subtract", sub, "[0 1] [2 0 0]")
+	inter := t1.Clone()
+	if err := inter.Intersect(t2); err != nil {
+		t.Fatal(err)
+	}
+	checkProfile(t, "Intersect", inter, "[5 0] [0 0 0]")
+	merged := t1.Clone()
+	if err := merged.Merge(t2); err != nil {
+		t.Fatal(err)
+	}
+	checkProfile(t, "Merge", merged, "[5 1] [2 0 4]")
+	checkProfile(t, "Uncovered", merged.Uncovered(), "[0 0] [0 1 0]")
+
+	excl, err := cmerge.Exclusive([]*cmerge.Profile{t1, t2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProfile(t, "Exclusive 1", excl[0], "[0 1] [2 0 0]")
+	checkProfile(t, "Exclusive 2", excl[1], "[0 0] [0 0 4]")
+
+	// The result can be written out and read back with the meta-data.
+	out := t.TempDir()
+	writeMetaFile(t, out)
+	name := fmt.Sprintf(coverage.CounterFileTempl, coverage.CounterFilePref, metaHash, 1, 1)
+	f, err := os.Create(filepath.Join(out, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := excl[1].WriteCounterData(f, map[string]string{"test": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	back, err := cmerge.ReadPod(collectPod(t, out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProfile(t, "written", back, "[0 0] [0 0 4]")
+
+	// Profiles for different meta-data files can't be combined.
+	other := t1.Clone()
+	other.MetaHash[0]++
+	if err := other.Subtract(t1); err == nil {
+		t.Errorf("expected error combining profiles for different meta-data files")
+	}
+}
@@ -130,7 +130,15 @@ func (d *CoverageMetaDataDecoder) ReadFunc(fidx uint32, f *coverage.FuncDesc) er
 				NxStmts: uint32(d.r.ReadULEB128()),
 			})
 	}
-	lit := d.r.ReadULEB128()
-	f.Lit = lit != 0
+	flags := d.r.ReadULEB128()
+	f.Lit = flags&coverage.FuncFlagLit != 0
+	if flags&coverage.FuncFlagUnitKinds != 0 {
+		for k := range f.Units {
+			u := &f.Units[k]
+			u.Kind = coverage.UnitKind(d.r.ReadULEB128())
+			u.Parent = uint32(d.r.ReadULEB128())
+			u.Decision = uint32(d.r.ReadULEB128())
+		}
+	}
 	return nil
 }
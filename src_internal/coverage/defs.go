@@ -62,7 +62,14 @@ var CovMetaMagic = [4]byte{'\x00', '\x63', '\x76', '\x6d'}
 const MetaFilePref = "covmeta"
 
 // MetaFileVersion contains the current (most recent) meta-data file version.
-const MetaFileVersion = 1
+// Version 2 added unit kinds (see FuncFlagUnitKinds). A file in which
+// no function has unit kinds is written as MetaFileVersionBlocks, so
+// that readers predating version 2 can still read it.
+const MetaFileVersion = 2
+
+// MetaFileVersionBlocks is the version of a meta-data file whose
+// functions have only block units.
+const MetaFileVersionBlocks = 1
 
 // MetaFileHeader stores file header information for a meta-data file.
 type MetaFileHeader struct {
@@ -87,7 +94,7 @@ type MetaSymbolHeader struct {
 	PkgPath    uint32 // string table index
 	ModulePath uint32 // string table index
 	MetaHash   [16]byte
-	_          byte    // currently unused
+	Flags      uint8   // MetaSymFlag* bits
 	_          [3]byte // padding
 	NumFiles   uint32
 	NumFuncs   uint32
@@ -95,6 +102,11 @@ type MetaSymbolHeader struct {
 
 const CovMetaHeaderSize = 16 + 4 + 4 + 4 + 4 + 4 + 4 + 4 // keep in sync with above
 
+// Flags stored in the Flags field of MetaSymbolHeader.
+const (
+	MetaSymFlagUnitKinds = 1 << 0 // some function has FuncFlagUnitKinds set
+)
+
 // As an example, consider the following Go package:
 //
 // 01: package p
@@ -125,6 +137,7 @@ const CovMetaHeaderSize = 16 + 4 + 4 + 4 + 4 + 4 + 4 + 4 // keep in sync with ab
 //  | size: size of this blob in bytes
 //  | packagepath: <path to p>
 //  | modulepath: <modpath for p>
+//  | flags: 0 (see MetaSymFlagUnitKinds)
 //  | nfiles: 1
 //  | nfunctions: 2
 //  --func offsets table------
@@ -150,6 +163,25 @@ const CovMetaHeaderSize = 16 + 4 + 4 + 4 + 4 + 4 + 4 + 4 // keep in sync with ab
 //  | <uleb128> file: S0 (index into string table)
 //  | <unit 0>:  S0   L15    L19   5
 //  ---end-----------
+//
+// Each function ends with a uleb128 flags word. FuncFlagLit marks a
+// function literal. If any unit of the function is not a simple block
+// unit (see UnitKind), FuncFlagUnitKinds is set and the flags are
+// followed by the kind, parent and decision of each unit, in order:
+//
+//  | <uleb128> flags: FuncFlagLit|FuncFlagUnitKinds
+//  | <unit 0>:  UnitBlock     0   0
+//  | <unit 1>:  UnitBranch    1   1
+//  | <unit 2>:  UnitBranch    1   1
+//
+// Functions with only block units are encoded (and hashed) exactly as
+// they were before unit kinds were introduced.
+
+// Flags stored at the end of the meta-data for each function.
+const (
+	FuncFlagLit       = 1 << 0 // function is a literal
+	FuncFlagUnitKinds = 1 << 1 // unit kinds, parents and decisions follow
+)
 
 // The following types and constants used by the meta-data encoder/decoder.
 
@@ -184,13 +216,58 @@ type FuncDesc struct {
 // clause in line 8, with Parent pointing to the index of the line 8
 // unit in the units array.
 //
-// Note: in the initial version of the coverage revamp, only simple
-// units will be in use.
+// By default only simple units are in use. With the "branch"
+// instrumentation strategy, a function also has intraline units for
+// the outcomes of its if, switch and select statements and for the
+// operands of its && and || expressions, as described by Kind. The
+// units for the outcomes of the same statement or operand share a
+// Decision number, which is 1-based within the function, and are
+// listed in source order.
 type CoverableUnit struct {
 	StLine, StCol uint32
 	EnLine, EnCol uint32
 	NxStmts       uint32
 	Parent        uint32
+	Kind          UnitKind
+	Decision      uint32
+}
+
+// UnitKind describes what the counter for a CoverableUnit counts.
+type UnitKind uint8
+
+const (
+	// UnitBlock is a simple unit: the counter counts executions of a
+	// basic block.
+	UnitBlock UnitKind = iota
+
+	// UnitBranch is one outcome of an if, switch or select statement:
+	// the then or else branch of an if, or one of the clauses of a
+	// switch or select. The source range is that of the branch. An if
+	// without an else, or a switch without a default clause, has an
+	// implicit outcome whose range is empty and at the end of the
+	// statement.
+	UnitBranch
+
+	// UnitCondTrue and UnitCondFalse count the evaluations of an
+	// operand of a && or || expression that were true and false,
+	// respectively. The source range is that of the operand, and the
+	// true unit comes first.
+	UnitCondTrue
+	UnitCondFalse
+)
+
+func (k UnitKind) String() string {
+	switch k {
+	case UnitBlock:
+		return "block"
+	case UnitBranch:
+		return "branch"
+	case UnitCondTrue:
+		return "condtrue"
+	case UnitCondFalse:
+		return "condfalse"
+	}
+	return "<invalid>"
 }
 
 // CounterMode tracks the "flavor" of the coverage counters being
@@ -29,6 +29,7 @@ type CoverageMetaDataBuilder struct {
 	pkgpath uint32
 	pkgname uint32
 	modpath uint32
+	flags   uint8 // MetaSymFlag* bits
 	debug   bool
 	werr    error
 }
@@ -78,11 +79,16 @@ func (b *CoverageMetaDataBuilder) AddFunc(f coverage.FuncDesc) uint {
 		b.tmp = uleb128.AppendUleb128(b.tmp, uint(u.EnCol))
 		b.tmp = uleb128.AppendUleb128(b.tmp, uint(u.NxStmts))
 	}
-	lit := uint(0)
-	if f.Lit {
-		lit = 1
+	flags := funcFlags(&f)
+	b.tmp = uleb128.AppendUleb128(b.tmp, uint(flags))
+	if flags&coverage.FuncFlagUnitKinds != 0 {
+		b.flags |= coverage.MetaSymFlagUnitKinds
+		for _, u := range f.Units {
+			b.tmp = uleb128.AppendUleb128(b.tmp, uint(u.Kind))
+			b.tmp = uleb128.AppendUleb128(b.tmp, uint(u.Parent))
+			b.tmp = uleb128.AppendUleb128(b.tmp, uint(u.Decision))
+		}
 	}
-	b.tmp = uleb128.AppendUleb128(b.tmp, lit)
 	fd.encoded = bytes.Clone(b.tmp)
 	rv := uint(len(b.funcs))
 	b.funcs = append(b.funcs, fd)
@@ -141,6 +147,7 @@ func (b *CoverageMetaDataBuilder) Emit(w io.WriteSeeker) ([16]byte, error) {
 		NumFiles:   uint32(b.stab.Nentries()),
 		NumFuncs:   uint32(len(b.funcs)),
 		MetaHash:   digest,
+		Flags:      b.flags,
 	}
 	if b.debug {
 		fmt.Fprintf(os.Stderr, "=-= writing header: %+v\n", mh)
@@ -207,9 +214,28 @@ func hashFuncDesc(h hash.Hash, f *coverage.FuncDesc, tmp []byte) {
 		h32(u.EnCol, h, tmp)
 		h32(u.NxStmts, h, tmp)
 	}
-	lit := uint32(0)
+	flags := funcFlags(f)
+	h32(flags, h, tmp)
+	if flags&coverage.FuncFlagUnitKinds != 0 {
+		for _, u := range f.Units {
+			h32(uint32(u.Kind), h, tmp)
+			h32(u.Parent, h, tmp)
+			h32(u.Decision, h, tmp)
+		}
+	}
+}
+
+// funcFlags returns the flags word that ends the meta-data for 'f'.
+func funcFlags(f *coverage.FuncDesc) uint32 {
+	var flags uint32
 	if f.Lit {
-		lit = 1
+		flags |= coverage.FuncFlagLit
+	}
+	for _, u := range f.Units {
+		if u.Kind != coverage.UnitBlock {
+			flags |= coverage.FuncFlagUnitKinds
+			break
+		}
 	}
-	h32(lit, h, tmp)
+	return flags
 }
@@ -59,7 +59,7 @@ func (m *CoverageMetaFileWriter) Write(finalHash [16]byte, blobs [][]byte, mode
 	// Emit header
 	mh := coverage.MetaFileHeader{
 		Magic:        coverage.CovMetaMagic,
-		Version:      coverage.MetaFileVersion,
+		Version:      metaFileVersion(blobs),
 		TotalLength:  tlen,
 		Entries:      uint64(len(blobs)),
 		MetaFileHash: finalHash,
@@ -130,3 +130,16 @@ func (m *CoverageMetaFileWriter) Write(finalHash [16]byte, blobs [][]byte, mode
 	}
 	return nil
 }
+
+// metaFileVersion returns the version of a meta-data file containing
+// 'blobs'. Only files with unit kinds need the current version; older
+// readers cannot decode those.
+func metaFileVersion(blobs [][]byte) uint32 {
+	off := unsafe.Offsetof(coverage.MetaSymbolHeader{}.Flags)
+	for _, blob := range blobs {
+		if uintptr(len(blob)) > off && blob[off]&coverage.MetaSymFlagUnitKinds != 0 {
+			return coverage.MetaFileVersion
+		}
+	}
+	return coverage.MetaFileVersionBlocks
+}
@@ -9,6 +9,7 @@ import (
 	"compile/src_internal/coverage/decodemeta"
 	"compile/src_internal/coverage/encodemeta"
 	"compile/src_internal/coverage/slicewriter"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
@@ -329,3 +330,146 @@ func TestMetaDataDecodeLitFlagIssue57942(t *testing.T) {
 		}
 	}
 }
+
+func TestMetaDataUnitKinds(t *testing.T) {
+	// Encode a function with branch and condition units next to
+	// functions with only block units, and check that they decode.
+	b, err := encodemeta.NewCoverageMetaDataBuilder("foo/bar/pkg", "pkg", "barmod")
+	if err != nil {
+		t.Fatalf("making builder: %v", err)
+	}
+	blocks := coverage.FuncDesc{
+		Funcname: "blocks",
+		Srcfile:  "foo.go",
+		Units: []coverage.CoverableUnit{
+			coverage.CoverableUnit{StLine: 1, StCol: 2, EnLine: 3, EnCol: 4, NxStmts: 5},
+		},
+	}
+	branches := coverage.FuncDesc{
+		Funcname: "branches",
+		Srcfile:  "foo.go",
+		Units: []coverage.CoverableUnit{
+			coverage.CoverableUnit{StLine: 10, StCol: 2, EnLine: 10, EnCol: 30, NxStmts: 1},
+			coverage.CoverableUnit{StLine: 10, StCol: 12, EnLine: 12, EnCol: 3, NxStmts: 1},
+			coverage.CoverableUnit{StLine: 10, StCol: 5, EnLine: 10, EnCol: 6, Parent: 1, Kind: coverage.UnitCondTrue, Decision: 1},
+			coverage.CoverableUnit{StLine: 10, StCol: 5, EnLine: 10, EnCol: 6, Parent: 1, Kind: coverage.UnitCondFalse, Decision: 1},
+			coverage.CoverableUnit{StLine: 10, StCol: 12, EnLine: 12, EnCol: 3, Parent: 1, Kind: coverage.UnitBranch, Decision: 2},
+			coverage.CoverableUnit{StLine: 12, StCol: 3, EnLine: 12, EnCol: 3, Parent: 1, Kind: coverage.UnitBranch, Decision: 2},
+		},
+		Lit: true,
+	}
+	want := []coverage.FuncDesc{blocks, branches, blocks}
+	for _, f := range want {
+		b.AddFunc(f)
+	}
+	drws := &slicewriter.WriteSeeker{}
+	b.Emit(drws)
+	drws.Seek(0, io.SeekStart)
+	dec, err := decodemeta.NewCoverageMetaDataDecoder(drws.BytesWritten(), false)
+	if err != nil {
+		t.Fatalf("making decoder: %v", err)
+	}
+	var fn coverage.FuncDesc
+	for i := range want {
+		if err := dec.ReadFunc(uint32(i), &fn); err != nil {
+			t.Fatalf("err reading function %d: %v", i, err)
+		}
+		if res := cmpFuncDesc(want[i], fn); res != "" {
+			t.Errorf("ReadFunc(%d): %s", i, res)
+		}
+	}
+
+	// The kinds are part of the hash.
+	other := branches
+	other.Units = append([]coverage.CoverableUnit(nil), branches.Units...)
+	other.Units[4].Kind = coverage.UnitCondTrue
+	if encodemeta.HashFuncDesc(&branches) == encodemeta.HashFuncDesc(&other) {
+		t.Errorf("HashFuncDesc doesn't depend on unit kinds")
+	}
+}
+
+func TestMetaFileVersion(t *testing.T) {
+	// A meta-data file gets the current version only if some
+	// function in it has unit kinds, since older readers can't
+	// decode those; otherwise it keeps the version they accept.
+	d := t.TempDir()
+	writeFile := func(name string, blobs [][]byte) string {
+		mfpath := filepath.Join(d, name)
+		of, err := os.Create(mfpath)
+		if err != nil {
+			t.Fatalf("opening covmeta: %v", err)
+		}
+		mfw := encodemeta.NewCoverageMetaFileWriter(mfpath, of)
+		if err := mfw.Write([16]byte{}, blobs, coverage.CtrModeSet, coverage.CtrGranularityPerBlock); err != nil {
+			t.Fatalf("writing meta-file: %v", err)
+		}
+		if err := of.Close(); err != nil {
+			t.Fatalf("closing meta-file: %v", err)
+		}
+		return mfpath
+	}
+	readFile := func(mfpath string) error {
+		inf, err := os.Open(mfpath)
+		if err != nil {
+			t.Fatalf("open() on meta-file: %v", err)
+		}
+		defer inf.Close()
+		_, err = decodemeta.NewCoverageMetaFileReader(inf, nil)
+		return err
+	}
+	version := func(mfpath string) uint32 {
+		data, err := os.ReadFile(mfpath)
+		if err != nil {
+			t.Fatalf("reading meta-file: %v", err)
+		}
+		return binary.LittleEndian.Uint32(data[4:])
+	}
+
+	b, err := encodemeta.NewCoverageMetaDataBuilder("foo/pkg", "pkg", "")
+	if err != nil {
+		t.Fatalf("making builder: %v", err)
+	}
+	b.AddFunc(coverage.FuncDesc{
+		Funcname: "branches",
+		Srcfile:  "foo.go",
+		Units: []coverage.CoverableUnit{
+			coverage.CoverableUnit{StLine: 1, StCol: 2, EnLine: 3, EnCol: 4, NxStmts: 1},
+			coverage.CoverableUnit{StLine: 1, StCol: 5, EnLine: 1, EnCol: 6, Parent: 1, Kind: coverage.UnitBranch, Decision: 1},
+		},
+	})
+	drws := &slicewriter.WriteSeeker{}
+	b.Emit(drws)
+	kinds := drws.BytesWritten()
+
+	blocks := createMetaDataBlobs(t, 3)
+	for _, tc := range []struct {
+		name  string
+		blobs [][]byte
+		want  uint32
+	}{
+		{"covmeta.blocks", blocks, coverage.MetaFileVersionBlocks},
+		{"covmeta.kinds", append(blocks, kinds), coverage.MetaFileVersion},
+	} {
+		mfpath := writeFile(tc.name, tc.blobs)
+		if got := version(mfpath); got != tc.want {
+			t.Errorf("%s: version %d, want %d", tc.name, got, tc.want)
+		}
+		if err := readFile(mfpath); err != nil {
+			t.Errorf("%s: NewCoverageMetaFileReader failed with: %v", tc.name, err)
+		}
+	}
+
+	// A file from the future is rejected.
+	mfpath := writeFile("covmeta.future", blocks)
+	data, err := os.ReadFile(mfpath)
+	if err != nil {
+		t.Fatalf("reading meta-file: %v", err)
+	}
+	binary.LittleEndian.PutUint32(data[4:], coverage.MetaFileVersion+1)
+	if err := os.WriteFile(mfpath, data, 0666); err != nil {
+		t.Fatalf("writing meta-file: %v", err)
+	}
+	if err := readFile(mfpath); err == nil {
+		t.Errorf("NewCoverageMetaFileReader accepted version %d", coverage.MetaFileVersion+1)
+	}
+}
@@ -7,7 +7,6 @@ package goarch
 const (
 	_ArchFamily          = RISCV64
 	_DefaultPhysPageSize = 4096
-	_PCQuantum           = 4
 	_MinFrameSize        = 8
 	_StackAlign          = PtrSize
 )
@@ -0,0 +1,11 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build riscv64 && riscv64.compressed
+
+package goarch
+
+// With GORISCV64=...,compressed the assembler emits 2-byte
+// instructions, so PC deltas are recorded in 2-byte units.
+const _PCQuantum = 2
@@ -0,0 +1,83 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Gotracediff compares two Go 1.22+ execution traces, typically taken
+// before and after a change.
+//
+// Usage:
+//
+//	gotracediff [-json] [-all] before.trace after.trace
+//
+// Goroutines are grouped by entry function and the functions of their
+// creation stack, user tasks by type and user regions by name. For each
+// group that differs, the report shows the count, duration quantiles,
+// execution, scheduling latency, syscall time and time blocked by reason
+// in both traces.
+// With -json, the full comparison, including duration histograms, is
+// written as JSON instead.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"compile/src_internal/trace/tracediff"
+)
+
+var (
+	jsonFlag = flag.Bool("json", false, "write the comparison as JSON")
+	allFlag  = flag.Bool("all", false, "report unchanged groups too")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json] [-all] before.trace after.trace\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n")
+		flag.PrintDefaults()
+	}
+	log.SetFlags(0)
+	log.SetPrefix("gotracediff: ")
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	before := readProfile(flag.Arg(0))
+	after := readProfile(flag.Arg(1))
+	diff := tracediff.Compare(before, after)
+
+	w := bufio.NewWriter(os.Stdout)
+	if *jsonFlag {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(diff); err != nil {
+			log.Fatal(err)
+		}
+	} else if err := diff.WriteText(w, *allFlag); err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readProfile(name string) *tracediff.Profile {
+	f, err := os.Open(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	p, err := tracediff.Read(bufio.NewReader(f))
+	if err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+	return p
+}
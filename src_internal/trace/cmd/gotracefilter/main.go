@@ -0,0 +1,105 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Gotracefilter cuts a smaller, valid trace out of a Go 1.22+ execution
+// trace, to share or to open in tools that can't handle the whole thing.
+//
+// Usage:
+//
+//	gotracefilter [-start d] [-end d] [-g ids] [-task id] -o output.trace input.trace
+//
+// -start and -end select a window of time, as durations from the start
+// of the trace such as 1.5s. -g keeps only the goroutines in a
+// comma-separated list of IDs. -task keeps a user task and its subtasks:
+// the time they span, the goroutines that annotated them, and their
+// task, region and log events. The filters can be combined.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	trace "compile/src_internal/trace/v2"
+	"compile/src_internal/trace/v2/filter"
+)
+
+var (
+	output     = flag.String("o", "", "write the filtered trace to `file`")
+	start      = flag.Duration("start", 0, "keep events from `d` after the start of the trace")
+	end        = flag.Duration("end", 0, "keep events until `d` after the start of the trace")
+	goroutines = flag.String("g", "", "keep only the goroutines with the comma-separated `ids`")
+	task       = flag.Int64("task", -1, "keep only the user task with `id` and its subtasks")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-start d] [-end d] [-g ids] [-task id] -o output.trace input.trace\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n")
+		flag.PrintDefaults()
+	}
+	log.SetFlags(0)
+	log.SetPrefix("gotracefilter: ")
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 || *output == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input := flag.Arg(0)
+
+	var f filter.Filter
+	if *task >= 0 {
+		in, err := os.Open(input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f, err = filter.TaskFilter(bufio.NewReader(in), trace.TaskID(*task))
+		in.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *start > f.Start {
+		f.Start = *start
+	}
+	if *end > 0 && (f.End == 0 || *end < f.End) {
+		f.End = *end
+	}
+	if *goroutines != "" {
+		keep := make(map[trace.GoID]bool)
+		for _, s := range strings.Split(*goroutines, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				log.Fatalf("bad goroutine ID %q", s)
+			}
+			if f.Goroutines == nil || f.Goroutines[trace.GoID(id)] {
+				keep[trace.GoID(id)] = true
+			}
+		}
+		f.Goroutines = keep
+	}
+
+	in, err := os.Open(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.Create(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := filter.Copy(out, bufio.NewReader(in), f); err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
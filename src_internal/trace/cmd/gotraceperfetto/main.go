@@ -0,0 +1,77 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Gotraceperfetto converts a Go 1.22+ execution trace to the JSON trace
+// event format, which can be opened in https://ui.perfetto.dev or
+// queried with Perfetto's trace_processor.
+//
+// Usage:
+//
+//	gotraceperfetto [-o output.json] [trace.out]
+//
+// The trace is read from the named file, or from standard input,
+// and the JSON is written to standard output unless -o is given.
+// The conversion is streaming, so traces larger than memory can be
+// converted.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"compile/src_internal/trace/perfetto"
+)
+
+var output = flag.String("o", "", "write the JSON to `file` instead of standard output")
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-o output.json] [trace.out]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "\n")
+		flag.PrintDefaults()
+	}
+	log.SetFlags(0)
+	log.SetPrefix("gotraceperfetto: ")
+}
+
+func main() {
+	flag.Parse()
+
+	var r io.Reader = os.Stdin
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				log.Fatal(err)
+			}
+		}()
+		w = f
+	}
+
+	if err := perfetto.Convert(w, bufio.NewReader(r)); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	tracev2 "compile/src_internal/trace/v2"
+	"sort"
+	"time"
+)
+
+// CriticalPathKind is the kind of activity on a critical path.
+type CriticalPathKind uint8
+
+const (
+	PathUnknown   CriticalPathKind = iota // No information, e.g. before the goroutine appeared in the trace.
+	PathRunning                           // The goroutine was executing.
+	PathSchedWait                         // The goroutine was runnable, waiting for a P.
+	PathSyscall                           // The goroutine was in a syscall.
+	PathGCAssist                          // The goroutine was in a GC mark assist.
+	PathBlocked                           // The goroutine was blocked, and nothing in the trace woke it (e.g. the network poller or a timer).
+)
+
+// String returns a human-readable name for the kind.
+func (k CriticalPathKind) String() string {
+	switch k {
+	case PathRunning:
+		return "running"
+	case PathSchedWait:
+		return "sched wait"
+	case PathSyscall:
+		return "syscall"
+	case PathGCAssist:
+		return "GC assist"
+	case PathBlocked:
+		return "blocked"
+	}
+	return "unknown"
+}
+
+// CriticalPath is the critical path of some period of a goroutine's
+// execution, such as a user task or region, together with the
+// attribution of its latency.
+//
+// The path is found by walking the goroutine's execution backwards
+// from the end of the period. Whenever the goroutine was blocked and
+// another goroutine woke it up, or created it, the path continues on
+// that goroutine for the time the first one was blocked, recursively.
+type CriticalPath struct {
+	Goroutine  tracev2.GoID // The goroutine the period ended on.
+	Start, End tracev2.Time
+
+	// Segments is the critical path in time order. The segments
+	// are non-overlapping and cover Start to End.
+	Segments []CriticalPathSegment
+
+	// ByKind is the time on the critical path by kind of activity.
+	ByKind map[CriticalPathKind]time.Duration
+
+	// ByGoroutine is the time on the critical path spent in each goroutine.
+	ByGoroutine map[tracev2.GoID]time.Duration
+
+	// BlockedOn is the time Goroutine spent blocked, by the goroutine
+	// that unblocked it. It answers "who was I waiting for", while
+	// ByGoroutine also includes who those goroutines were waiting for.
+	BlockedOn map[tracev2.GoID]time.Duration
+}
+
+// CriticalPathSegment is a single step of a critical path.
+type CriticalPathSegment struct {
+	Goroutine  tracev2.GoID
+	Kind       CriticalPathKind
+	Reason     string // The block reason for PathBlocked.
+	Start, End tracev2.Time
+}
+
+// Duration returns the length of the segment.
+func (s CriticalPathSegment) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// CriticalPathAnalyzer records goroutine state history and the edges
+// between goroutines from a v2 trace, for computing critical paths.
+type CriticalPathAnalyzer struct {
+	gs     map[tracev2.GoID]*goroutineHistory
+	lastTs tracev2.Time // timestamp of the last event processed.
+}
+
+// goroutineHistory is the sequence of states of a goroutine.
+type goroutineHistory struct {
+	spans  []stateSpan
+	assist bool // whether the goroutine is currently in a mark assist.
+}
+
+// stateSpan is a period of time a goroutine spent in a single state.
+// It ends where the next span starts.
+type stateSpan struct {
+	start  tracev2.Time
+	state  tracev2.GoState
+	assist bool
+	reason string
+
+	// waker is the goroutine that took this goroutine out of
+	// the span's state, if the state is GoWaiting or GoNotExist.
+	waker tracev2.GoID
+}
+
+// NewCriticalPathAnalyzer creates a new critical path analyzer.
+func NewCriticalPathAnalyzer() *CriticalPathAnalyzer {
+	return &CriticalPathAnalyzer{
+		gs: make(map[tracev2.GoID]*goroutineHistory),
+	}
+}
+
+// Event feeds a single event into the analyzer. Events must be
+// fed in trace order.
+func (a *CriticalPathAnalyzer) Event(ev *tracev2.Event) {
+	a.lastTs = ev.Time()
+
+	switch ev.Kind() {
+	case tracev2.EventStateTransition:
+		st := ev.StateTransition()
+		if st.Resource.Kind != tracev2.ResourceGoroutine {
+			break
+		}
+		id := st.Resource.Goroutine()
+		old, new := st.Goroutine()
+		if old == new {
+			// Skip these events; they're not telling us anything new.
+			break
+		}
+		g := a.gs[id]
+		if g == nil {
+			g = &goroutineHistory{}
+			if old == tracev2.GoNotExist {
+				// The goroutine didn't exist for all time before this.
+				g.spans = append(g.spans, stateSpan{state: tracev2.GoNotExist})
+			}
+			a.gs[id] = g
+		}
+		// Record the edge out of a waiting state (an unblock) or
+		// out of nonexistence (a creation).
+		if n := len(g.spans); n > 0 && (old == tracev2.GoWaiting || old == tracev2.GoNotExist) {
+			if waker := ev.Goroutine(); waker != id {
+				g.spans[n-1].waker = waker
+			}
+		}
+		g.push(stateSpan{start: ev.Time(), state: new, assist: g.assist, reason: st.Reason})
+
+	case tracev2.EventRangeBegin, tracev2.EventRangeActive, tracev2.EventRangeEnd:
+		r := ev.Range()
+		if r.Scope.Kind != tracev2.ResourceGoroutine || r.Name != "GC mark assist" {
+			break
+		}
+		g := a.gs[r.Scope.Goroutine()]
+		if g == nil {
+			break
+		}
+		assist := ev.Kind() != tracev2.EventRangeEnd
+		if g.assist == assist {
+			break
+		}
+		g.assist = assist
+		if n := len(g.spans); n > 0 {
+			last := g.spans[n-1]
+			last.start = ev.Time()
+			last.assist = assist
+			last.waker = tracev2.NoGoroutine
+			g.push(last)
+		}
+	}
+}
+
+// push adds a new span to the history, replacing the last one
+// if they start at the same time.
+func (g *goroutineHistory) push(s stateSpan) {
+	if n := len(g.spans); n > 0 && g.spans[n-1].start == s.start {
+		g.spans[n-1] = s
+		return
+	}
+	g.spans = append(g.spans, s)
+}
+
+// Goroutine returns the critical path of goroutine goid's execution
+// between start and end.
+func (a *CriticalPathAnalyzer) Goroutine(goid tracev2.GoID, start, end tracev2.Time) *CriticalPath {
+	p := &CriticalPath{
+		Goroutine:   goid,
+		Start:       start,
+		End:         end,
+		ByKind:      make(map[CriticalPathKind]time.Duration),
+		ByGoroutine: make(map[tracev2.GoID]time.Duration),
+		BlockedOn:   make(map[tracev2.GoID]time.Duration),
+	}
+	if end <= start {
+		return p
+	}
+	w := &pathWalker{a: a, path: p, active: make(map[tracev2.GoID]bool)}
+	w.walk(goid, start, end, true)
+
+	// The walk produces the segments backwards.
+	segs := p.Segments
+	for i, j := 0, len(segs)-1; i < j; i, j = i+1, j-1 {
+		segs[i], segs[j] = segs[j], segs[i]
+	}
+	for _, s := range segs {
+		p.ByKind[s.Kind] += s.Duration()
+		p.ByGoroutine[s.Goroutine] += s.Duration()
+	}
+	return p
+}
+
+// Region returns the critical path of a region of goroutine g.
+// A region that isn't ended in the trace is taken to end at
+// the last event the analyzer saw.
+func (a *CriticalPathAnalyzer) Region(g *GoroutineSummary, r *UserRegionSummary) *CriticalPath {
+	start, end := g.CreationTime, a.lastTs
+	if r.Start != nil {
+		start = r.Start.Time()
+	}
+	if r.End != nil {
+		end = r.End.Time()
+	}
+	return a.Goroutine(g.ID, start, end)
+}
+
+// Task returns the critical path of task t, which ends on the goroutine
+// that ended the task. It returns nil if the task is not complete.
+func (a *CriticalPathAnalyzer) Task(t *UserTaskSummary) *CriticalPath {
+	if !t.Complete() {
+		return nil
+	}
+	return a.Goroutine(t.End.Goroutine(), t.Start.Time(), t.End.Time())
+}
+
+// pathWalker holds the state of a single critical path computation.
+type pathWalker struct {
+	a    *CriticalPathAnalyzer
+	path *CriticalPath
+
+	// active is the set of goroutines the walk is following, used to
+	// break cycles of goroutines apparently waking each other up.
+	active map[tracev2.GoID]bool
+}
+
+// walk adds the critical path of goroutine goid between start and end
+// to the path, backwards. root indicates whether goid is the goroutine
+// the path was requested for.
+func (w *pathWalker) walk(goid tracev2.GoID, start, end tracev2.Time, root bool) {
+	w.active[goid] = true
+	defer delete(w.active, goid)
+
+	var spans []stateSpan
+	if g := w.a.gs[goid]; g != nil {
+		spans = g.spans
+	}
+	// Find the last span that starts before end.
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].start >= end }) - 1
+	for ; i >= 0 && end > start; i-- {
+		s := spans[i]
+		from := max(s.start, start)
+		switch {
+		case s.state == tracev2.GoNotExist && s.waker == tracev2.NoGoroutine,
+			s.state == tracev2.GoUndetermined:
+			w.add(goid, PathUnknown, "", from, end)
+		case s.state == tracev2.GoNotExist || s.state == tracev2.GoWaiting && !s.assist:
+			if s.waker == tracev2.NoGoroutine || w.active[s.waker] {
+				w.add(goid, PathBlocked, s.reason, from, end)
+				break
+			}
+			if root && s.state == tracev2.GoWaiting {
+				w.path.BlockedOn[s.waker] += end.Sub(from)
+			}
+			w.walk(s.waker, from, end, false)
+		case s.assist:
+			w.add(goid, PathGCAssist, "", from, end)
+		case s.state == tracev2.GoRunning:
+			w.add(goid, PathRunning, "", from, end)
+		case s.state == tracev2.GoRunnable:
+			w.add(goid, PathSchedWait, "", from, end)
+		case s.state == tracev2.GoSyscall:
+			w.add(goid, PathSyscall, "", from, end)
+		}
+		end = from
+	}
+	if end > start {
+		// Before the goroutine's first appearance.
+		w.add(goid, PathUnknown, "", start, end)
+	}
+}
+
+// add adds a segment to the path, which is built backwards, merging
+// it with the previously added segment if possible.
+func (w *pathWalker) add(goid tracev2.GoID, kind CriticalPathKind, reason string, start, end tracev2.Time) {
+	segs := w.path.Segments
+	if n := len(segs); n > 0 {
+		last := &segs[n-1]
+		if last.Goroutine == goid && last.Kind == kind && last.Reason == reason && last.Start == end {
+			last.Start = start
+			return
+		}
+	}
+	w.path.Segments = append(segs, CriticalPathSegment{
+		Goroutine: goid,
+		Kind:      kind,
+		Reason:    reason,
+		Start:     start,
+		End:       end,
+	})
+}
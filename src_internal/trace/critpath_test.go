@@ -0,0 +1,186 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	tracev2 "compile/src_internal/trace/v2"
+	"compile/src_internal/trace/v2/testtrace"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCriticalPathTrace(t *testing.T) {
+	summary, a := criticalPathTraceTest(t, "v2/testdata/tests/go122-critical-path.test")
+
+	// See the generator for the scenario. Every event in the
+	// trace is one tick after the previous one.
+	task := summary.Tasks[1]
+	if task == nil || task.Name != "rpc" {
+		t.Fatalf("missing task rpc")
+	}
+	p := a.Task(task)
+	checkCriticalPath(t, p)
+	if p.Goroutine != 1 {
+		t.Errorf("got path ending on goroutine %d, want 1", p.Goroutine)
+	}
+	tick := p.End.Sub(p.Start) / 13
+
+	type seg struct {
+		g          tracev2.GoID
+		kind       CriticalPathKind
+		start, end int
+	}
+	want := []seg{
+		{1, PathRunning, 0, 3},
+		{2, PathSchedWait, 3, 4},
+		{2, PathRunning, 4, 5},
+		{2, PathSyscall, 5, 6},
+		{2, PathRunning, 6, 7},
+		{1, PathSchedWait, 7, 9},
+		{1, PathRunning, 9, 10},
+		{1, PathGCAssist, 10, 11},
+		{1, PathRunning, 11, 13},
+	}
+	var got []seg
+	for _, s := range p.Segments {
+		got = append(got, seg{s.Goroutine, s.Kind, int(s.Start.Sub(p.Start) / tick), int(s.End.Sub(p.Start) / tick)})
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got segments %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("segment %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	checkDurations(t, "ByKind", p.ByKind, map[CriticalPathKind]time.Duration{
+		PathRunning:   8 * tick,
+		PathSchedWait: 3 * tick,
+		PathSyscall:   1 * tick,
+		PathGCAssist:  1 * tick,
+	})
+	checkDurations(t, "ByGoroutine", p.ByGoroutine, map[tracev2.GoID]time.Duration{
+		1: 9 * tick,
+		2: 4 * tick,
+	})
+	checkDurations(t, "BlockedOn", p.BlockedOn, map[tracev2.GoID]time.Duration{
+		2: 4 * tick,
+	})
+
+	// The region starts one tick after the task and ends
+	// one tick before it.
+	g := summary.Goroutines[1]
+	var region *UserRegionSummary
+	for _, r := range g.Regions {
+		if r.Name == "handle" {
+			region = r
+		}
+	}
+	if region == nil {
+		t.Fatalf("missing region handle")
+	}
+	p = a.Region(g, region)
+	checkCriticalPath(t, p)
+	checkDurations(t, "region ByKind", p.ByKind, map[CriticalPathKind]time.Duration{
+		PathRunning:   6 * tick,
+		PathSchedWait: 3 * tick,
+		PathSyscall:   1 * tick,
+		PathGCAssist:  1 * tick,
+	})
+
+	// Before the trace starts, there's no information.
+	p = a.Goroutine(2, 0, p.End)
+	checkCriticalPath(t, p)
+	if p.Segments[0].Kind != PathUnknown {
+		t.Errorf("got first segment %+v, want unknown", p.Segments[0])
+	}
+}
+
+func TestCriticalPathAnnotationsTrace(t *testing.T) {
+	summary, a := criticalPathTraceTest(t, "v2/testdata/tests/go122-annotations.test")
+	n := 0
+	for _, task := range summary.Tasks {
+		if p := a.Task(task); p != nil {
+			checkCriticalPath(t, p)
+			n++
+		}
+	}
+	if n == 0 {
+		t.Error("no complete tasks")
+	}
+	for _, g := range summary.Goroutines {
+		for _, r := range g.Regions {
+			checkCriticalPath(t, a.Region(g, r))
+		}
+	}
+}
+
+// checkCriticalPath checks that the segments of a path are in order
+// and cover it exactly, and that the totals add up.
+func checkCriticalPath(t *testing.T, p *CriticalPath) {
+	t.Helper()
+	ts := p.Start
+	for _, s := range p.Segments {
+		if s.Start != ts || s.End <= s.Start {
+			t.Fatalf("bad segment %+v at %d in path %d-%d", s, ts, p.Start, p.End)
+		}
+		ts = s.End
+	}
+	if ts != p.End {
+		t.Fatalf("path ends at %d, want %d", ts, p.End)
+	}
+	var byKind, byG time.Duration
+	for _, dt := range p.ByKind {
+		byKind += dt
+	}
+	for _, dt := range p.ByGoroutine {
+		byG += dt
+	}
+	if total := p.End.Sub(p.Start); byKind != total || byG != total {
+		t.Errorf("got totals %v by kind and %v by goroutine, want %v", byKind, byG, total)
+	}
+}
+
+func checkDurations[K comparable](t *testing.T, what string, got, want map[K]time.Duration) {
+	t.Helper()
+	for k, dt := range want {
+		if got[k] != dt {
+			t.Errorf("%s[%v] = %v, want %v", what, k, got[k], dt)
+		}
+	}
+	for k, dt := range got {
+		if _, ok := want[k]; !ok && dt != 0 {
+			t.Errorf("unexpected %s[%v] = %v", what, k, dt)
+		}
+	}
+}
+
+func criticalPathTraceTest(t *testing.T, testPath string) (*Summary, *CriticalPathAnalyzer) {
+	trace, _, err := testtrace.ParseFile(testPath)
+	if err != nil {
+		t.Fatalf("malformed test %s: bad trace file: %v", testPath, err)
+	}
+	s := NewSummarizer()
+	a := NewCriticalPathAnalyzer()
+
+	r, err := tracev2.NewReader(trace)
+	if err != nil {
+		t.Fatalf("failed to create trace reader for %s: %v", testPath, err)
+	}
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to process trace %s: %v", testPath, err)
+		}
+		s.Event(&ev)
+		a.Event(&ev)
+	}
+	return s.Finalize(), a
+}
@@ -0,0 +1,514 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package perfetto converts v2 execution traces to the JSON trace event
+// format, which Perfetto (https://ui.perfetto.dev and trace_processor)
+// and the Chrome trace viewer import.
+//
+// The output is organized as processes containing tracks:
+//
+//   - "Runtime" has a track for each of the GC concurrent mark phase
+//     and stop-the-world pauses, and counters for the heap size, heap
+//     goal and GOMAXPROCS.
+//   - "Procs" has a track per P, with a slice for each goroutine that
+//     runs on it, and GC sweeps as async slices.
+//   - "Threads" has a track per M, with a slice for each goroutine that
+//     runs or makes a system call on it.
+//   - "Goroutines" has a track per goroutine, with a slice for each of
+//     its states, logs and labels as instant events, and user regions
+//     and GC mark assists as async slices.
+//   - "Tasks" has user tasks as async slices.
+//
+// Stacks are not exported. The conversion is streaming: memory use
+// depends on the number of live goroutines, Ps, Ms and tasks, not on
+// the length of the trace.
+package perfetto
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"compile/src_internal/trace/traceviewer/format"
+	tracev2 "compile/src_internal/trace/v2"
+)
+
+// Process IDs for the groups of tracks.
+const (
+	pidRuntime = iota
+	pidProcs
+	pidThreads
+	pidGoroutines
+	pidTasks
+)
+
+var processNames = [...]string{
+	pidRuntime:    "Runtime",
+	pidProcs:      "Procs",
+	pidThreads:    "Threads",
+	pidGoroutines: "Goroutines",
+	pidTasks:      "Tasks",
+}
+
+// Thread IDs of the tracks in the Runtime process.
+const (
+	tidGC  = 1
+	tidSTW = 2
+)
+
+// Async slices are matched by category and ID. These are ORed into the
+// ID of the goroutine or P an async slice belongs to, to keep the IDs
+// of each kind apart and nonzero. Task IDs are used unchanged.
+const (
+	idRegion = 1 << 56
+	idAssist = 2 << 56
+	idSweep  = 3 << 56
+)
+
+// A Converter converts a v2 execution trace to the JSON trace event
+// format. Events are passed to it in the order they are read from a
+// [tracev2.Reader], and it writes their JSON as it goes.
+type Converter struct {
+	w   *bufio.Writer
+	err error // first error writing
+
+	wrote   bool         // whether any JSON event has been written
+	n       int          // number of trace events converted
+	firstTs tracev2.Time // timestamp of the first trace event
+	lastTs  tracev2.Time // timestamp of the last event
+
+	// State of the goroutines that currently exist.
+	gs map[tracev2.GoID]*goroutine
+
+	// The tracks that have been named.
+	named map[trackKey]bool
+
+	// Open slices: duration slices by track, and async
+	// slices by category and ID.
+	depth map[trackKey]int
+	async map[asyncKey][]string
+}
+
+// goroutine is the state of a goroutine.
+type goroutine struct {
+	name  string           // goroutine name for slices
+	named bool             // whether name includes the entry function
+	p     tracev2.ProcID   // P it is running on, or NoProc
+	m     tracev2.ThreadID // M it is executing on, or NoThread
+}
+
+type trackKey struct {
+	pid, tid uint64
+}
+
+type asyncKey struct {
+	pid uint64
+	cat string
+	id  uint64
+}
+
+// NewConverter returns a Converter that writes to w.
+func NewConverter(w io.Writer) *Converter {
+	c := &Converter{
+		w:     bufio.NewWriter(w),
+		gs:    make(map[tracev2.GoID]*goroutine),
+		named: make(map[trackKey]bool),
+		depth: make(map[trackKey]int),
+		async: make(map[asyncKey][]string),
+	}
+	c.w.WriteString(`{"displayTimeUnit":"ns","traceEvents":[`)
+	for pid, name := range processNames {
+		c.emit(&format.Event{Name: "process_name", Phase: "M", PID: uint64(pid), Arg: &format.NameArg{Name: name}})
+		c.emit(&format.Event{Name: "process_sort_index", Phase: "M", PID: uint64(pid), Arg: &format.SortIndexArg{Index: pid}})
+	}
+	c.nameTrack(pidRuntime, tidGC, "GC")
+	c.nameTrack(pidRuntime, tidSTW, "STW")
+	return c
+}
+
+// Convert reads a v2 execution trace from r and writes it to w
+// in the JSON trace event format.
+func Convert(w io.Writer, r io.Reader) error {
+	tr, err := tracev2.NewReader(r)
+	if err != nil {
+		return err
+	}
+	c := NewConverter(w)
+	for {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.Event(&ev); err != nil {
+			return err
+		}
+	}
+	return c.Close()
+}
+
+// Event converts a single event.
+func (c *Converter) Event(ev *tracev2.Event) error {
+	if c.n == 0 {
+		c.firstTs = ev.Time()
+	}
+	c.lastTs = ev.Time()
+
+	switch ev.Kind() {
+	case tracev2.EventStateTransition:
+		st := ev.StateTransition()
+		if st.Resource.Kind == tracev2.ResourceGoroutine {
+			c.goTransition(ev, st)
+		}
+
+	case tracev2.EventRangeBegin, tracev2.EventRangeActive:
+		r := ev.Range()
+		pid, tid, cat, id := c.rangeTrack(r)
+		if cat != "" {
+			key := asyncKey{pid, cat, id}
+			if len(c.async[key]) == 0 {
+				c.asyncBegin(key, tid, r.Name, ev.Time(), nil)
+			}
+		} else if c.depth[trackKey{pid, tid}] == 0 {
+			c.begin(pid, tid, r.Name, ev.Time(), nil)
+		}
+	case tracev2.EventRangeEnd:
+		r := ev.Range()
+		var args map[string]any
+		if attrs := ev.RangeAttributes(); len(attrs) > 0 {
+			args = make(map[string]any)
+			for _, a := range attrs {
+				args[a.Name] = a.Value.Uint64()
+			}
+		}
+		pid, tid, cat, id := c.rangeTrack(r)
+		if cat != "" {
+			c.asyncEnd(asyncKey{pid, cat, id}, tid, ev.Time(), args)
+		} else {
+			c.end(pid, tid, ev.Time(), args)
+		}
+
+	case tracev2.EventTaskBegin:
+		t := ev.Task()
+		args := map[string]any{"id": uint64(t.ID)}
+		if t.Parent != tracev2.NoTask && t.Parent != tracev2.BackgroundTask {
+			args["parent"] = uint64(t.Parent)
+		}
+		c.asyncBegin(asyncKey{pidTasks, "task", uint64(t.ID)}, 0, t.Type, ev.Time(), args)
+	case tracev2.EventTaskEnd:
+		t := ev.Task()
+		key := asyncKey{pidTasks, "task", uint64(t.ID)}
+		if len(c.async[key]) == 0 {
+			// The task began before the trace did.
+			c.asyncBegin(key, 0, t.Type, c.firstTs, map[string]any{"id": uint64(t.ID)})
+		}
+		c.asyncEnd(key, 0, ev.Time(), nil)
+
+	case tracev2.EventRegionBegin:
+		r := ev.Region()
+		goid := ev.Goroutine()
+		c.asyncBegin(asyncKey{pidGoroutines, "region", idRegion | uint64(goid)}, uint64(goid), r.Type, ev.Time(), map[string]any{"task": uint64(r.Task)})
+	case tracev2.EventRegionEnd:
+		r := ev.Region()
+		goid := ev.Goroutine()
+		key := asyncKey{pidGoroutines, "region", idRegion | uint64(goid)}
+		if len(c.async[key]) == 0 {
+			// The region began before the trace did.
+			c.asyncBegin(key, uint64(goid), r.Type, c.firstTs, map[string]any{"task": uint64(r.Task)})
+		}
+		c.asyncEnd(key, uint64(goid), ev.Time(), nil)
+
+	case tracev2.EventLog:
+		if ev.Goroutine() == tracev2.NoGoroutine {
+			break
+		}
+		l := ev.Log()
+		name := l.Category
+		if name == "" {
+			name = "log"
+		}
+		c.emit(&format.Event{
+			Name:  name,
+			Phase: "i",
+			Scope: "t",
+			Time:  c.ts(ev.Time()),
+			PID:   pidGoroutines,
+			TID:   uint64(ev.Goroutine()),
+			Arg:   map[string]any{"category": l.Category, "message": l.Message, "task": uint64(l.Task)},
+		})
+	case tracev2.EventLabel:
+		l := ev.Label()
+		if l.Resource.Kind == tracev2.ResourceGoroutine {
+			c.emit(&format.Event{
+				Name:     l.Label,
+				Phase:    "i",
+				Scope:    "t",
+				Time:     c.ts(ev.Time()),
+				PID:      pidGoroutines,
+				TID:      uint64(l.Resource.Goroutine()),
+				Category: "label",
+			})
+		}
+
+	case tracev2.EventMetric:
+		m := ev.Metric()
+		if m.Value.Kind() == tracev2.ValueUint64 {
+			c.emit(&format.Event{
+				Name:  m.Name,
+				Phase: "C",
+				Time:  c.ts(ev.Time()),
+				PID:   pidRuntime,
+				Arg:   map[string]uint64{"value": m.Value.Uint64()},
+			})
+		}
+	}
+	c.n++
+	return c.err
+}
+
+// goTransition converts a goroutine state transition.
+func (c *Converter) goTransition(ev *tracev2.Event, st tracev2.StateTransition) {
+	id := st.Resource.Goroutine()
+	old, new := st.Goroutine()
+	if old == new {
+		return
+	}
+	ts := ev.Time()
+	g := c.gs[id]
+	if g == nil {
+		g = &goroutine{name: fmt.Sprintf("G%d", id), p: tracev2.NoProc, m: tracev2.NoThread}
+		c.gs[id] = g
+		c.nameTrack(pidGoroutines, uint64(id), g.name)
+	}
+	if !g.named {
+		// The root frame of any transition stack of the goroutine
+		// is its entry function. See trace.GoroutineSummary.
+		var fn string
+		st.Stack.Frames(func(f tracev2.StackFrame) bool {
+			fn = f.Func
+			return true
+		})
+		if fn != "" {
+			g.name = fmt.Sprintf("G%d %s", id, fn)
+			g.named = true
+			c.emit(&format.Event{Name: "thread_name", Phase: "M", PID: pidGoroutines, TID: uint64(id), Arg: &format.NameArg{Name: g.name}})
+		}
+	}
+
+	// Transition out of the old state.
+	c.end(pidGoroutines, uint64(id), ts, nil)
+	if g.p != tracev2.NoProc {
+		c.end(pidProcs, uint64(g.p), ts, nil)
+		g.p = tracev2.NoProc
+	}
+	if g.m != tracev2.NoThread {
+		c.end(pidThreads, uint64(g.m), ts, nil)
+		g.m = tracev2.NoThread
+	}
+
+	// Transition into the new state.
+	var args map[string]any
+	if st.Reason != "" {
+		args = map[string]any{"reason": st.Reason}
+	}
+	switch new {
+	case tracev2.GoRunning:
+		c.begin(pidGoroutines, uint64(id), "running", ts, nil)
+		if p := ev.Proc(); p != tracev2.NoProc {
+			c.nameTrack(pidProcs, uint64(p), fmt.Sprintf("Proc %d", p))
+			c.begin(pidProcs, uint64(p), g.name, ts, nil)
+			g.p = p
+		}
+		fallthrough
+	case tracev2.GoSyscall:
+		if new == tracev2.GoSyscall {
+			c.begin(pidGoroutines, uint64(id), "syscall", ts, nil)
+		}
+		if m := ev.Thread(); m != tracev2.NoThread {
+			c.nameTrack(pidThreads, uint64(m), fmt.Sprintf("Thread %d", m))
+			name := g.name
+			if new == tracev2.GoSyscall {
+				name += " (syscall)"
+			}
+			c.begin(pidThreads, uint64(m), name, ts, nil)
+			g.m = m
+		}
+	case tracev2.GoRunnable:
+		c.begin(pidGoroutines, uint64(id), stateName("runnable", st.Reason), ts, args)
+	case tracev2.GoWaiting:
+		c.begin(pidGoroutines, uint64(id), stateName("waiting", st.Reason), ts, args)
+	case tracev2.GoNotExist:
+		// End anything the goroutine left open.
+		for _, key := range []asyncKey{
+			{pidGoroutines, "region", idRegion | uint64(id)},
+			{pidGoroutines, "gc", idAssist | uint64(id)},
+		} {
+			for len(c.async[key]) > 0 {
+				c.asyncEnd(key, uint64(id), ts, nil)
+			}
+		}
+		delete(c.gs, id)
+		delete(c.named, trackKey{pidGoroutines, uint64(id)})
+	}
+}
+
+// stateName returns the name of a slice for a goroutine state.
+func stateName(state, reason string) string {
+	if reason == "" {
+		return state
+	}
+	return state + " (" + reason + ")"
+}
+
+// rangeTrack returns the track for a range. If cat is not empty,
+// the range is an async slice with that category and ID.
+func (c *Converter) rangeTrack(r tracev2.Range) (pid, tid uint64, cat string, id uint64) {
+	switch r.Scope.Kind {
+	case tracev2.ResourceGoroutine:
+		goid := uint64(r.Scope.Goroutine())
+		if strings.HasPrefix(r.Name, "stop-the-world") {
+			// Stopping the world is done by a goroutine,
+			// but affects all of them.
+			return pidRuntime, tidSTW, "", 0
+		}
+		return pidGoroutines, goid, "gc", idAssist | goid
+	case tracev2.ResourceProc:
+		p := uint64(r.Scope.Proc())
+		c.nameTrack(pidProcs, p, fmt.Sprintf("Proc %d", p))
+		return pidProcs, p, "gc", idSweep | p
+	}
+	return pidRuntime, tidGC, "", 0
+}
+
+// nameTrack names a track, if it is not yet named.
+func (c *Converter) nameTrack(pid, tid uint64, name string) {
+	key := trackKey{pid, tid}
+	if c.named[key] {
+		return
+	}
+	c.named[key] = true
+	c.emit(&format.Event{Name: "thread_name", Phase: "M", PID: pid, TID: tid, Arg: &format.NameArg{Name: name}})
+	c.emit(&format.Event{Name: "thread_sort_index", Phase: "M", PID: pid, TID: tid, Arg: &format.SortIndexArg{Index: int(tid)}})
+}
+
+// begin begins a slice on a track.
+func (c *Converter) begin(pid, tid uint64, name string, ts tracev2.Time, args map[string]any) {
+	c.depth[trackKey{pid, tid}]++
+	c.emit(&format.Event{Name: name, Phase: "B", Time: c.ts(ts), PID: pid, TID: tid, Arg: args})
+}
+
+// end ends the innermost open slice on a track, if there is one.
+func (c *Converter) end(pid, tid uint64, ts tracev2.Time, args map[string]any) {
+	key := trackKey{pid, tid}
+	if c.depth[key] == 0 {
+		return
+	}
+	if c.depth[key]--; c.depth[key] == 0 {
+		delete(c.depth, key)
+	}
+	c.emit(&format.Event{Phase: "E", Time: c.ts(ts), PID: pid, TID: tid, Arg: args})
+}
+
+// asyncBegin begins an async slice.
+func (c *Converter) asyncBegin(key asyncKey, tid uint64, name string, ts tracev2.Time, args map[string]any) {
+	c.async[key] = append(c.async[key], name)
+	c.emit(&format.Event{Name: name, Phase: "b", Category: key.cat, ID: key.id, Time: c.ts(ts), PID: key.pid, TID: tid, Arg: args})
+}
+
+// asyncEnd ends the innermost open async slice with the key,
+// if there is one.
+func (c *Converter) asyncEnd(key asyncKey, tid uint64, ts tracev2.Time, args map[string]any) {
+	stk := c.async[key]
+	if len(stk) == 0 {
+		return
+	}
+	name := stk[len(stk)-1]
+	if stk = stk[:len(stk)-1]; len(stk) == 0 {
+		delete(c.async, key)
+	} else {
+		c.async[key] = stk
+	}
+	c.emit(&format.Event{Name: name, Phase: "e", Category: key.cat, ID: key.id, Time: c.ts(ts), PID: key.pid, TID: tid, Arg: args})
+}
+
+// ts converts a trace timestamp to a JSON one, in microseconds
+// since the start of the trace.
+func (c *Converter) ts(t tracev2.Time) float64 {
+	return float64(t-c.firstTs) / 1e3
+}
+
+// emit writes an event.
+func (c *Converter) emit(ev *format.Event) {
+	if c.err != nil {
+		return
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		c.err = err
+		return
+	}
+	if c.wrote {
+		c.w.WriteByte(',')
+	}
+	c.wrote = true
+	c.w.WriteByte('\n')
+	if _, err := c.w.Write(b); err != nil {
+		c.err = err
+	}
+}
+
+// Close ends all the open slices at the time of the last event and
+// finishes writing the JSON. It does not close the underlying writer.
+func (c *Converter) Close() error {
+	// End the open slices in a deterministic order.
+	tracks := make([]trackKey, 0, len(c.depth))
+	for key := range c.depth {
+		tracks = append(tracks, key)
+	}
+	slices.SortFunc(tracks, func(a, b trackKey) int {
+		if a.pid != b.pid {
+			return cmp.Compare(a.pid, b.pid)
+		}
+		return cmp.Compare(a.tid, b.tid)
+	})
+	for _, key := range tracks {
+		for c.depth[key] > 0 {
+			c.end(key.pid, key.tid, c.lastTs, nil)
+		}
+	}
+	keys := make([]asyncKey, 0, len(c.async))
+	for key := range c.async {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b asyncKey) int {
+		if a.pid != b.pid {
+			return cmp.Compare(a.pid, b.pid)
+		}
+		if a.cat != b.cat {
+			return strings.Compare(a.cat, b.cat)
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	for _, key := range keys {
+		tid := key.id &^ (0xff << 56)
+		if key.pid == pidTasks {
+			tid = 0
+		}
+		for len(c.async[key]) > 0 {
+			c.asyncEnd(key, tid, c.lastTs, nil)
+		}
+	}
+
+	if c.err == nil {
+		c.w.WriteString("\n]}\n")
+		c.err = c.w.Flush()
+	}
+	return c.err
+}
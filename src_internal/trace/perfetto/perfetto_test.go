@@ -0,0 +1,277 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package perfetto
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"compile/src_internal/trace/traceviewer/format"
+	tracev2 "compile/src_internal/trace/v2"
+	"compile/src_internal/trace/v2/testtrace"
+)
+
+func TestConvert(t *testing.T) {
+	matches, err := filepath.Glob("../v2/testdata/tests/*.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) == 0 {
+		t.Fatal("no test traces")
+	}
+	for _, testPath := range matches {
+		t.Run(filepath.Base(testPath), func(t *testing.T) {
+			tr, exp, err := testtrace.ParseFile(testPath)
+			if err != nil {
+				t.Fatalf("malformed test %s: %v", testPath, err)
+			}
+			trace := tr.(*bytes.Buffer).Bytes()
+			if err := readAll(trace); exp.Check(err) != nil {
+				// Converting can only fail as reading does.
+				t.Skipf("reader does not meet expectation: %v", err)
+			}
+			var buf bytes.Buffer
+			err = Convert(&buf, bytes.NewReader(trace))
+			if err := exp.Check(err); err != nil {
+				t.Fatal(err)
+			}
+			if err == nil {
+				checkEvents(t, parseJSON(t, buf.Bytes()))
+			}
+		})
+	}
+}
+
+// readAll reads all the events in a trace.
+func readAll(trace []byte) error {
+	r, err := tracev2.NewReader(bytes.NewReader(trace))
+	if err != nil {
+		return err
+	}
+	for {
+		_, err := r.ReadEvent()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestConvertAnnotations(t *testing.T) {
+	data := convertTest(t, "go122-annotations.test")
+
+	want := map[string]string{
+		"task0":                "b",
+		"region0":              "b",
+		"region1":              "b",
+		"task0 region":         "b",
+		"unended region":       "b",
+		"post-existing region": "b",
+		"key0":                 "i",
+	}
+	for _, ev := range data.Events {
+		if want[ev.Name] == ev.Phase {
+			delete(want, ev.Name)
+		}
+		if ev.Phase == "i" && ev.Name == "key0" {
+			args := ev.Arg.(map[string]any)
+			if args["message"] != "0123456789abcdef" {
+				t.Errorf("log args = %v", args)
+			}
+		}
+	}
+	for name, phase := range want {
+		t.Errorf("missing %q event %q", phase, name)
+	}
+}
+
+func TestConvertGC(t *testing.T) {
+	data := convertTest(t, "go122-gc-ranges.test")
+
+	want := map[string]bool{
+		"GC":        false,
+		"STW":       false,
+		"assist":    false,
+		"sweep":     false,
+		"heap":      false,
+		"heap goal": false,
+		"label":     false,
+	}
+	for _, ev := range data.Events {
+		switch {
+		case ev.Phase == "B" && ev.PID == pidRuntime && ev.TID == tidGC:
+			want["GC"] = true
+		case ev.Phase == "B" && ev.PID == pidRuntime && ev.TID == tidSTW && ev.Name == "stop-the-world (sweep termination)":
+			want["STW"] = true
+		case ev.Phase == "b" && ev.Name == "GC mark assist" && ev.PID == pidGoroutines:
+			want["assist"] = true
+		case ev.Phase == "e" && ev.Name == "GC incremental sweep" && ev.PID == pidProcs:
+			args := ev.Arg.(map[string]any)
+			want["sweep"] = args["bytes swept"] == 8192.0 && args["bytes reclaimed"] == 4096.0
+		case ev.Phase == "C" && ev.Name == "/memory/classes/heap/objects:bytes":
+			want["heap"] = true
+		case ev.Phase == "C" && ev.Name == "/gc/heap/goal:bytes":
+			want["heap goal"] = true
+		case ev.Phase == "i" && ev.Name == "GC (dedicated)" && ev.TID == 1:
+			want["label"] = true
+		}
+	}
+	for what, ok := range want {
+		if !ok {
+			t.Errorf("missing %s", what)
+		}
+	}
+
+	// The mark assist and GC are active across the generation
+	// boundary, and appear as a single slice each.
+	n := 0
+	for _, ev := range data.Events {
+		if ev.Phase == "b" && ev.Name == "GC mark assist" || ev.Phase == "B" && ev.TID == tidGC && ev.PID == pidRuntime {
+			n++
+		}
+	}
+	if n != 2 {
+		t.Errorf("got %d GC and mark assist slices, want 2", n)
+	}
+}
+
+func TestConvertShortLivedGoroutines(t *testing.T) {
+	r, _, err := testtrace.ParseFile("../v2/testdata/tests/go122-short-lived-goroutines.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := tracev2.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	c := NewConverter(&buf)
+	maxGs, maxNamed := 0, 0
+	for {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Event(&ev); err != nil {
+			t.Fatal(err)
+		}
+		maxGs = max(maxGs, len(c.gs))
+		maxNamed = max(maxNamed, len(c.named))
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, parseJSON(t, buf.Bytes()))
+
+	// The trace creates 100 goroutines, but at most two
+	// exist at once. The state kept for them must not grow
+	// with the number of goroutines that have exited.
+	if maxGs > 2 {
+		t.Errorf("converter kept %d goroutines, want at most 2", maxGs)
+	}
+	if maxNamed > 10 {
+		t.Errorf("converter kept %d named tracks, want at most 10", maxNamed)
+	}
+}
+
+func convertTest(t *testing.T, name string) *format.Data {
+	tr, _, err := testtrace.ParseFile(filepath.Join("../v2/testdata/tests", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Convert(&buf, tr); err != nil {
+		t.Fatal(err)
+	}
+	data := parseJSON(t, buf.Bytes())
+	checkEvents(t, data)
+	return data
+}
+
+func parseJSON(t *testing.T, b []byte) *format.Data {
+	var data format.Data
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(data.Events) == 0 {
+		t.Fatal("no events")
+	}
+	return &data
+}
+
+// checkEvents checks that slices are properly nested
+// and ended, and that time never goes backwards on a track.
+func checkEvents(t *testing.T, data *format.Data) {
+	t.Helper()
+	type async struct {
+		pid uint64
+		cat string
+		id  uint64
+	}
+	depth := make(map[trackKey]int)
+	last := make(map[trackKey]float64)
+	open := make(map[async][]string)
+	for _, ev := range data.Events {
+		key := trackKey{ev.PID, ev.TID}
+		switch ev.Phase {
+		case "B", "E", "i":
+			if ev.Time < last[key] {
+				t.Fatalf("time goes backwards on track %v: %+v", key, ev)
+			}
+			last[key] = ev.Time
+		}
+		switch ev.Phase {
+		case "B":
+			depth[key]++
+		case "E":
+			if depth[key]--; depth[key] < 0 {
+				t.Fatalf("end without begin on track %v: %+v", key, ev)
+			}
+		case "b":
+			k := async{ev.PID, ev.Category, ev.ID}
+			open[k] = append(open[k], ev.Name)
+		case "e":
+			k := async{ev.PID, ev.Category, ev.ID}
+			stk := open[k]
+			if len(stk) == 0 || stk[len(stk)-1] != ev.Name {
+				t.Fatalf("async end does not match begin %v: %+v", stk, ev)
+			}
+			open[k] = stk[:len(stk)-1]
+		}
+	}
+	for key, d := range depth {
+		if d != 0 {
+			t.Errorf("track %v has %d slices not ended", key, d)
+		}
+	}
+	for key, stk := range open {
+		if len(stk) != 0 {
+			t.Errorf("async slices %v not ended: %v", key, stk)
+		}
+	}
+}
+
+func BenchmarkConvert(b *testing.B) {
+	tr, _, err := testtrace.ParseFile("../v2/testdata/tests/go122-annotations.test")
+	if err != nil {
+		b.Fatal(err)
+	}
+	trace := tr.(*bytes.Buffer).Bytes()
+	b.SetBytes(int64(len(trace)))
+	for i := 0; i < b.N; i++ {
+		var out bytes.Buffer
+		if err := Convert(&out, bytes.NewReader(trace)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
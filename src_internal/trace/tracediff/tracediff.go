@@ -0,0 +1,401 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tracediff compares two Go execution traces.
+//
+// Each trace is summarized into a Profile, which aggregates the
+// goroutines by their entry function and creation stack, user tasks
+// by type, and user regions by name. Two profiles are then compared group
+// by group, reporting changes in counts, duration distributions,
+// scheduling latency, syscall time and time blocked by reason.
+//
+// Aggregating this way makes traces of different runs of the same
+// program comparable even though goroutine and task IDs differ.
+// Creation stacks are compared by the functions in all of their
+// frames, so that goroutines created through a shared helper are
+// grouped by its callers, but line numbers are not compared, so that
+// traces of different builds of a program remain comparable.
+package tracediff
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"compile/src_internal/trace"
+	"compile/src_internal/trace/traceviewer"
+	tracev2 "compile/src_internal/trace/v2"
+)
+
+// Profile is the aggregated statistics of a single trace.
+type Profile struct {
+	Goroutines map[string]*Stats // by entry function and creation stack
+	Tasks      map[string]*Stats // by task type
+	Regions    map[string]*Stats // by region name
+}
+
+// Stats are the statistics of a group of goroutines, tasks or regions.
+type Stats struct {
+	Count int
+
+	// Distribution of the durations of the members of the group.
+	// Only tasks and regions with both a start and an end in the
+	// trace are counted here.
+	Histogram traceviewer.TimeHistogram
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+	Total     time.Duration
+
+	// Execution statistics, summed over the group.
+	ExecTime      time.Duration
+	SchedWaitTime time.Duration
+	SyscallTime   time.Duration
+	BlockTime     map[string]time.Duration // by reason
+
+	durations []time.Duration
+}
+
+func newStats() *Stats {
+	return &Stats{BlockTime: make(map[string]time.Duration)}
+}
+
+// add adds a member with duration d to the group. complete is false
+// if the duration isn't known.
+func (s *Stats) add(d time.Duration, complete bool, exec *trace.GoroutineExecStats) {
+	s.Count++
+	if complete {
+		s.durations = append(s.durations, d)
+		s.Histogram.Add(d)
+		s.Total += d
+	}
+	if exec == nil {
+		return
+	}
+	s.ExecTime += exec.ExecTime
+	s.SchedWaitTime += exec.SchedWaitTime
+	s.SyscallTime += exec.SyscallTime
+	s.BlockTime["syscall"] += exec.SyscallBlockTime
+	for reason, dt := range exec.BlockTimeByReason {
+		s.BlockTime[reason] += dt
+	}
+}
+
+// finish computes the quantiles and drops the samples.
+func (s *Stats) finish() {
+	slices.Sort(s.durations)
+	if n := len(s.durations); n > 0 {
+		s.P50 = s.durations[(n-1)*50/100]
+		s.P90 = s.durations[(n-1)*90/100]
+		s.P99 = s.durations[(n-1)*99/100]
+		s.Max = s.durations[n-1]
+	}
+	for reason, dt := range s.BlockTime {
+		if dt == 0 {
+			delete(s.BlockTime, reason)
+		}
+	}
+	s.durations = nil
+}
+
+// Read reads a v2 trace from r and summarizes it into a profile.
+func Read(r io.Reader) (*Profile, error) {
+	tr, err := tracev2.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	s := trace.NewSummarizer()
+	creators := make(map[tracev2.GoID]string)
+	for {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.Event(&ev)
+
+		// Record where goroutines were created, which the summary
+		// doesn't keep.
+		if ev.Kind() != tracev2.EventStateTransition {
+			continue
+		}
+		st := ev.StateTransition()
+		if st.Resource.Kind != tracev2.ResourceGoroutine {
+			continue
+		}
+		if old, _ := st.Goroutine(); old == tracev2.GoNotExist {
+			creators[st.Resource.Goroutine()] = stackFuncs(ev.Stack())
+		}
+	}
+	return NewProfile(s.Finalize(), creators), nil
+}
+
+// stackFuncs returns the functions of the frames of stk,
+// innermost first.
+func stackFuncs(stk tracev2.Stack) string {
+	var fns []string
+	stk.Frames(func(f tracev2.StackFrame) bool {
+		fns = append(fns, f.Func)
+		return true
+	})
+	return strings.Join(fns, " <- ")
+}
+
+// NewProfile aggregates a trace summary into a profile. creators maps
+// goroutines to the functions of the stack they were created on,
+// innermost first and separated by " <- ", if known.
+func NewProfile(s *trace.Summary, creators map[tracev2.GoID]string) *Profile {
+	p := &Profile{
+		Goroutines: make(map[string]*Stats),
+		Tasks:      make(map[string]*Stats),
+		Regions:    make(map[string]*Stats),
+	}
+	get := func(m map[string]*Stats, key string) *Stats {
+		st := m[key]
+		if st == nil {
+			st = newStats()
+			m[key] = st
+		}
+		return st
+	}
+	for _, g := range s.Goroutines {
+		get(p.Goroutines, goroutineKey(g.Name, creators[g.ID])).add(g.TotalTime, true, &g.GoroutineExecStats)
+		for _, r := range g.Regions {
+			if r.Name == "" {
+				// Task inheritance marker; not a real region.
+				continue
+			}
+			complete := r.Start != nil && r.End != nil
+			var d time.Duration
+			if complete {
+				d = r.End.Time().Sub(r.Start.Time())
+			}
+			get(p.Regions, r.Name).add(d, complete, &r.GoroutineExecStats)
+		}
+	}
+	for _, t := range s.Tasks {
+		if t.Name == "" {
+			// Only mentioned in the trace, never started or ended.
+			continue
+		}
+		var d time.Duration
+		if t.Complete() {
+			d = t.End.Time().Sub(t.Start.Time())
+		}
+		get(p.Tasks, t.Name).add(d, t.Complete(), nil)
+	}
+	for _, m := range []map[string]*Stats{p.Goroutines, p.Tasks, p.Regions} {
+		for _, st := range m {
+			st.finish()
+		}
+	}
+	return p
+}
+
+// goroutineKey returns the key goroutines are grouped by.
+func goroutineKey(name, creator string) string {
+	if name == "" {
+		name = "(unknown)"
+	}
+	if creator == "" {
+		return name
+	}
+	return name + " (created by " + creator + ")"
+}
+
+// Diff is the comparison of two profiles.
+type Diff struct {
+	Goroutines []*Delta
+	Tasks      []*Delta
+	Regions    []*Delta
+}
+
+// Delta is the change in a single group. Before or After is nil if
+// the group only appears in one of the traces.
+type Delta struct {
+	Name          string
+	Before, After *Stats
+}
+
+// change returns the change in total duration, which is
+// what deltas are ordered by.
+func (d *Delta) change() time.Duration {
+	var before, after time.Duration
+	if d.Before != nil {
+		before = d.Before.Total
+	}
+	if d.After != nil {
+		after = d.After.Total
+	}
+	if after < before {
+		return before - after
+	}
+	return after - before
+}
+
+// Compare compares two profiles. The deltas of each kind are sorted
+// by decreasing change in total duration, then by name.
+func Compare(before, after *Profile) *Diff {
+	return &Diff{
+		Goroutines: compare(before.Goroutines, after.Goroutines),
+		Tasks:      compare(before.Tasks, after.Tasks),
+		Regions:    compare(before.Regions, after.Regions),
+	}
+}
+
+func compare(before, after map[string]*Stats) []*Delta {
+	var ds []*Delta
+	for name, st := range before {
+		ds = append(ds, &Delta{Name: name, Before: st, After: after[name]})
+	}
+	for name, st := range after {
+		if before[name] == nil {
+			ds = append(ds, &Delta{Name: name, After: st})
+		}
+	}
+	slices.SortFunc(ds, func(a, b *Delta) int {
+		if c := cmp.Compare(b.change(), a.change()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return ds
+}
+
+// Changed reports whether any statistic differs between the two traces.
+func (d *Delta) Changed() bool {
+	if d.Before == nil || d.After == nil {
+		return true
+	}
+	rows := d.rows()
+	for _, r := range rows {
+		if r.before != r.after {
+			return true
+		}
+	}
+	return false
+}
+
+// row is a single statistic of a delta.
+type row struct {
+	name          string
+	before, after int64
+	duration      bool
+}
+
+func (d *Delta) rows() []row {
+	var before, after Stats
+	if d.Before != nil {
+		before = *d.Before
+	}
+	if d.After != nil {
+		after = *d.After
+	}
+	rows := []row{
+		{"count", int64(before.Count), int64(after.Count), false},
+		{"total", int64(before.Total), int64(after.Total), true},
+		{"p50", int64(before.P50), int64(after.P50), true},
+		{"p90", int64(before.P90), int64(after.P90), true},
+		{"p99", int64(before.P99), int64(after.P99), true},
+		{"max", int64(before.Max), int64(after.Max), true},
+		{"exec", int64(before.ExecTime), int64(after.ExecTime), true},
+		{"sched wait", int64(before.SchedWaitTime), int64(after.SchedWaitTime), true},
+		{"syscall", int64(before.SyscallTime), int64(after.SyscallTime), true},
+	}
+	var reasons []string
+	for reason := range before.BlockTime {
+		reasons = append(reasons, reason)
+	}
+	for reason := range after.BlockTime {
+		if _, ok := before.BlockTime[reason]; !ok {
+			reasons = append(reasons, reason)
+		}
+	}
+	slices.Sort(reasons)
+	for _, reason := range reasons {
+		label := reason
+		if label == "" {
+			label = "unknown"
+		}
+		rows = append(rows, row{"block (" + label + ")", int64(before.BlockTime[reason]), int64(after.BlockTime[reason]), true})
+	}
+	return rows
+}
+
+// WriteText writes a human-readable report of the changed groups to w.
+// If all is set, unchanged groups are reported too.
+func (d *Diff) WriteText(w io.Writer, all bool) error {
+	pw := &printer{w: w}
+	for _, sec := range []struct {
+		title  string
+		deltas []*Delta
+	}{
+		{"Goroutines", d.Goroutines},
+		{"Tasks", d.Tasks},
+		{"Regions", d.Regions},
+	} {
+		pw.printf("%s:\n", sec.title)
+		n := 0
+		for _, delta := range sec.deltas {
+			if !all && !delta.Changed() {
+				continue
+			}
+			n++
+			pw.printf("  %s", delta.Name)
+			switch {
+			case delta.Before == nil:
+				pw.printf(" (new)")
+			case delta.After == nil:
+				pw.printf(" (gone)")
+			}
+			pw.printf("\n")
+			for _, r := range delta.rows() {
+				if r.before == 0 && r.after == 0 {
+					continue
+				}
+				pw.printf("    %-24s %12s → %-12s %s\n", r.name, r.format(r.before), r.format(r.after), percent(r.before, r.after))
+			}
+		}
+		if n == 0 {
+			pw.printf("  (no changes)\n")
+		}
+	}
+	return pw.err
+}
+
+func (r row) format(v int64) string {
+	if r.duration {
+		return time.Duration(v).String()
+	}
+	return fmt.Sprint(v)
+}
+
+// percent returns the relative change from before to after.
+func percent(before, after int64) string {
+	switch {
+	case before == after:
+		return ""
+	case before == 0:
+		return "(new)"
+	}
+	return fmt.Sprintf("(%+.1f%%)", float64(after-before)/float64(before)*100)
+}
+
+// printer is a writer that remembers the first error.
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, args ...any) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
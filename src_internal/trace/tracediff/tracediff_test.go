@@ -0,0 +1,147 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tracediff
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"compile/src_internal/trace/v2/testtrace"
+)
+
+func TestCompareSame(t *testing.T) {
+	p := readTest(t, "go122-annotations.test")
+	d := Compare(p, readTest(t, "go122-annotations.test"))
+	for _, ds := range [][]*Delta{d.Goroutines, d.Tasks, d.Regions} {
+		if len(ds) == 0 {
+			t.Fatal("no deltas")
+		}
+		for _, delta := range ds {
+			if delta.Changed() {
+				t.Errorf("%s changed: %+v → %+v", delta.Name, delta.Before, delta.After)
+			}
+		}
+	}
+	var text strings.Builder
+	if err := d.WriteText(&text, false); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(text.String(), "(no changes)"); n != 3 {
+		t.Errorf("got report\n%s\nwant no changes", text.String())
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := readTest(t, "go122-critical-path.test")
+	after := readTest(t, "go122-annotations.test")
+
+	// See the generator of the critical path test for the scenario.
+	// Every event is one tick after the previous one.
+	task := before.Tasks["rpc"]
+	if task == nil {
+		t.Fatal("missing task rpc")
+	}
+	tick := task.Total / 13
+	if task.Count != 1 || task.P50 != 13*tick || task.Max != 13*tick || task.Histogram.Count != 1 {
+		t.Errorf("got task stats %+v", task)
+	}
+	region := before.Regions["handle"]
+	if region == nil {
+		t.Fatal("missing region handle")
+	}
+	if region.Total != 11*tick || region.SchedWaitTime != 2*tick || region.BlockTime["chan receive"] != 4*tick {
+		t.Errorf("got region stats %+v", region)
+	}
+
+	d := Compare(before, after)
+	checkDelta(t, d.Tasks, "rpc", true, false)
+	checkDelta(t, d.Regions, "handle", true, false)
+	checkDelta(t, d.Tasks, "task0", false, true)
+	checkDelta(t, d.Regions, "region0", false, true)
+
+	var text strings.Builder
+	if err := d.WriteText(&text, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"  rpc (gone)\n", "  task0 (new)\n", "block (chan receive)"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, text.String())
+		}
+	}
+
+	// The JSON form has the same content.
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Diff
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, d) {
+		t.Errorf("JSON round trip: got %+v, want %+v", got, d)
+	}
+}
+
+func TestGoroutineCreationStack(t *testing.T) {
+	// See the generator for the scenario: goroutines with the same
+	// entry function, created by the same helper from two callers.
+	p := readTest(t, "go122-go-create-shared-helper.test")
+	want := map[string]int{
+		"main.worker (created by main.spawn <- main.serve <- main.main)": 2,
+		"main.worker (created by main.spawn <- main.flush <- main.main)": 1,
+	}
+	for key, n := range want {
+		if st := p.Goroutines[key]; st == nil || st.Count != n {
+			t.Errorf("goroutines %q: got %+v, want count %d", key, st, n)
+		}
+	}
+}
+
+func checkDelta(t *testing.T, ds []*Delta, name string, before, after bool) {
+	t.Helper()
+	for _, d := range ds {
+		if d.Name == name {
+			if (d.Before != nil) != before || (d.After != nil) != after {
+				t.Errorf("delta %s: got before %v, after %v", name, d.Before, d.After)
+			}
+			return
+		}
+	}
+	t.Errorf("missing delta %s", name)
+}
+
+func readTest(t *testing.T, name string) *Profile {
+	tr, _, err := testtrace.ParseFile(filepath.Join("../v2/testdata/tests", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Read(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPercent(t *testing.T) {
+	for _, test := range []struct {
+		before, after int64
+		want          string
+	}{
+		{0, 0, ""},
+		{5, 5, ""},
+		{0, 5, "(new)"},
+		{4, 5, "(+25.0%)"},
+		{int64(2 * time.Second), int64(time.Second), "(-50.0%)"},
+	} {
+		if got := percent(test.before, test.after); got != test.want {
+			t.Errorf("percent(%d, %d) = %q, want %q", test.before, test.after, got, test.want)
+		}
+	}
+}
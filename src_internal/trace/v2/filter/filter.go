@@ -0,0 +1,172 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package filter writes a subset of a Go execution trace as a new,
+// valid trace: the events in a time window, of a set of goroutines, or
+// of a user task and its subtasks.
+//
+// The output is a Go 1.22 trace with its own generations, string and
+// stack tables, and with the states of goroutines and Ps at the start
+// of the window written as status events, so it can be read by
+// [trace.Reader] and the tools built on it.
+//
+// Events that belong to no goroutine, like Ps starting and stopping,
+// heap metrics and GC sweeps, are always kept within the window. When
+// filtering by goroutine, so that the output stays consistent, a
+// goroutine that isn't kept appears to never leave the Ps it runs on
+// during syscalls, and GC phases are dropped.
+package filter
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	trace "compile/src_internal/trace/v2"
+)
+
+// Filter selects the events of a trace to keep.
+type Filter struct {
+	// Start and End bound the window of time to keep, as offsets from
+	// the first event of the trace. A zero End means the end of the
+	// trace.
+	Start, End time.Duration
+
+	// Goroutines, if not nil, is the set of goroutines whose events
+	// are kept.
+	Goroutines map[trace.GoID]bool
+
+	// Tasks, if not nil, is the set of tasks whose task, region and
+	// log events are kept.
+	Tasks map[trace.TaskID]bool
+}
+
+// Copy reads a trace from r and writes the events that pass f to w.
+func Copy(w io.Writer, r io.Reader, f Filter) error {
+	tr, err := trace.NewReader(r)
+	if err != nil {
+		return err
+	}
+	fw := NewWriter(w, f)
+	for !fw.done {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := fw.Event(&ev); err != nil {
+			return err
+		}
+	}
+	return fw.Close()
+}
+
+// TaskFilter reads a trace from r and returns a filter for the user
+// task id and its subtasks. It keeps the time from the start of the task
+// to the end of the last of them, the goroutines that have task,
+// region or log events for any of them, and the annotations of those
+// tasks. If a task doesn't end in the trace, the window extends to the
+// end of the trace.
+func TaskFilter(r io.Reader, id trace.TaskID) (Filter, error) {
+	tr, err := trace.NewReader(r)
+	if err != nil {
+		return Filter{}, err
+	}
+	type taskInfo struct {
+		parent     trace.TaskID
+		start, end trace.Time
+		goroutines map[trace.GoID]bool
+	}
+	tasks := make(map[trace.TaskID]*taskInfo)
+	get := func(id trace.TaskID) *taskInfo {
+		t := tasks[id]
+		if t == nil {
+			t = &taskInfo{parent: trace.NoTask, goroutines: make(map[trace.GoID]bool)}
+			tasks[id] = t
+		}
+		return t
+	}
+	var first trace.Time
+	for n := 0; ; n++ {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Filter{}, err
+		}
+		if n == 0 {
+			first = ev.Time()
+		}
+		var t *taskInfo
+		switch ev.Kind() {
+		case trace.EventTaskBegin:
+			task := ev.Task()
+			t = get(task.ID)
+			t.parent = task.Parent
+			t.start = ev.Time()
+		case trace.EventTaskEnd:
+			t = get(ev.Task().ID)
+			t.end = ev.Time()
+		case trace.EventRegionBegin, trace.EventRegionEnd:
+			t = get(ev.Region().Task)
+		case trace.EventLog:
+			t = get(ev.Log().Task)
+		default:
+			continue
+		}
+		if t.start == 0 {
+			// The task began before the trace.
+			t.start = first
+		}
+		t.goroutines[ev.Goroutine()] = true
+	}
+
+	root := tasks[id]
+	if root == nil || id == trace.BackgroundTask {
+		return Filter{}, fmt.Errorf("task %d not found", id)
+	}
+	f := Filter{
+		Start:      root.start.Sub(first),
+		Goroutines: make(map[trace.GoID]bool),
+		Tasks:      make(map[trace.TaskID]bool),
+	}
+	// inTree reports whether task t is in the subtree. The walk is
+	// bounded in case of a malformed trace with a cycle of parents.
+	inTree := func(t trace.TaskID) bool {
+		for i := 0; t != trace.NoTask && t != trace.BackgroundTask && i <= len(tasks); i++ {
+			if t == id {
+				return true
+			}
+			p := tasks[t]
+			if p == nil {
+				break
+			}
+			t = p.parent
+		}
+		return false
+	}
+	var end trace.Time
+	open := false
+	for tid, t := range tasks {
+		if !inTree(tid) {
+			continue
+		}
+		f.Tasks[tid] = true
+		for g := range t.goroutines {
+			f.Goroutines[g] = true
+		}
+		if t.end == 0 {
+			open = true
+		}
+		end = max(end, t.end)
+	}
+	if !open {
+		// Include the last task end itself.
+		f.End = end.Sub(first) + 1
+	}
+	return f, nil
+}
@@ -0,0 +1,275 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package filter_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	trace "compile/src_internal/trace/v2"
+	"compile/src_internal/trace/v2/filter"
+	"compile/src_internal/trace/v2/testtrace"
+)
+
+func TestCopy(t *testing.T) {
+	matches, err := filepath.Glob("../testdata/tests/*.test")
+	if err != nil {
+		t.Fatalf("failed to glob for tests: %v", err)
+	}
+	for _, testPath := range matches {
+		t.Run(filepath.Base(testPath), func(t *testing.T) {
+			in, err := tryReadTestTrace(testPath)
+			if err != nil {
+				t.Skipf("can't read input: %v", err)
+			}
+			out := filterTrace(t, testPath, filter.Filter{})
+
+			// Statuses, active ranges and syncs are rewritten at the
+			// start of each generation, but all else should be the same.
+			checkEvents(t, out, in, true, func(ev *trace.Event) bool {
+				return !isStatus(ev) && ev.Kind() != trace.EventSync
+			})
+		})
+	}
+}
+
+func TestCopyWindow(t *testing.T) {
+	const testPath = "../testdata/tests/go122-critical-path.test"
+	in := readTestTrace(t, testPath)
+	base := in[0].Time()
+	for i := 0; i < len(in); i++ {
+		for j := i + 1; j <= len(in); j += 3 {
+			f := filter.Filter{Start: in[i].Time().Sub(base)}
+			if j < len(in) {
+				f.End = in[j].Time().Sub(base)
+			}
+			out := filterTrace(t, testPath, f)
+
+			// Ranges that started before the window are cut, so
+			// only check the goroutine and P transitions.
+			checkEvents(t, out, in, true, func(ev *trace.Event) bool {
+				d := ev.Time().Sub(base)
+				return ev.Kind() == trace.EventStateTransition && !isStatus(ev) &&
+					d >= f.Start && (f.End == 0 || d < f.End)
+			})
+		}
+	}
+}
+
+func TestCopyGoroutines(t *testing.T) {
+	const testPath = "../testdata/tests/go122-critical-path.test"
+	in := readTestTrace(t, testPath)
+	for _, g := range []trace.GoID{1, 2} {
+		f := filter.Filter{Goroutines: map[trace.GoID]bool{g: true}}
+		out := filterTrace(t, testPath, f)
+		// Goroutines that aren't kept disappear from the context
+		// of the events.
+		checkEvents(t, out, in, false, func(ev *trace.Event) bool {
+			if isStatus(ev) || ev.Kind() == trace.EventSync {
+				return false
+			}
+			if ev.Kind() == trace.EventStateTransition {
+				if st := ev.StateTransition(); st.Resource.Kind == trace.ResourceGoroutine {
+					return st.Resource.Goroutine() == g
+				}
+			}
+			switch ev.Kind() {
+			case trace.EventTaskBegin, trace.EventTaskEnd, trace.EventRegionBegin, trace.EventRegionEnd, trace.EventLog:
+				return ev.Goroutine() == g
+			case trace.EventRangeBegin, trace.EventRangeEnd:
+				r := ev.Range()
+				return r.Scope.Kind == trace.ResourceGoroutine && r.Scope.Goroutine() == g
+			}
+			return false
+		})
+	}
+}
+
+func TestTaskFilter(t *testing.T) {
+	const testPath = "../testdata/tests/go122-annotations.test"
+	in := readTestTrace(t, testPath)
+	for _, ev := range in {
+		if ev.Kind() != trace.EventTaskBegin {
+			continue
+		}
+		id := ev.Task().ID
+		r, _, err := testtrace.ParseFile(testPath)
+		if err != nil {
+			t.Fatalf("failed to parse test file at %s: %v", testPath, err)
+		}
+		f, err := filter.TaskFilter(r, id)
+		if err != nil {
+			t.Fatalf("task %d: %v", id, err)
+		}
+		if !f.Tasks[id] || len(f.Goroutines) == 0 {
+			t.Fatalf("task %d: got filter %+v", id, f)
+		}
+		out := filterTrace(t, testPath, f)
+		var n int
+		for _, ev := range out {
+			switch ev.Kind() {
+			case trace.EventTaskBegin, trace.EventTaskEnd:
+				if tid := ev.Task().ID; !f.Tasks[tid] {
+					t.Errorf("task %d: found event for task %d: %s", id, tid, ev.String())
+				}
+				n++
+			case trace.EventRegionBegin, trace.EventRegionEnd:
+				if tid := ev.Region().Task; !f.Tasks[tid] {
+					t.Errorf("task %d: found region of task %d: %s", id, tid, ev.String())
+				}
+			}
+		}
+		if n == 0 {
+			t.Errorf("task %d: no task events in output", id)
+		}
+	}
+	if _, err := filter.TaskFilter(mustParse(t, testPath), 1000); err == nil {
+		t.Errorf("expected error for unknown task")
+	}
+}
+
+// checkEvents checks that out has the events of in selected by keep,
+// in order, and no others besides statuses, active ranges and syncs.
+func checkEvents(t *testing.T, out, in []trace.Event, withCtx bool, keep func(*trace.Event) bool) {
+	t.Helper()
+	var want, got []string
+	for i := range in {
+		if keep(&in[i]) {
+			want = append(want, eventString(&in[i], withCtx))
+		}
+	}
+	for i := range out {
+		if keep(&out[i]) {
+			got = append(got, eventString(&out[i], withCtx))
+		}
+	}
+	for i := range min(len(got), len(want)) {
+		if got[i] != want[i] {
+			t.Fatalf("event %d: got\n%s\nwant\n%s", i, got[i], want[i])
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+}
+
+// eventString returns the string form of ev, without its timestamp,
+// which the reader may move by a few nanoseconds when it breaks ties
+// between events, and optionally without its context.
+func eventString(ev *trace.Event, withCtx bool) string {
+	s := timeRE.ReplaceAllString(ev.String(), "")
+	if !withCtx {
+		s = ctxRE.ReplaceAllString(s, "")
+	}
+	return s
+}
+
+var (
+	timeRE = regexp.MustCompile(` Time=\d+`)
+	ctxRE  = regexp.MustCompile(`^M=\S+ P=\S+ G=\S+ `)
+)
+
+// isStatus reports whether ev only states what is already known, or
+// the state of a resource the first time it's mentioned.
+func isStatus(ev *trace.Event) bool {
+	switch ev.Kind() {
+	case trace.EventRangeActive:
+		return true
+	case trace.EventStateTransition:
+		st := ev.StateTransition()
+		switch st.Resource.Kind {
+		case trace.ResourceGoroutine:
+			old, new := st.Goroutine()
+			return old == new || old == trace.GoUndetermined
+		case trace.ResourceProc:
+			old, new := st.Proc()
+			return old == new || old == trace.ProcUndetermined || old == trace.ProcNotExist
+		}
+	}
+	return false
+}
+
+// readTestTrace reads all the events of a test trace that is expected
+// to be read successfully.
+func readTestTrace(t *testing.T, testPath string) []trace.Event {
+	t.Helper()
+	evs, err := tryReadTestTrace(testPath)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", testPath, err)
+	}
+	return evs
+}
+
+func tryReadTestTrace(testPath string) ([]trace.Event, error) {
+	r, exp, err := testtrace.ParseFile(testPath)
+	if err != nil {
+		return nil, err
+	}
+	if exp.Check(nil) != nil {
+		return nil, fmt.Errorf("trace is expected to be invalid")
+	}
+	tr, err := trace.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var evs []trace.Event
+	for {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			return evs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		evs = append(evs, ev)
+	}
+}
+
+// filterTrace filters a test trace, and reads and validates the output.
+func filterTrace(t *testing.T, testPath string, f filter.Filter) []trace.Event {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := filter.Copy(&buf, mustParse(t, testPath), f); err != nil {
+		t.Fatalf("failed to filter %s: %v", testPath, err)
+	}
+	return readTrace(t, &buf)
+}
+
+func mustParse(t *testing.T, testPath string) io.Reader {
+	t.Helper()
+	r, _, err := testtrace.ParseFile(testPath)
+	if err != nil {
+		t.Fatalf("failed to parse test file at %s: %v", testPath, err)
+	}
+	return r
+}
+
+func readTrace(t *testing.T, r io.Reader) []trace.Event {
+	t.Helper()
+	tr, err := trace.NewReader(r)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	v := testtrace.NewValidator()
+	var evs []trace.Event
+	for {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read trace: %v", err)
+		}
+		if err := v.Event(ev); err != nil {
+			t.Fatalf("invalid trace: %v", err)
+		}
+		evs = append(evs, ev)
+	}
+	return evs
+}
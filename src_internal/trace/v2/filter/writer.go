@@ -0,0 +1,931 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package filter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+	"strings"
+
+	trace "compile/src_internal/trace/v2"
+	"compile/src_internal/trace/v2/event"
+	"compile/src_internal/trace/v2/event/go122"
+	"compile/src_internal/trace/v2/raw"
+	"compile/src_internal/trace/v2/version"
+)
+
+// A Writer writes the events of a trace that pass a filter as a new
+// trace. Events are passed to it in the order they are read from a
+// [trace.Reader].
+//
+// The writer keeps a model of the state of every goroutine, P and M in
+// the input, and of the state the output has told its reader about.
+// At the start of each output generation it writes the status of every
+// P and kept goroutine from that model, and at the start of the output
+// it also writes the GC phases, sweeps and mark assists in progress.
+// Ranges that began outside the output are never ended in it.
+//
+// Events of the first input generation are buffered until its end,
+// because the states of resources that were already running when
+// tracing started are only known by the time they are first mentioned.
+// After that, memory use is bounded by a batch per M and the string and
+// stack tables of the current generation.
+type Writer struct {
+	bw  *bufio.Writer
+	rw  *raw.Writer
+	f   Filter
+	err error // first error writing
+
+	start, end trace.Time // absolute window, known at the first event
+	started    bool
+	done       bool // past the end of the window
+
+	firstGen bool          // whether events are from the first input generation
+	pending  []trace.Event // events of the first input generation
+
+	// State of the input.
+	gs map[trace.GoID]*gState
+	ps map[trace.ProcID]*pState
+	ms map[trace.ThreadID]*mState
+	gc bool // whether the GC mark phase is in progress
+
+	// State of the output.
+	gen      *genWriter // current output generation, nil between generations
+	genNum   uint64     // number of the last output generation
+	genStart trace.Time // time of the statuses that start the next generation
+	outGC    bool       // GC mark phase in progress
+	outGCSet bool       // any GC phase event written
+	outGCSeq uint64
+}
+
+// gState is the state of a goroutine.
+type gState struct {
+	status trace.GoState
+	m      trace.ThreadID // while running or in a syscall
+	assist bool           // in a GC mark assist
+
+	// Output state.
+	seq       uint64
+	outAssist bool
+	outSTW    bool
+}
+
+// procStatus is the state of a P. It distinguishes the two kinds of
+// idle P and running P the trace format has.
+type procStatus uint8
+
+const (
+	procIdle procStatus = iota
+	procRunning
+	procSyscall   // the goroutine on the P's M is in a syscall
+	procAbandoned // the goroutine that was in a syscall on the P exited
+)
+
+// pState is the state of a P.
+type pState struct {
+	status procStatus
+	m      trace.ThreadID // while running or in a syscall
+	sweep  bool           // sweeping
+
+	// Output state. A P is in a syscall in the output only if the
+	// goroutine in the syscall is kept.
+	out      procStatus
+	seq      uint64
+	outSweep bool
+}
+
+// mState is what an M is bound to.
+type mState struct {
+	p trace.ProcID
+	g trace.GoID
+}
+
+// NewWriter creates a writer that writes the events that pass f as a
+// new trace to w.
+func NewWriter(w io.Writer, f Filter) *Writer {
+	fw := &Writer{
+		bw:       bufio.NewWriter(w),
+		f:        f,
+		firstGen: true,
+		gs:       make(map[trace.GoID]*gState),
+		ps:       make(map[trace.ProcID]*pState),
+		ms:       make(map[trace.ThreadID]*mState),
+	}
+	fw.rw, fw.err = raw.NewWriter(fw.bw, version.Go122)
+	return fw
+}
+
+// Event passes a single event to the writer. It returns the first
+// error writing the output, if any.
+func (w *Writer) Event(ev *trace.Event) error {
+	if w.err != nil || w.done {
+		return w.err
+	}
+	if !w.started {
+		w.started = true
+		w.genStart = ev.Time()
+		w.start = ev.Time() + trace.Time(w.f.Start)
+		w.end = math.MaxInt64
+		if w.f.End > 0 {
+			w.end = ev.Time() + trace.Time(w.f.End)
+		}
+	}
+	if w.firstGen {
+		if ev.Kind() != trace.EventSync {
+			w.pending = append(w.pending, *ev)
+			return nil
+		}
+		w.flushFirstGen()
+	}
+	w.event(ev)
+	return w.err
+}
+
+// Close writes the rest of the output. It must be called after the
+// last event.
+func (w *Writer) Close() error {
+	if w.firstGen {
+		w.flushFirstGen()
+	}
+	if w.genNum == 0 {
+		// Nothing was in the window. A trace needs at least one
+		// generation to be valid.
+		w.beginGen()
+	}
+	if w.gen != nil {
+		w.endGen()
+	}
+	if w.err == nil {
+		w.err = w.bw.Flush()
+	}
+	return w.err
+}
+
+// flushFirstGen processes the buffered events of the first generation.
+func (w *Writer) flushFirstGen() {
+	w.firstGen = false
+	w.initState(w.pending)
+	for i := range w.pending {
+		if w.done {
+			break
+		}
+		w.event(&w.pending[i])
+	}
+	w.pending = nil
+}
+
+// initState sets the state of the resources that events of the first
+// generation find already existing, as of the start of the trace.
+func (w *Writer) initState(evs []trace.Event) {
+	for i := range evs {
+		ev := &evs[i]
+		switch ev.Kind() {
+		case trace.EventStateTransition:
+			st := ev.StateTransition()
+			switch st.Resource.Kind {
+			case trace.ResourceGoroutine:
+				old, new := st.Goroutine()
+				if old != trace.GoUndetermined {
+					continue
+				}
+				id := st.Resource.Goroutine()
+				g := &gState{status: new, m: trace.NoThread}
+				if new == trace.GoRunning || new == trace.GoSyscall {
+					g.m = ev.Thread()
+					w.mstate(g.m).g = id
+				}
+				w.gs[id] = g
+			case trace.ResourceProc:
+				old, new := st.Proc()
+				if old != trace.ProcUndetermined {
+					continue
+				}
+				id := st.Resource.Proc()
+				p := &pState{status: procIdle, m: trace.NoThread}
+				if new == trace.ProcRunning {
+					p.status = procRunning
+					p.m = ev.Thread()
+					w.mstate(p.m).p = id
+				}
+				w.ps[id] = p
+			}
+		case trace.EventRangeActive:
+			switch r := ev.Range(); r.Name {
+			case "GC concurrent mark phase":
+				w.gc = true
+			case "GC incremental sweep":
+				if p := w.ps[r.Scope.Proc()]; p != nil {
+					p.sweep = true
+				}
+			case "GC mark assist":
+				if g := w.gs[r.Scope.Goroutine()]; g != nil {
+					g.assist = true
+				}
+			}
+		}
+	}
+
+	// The API reports Ps in a syscall as running. If the goroutine on
+	// the M of a running P is in a syscall, the P is either in the
+	// syscall or was acquired by the M on the way out of it. Only in
+	// the latter case can the syscall end with the goroutine blocked
+	// before anything happens to the P.
+	syscallP := make(map[trace.GoID]trace.ProcID)
+	for id, p := range w.ps {
+		if p.status != procRunning {
+			continue
+		}
+		gid := w.mstate(p.m).g
+		if g := w.gs[gid]; g != nil && g.status == trace.GoSyscall {
+			p.status = procSyscall
+			syscallP[gid] = id
+		}
+	}
+	for i := 0; i < len(evs) && len(syscallP) > 0; i++ {
+		ev := &evs[i]
+		if ev.Kind() != trace.EventStateTransition {
+			continue
+		}
+		st := ev.StateTransition()
+		switch st.Resource.Kind {
+		case trace.ResourceGoroutine:
+			old, new := st.Goroutine()
+			pid, ok := syscallP[st.Resource.Goroutine()]
+			if !ok || old != trace.GoSyscall || new == old {
+				continue
+			}
+			if new == trace.GoRunnable {
+				w.ps[pid].status = procRunning
+			}
+			delete(syscallP, st.Resource.Goroutine())
+		case trace.ResourceProc:
+			old, new := st.Proc()
+			if old == trace.ProcUndetermined || old == new {
+				continue
+			}
+			for gid, pid := range syscallP {
+				if pid == st.Resource.Proc() {
+					delete(syscallP, gid)
+				}
+			}
+		}
+	}
+}
+
+func (w *Writer) mstate(m trace.ThreadID) *mState {
+	s := w.ms[m]
+	if s == nil {
+		s = &mState{p: trace.NoProc, g: trace.NoGoroutine}
+		w.ms[m] = s
+	}
+	return s
+}
+
+// keep reports whether events of goroutine g are kept.
+func (w *Writer) keep(g trace.GoID) bool {
+	return g != trace.NoGoroutine && (w.f.Goroutines == nil || w.f.Goroutines[g])
+}
+
+// keepTask reports whether annotations of task id are kept.
+func (w *Writer) keepTask(id trace.TaskID) bool {
+	return w.f.Tasks == nil || w.f.Tasks[id]
+}
+
+// event processes a single event, which is written out if it's in the
+// window and passes the filter.
+func (w *Writer) event(ev *trace.Event) {
+	t := ev.Time()
+	if ev.Kind() == trace.EventSync {
+		if w.gen != nil {
+			w.endGen()
+		}
+		w.genStart = t
+		return
+	}
+	if t >= w.end {
+		w.done = true
+		return
+	}
+	if t >= w.start && w.gen == nil {
+		w.beginGen()
+	}
+	w.genStart = t
+
+	switch ev.Kind() {
+	case trace.EventStateTransition:
+		st := ev.StateTransition()
+		switch st.Resource.Kind {
+		case trace.ResourceGoroutine:
+			w.goTransition(ev, st)
+		case trace.ResourceProc:
+			w.procTransition(ev, st)
+		}
+	case trace.EventRangeBegin, trace.EventRangeActive, trace.EventRangeEnd:
+		w.rangeEvent(ev)
+	case trace.EventMetric:
+		w.metric(ev)
+	case trace.EventLabel:
+		if w.gen != nil && w.keep(ev.Goroutine()) {
+			w.emit(ev.Thread(), t, go122.EvGoLabel, w.gen.str(ev.Label().Label))
+		}
+	case trace.EventTaskBegin, trace.EventTaskEnd, trace.EventRegionBegin, trace.EventRegionEnd, trace.EventLog:
+		w.annotation(ev)
+	case trace.EventStackSample:
+		if w.gen != nil && (w.f.Goroutines == nil || w.keep(ev.Goroutine())) {
+			g := uint64(ev.Goroutine())
+			if ev.Goroutine() == trace.NoGoroutine {
+				g = 0
+			}
+			w.gen.samples.add(appendEvent(nil, go122.EvCPUSample, uint64(t), uint64(ev.Thread()), uint64(ev.Proc()), g, w.gen.stack(ev.Stack())))
+		}
+	}
+}
+
+func (w *Writer) goTransition(ev *trace.Event, st trace.StateTransition) {
+	old, new := st.Goroutine()
+	if old == new || old == trace.GoUndetermined {
+		// Statuses are written by beginGen.
+		return
+	}
+	id := st.Resource.Goroutine()
+	m, t := ev.Thread(), ev.Time()
+	out := w.gen != nil && w.keep(id)
+	g := w.gs[id]
+	if g == nil {
+		g = &gState{m: trace.NoThread}
+		w.gs[id] = g
+	}
+	g.status = new
+	switch {
+	case old == trace.GoNotExist && new == trace.GoRunnable:
+		*g = gState{status: new, m: trace.NoThread}
+		if out {
+			w.emit(m, t, go122.EvGoCreate, uint64(id), w.gen.stack(st.Stack), w.gen.stack(ev.Stack()))
+		}
+	case old == trace.GoNotExist && new == trace.GoSyscall:
+		*g = gState{status: new, m: m}
+		w.mstate(m).g = id
+		if out {
+			w.emit(m, t, go122.EvGoCreateSyscall, uint64(id))
+		}
+	case old == trace.GoRunnable && new == trace.GoRunning:
+		g.m = m
+		w.mstate(m).g = id
+		if out {
+			g.seq++
+			w.emit(m, t, go122.EvGoStart, uint64(id), g.seq)
+		}
+	case old == trace.GoRunning && new == trace.GoNotExist:
+		w.mstate(m).g = trace.NoGoroutine
+		delete(w.gs, id)
+		if out {
+			w.emit(m, t, go122.EvGoDestroy)
+		}
+	case old == trace.GoSyscall && new == trace.GoNotExist:
+		ms := w.mstate(m)
+		ms.g = trace.NoGoroutine
+		delete(w.gs, id)
+		if out {
+			w.emit(m, t, go122.EvGoDestroySyscall)
+		}
+		// The goroutine's M gives up its P, if it still has one.
+		p := w.ps[ms.p]
+		if p == nil {
+			break
+		}
+		ms.p = trace.NoProc
+		p.status = procAbandoned
+		p.m = trace.NoThread
+		if w.gen == nil {
+			break
+		}
+		if out {
+			p.out = procAbandoned
+		} else {
+			// The syscall is hidden, so the P is running.
+			w.emit(m, t, go122.EvProcStop)
+			p.out = procIdle
+		}
+	case old == trace.GoRunning && new == trace.GoRunnable:
+		w.mstate(m).g = trace.NoGoroutine
+		if out {
+			w.emit(m, t, go122.EvGoStop, w.gen.str(st.Reason), w.gen.stack(ev.Stack()))
+		}
+	case old == trace.GoRunning && new == trace.GoWaiting:
+		w.mstate(m).g = trace.NoGoroutine
+		if out {
+			w.emit(m, t, go122.EvGoBlock, w.gen.str(st.Reason), w.gen.stack(ev.Stack()))
+		}
+	case old == trace.GoWaiting && new == trace.GoRunnable:
+		if out {
+			g.seq++
+			w.emit(m, t, go122.EvGoUnblock, uint64(id), g.seq, w.gen.stack(ev.Stack()))
+		}
+	case old == trace.GoRunning && new == trace.GoSyscall:
+		p := w.ps[w.mstate(m).p]
+		if p != nil {
+			p.status = procSyscall
+		}
+		if out && p != nil {
+			p.seq++
+			p.out = procSyscall
+			w.emit(m, t, go122.EvGoSyscallBegin, p.seq, w.gen.stack(ev.Stack()))
+		}
+	case old == trace.GoSyscall && new == trace.GoRunning:
+		p := w.ps[w.mstate(m).p]
+		if p != nil {
+			p.status = procRunning
+		}
+		if out && p != nil {
+			p.out = procRunning
+			w.emit(m, t, go122.EvGoSyscallEnd)
+		}
+	case old == trace.GoSyscall && new == trace.GoRunnable:
+		w.mstate(m).g = trace.NoGoroutine
+		if out {
+			w.emit(m, t, go122.EvGoSyscallEndBlocked)
+		}
+	}
+}
+
+func (w *Writer) procTransition(ev *trace.Event, st trace.StateTransition) {
+	old, new := st.Proc()
+	id := st.Resource.Proc()
+	m, t := ev.Thread(), ev.Time()
+	p := w.ps[id]
+	switch {
+	case old == trace.ProcUndetermined:
+		// Handled by initState.
+	case old == trace.ProcNotExist || p == nil:
+		// A P that appeared after the first generation.
+		p = &pState{status: procIdle, m: trace.NoThread}
+		if new == trace.ProcRunning {
+			p.status = procRunning
+			p.m = m
+			w.mstate(m).p = id
+		}
+		w.ps[id] = p
+		if w.gen != nil {
+			p.out = p.status
+			w.emit(m, t, go122.EvProcStatus, uint64(id), uint64(p.out.go122()))
+		}
+	case old == trace.ProcIdle && new == trace.ProcIdle:
+		// Either a status, or a P whose goroutine exited in a syscall
+		// being stolen. Treat it as the latter, as if the steal had
+		// happened at the status.
+		if p.status != procAbandoned {
+			break
+		}
+		p.status = procIdle
+		if w.gen != nil && p.out == procAbandoned {
+			p.seq++
+			p.out = procIdle
+			w.emit(m, t, go122.EvProcSteal, uint64(id), p.seq, uint64(m))
+		}
+	case old == trace.ProcIdle && new == trace.ProcRunning:
+		p.status = procRunning
+		p.m = m
+		w.mstate(m).p = id
+		if w.gen != nil {
+			p.seq++
+			p.out = procRunning
+			w.emit(m, t, go122.EvProcStart, uint64(id), p.seq)
+		}
+	case old == trace.ProcRunning && new == trace.ProcIdle:
+		if p.status == procAbandoned {
+			// Follows a goroutine exiting in a syscall, which
+			// already took the P away from its M.
+			break
+		}
+		holder := p.m
+		p.status = procIdle
+		p.m = trace.NoThread
+		if hs := w.ms[holder]; hs != nil {
+			hs.p = trace.NoProc
+		}
+		if w.gen == nil || holder == trace.NoThread {
+			break
+		}
+		switch {
+		case holder == m:
+			w.emit(m, t, go122.EvProcStop)
+		case p.out == procSyscall:
+			p.seq++
+			w.emit(m, t, go122.EvProcSteal, uint64(id), p.seq, uint64(holder))
+		default:
+			// The P was stolen from a syscall that isn't in the
+			// output, where it's still running on the M.
+			w.emit(holder, t, go122.EvProcStop)
+		}
+		p.out = procIdle
+	}
+}
+
+func (w *Writer) rangeEvent(ev *trace.Event) {
+	r := ev.Range()
+	kind := ev.Kind()
+	m, t := ev.Thread(), ev.Time()
+	switch {
+	case r.Name == "GC concurrent mark phase":
+		w.gc = kind != trace.EventRangeEnd
+		if w.gen == nil || w.f.Goroutines != nil {
+			// GC phase events belong to whichever goroutine happens
+			// to start or end them, which may not be kept.
+			return
+		}
+		switch kind {
+		case trace.EventRangeBegin:
+			if !w.outGC {
+				w.outGCSeq++
+				w.emit(m, t, go122.EvGCBegin, w.outGCSeq, w.gen.stack(ev.Stack()))
+				w.outGC, w.outGCSet = true, true
+			}
+		case trace.EventRangeActive:
+			if w.genNum == 1 && !w.outGCSet {
+				w.outGCSeq++
+				w.emit(m, t, go122.EvGCActive, w.outGCSeq)
+				w.outGC, w.outGCSet = true, true
+			}
+		case trace.EventRangeEnd:
+			if w.outGC {
+				w.outGCSeq++
+				w.emit(m, t, go122.EvGCEnd, w.outGCSeq)
+				w.outGC = false
+			}
+		}
+	case strings.HasPrefix(r.Name, "stop-the-world ("):
+		g := w.gs[ev.Goroutine()]
+		if g == nil {
+			return
+		}
+		switch kind {
+		case trace.EventRangeBegin:
+			if w.gen != nil && w.keep(ev.Goroutine()) {
+				desc := strings.TrimSuffix(strings.TrimPrefix(r.Name, "stop-the-world ("), ")")
+				w.emit(m, t, go122.EvSTWBegin, w.gen.str(desc), w.gen.stack(ev.Stack()))
+				g.outSTW = true
+			}
+		case trace.EventRangeEnd:
+			if g.outSTW {
+				w.emit(m, t, go122.EvSTWEnd)
+				g.outSTW = false
+			}
+		}
+	case r.Name == "GC incremental sweep":
+		p := w.ps[r.Scope.Proc()]
+		if p == nil {
+			return
+		}
+		p.sweep = kind != trace.EventRangeEnd
+		switch kind {
+		case trace.EventRangeBegin:
+			if w.gen != nil {
+				w.emit(m, t, go122.EvGCSweepBegin, w.gen.stack(ev.Stack()))
+				p.outSweep = true
+			}
+		case trace.EventRangeActive:
+			if w.gen != nil && w.genNum == 1 && !p.outSweep {
+				w.emit(m, t, go122.EvGCSweepActive, uint64(r.Scope.Proc()))
+				p.outSweep = true
+			}
+		case trace.EventRangeEnd:
+			if p.outSweep {
+				var swept, reclaimed uint64
+				for _, attr := range ev.RangeAttributes() {
+					switch attr.Name {
+					case "bytes swept":
+						swept = attr.Value.Uint64()
+					case "bytes reclaimed":
+						reclaimed = attr.Value.Uint64()
+					}
+				}
+				w.emit(m, t, go122.EvGCSweepEnd, swept, reclaimed)
+				p.outSweep = false
+			}
+		}
+	case r.Name == "GC mark assist":
+		id := r.Scope.Goroutine()
+		g := w.gs[id]
+		if g == nil {
+			return
+		}
+		g.assist = kind != trace.EventRangeEnd
+		switch kind {
+		case trace.EventRangeBegin:
+			if w.gen != nil && w.keep(id) {
+				w.emit(m, t, go122.EvGCMarkAssistBegin, w.gen.stack(ev.Stack()))
+				g.outAssist = true
+			}
+		case trace.EventRangeActive:
+			if w.gen != nil && w.genNum == 1 && w.keep(id) && !g.outAssist {
+				w.emit(m, t, go122.EvGCMarkAssistActive, uint64(id))
+				g.outAssist = true
+			}
+		case trace.EventRangeEnd:
+			if g.outAssist {
+				w.emit(m, t, go122.EvGCMarkAssistEnd)
+				g.outAssist = false
+			}
+		}
+	}
+}
+
+func (w *Writer) metric(ev *trace.Event) {
+	if w.gen == nil {
+		return
+	}
+	m, t := ev.Thread(), ev.Time()
+	metric := ev.Metric()
+	switch metric.Name {
+	case "/sched/gomaxprocs:threads":
+		if w.keep(ev.Goroutine()) {
+			w.emit(m, t, go122.EvProcsChange, metric.Value.Uint64(), w.gen.stack(ev.Stack()))
+		}
+	case "/memory/classes/heap/objects:bytes":
+		w.emit(m, t, go122.EvHeapAlloc, metric.Value.Uint64())
+	case "/gc/heap/goal:bytes":
+		w.emit(m, t, go122.EvHeapGoal, metric.Value.Uint64())
+	}
+}
+
+func (w *Writer) annotation(ev *trace.Event) {
+	if w.gen == nil || !w.keep(ev.Goroutine()) {
+		return
+	}
+	m, t := ev.Thread(), ev.Time()
+	stk := w.gen.stack(ev.Stack())
+	switch ev.Kind() {
+	case trace.EventTaskBegin:
+		task := ev.Task()
+		if !w.keepTask(task.ID) {
+			return
+		}
+		parent := uint64(task.Parent)
+		if task.Parent == trace.NoTask {
+			parent = 0
+		}
+		w.emit(m, t, go122.EvUserTaskBegin, uint64(task.ID), parent, w.gen.str(task.Type), stk)
+	case trace.EventTaskEnd:
+		if task := ev.Task(); w.keepTask(task.ID) {
+			w.emit(m, t, go122.EvUserTaskEnd, uint64(task.ID), stk)
+		}
+	case trace.EventRegionBegin, trace.EventRegionEnd:
+		r := ev.Region()
+		if !w.keepTask(r.Task) {
+			return
+		}
+		typ := go122.EvUserRegionBegin
+		if ev.Kind() == trace.EventRegionEnd {
+			typ = go122.EvUserRegionEnd
+		}
+		w.emit(m, t, typ, uint64(r.Task), w.gen.str(r.Type), stk)
+	case trace.EventLog:
+		l := ev.Log()
+		if w.keepTask(l.Task) {
+			w.emit(m, t, go122.EvUserLog, uint64(l.Task), w.gen.str(l.Category), w.gen.str(l.Message), stk)
+		}
+	}
+}
+
+// beginGen starts a new output generation with the status of every P
+// and kept goroutine.
+func (w *Writer) beginGen() {
+	w.genNum++
+	w.gen = newGenWriter()
+	first := w.genNum == 1
+	t := w.genStart
+
+	// Statuses of resources bound to an M go on that M, and the rest
+	// on some other M. Range events go after the status of the
+	// resource they belong to.
+	anchor := trace.ThreadID(0)
+	if len(w.ms) > 0 {
+		anchor = slices.Min(sortedKeys(w.ms))
+	}
+	if first && w.gc && w.f.Goroutines == nil {
+		w.outGCSeq++
+		w.emit(anchor, t, go122.EvGCActive, w.outGCSeq)
+		w.outGC, w.outGCSet = true, true
+	}
+	for _, id := range sortedKeys(w.ps) {
+		p := w.ps[id]
+		if first {
+			p.out = p.status
+			if p.out == procSyscall && !w.keep(w.mstate(p.m).g) {
+				p.out = procRunning
+			}
+		}
+		p.seq = 0
+		m := anchor
+		if p.out == procRunning || p.out == procSyscall {
+			m = p.m
+		}
+		w.emit(m, t, go122.EvProcStatus, uint64(id), uint64(p.out.go122()))
+		if first && p.sweep {
+			w.emit(m, t, go122.EvGCSweepActive, uint64(id))
+			p.outSweep = true
+		}
+	}
+	for _, id := range sortedKeys(w.gs) {
+		g := w.gs[id]
+		if !w.keep(id) || g.status == trace.GoNotExist {
+			continue
+		}
+		g.seq = 0
+		m, gm := anchor, trace.NoThread
+		if g.status == trace.GoRunning || g.status == trace.GoSyscall {
+			m, gm = g.m, g.m
+		}
+		w.emit(m, t, go122.EvGoStatus, uint64(id), uint64(gm), uint64(goStatus(g.status)))
+		if first && g.assist {
+			w.emit(m, t, go122.EvGCMarkAssistActive, uint64(id))
+			g.outAssist = true
+		}
+	}
+}
+
+// endGen writes out the current output generation.
+func (w *Writer) endGen() {
+	g := w.gen
+	w.gen = nil
+	for _, m := range sortedKeys(g.batches) {
+		w.writeBatch(m, g.batches[m])
+	}
+	w.writeBatch(trace.NoThread, &batch{buf: appendEvent(nil, go122.EvFrequency, 1e9)})
+	for _, c := range [][][]byte{g.stacks.chunks, g.strings.chunks, g.samples.chunks} {
+		for _, buf := range c {
+			w.writeBatch(trace.NoThread, &batch{buf: buf})
+		}
+	}
+}
+
+// emit adds an event to the batch of M m.
+func (w *Writer) emit(m trace.ThreadID, t trace.Time, typ event.Type, args ...uint64) {
+	b := w.gen.batches[m]
+	if b == nil {
+		b = &batch{}
+		w.gen.batches[m] = b
+	}
+	if len(b.buf) == 0 {
+		b.base, b.last = t, t
+	}
+	dt := uint64(0)
+	if t > b.last {
+		dt = uint64(t - b.last)
+		b.last = t
+	}
+	b.buf = appendEvent(b.buf, typ, append([]uint64{dt}, args...)...)
+	if len(b.buf) > go122.MaxBatchSize-maxEventSize {
+		w.writeBatch(m, b)
+	}
+}
+
+// maxEventSize is more than the size of any event the writer emits
+// into an M's batch.
+const maxEventSize = 128
+
+// writeBatch writes out a batch and resets it.
+func (w *Writer) writeBatch(m trace.ThreadID, b *batch) {
+	if w.err != nil || len(b.buf) == 0 {
+		return
+	}
+	w.err = w.rw.WriteEvent(raw.Event{
+		Version: version.Go122,
+		Ev:      go122.EvEventBatch,
+		Args:    []uint64{w.genNum, uint64(m), uint64(b.base), uint64(len(b.buf))},
+	})
+	if w.err == nil {
+		_, w.err = w.bw.Write(b.buf)
+	}
+	b.buf = b.buf[:0]
+}
+
+func (s procStatus) go122() go122.ProcStatus {
+	switch s {
+	case procRunning:
+		return go122.ProcRunning
+	case procSyscall:
+		return go122.ProcSyscall
+	case procAbandoned:
+		return go122.ProcSyscallAbandoned
+	}
+	return go122.ProcIdle
+}
+
+func goStatus(s trace.GoState) go122.GoStatus {
+	switch s {
+	case trace.GoRunnable:
+		return go122.GoRunnable
+	case trace.GoRunning:
+		return go122.GoRunning
+	case trace.GoSyscall:
+		return go122.GoSyscall
+	case trace.GoWaiting:
+		return go122.GoWaiting
+	}
+	return go122.GoBad
+}
+
+// genWriter is an output generation being built.
+type genWriter struct {
+	batches map[trace.ThreadID]*batch
+
+	strings   chunks // EvString events
+	stringIDs map[string]uint64
+	stacks    chunks // EvStack events
+	stackIDs  map[trace.Stack]uint64
+	samples   chunks // EvCPUSample events
+}
+
+func newGenWriter() *genWriter {
+	return &genWriter{
+		batches:   make(map[trace.ThreadID]*batch),
+		strings:   chunks{header: go122.EvStrings},
+		stringIDs: make(map[string]uint64),
+		stacks:    chunks{header: go122.EvStacks},
+		stackIDs:  make(map[trace.Stack]uint64),
+		samples:   chunks{header: go122.EvCPUSamples},
+	}
+}
+
+// str returns the ID of s in the generation's string table.
+func (g *genWriter) str(s string) uint64 {
+	if s == "" {
+		return 0
+	}
+	if id, ok := g.stringIDs[s]; ok {
+		return id
+	}
+	id := uint64(len(g.stringIDs) + 1)
+	g.stringIDs[s] = id
+	buf := appendEvent(nil, go122.EvString, id, uint64(len(s)))
+	g.strings.add(append(buf, s...))
+	return id
+}
+
+// stack returns the ID of stk in the generation's stack table.
+func (g *genWriter) stack(stk trace.Stack) uint64 {
+	if stk == trace.NoStack {
+		return 0
+	}
+	if id, ok := g.stackIDs[stk]; ok {
+		return id
+	}
+	var frames []trace.StackFrame
+	stk.Frames(func(f trace.StackFrame) bool {
+		frames = append(frames, f)
+		return len(frames) < go122.MaxFramesPerStack
+	})
+	id := uint64(len(g.stackIDs) + 1)
+	g.stackIDs[stk] = id
+	args := []uint64{id, uint64(len(frames))}
+	for _, f := range frames {
+		args = append(args, f.PC, g.str(f.Func), g.str(f.File), f.Line)
+	}
+	g.stacks.add(appendEvent(nil, go122.EvStack, args...))
+	return id
+}
+
+// batch is the events of an M in a generation.
+type batch struct {
+	buf        []byte
+	base, last trace.Time
+}
+
+// chunks is a section of a generation's structural data, like its
+// string table, split into batches.
+type chunks struct {
+	header event.Type
+	chunks [][]byte
+}
+
+func (c *chunks) add(ev []byte) {
+	n := len(c.chunks)
+	if n == 0 || len(c.chunks[n-1])+len(ev) > go122.MaxBatchSize {
+		c.chunks = append(c.chunks, []byte{byte(c.header)})
+		n++
+	}
+	c.chunks[n-1] = append(c.chunks[n-1], ev...)
+}
+
+func appendEvent(buf []byte, typ event.Type, args ...uint64) []byte {
+	buf = append(buf, byte(typ))
+	for _, arg := range args {
+		buf = binary.AppendUvarint(buf, arg)
+	}
+	return buf
+}
+
+func sortedKeys[K interface{ ~int64 }, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
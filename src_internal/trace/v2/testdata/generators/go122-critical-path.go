@@ -0,0 +1,45 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Tests a task whose goroutine blocks on another goroutine,
+// which makes a syscall before unblocking it.
+//
+// G1 begins a task and a region, wakes G2, and blocks. G2 runs,
+// makes a syscall, wakes G1, and blocks. G1 then runs, does a
+// mark assist, and ends the region and the task.
+
+package main
+
+import (
+	"compile/src_internal/trace/v2"
+	"compile/src_internal/trace/v2/event/go122"
+	testgen "compile/src_internal/trace/v2/internal/testgen/go122"
+)
+
+func main() {
+	testgen.Main(gen)
+}
+
+func gen(t *testgen.Trace) {
+	g1 := t.Generation(1)
+
+	b0 := g1.Batch(trace.ThreadID(0), 0)
+	b0.Event("ProcStatus", trace.ProcID(0), go122.ProcRunning)
+	b0.Event("GoStatus", trace.GoID(1), trace.ThreadID(0), go122.GoRunning)
+	b0.Event("GoStatus", trace.GoID(2), trace.NoThread, go122.GoWaiting)
+	b0.Event("UserTaskBegin", trace.TaskID(1), trace.TaskID(0), "rpc", testgen.NoStack)
+	b0.Event("UserRegionBegin", trace.TaskID(1), "handle", testgen.NoStack)
+	b0.Event("GoUnblock", trace.GoID(2), testgen.Seq(1), testgen.NoStack)
+	b0.Event("GoBlock", "chan receive", testgen.NoStack)
+	b0.Event("GoStart", trace.GoID(2), testgen.Seq(2))
+	b0.Event("GoSyscallBegin", testgen.Seq(1), testgen.NoStack)
+	b0.Event("GoSyscallEnd")
+	b0.Event("GoUnblock", trace.GoID(1), testgen.Seq(1), testgen.NoStack)
+	b0.Event("GoBlock", "sync", testgen.NoStack)
+	b0.Event("GoStart", trace.GoID(1), testgen.Seq(2))
+	b0.Event("GCMarkAssistBegin", testgen.NoStack)
+	b0.Event("GCMarkAssistEnd")
+	b0.Event("UserRegionEnd", trace.TaskID(1), "handle", testgen.NoStack)
+	b0.Event("UserTaskEnd", trace.TaskID(1), testgen.NoStack)
+}
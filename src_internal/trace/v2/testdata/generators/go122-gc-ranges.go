@@ -0,0 +1,53 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Tests GC ranges of each scope, some of which continue
+// across a generation boundary.
+//
+// A GC cycle and a mark assist begin in one generation and
+// end in the next, where they're active at the start. A sweep
+// and a stop-the-world begin and end within one generation.
+
+package main
+
+import (
+	"compile/src_internal/trace/v2"
+	"compile/src_internal/trace/v2/event/go122"
+	testgen "compile/src_internal/trace/v2/internal/testgen/go122"
+)
+
+func main() {
+	testgen.Main(gen)
+}
+
+func gen(t *testgen.Trace) {
+	g1 := t.Generation(1)
+
+	// A running goroutine stops the world, starts a GC,
+	// sweeps, and begins a mark assist.
+	b1 := g1.Batch(trace.ThreadID(0), 0)
+	b1.Event("ProcStatus", trace.ProcID(0), go122.ProcRunning)
+	b1.Event("GoStatus", trace.GoID(1), trace.ThreadID(0), go122.GoRunning)
+	b1.Event("HeapAlloc", uint64(1<<20))
+	b1.Event("HeapGoal", uint64(4<<20))
+	b1.Event("STWBegin", "sweep termination", testgen.NoStack)
+	b1.Event("STWEnd")
+	b1.Event("GCBegin", testgen.Seq(1), testgen.NoStack)
+	b1.Event("GoLabel", "GC (dedicated)")
+	b1.Event("GCSweepBegin", testgen.NoStack)
+	b1.Event("GCSweepEnd", uint64(8192), uint64(4096))
+	b1.Event("GCMarkAssistBegin", testgen.NoStack)
+
+	g2 := t.Generation(2)
+
+	// The GC and the mark assist are still active, and end.
+	b2 := g2.Batch(trace.ThreadID(0), 5)
+	b2.Event("ProcStatus", trace.ProcID(0), go122.ProcRunning)
+	b2.Event("GoStatus", trace.GoID(1), trace.ThreadID(0), go122.GoRunning)
+	b2.Event("GCActive", testgen.Seq(2))
+	b2.Event("GCMarkAssistActive", trace.GoID(1))
+	b2.Event("GCMarkAssistEnd")
+	b2.Event("HeapAlloc", uint64(2<<20))
+	b2.Event("GCEnd", testgen.Seq(3))
+}
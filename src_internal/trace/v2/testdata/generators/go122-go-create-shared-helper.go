@@ -0,0 +1,48 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Tests goroutines created through a shared helper.
+//
+// The goroutines have the same entry function and are created by the
+// same function, but from different callers, so only the outer frames
+// of their creation stacks tell them apart.
+
+package main
+
+import (
+	"compile/src_internal/trace/v2"
+	"compile/src_internal/trace/v2/event/go122"
+	testgen "compile/src_internal/trace/v2/internal/testgen/go122"
+)
+
+func main() {
+	testgen.Main(gen)
+}
+
+func gen(t *testgen.Trace) {
+	g1 := t.Generation(1)
+
+	worker := []trace.StackFrame{
+		{PC: 1, Func: "main.worker", File: "main.go", Line: 10},
+	}
+	fromServe := []trace.StackFrame{
+		{PC: 2, Func: "main.spawn", File: "main.go", Line: 20},
+		{PC: 3, Func: "main.serve", File: "main.go", Line: 30},
+		{PC: 4, Func: "main.main", File: "main.go", Line: 40},
+	}
+	fromFlush := []trace.StackFrame{
+		{PC: 2, Func: "main.spawn", File: "main.go", Line: 20},
+		{PC: 5, Func: "main.flush", File: "main.go", Line: 50},
+		{PC: 6, Func: "main.main", File: "main.go", Line: 41},
+	}
+
+	// A running goroutine creates two goroutines from serve and
+	// one from flush, all through spawn.
+	b0 := g1.Batch(trace.ThreadID(0), 0)
+	b0.Event("ProcStatus", trace.ProcID(0), go122.ProcRunning)
+	b0.Event("GoStatus", trace.GoID(1), trace.ThreadID(0), go122.GoRunning)
+	b0.Event("GoCreate", trace.GoID(2), worker, fromServe)
+	b0.Event("GoCreate", trace.GoID(3), worker, fromServe)
+	b0.Event("GoCreate", trace.GoID(4), worker, fromFlush)
+}
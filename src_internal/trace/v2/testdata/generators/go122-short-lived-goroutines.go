@@ -0,0 +1,38 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Tests many goroutines that each run once and exit.
+//
+// Consumers that keep per-goroutine state should not grow with
+// the number of goroutines that have ever existed.
+
+package main
+
+import (
+	"compile/src_internal/trace/v2"
+	"compile/src_internal/trace/v2/event/go122"
+	testgen "compile/src_internal/trace/v2/internal/testgen/go122"
+)
+
+func main() {
+	testgen.Main(gen)
+}
+
+func gen(t *testgen.Trace) {
+	g1 := t.Generation(1)
+
+	// A running goroutine creates goroutines one after another,
+	// each of which runs and exits before the next is created.
+	b0 := g1.Batch(trace.ThreadID(0), 0)
+	b0.Event("ProcStatus", trace.ProcID(0), go122.ProcRunning)
+	b0.Event("GoStatus", trace.GoID(1), trace.ThreadID(0), go122.GoRunning)
+	for i := 0; i < 100; i++ {
+		g := trace.GoID(2 + i)
+		b0.Event("GoCreate", g, testgen.NoStack, testgen.NoStack)
+		b0.Event("GoStop", "whatever", testgen.NoStack)
+		b0.Event("GoStart", g, testgen.Seq(1))
+		b0.Event("GoDestroy")
+		b0.Event("GoStart", trace.GoID(1), testgen.Seq(uint64(1+i)))
+	}
+}
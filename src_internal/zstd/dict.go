@@ -0,0 +1,165 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// dictMagic is the magic number at the start of a dictionary. RFC 5.
+const dictMagic = 0xec30a437
+
+// A Dict is a dictionary used to decompress frames. RFC 5.
+//
+// Its content is treated as if it preceded the data of each frame that
+// uses it, so that the frame may refer back into it. A dictionary in
+// the format produced by zstd --train also provides the entropy tables
+// and repeated offsets that the first block of each such frame starts
+// with.
+type Dict struct {
+	id      uint32
+	content []byte
+
+	// The repeated offsets a frame starts with.
+	repeatedOffset1 uint32
+	repeatedOffset2 uint32
+	repeatedOffset3 uint32
+
+	// The Huffman table for literals, if any.
+	huffmanTable     []uint16
+	huffmanTableBits int
+
+	// The sequence decode FSE tables, if any.
+	seqTables    [3][]fseBaselineEntry
+	seqTableBits [3]uint8
+}
+
+// ParseDict parses a dictionary. If data starts with the dictionary
+// magic number, it is a formatted dictionary holding an ID, entropy
+// tables and content. Otherwise all of data is raw content, and the
+// dictionary has ID 0. The returned Dict refers to data, which must
+// not be modified while it is in use.
+func ParseDict(data []byte) (*Dict, error) {
+	if len(data) < 8 || binary.LittleEndian.Uint32(data) != dictMagic {
+		return NewRawDict(0, data), nil
+	}
+
+	d := &Dict{
+		id: binary.LittleEndian.Uint32(data[4:]),
+	}
+	if d.id == 0 {
+		// RFC 5: the value 0 is reserved for raw content.
+		return nil, fmt.Errorf("invalid zstd dictionary: reserved dictionary ID 0")
+	}
+
+	// Entropy_Tables. RFC 5.
+	// We borrow a Reader for its table readers and error reporting.
+	r := new(Reader)
+	b := block(data)
+	off := 8
+
+	d.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+	huffmanTableBits, off, err := r.readHuff(b, off, d.huffmanTable)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zstd dictionary: %w", err)
+	}
+	d.huffmanTableBits = huffmanTableBits
+
+	// The FSE tables are in the order offsets, match lengths,
+	// literal lengths.
+	for _, kind := range [...]seqCode{seqOffset, seqMatch, seqLiteral} {
+		info := &seqCodeInfo[kind]
+		if cap(r.fseScratch) < 1<<info.maxBits {
+			r.fseScratch = make([]fseEntry, 1<<info.maxBits)
+		}
+		r.fseScratch = r.fseScratch[:1<<info.maxBits]
+
+		tableBits, roff, err := r.readFSE(b, off, info.maxSym, info.maxBits, r.fseScratch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid zstd dictionary: %w", err)
+		}
+		table := make([]fseBaselineEntry, 1<<tableBits)
+		if err := info.toBaseline(r, roff, r.fseScratch[:1<<tableBits], table); err != nil {
+			return nil, fmt.Errorf("invalid zstd dictionary: %w", err)
+		}
+		d.seqTables[kind] = table
+		d.seqTableBits[kind] = uint8(tableBits)
+		off = roff
+	}
+
+	if off+12 > len(data) {
+		return nil, fmt.Errorf("invalid zstd dictionary: %w", r.makeEOFError(off))
+	}
+	d.repeatedOffset1 = binary.LittleEndian.Uint32(data[off:])
+	d.repeatedOffset2 = binary.LittleEndian.Uint32(data[off+4:])
+	d.repeatedOffset3 = binary.LittleEndian.Uint32(data[off+8:])
+	off += 12
+
+	d.content = data[off:]
+
+	// Each repeated offset must refer to the content.
+	for _, ro := range [...]uint32{d.repeatedOffset1, d.repeatedOffset2, d.repeatedOffset3} {
+		if ro == 0 || ro > uint32(len(d.content)) {
+			return nil, fmt.Errorf("invalid zstd dictionary: repeated offset %d out of range", ro)
+		}
+	}
+
+	return d, nil
+}
+
+// NewRawDict returns a dictionary with the given ID whose content is
+// content, without entropy tables. The returned Dict refers to content,
+// which must not be modified while it is in use.
+func NewRawDict(id uint32, content []byte) *Dict {
+	return &Dict{
+		id:              id,
+		content:         content,
+		repeatedOffset1: 1,
+		repeatedOffset2: 4,
+		repeatedOffset3: 8,
+	}
+}
+
+// ID returns the dictionary ID, which frames use to refer to d.
+// The ID 0 means that d is used for frames that do not name a
+// dictionary.
+func (d *Dict) ID() uint32 {
+	return d.id
+}
+
+// AddDict makes d available to decompress frames with d's ID,
+// replacing any dictionary with the same ID that was added before.
+// Dictionaries are kept when r is Reset.
+func (r *Reader) AddDict(d *Dict) {
+	if r.dicts == nil {
+		r.dicts = make(map[uint32]*Dict)
+	}
+	r.dicts[d.id] = d
+}
+
+// startDict prepares to read blocks from a frame that uses d.
+func (r *Reader) startDict(d *Dict) {
+	r.repeatedOffset1 = d.repeatedOffset1
+	r.repeatedOffset2 = d.repeatedOffset2
+	r.repeatedOffset3 = d.repeatedOffset3
+
+	if d.huffmanTableBits != 0 {
+		// Compressed literals overwrite r.huffmanTable,
+		// so copy the dictionary's table.
+		if len(r.huffmanTable) < 1<<maxHuffmanBits {
+			r.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+		}
+		copy(r.huffmanTable, d.huffmanTable)
+		r.huffmanTableBits = d.huffmanTableBits
+	}
+
+	// The sequence tables are only read; new tables are built in
+	// r.seqTableBuffers.
+	r.seqTables = d.seqTables
+	r.seqTableBits = d.seqTableBits
+
+	r.window.save(d.content)
+}
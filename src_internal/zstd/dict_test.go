@@ -0,0 +1,161 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDictFileSamples decompresses the files in testdata/dict,
+// which were compressed with zstd -D testdata/dict/dict.
+// They are named like the files in testdata.
+func TestDictFileSamples(t *testing.T) {
+	dictData, err := os.ReadFile("testdata/dict/dict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dict, err := ParseDict(dictData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dict.ID() == 0 {
+		t.Error("trained dictionary has ID 0")
+	}
+
+	samples, err := os.ReadDir("testdata/dict")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sample := range samples {
+		name := sample.Name()
+		if !strings.HasSuffix(name, ".zst") {
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata/dict", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			r := NewReader(f)
+			r.AddDict(dict)
+			h := sha256.New()
+			if _, err := io.Copy(h, r); err != nil {
+				t.Fatal(err)
+			}
+			got := fmt.Sprintf("%x", h.Sum(nil))[:8]
+
+			want, _, _ := strings.Cut(name, ".")
+			if got != want {
+				t.Errorf("Wrong uncompressed content hash: got %s, want %s", got, want)
+			}
+
+			// Without the dictionary, decompression must fail.
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			r.Reset(f)
+			r.dicts = nil
+			if _, err := io.Copy(io.Discard, r); err == nil {
+				t.Error("decompressed without dictionary")
+			}
+		})
+	}
+}
+
+// TestDictZstd compresses data with zstd using raw content and trained
+// dictionaries, and checks that we decompress it.
+func TestDictZstd(t *testing.T) {
+	zstd := findZstd(t)
+	dir := t.TempDir()
+
+	var samples []string
+	for i := 0; i < 200; i++ {
+		sample := fmt.Sprintf("{\n \"id\": %d,\n \"name\": %q,\n \"status\": %q,\n \"size\": %d\n}\n",
+			i, strings.Repeat("x", i%13), []string{"ok", "error", "retry"}[i%3], i*i%1000)
+		file := filepath.Join(dir, fmt.Sprintf("sample%03d", i))
+		if err := os.WriteFile(file, []byte(sample), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		samples = append(samples, sample)
+	}
+
+	trained := filepath.Join(dir, "trained")
+	args := []string{"-q", "--train", "--maxdict=2048", "-o", trained}
+	for i := range samples {
+		args = append(args, filepath.Join(dir, fmt.Sprintf("sample%03d", i)))
+	}
+	if out, err := exec.Command(zstd, args...).CombinedOutput(); err != nil {
+		t.Fatalf("zstd --train failed: %v\n%s", err, out)
+	}
+
+	raw := filepath.Join(dir, "raw")
+	if err := os.WriteFile(raw, []byte(strings.Join(samples[:10], "")), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dictFile := range []string{trained, raw} {
+		t.Run(filepath.Base(dictFile), func(t *testing.T) {
+			dictData, err := os.ReadFile(dictFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dict, err := ParseDict(dictData)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := NewReader(nil)
+			r.AddDict(dict)
+			for _, input := range []string{samples[42], samples[150], strings.Join(samples[100:], "")} {
+				cmd := exec.Command(zstd, "-q", "-c", "-D", dictFile)
+				cmd.Stdin = strings.NewReader(input)
+				compressed, err := cmd.Output()
+				if err != nil {
+					t.Fatalf("zstd -D failed: %v", err)
+				}
+
+				r.Reset(bytes.NewReader(compressed))
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, []byte(input)) {
+					showDiffs(t, got, []byte(input))
+				}
+			}
+		})
+	}
+}
+
+func TestDictBad(t *testing.T) {
+	dictData, err := os.ReadFile("testdata/dict/dict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{8, 9, 50, 100} {
+		if _, err := ParseDict(dictData[:n]); err == nil {
+			t.Errorf("ParseDict of %d bytes succeeded", n)
+		}
+	}
+
+	// Dictionary ID 0 is reserved.
+	zeroID := bytes.Clone(dictData)
+	copy(zeroID[4:8], []byte{0, 0, 0, 0})
+	if _, err := ParseDict(zeroID); err == nil {
+		t.Errorf("ParseDict of dictionary with ID 0 succeeded")
+	}
+}
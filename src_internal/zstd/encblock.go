@@ -0,0 +1,379 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"math/bits"
+)
+
+// seq is a sequence: some literals followed by a match.
+// RFC 3.1.1.3.2.
+type seq struct {
+	litLen   uint32 // number of literals
+	matchLen uint32 // length of the match
+	offset   uint32 // distance back to the match
+}
+
+// minHuffLiterals is the smallest number of literals that we try to
+// compress with a Huffman code.
+const minHuffLiterals = 32
+
+// predefDistribution is the predefined distribution for each kind of
+// sequence code, for Predefined_Mode.
+var predefDistribution = [3][]int16{
+	seqLiteral: literalPredefinedDistribution,
+	seqOffset:  offsetPredefinedDistribution,
+	seqMatch:   matchPredefinedDistribution,
+}
+
+// blockEncoder encodes compressed blocks. RFC 3.1.1.3.
+type blockEncoder struct {
+	// The repeated offsets, as the decoder will see them.
+	repeatedOffsets [3]uint32
+
+	// Encoder for Huffman compressed literals.
+	huff huffEncoder
+
+	// The codes for each kind of sequence code,
+	// and the offset values, one per sequence.
+	codes   [3][]uint8
+	offsets []uint32
+
+	// The tables used to encode the sequence codes.
+	tables [3]fseEncTable
+	norm   []int16
+
+	// Scratch space for the Huffman streams.
+	streams []byte
+}
+
+// reset prepares to encode the blocks of a new frame.
+func (e *blockEncoder) reset() {
+	e.repeatedOffsets = [3]uint32{1, 4, 8}
+}
+
+// appendBlock appends a compressed block holding lits and seqs to out,
+// without the block header. The literals of the sequences are in lits
+// in order, followed by the literals that end the block.
+func (e *blockEncoder) appendBlock(out []byte, lits []byte, seqs []seq) []byte {
+	out = e.appendLiterals(out, lits)
+	return e.appendSeqs(out, seqs)
+}
+
+// appendLiterals appends the literals section to out. RFC 3.1.1.3.1.
+func (e *blockEncoder) appendLiterals(out []byte, lits []byte) []byte {
+	if len(lits) > 1 && allSame(lits) {
+		out = appendLiteralsHeader(out, 1, len(lits))
+		return append(out, lits[0])
+	}
+	if len(lits) >= minHuffLiterals {
+		start := len(out)
+		var ok bool
+		out, ok = e.appendHuffLiterals(out, lits)
+		if ok && len(out)-start < len(lits) {
+			return out
+		}
+		out = out[:start]
+	}
+	out = appendLiteralsHeader(out, 0, len(lits))
+	return append(out, lits...)
+}
+
+// allSame reports whether all the bytes in b are the same.
+func allSame(b []byte) bool {
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendLiteralsHeader appends the header for a Raw_Literals_Block
+// (typ 0) or RLE_Literals_Block (typ 1) of size bytes. RFC 3.1.1.3.1.1.
+func appendLiteralsHeader(out []byte, typ byte, size int) []byte {
+	switch {
+	case size < 1<<5:
+		return append(out, typ|byte(size)<<3)
+	case size < 1<<12:
+		return append(out, typ|1<<2|byte(size)<<4, byte(size>>4))
+	default:
+		return append(out, typ|3<<2|byte(size)<<4, byte(size>>4), byte(size>>12))
+	}
+}
+
+// appendHuffLiterals appends a Compressed_Literals_Block holding lits
+// to out. It reports false if lits cannot be compressed this way.
+// RFC 3.1.1.3.1.
+func (e *blockEncoder) appendHuffLiterals(out []byte, lits []byte) ([]byte, bool) {
+	var counts [256]uint32
+	for _, b := range lits {
+		counts[b]++
+	}
+	e.huff.build(&counts)
+
+	var ok bool
+	e.streams, ok = e.huff.appendTree(e.streams[:0])
+	if !ok {
+		return out, false
+	}
+
+	// Small literal sections use a single stream. Otherwise
+	// there are four streams, with a jump table giving the
+	// sizes of the first three. RFC 3.1.1.3.1.6.
+	var sizeFormat byte
+	if len(lits) < 256 {
+		e.streams = e.huff.appendStream(e.streams, lits)
+		sizeFormat = 0
+	} else {
+		jump := len(e.streams)
+		e.streams = append(e.streams, 0, 0, 0, 0, 0, 0)
+		segment := (len(lits) + 3) / 4
+		for i := 0; i < 4; i++ {
+			start := len(e.streams)
+			e.streams = e.huff.appendStream(e.streams, lits[i*segment:min((i+1)*segment, len(lits))])
+			if i < 3 {
+				size := len(e.streams) - start
+				if size > 0xffff {
+					return out, false
+				}
+				e.streams[jump+2*i] = byte(size)
+				e.streams[jump+2*i+1] = byte(size >> 8)
+			}
+		}
+		sizeFormat = 1
+	}
+
+	// Literals_Section_Header. RFC 3.1.1.3.1.1.
+	regenerated, compressed := uint64(len(lits)), uint64(len(e.streams))
+	size := max(regenerated, compressed)
+	var sizeBits, hdrLen int
+	switch {
+	case size < 1<<10:
+		sizeBits, hdrLen = 10, 3
+	case size < 1<<14:
+		sizeBits, hdrLen, sizeFormat = 14, 4, 2
+	case size < 1<<18:
+		sizeBits, hdrLen, sizeFormat = 18, 5, 3
+	default:
+		return out, false
+	}
+	if sizeFormat == 0 && sizeBits != 10 {
+		return out, false
+	}
+	hdr := 2 | uint64(sizeFormat)<<2 | regenerated<<4 | compressed<<(4+sizeBits)
+	for i := 0; i < hdrLen; i++ {
+		out = append(out, byte(hdr>>(8*i)))
+	}
+	return append(out, e.streams...), true
+}
+
+// appendSeqs appends the sequences section to out. RFC 3.1.1.3.2.
+func (e *blockEncoder) appendSeqs(out []byte, seqs []seq) []byte {
+	// Sequences_Section_Header. RFC 3.1.1.3.2.1.
+	n := len(seqs)
+	switch {
+	case n < 128:
+		out = append(out, byte(n))
+	case n < 0x7f00:
+		out = append(out, byte(n>>8)+128, byte(n))
+	default:
+		out = append(out, 255, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if n == 0 {
+		return out
+	}
+
+	for kind := range e.codes {
+		e.codes[kind] = resize(e.codes[kind], n)
+	}
+	e.offsets = resize(e.offsets, n)
+	for i, s := range seqs {
+		e.codes[seqLiteral][i] = literalLengthCode(s.litLen)
+		e.codes[seqMatch][i] = matchLengthCode(s.matchLen)
+		ov := e.offsetValue(s.offset, s.litLen)
+		e.offsets[i] = ov
+		e.codes[seqOffset][i] = offsetCode(ov)
+	}
+
+	// Choose the compression mode for each kind of code,
+	// in the order of the Symbol_Compression_Modes byte.
+	modesOff := len(out)
+	out = append(out, 0)
+	for _, kind := range [...]seqCode{seqLiteral, seqOffset, seqMatch} {
+		var mode byte
+		out, mode = e.appendSeqTable(out, kind)
+		out[modesOff] |= mode << (6 - 2*kind)
+	}
+
+	// The bitstream is read in reverse, starting with the
+	// initial states, and then for each sequence the extra bits
+	// for the offset, match length and literal length codes,
+	// followed, except for the last sequence, by the bits to move
+	// to the next states, for the literal length, match length
+	// and offset codes. RFC 3.1.1.3.2.2. See execSeqs.
+	// So we write all that backward.
+	bw := bitWriter{out: out}
+	var state [3]uint16
+	for i := n - 1; i >= 0; i-- {
+		ll := e.codes[seqLiteral][i]
+		ml := e.codes[seqMatch][i]
+		of := e.codes[seqOffset][i]
+		if i == n-1 {
+			state[seqLiteral] = e.tables[seqLiteral].start(ll)
+			state[seqMatch] = e.tables[seqMatch].start(ml)
+			state[seqOffset] = e.tables[seqOffset].start(of)
+		} else {
+			for _, kind := range [...]seqCode{seqOffset, seqMatch, seqLiteral} {
+				s, v, nb := e.tables[kind].encode(e.codes[kind][i], state[kind])
+				state[kind] = s
+				bw.add(v, nb)
+			}
+		}
+
+		s := seqs[i]
+		v, nb := literalLengthExtra(s.litLen, ll)
+		bw.add(v, nb)
+		v, nb = matchLengthExtra(s.matchLen, ml)
+		bw.add(v, nb)
+		bw.add(e.offsets[i]-1<<of, of)
+	}
+	bw.add(uint32(state[seqMatch]), uint8(e.tables[seqMatch].tableBits))
+	bw.add(uint32(state[seqOffset]), uint8(e.tables[seqOffset].tableBits))
+	bw.add(uint32(state[seqLiteral]), uint8(e.tables[seqLiteral].tableBits))
+	bw.closeReverse()
+	return bw.out
+}
+
+// appendSeqTable chooses how to encode the codes of kind, sets up
+// e.tables[kind] for it, and appends the table description, if any,
+// to out. It returns the Compression_Mode. RFC 3.1.1.3.2.1.
+func (e *blockEncoder) appendSeqTable(out []byte, kind seqCode) ([]byte, byte) {
+	info := &seqCodeInfo[kind]
+	var countsBuf [53]uint32
+	counts := countsBuf[:info.maxSym+1]
+	distinct := 0
+	for _, c := range e.codes[kind] {
+		if counts[c] == 0 {
+			distinct++
+		}
+		counts[c]++
+	}
+
+	codes := e.codes[kind]
+	if distinct == 1 {
+		// RLE_Mode.
+		e.tables[kind].initRLE(codes[0])
+		return append(out, codes[0]), 1
+	}
+
+	predef := fseCost(counts, predefDistribution[kind], info.predefTableBits)
+
+	total := uint32(len(codes))
+	tableBits := fseTableBits(total, distinct, info.maxBits)
+	e.norm = normalizeCounts(e.norm, counts, total, tableBits)
+	start := len(out)
+	out = writeFSE(out, e.norm, tableBits)
+	compressed := float64(8*(len(out)-start)) + fseCost(counts, e.norm, tableBits)
+
+	if predef >= 0 && predef <= compressed {
+		// Predefined_Mode.
+		out = out[:start]
+		e.tables[kind].init(predefDistribution[kind], info.predefTableBits)
+		return out, 0
+	}
+
+	// FSE_Compressed_Mode.
+	e.tables[kind].init(e.norm, tableBits)
+	return out, 2
+}
+
+// offsetValue returns the Offset_Value for a match at offset after
+// litLen literals, using a repeated offset if possible, and updates
+// the repeated offsets as the decoder will. RFC 3.1.1.5.
+func (e *blockEncoder) offsetValue(offset, litLen uint32) uint32 {
+	r := &e.repeatedOffsets
+	if litLen > 0 {
+		switch offset {
+		case r[0]:
+			return 1
+		case r[1]:
+			r[0], r[1] = r[1], r[0]
+			return 2
+		case r[2]:
+			r[0], r[1], r[2] = r[2], r[0], r[1]
+			return 3
+		}
+	} else {
+		// Without literals, the values are shifted by one,
+		// as repeating the last offset would have extended
+		// the last match.
+		switch offset {
+		case r[1]:
+			r[0], r[1] = r[1], r[0]
+			return 1
+		case r[2]:
+			r[0], r[1], r[2] = r[2], r[0], r[1]
+			return 2
+		case r[0] - 1:
+			r[0], r[1], r[2] = offset, r[0], r[1]
+			return 3
+		}
+	}
+	r[0], r[1], r[2] = offset, r[0], r[1]
+	return offset + 3
+}
+
+// offsetCode returns the offset code for the Offset_Value ov.
+// RFC 3.1.1.3.2.1.1.
+func offsetCode(ov uint32) uint8 {
+	return uint8(bits.Len32(ov) - 1)
+}
+
+// literalLengthCode returns the literal length code for ll.
+// RFC 3.1.1.3.2.1.1.
+func literalLengthCode(ll uint32) uint8 {
+	if ll < literalLengthOffset {
+		return uint8(ll)
+	}
+	i := len(literalLengthBase) - 1
+	for literalLengthBase[i]&0xffffff > ll {
+		i--
+	}
+	return uint8(literalLengthOffset + i)
+}
+
+// literalLengthExtra returns the extra bits for ll with the literal
+// length code c, and the number of them.
+func literalLengthExtra(ll uint32, c uint8) (uint32, uint8) {
+	if c < literalLengthOffset {
+		return 0, 0
+	}
+	base := literalLengthBase[c-literalLengthOffset]
+	return ll - base&0xffffff, uint8(base >> 24)
+}
+
+// matchLengthCode returns the match length code for ml.
+// RFC 3.1.1.3.2.1.1.
+func matchLengthCode(ml uint32) uint8 {
+	if ml-3 < matchLengthOffset {
+		return uint8(ml - 3)
+	}
+	i := len(matchLengthBase) - 1
+	for matchLengthBase[i]&0xffffff > ml {
+		i--
+	}
+	return uint8(matchLengthOffset + i)
+}
+
+// matchLengthExtra returns the extra bits for ml with the match
+// length code c, and the number of them.
+func matchLengthExtra(ml uint32, c uint8) (uint32, uint8) {
+	if c < matchLengthOffset {
+		return 0, 0
+	}
+	base := matchLengthBase[c-matchLengthOffset]
+	return ml - base&0xffffff, uint8(base >> 24)
+}
@@ -0,0 +1,319 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"math"
+	"math/bits"
+)
+
+// literalPredefinedDistribution is the predefined distribution table
+// for literal lengths. RFC 3.1.1.3.2.2.1.
+var literalPredefinedDistribution = []int16{
+	4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+	2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+	-1, -1, -1, -1,
+}
+
+// offsetPredefinedDistribution is the predefined distribution table
+// for offsets. RFC 3.1.1.3.2.2.3.
+var offsetPredefinedDistribution = []int16{
+	1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+}
+
+// matchPredefinedDistribution is the predefined distribution table
+// for match lengths. RFC 3.1.1.3.2.2.2.
+var matchPredefinedDistribution = []int16{
+	1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+	-1, -1, -1, -1, -1,
+}
+
+// bitWriter writes a bit stream, least significant bit first.
+// A stream written this way can be read going forward with a
+// bitReader, or in reverse with a reverseBitReader, which returns
+// the values in the opposite order from which they were added.
+type bitWriter struct {
+	out  []byte // the bytes written so far
+	bits uint64 // bits not yet written to out
+	cnt  uint   // number of valid bits in the bits field
+}
+
+// add adds the low n bits of v to the stream. n must be at most 32.
+func (bw *bitWriter) add(v uint32, n uint8) {
+	bw.bits |= uint64(v&(1<<n-1)) << bw.cnt
+	bw.cnt += uint(n)
+	if bw.cnt >= 32 {
+		bw.out = append(bw.out, byte(bw.bits), byte(bw.bits>>8), byte(bw.bits>>16), byte(bw.bits>>24))
+		bw.bits >>= 32
+		bw.cnt -= 32
+	}
+}
+
+// close pads the stream with zero bits to a byte boundary.
+func (bw *bitWriter) close() {
+	for bw.cnt > 0 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits >>= 8
+		bw.cnt -= min(bw.cnt, 8)
+	}
+	bw.bits = 0
+}
+
+// closeReverse ends a stream to be read in reverse,
+// marking where it ends with a 1 bit. RFC 4.1.
+func (bw *bitWriter) closeReverse() {
+	bw.add(1, 1)
+	bw.close()
+}
+
+// fseEncTable is a table for encoding symbols with FSE. RFC 4.1.
+//
+// Encoding runs backward: given the decoding state that follows a
+// symbol, encode finds a decoding state for the symbol that moves to
+// that following state, and the bits that the decoder reads to do so.
+type fseEncTable struct {
+	tableBits int
+
+	// For each symbol, the number of states that decode it,
+	// and the index in states of the first of them.
+	count []uint16
+	first []uint16
+
+	// The decoding states grouped by symbol,
+	// each group in increasing order.
+	states []uint16
+}
+
+// init sets up t for the probabilities in norm, using a table with
+// tableBits bits. This matches the table that buildFSE builds.
+func (t *fseEncTable) init(norm []int16, tableBits int) {
+	tableSize := 1 << tableBits
+	highThreshold := tableSize - 1
+
+	t.tableBits = tableBits
+	t.count = resize(t.count, len(norm))
+	t.first = resize(t.first, len(norm))
+	t.states = resize(t.states, tableSize)
+
+	var syms [1 << 9]uint8
+	for i, n := range norm {
+		if n >= 0 {
+			t.count[i] = uint16(n)
+		} else {
+			syms[highThreshold] = uint8(i)
+			highThreshold--
+			t.count[i] = 1
+		}
+	}
+
+	pos := 0
+	step := (tableSize >> 1) + (tableSize >> 3) + 3
+	mask := tableSize - 1
+	for i, n := range norm {
+		for j := 0; j < int(n); j++ {
+			syms[pos] = uint8(i)
+			pos = (pos + step) & mask
+			for pos > highThreshold {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+
+	next := uint16(0)
+	for i, c := range t.count {
+		t.first[i] = next
+		next += c
+	}
+	var filled [256]uint16
+	for i := 0; i < tableSize; i++ {
+		sym := syms[i]
+		t.states[t.first[sym]+filled[sym]] = uint16(i)
+		filled[sym]++
+	}
+}
+
+// initRLE sets up t to encode only sym, for RLE_Mode.
+func (t *fseEncTable) initRLE(sym uint8) {
+	t.tableBits = 0
+	t.count = resize(t.count, int(sym)+1)
+	t.first = resize(t.first, int(sym)+1)
+	t.states = resize(t.states, 1)
+	clear(t.count)
+	t.count[sym] = 1
+	t.first[sym] = 0
+	t.states[0] = 0
+}
+
+// start returns the state to use for the last symbol encoded in a
+// stream, which is the first one decoded. Of the states for sym, it is
+// the one whose transition reads the most bits, which is at least one
+// bit unless sym is the only symbol.
+func (t *fseEncTable) start(sym uint8) uint16 {
+	return t.states[t.first[sym]]
+}
+
+// encode returns the state that decodes sym and then moves to state
+// next, along with the value and number of bits that the decoder
+// reads for that move.
+func (t *fseEncTable) encode(sym uint8, next uint16) (state uint16, v uint32, n uint8) {
+	// The decoder's state after reading v is
+	// (ns << n) - tableSize + v, where ns counts the states
+	// for sym from count to 2*count-1. See buildFSE.
+	c := uint32(t.count[sym])
+	tv := uint32(next) + 1<<t.tableBits
+	shift := bits.Len32(tv) - bits.Len32(c)
+	if tv>>shift < c {
+		shift--
+	}
+	ns := tv >> shift
+	state = t.states[uint32(t.first[sym])+ns-c]
+	return state, tv & (1<<shift - 1), uint8(shift)
+}
+
+// fseCost returns an estimate of the number of bits needed to encode
+// symbols with the given counts using the probabilities in norm,
+// or -1 if norm cannot encode all of them.
+func fseCost(counts []uint32, norm []int16, tableBits int) float64 {
+	total := 0.0
+	for sym, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if sym >= len(norm) || norm[sym] == 0 {
+			return -1
+		}
+		n := float64(norm[sym])
+		if n < 0 {
+			n = 1
+		}
+		total += float64(c) * (float64(tableBits) - math.Log2(n))
+	}
+	return total
+}
+
+// normalizeCounts sets norm to probabilities that approximate counts
+// and add up to 1<<tableBits, giving each symbol that occurs at least
+// one state. The sum of counts is total, and it must have at least
+// two symbols. It returns norm.
+func normalizeCounts(norm []int16, counts []uint32, total uint32, tableBits int) []int16 {
+	norm = resize(norm, len(counts))
+	tableSize := int32(1) << tableBits
+	sum := int32(0)
+	largest := 0
+	for sym, c := range counts {
+		n := int32(0)
+		if c > 0 {
+			n = int32((uint64(c)<<tableBits + uint64(total)/2) / uint64(total))
+			n = max(n, 1)
+		}
+		norm[sym] = int16(n)
+		sum += n
+		if c > counts[largest] {
+			largest = sym
+		}
+	}
+
+	// Rounding may leave the probabilities off by a little.
+	// Take the difference from the most common symbols.
+	if sum < tableSize {
+		norm[largest] += int16(tableSize - sum)
+	}
+	for sum > tableSize {
+		big := largest
+		for sym, n := range norm {
+			if n > norm[big] {
+				big = sym
+			}
+		}
+		norm[big]--
+		sum--
+	}
+	return norm
+}
+
+// fseTableBits returns the number of bits to use for an FSE table
+// for nsyms distinct symbols occurring total times in all,
+// no more than maxBits.
+func fseTableBits(total uint32, nsyms, maxBits int) int {
+	// There is no point in a table that is much larger than
+	// the number of symbols it encodes.
+	tableBits := min(maxBits, bits.Len32(total)+1)
+	// Each symbol needs at least one state.
+	tableBits = max(tableBits, bits.Len(uint(nsyms))+1)
+	return max(tableBits, 5)
+}
+
+// writeFSE appends the description of the probabilities in norm for a
+// table with tableBits bits to out. This is the inverse of readFSE.
+// RFC 4.1.1.
+func writeFSE(out []byte, norm []int16, tableBits int) []byte {
+	// Trailing symbols with a zero probability are implied.
+	last := len(norm) - 1
+	for norm[last] == 0 {
+		last--
+	}
+
+	bw := bitWriter{out: out}
+	bw.add(uint32(tableBits-5), 4)
+
+	remaining := int32(1<<tableBits) + 1
+	threshold := int32(1) << tableBits
+	bitsNeeded := uint8(tableBits + 1)
+
+	for sym := 0; sym <= last && remaining > 1; {
+		n := int32(norm[sym])
+		sym++
+
+		max := (2*threshold - 1) - remaining
+		if n < 0 {
+			remaining--
+		} else {
+			remaining -= n
+		}
+		v := n + 1
+		if v >= threshold {
+			v += max
+		}
+		if v < max {
+			// A small value, which needs one bit less.
+			bw.add(uint32(v), bitsNeeded-1)
+		} else {
+			bw.add(uint32(v), bitsNeeded)
+		}
+		for remaining < threshold {
+			bitsNeeded--
+			threshold >>= 1
+		}
+
+		if n == 0 {
+			// A zero probability is followed by a count of the
+			// zero probabilities that come next, two bits at a
+			// time, with 3 meaning that the count continues.
+			zeros := 0
+			for norm[sym+zeros] == 0 {
+				zeros++
+			}
+			sym += zeros
+			for ; zeros >= 3; zeros -= 3 {
+				bw.add(3, 2)
+			}
+			bw.add(uint32(zeros), 2)
+		}
+	}
+
+	bw.close()
+	return bw.out
+}
+
+// resize returns s with length n, reusing its storage if possible.
+func resize[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
@@ -0,0 +1,238 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"slices"
+)
+
+// huffEncoder encodes literals with a Huffman code. RFC 4.2.
+type huffEncoder struct {
+	// The code and code length of each symbol.
+	codes [256]uint16
+	lens  [256]uint8
+
+	// The number of bits in the longest code.
+	tableBits int
+
+	// The highest symbol with a code.
+	maxSym int
+
+	// The weight of each symbol. RFC 4.2.1.
+	weights [256]uint8
+
+	// Scratch space used to build the code.
+	nodes  []huffNode
+	counts [256]uint32
+
+	// Scratch space used to describe the code.
+	fse  fseEncTable
+	norm []int16
+	desc []byte
+}
+
+// huffNode is a node in the tree built to find the code lengths.
+type huffNode struct {
+	count  uint32
+	parent int32
+	depth  uint8
+}
+
+// build builds a Huffman code for the symbols in counts.
+// At least two symbols must occur.
+func (h *huffEncoder) build(counts *[256]uint32) {
+	h.counts = *counts
+	for {
+		h.tableBits = h.buildLens()
+		if h.tableBits <= maxHuffmanBits {
+			break
+		}
+		// The code is too deep for the decoder's table.
+		// Flatten the counts and try again.
+		for i, c := range h.counts {
+			if c > 0 {
+				h.counts[i] = (c + 1) / 2
+			}
+		}
+	}
+
+	// Convert the lengths to weights and assign the codes
+	// in the order in which the decoder builds its table.
+	// See readHuff.
+	var weightCount [maxHuffmanBits + 2]uint32
+	h.maxSym = 0
+	for sym, l := range h.lens {
+		w := uint8(0)
+		if l > 0 {
+			w = uint8(h.tableBits) + 1 - l
+			h.maxSym = sym
+		}
+		h.weights[sym] = w
+		weightCount[w]++
+	}
+	var start [maxHuffmanBits + 2]uint32
+	next := uint32(0)
+	for w := 1; w <= h.tableBits; w++ {
+		start[w] = next
+		next += weightCount[w] << (w - 1)
+	}
+	for sym, w := range h.weights {
+		if w == 0 {
+			continue
+		}
+		h.codes[sym] = uint16(start[w] >> (w - 1))
+		start[w] += 1 << (w - 1)
+	}
+}
+
+// buildLens sets h.lens to the code lengths of a Huffman code for
+// h.counts, and returns the longest length.
+func (h *huffEncoder) buildLens() int {
+	// The leaves are the symbols in increasing order of count.
+	// The internal nodes follow, and are created in increasing
+	// order of count as well, so the two lowest nodes not yet
+	// in the tree are always at the front of one of the lists.
+	var syms [256]uint8
+	n := 0
+	for sym, c := range h.counts {
+		if c > 0 {
+			syms[n] = uint8(sym)
+			n++
+		}
+	}
+	slices.SortStableFunc(syms[:n], func(a, b uint8) int {
+		return int(h.counts[a]) - int(h.counts[b])
+	})
+
+	h.nodes = resize(h.nodes, 2*n-1)
+	for i, sym := range syms[:n] {
+		h.nodes[i] = huffNode{count: h.counts[sym]}
+	}
+	leaf, inner := 0, n
+	lowest := func(created int) int {
+		if leaf < n && (inner >= created || h.nodes[leaf].count <= h.nodes[inner].count) {
+			leaf++
+			return leaf - 1
+		}
+		inner++
+		return inner - 1
+	}
+	for i := n; i < len(h.nodes); i++ {
+		a := lowest(i)
+		b := lowest(i)
+		h.nodes[i] = huffNode{count: h.nodes[a].count + h.nodes[b].count}
+		h.nodes[a].parent = int32(i)
+		h.nodes[b].parent = int32(i)
+	}
+
+	// Parents follow their children, so set depths from the root.
+	maxLen := 0
+	h.nodes[len(h.nodes)-1].depth = 0
+	for i := len(h.nodes) - 2; i >= 0; i-- {
+		h.nodes[i].depth = h.nodes[h.nodes[i].parent].depth + 1
+	}
+	h.lens = [256]uint8{}
+	for i, sym := range syms[:n] {
+		h.lens[sym] = h.nodes[i].depth
+		maxLen = max(maxLen, int(h.nodes[i].depth))
+	}
+	return maxLen
+}
+
+// appendTree appends the description of the code to out.
+// It reports false if the code cannot be described. RFC 4.2.1.
+func (h *huffEncoder) appendTree(out []byte) ([]byte, bool) {
+	// The weight of the last symbol is implied.
+	weights := h.weights[:h.maxSym]
+
+	// Try the weights compressed with FSE.
+	h.desc = h.appendFSEWeights(h.desc[:0], weights)
+
+	if len(weights) <= 128 && (len(h.desc) == 0 || 1+(len(weights)+1)/2 <= len(h.desc)) {
+		// Use the weights directly, 4 bits each.
+		out = append(out, byte(127+len(weights)))
+		for i := 0; i < len(weights); i += 2 {
+			b := weights[i] << 4
+			if i+1 < len(weights) {
+				b |= weights[i+1]
+			}
+			out = append(out, b)
+		}
+		return out, true
+	}
+
+	if len(h.desc) == 0 {
+		return out, false
+	}
+	return append(out, h.desc...), true
+}
+
+// appendFSEWeights appends weights compressed with FSE to out, or
+// returns out unchanged if that is not possible. RFC 4.2.1.2.
+func (h *huffEncoder) appendFSEWeights(out []byte, weights []uint8) []byte {
+	if len(weights) < 2 {
+		return out
+	}
+	var counts [maxHuffmanBits + 1]uint32
+	distinct := 0
+	for _, w := range weights {
+		if counts[w] == 0 {
+			distinct++
+		}
+		counts[w]++
+	}
+	if distinct < 2 {
+		return out
+	}
+
+	tableBits := fseTableBits(uint32(len(weights)), distinct, 6)
+	h.norm = normalizeCounts(h.norm, counts[:], uint32(len(weights)), tableBits)
+	h.fse.init(h.norm, tableBits)
+
+	hdr := len(out)
+	out = append(out, 0)
+	out = writeFSE(out, h.norm, tableBits)
+
+	// There are two interleaved states: the first decodes the
+	// weights at even indexes, the second those at odd indexes.
+	// The last weight for each state is decoded from its final
+	// state without reading any further bits, and the decoder
+	// stops when it runs out of bits to move past it.
+	// See readHuff.
+	n := len(weights)
+	var state [2]uint16
+	state[(n-1)%2] = h.fse.start(weights[n-1])
+	state[(n-2)%2] = h.fse.start(weights[n-2])
+	bw := bitWriter{out: out}
+	for i := n - 3; i >= 0; i-- {
+		s, v, nb := h.fse.encode(weights[i], state[i%2])
+		state[i%2] = s
+		bw.add(v, nb)
+	}
+	bw.add(uint32(state[1]), uint8(tableBits))
+	bw.add(uint32(state[0]), uint8(tableBits))
+	bw.closeReverse()
+	out = bw.out
+
+	size := len(out) - hdr - 1
+	if size >= 128 {
+		return out[:hdr]
+	}
+	out[hdr] = byte(size)
+	return out
+}
+
+// appendStream appends lits encoded as a single stream to out.
+func (h *huffEncoder) appendStream(out []byte, lits []byte) []byte {
+	// The decoder reads the stream in reverse,
+	// so write the literals from last to first.
+	bw := bitWriter{out: out}
+	for i := len(lits) - 1; i >= 0; i-- {
+		b := lits[i]
+		bw.add(uint32(h.codes[b]), h.lens[b])
+	}
+	bw.closeReverse()
+	return bw.out
+}
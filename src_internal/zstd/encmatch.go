@@ -0,0 +1,205 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// minMatch is the shortest match that the matcher looks for.
+const minMatch = 4
+
+// matchParams are the parameters of the matcher for a compression level.
+type matchParams struct {
+	windowLog uint8 // log2 of the window size
+	hashLog   uint8 // log2 of the number of hash chains
+	depth     int   // number of earlier positions to try for a match
+	lazy      bool  // whether to try for a longer match one byte later
+}
+
+// matcher finds matches for the data in a block in the data before it,
+// using hash chains: for each position, the previous position whose
+// next four bytes have the same hash.
+type matcher struct {
+	matchParams
+
+	// The data seen so far: up to twice the window size of earlier
+	// data, followed by the data of the current block.
+	hist []byte
+
+	// For each hash, the latest position with that hash, plus one,
+	// or 0 if there is none.
+	head []int32
+
+	// For each position modulo the window size, the previous position
+	// with the same hash, plus one, or 0 if there is none.
+	chain []int32
+
+	// The positions before inserted are in the hash chains.
+	inserted int
+
+	// The offset of the last match, which is cheap to use again.
+	lastOffset int
+}
+
+// reset prepares m to find matches in a new frame.
+func (m *matcher) reset(p matchParams) {
+	window := 1 << p.windowLog
+	if m.matchParams != p || m.head == nil {
+		m.matchParams = p
+		m.hist = make([]byte, 0, 2*window+maxBlockSize)
+		m.head = make([]int32, 1<<p.hashLog)
+		m.chain = make([]int32, window)
+	} else {
+		clear(m.head)
+		clear(m.chain)
+	}
+	m.hist = m.hist[:0]
+	m.inserted = 0
+	m.lastOffset = 0
+}
+
+// slide drops the data that is no longer needed for matches once twice
+// the window size has been seen, keeping the current block at the end
+// of m.hist. It returns the number of bytes dropped.
+func (m *matcher) slide() int {
+	window := 1 << m.windowLog
+	if len(m.hist) <= 2*window {
+		return 0
+	}
+	// Drop exactly the window size, so that positions keep
+	// their index into chain.
+	copy(m.hist, m.hist[window:])
+	m.hist = m.hist[:len(m.hist)-window]
+	for i, v := range m.head {
+		m.head[i] = max(v-int32(window), 0)
+	}
+	for i, v := range m.chain {
+		m.chain[i] = max(v-int32(window), 0)
+	}
+	m.inserted -= window
+	return window
+}
+
+// hash returns the hash of the four bytes at p.
+func (m *matcher) hash(p int) uint32 {
+	return (binary.LittleEndian.Uint32(m.hist[p:]) * 2654435761) >> (32 - m.hashLog)
+}
+
+// insertUpTo inserts the positions before limit into the hash chains.
+func (m *matcher) insertUpTo(limit int) {
+	limit = min(limit, len(m.hist)-minMatch+1)
+	mask := 1<<m.windowLog - 1
+	for ; m.inserted < limit; m.inserted++ {
+		h := m.hash(m.inserted)
+		m.chain[m.inserted&mask] = m.head[h]
+		m.head[h] = int32(m.inserted + 1)
+	}
+}
+
+// find finds matches for the data in m.hist from start to the end.
+// It appends the literals between the matches to lits and the
+// sequences to seqs, and returns them.
+func (m *matcher) find(start int, lits []byte, seqs []seq) ([]byte, []seq) {
+	end := len(m.hist)
+	lit := start
+	for p := start; p+minMatch <= end; {
+		m.insertUpTo(p)
+		length, offset := m.longest(p, end)
+		if length > 0 && m.lazy {
+			// Take a longer match at the next position
+			// in place of this one.
+			for p+1+minMatch <= end {
+				m.insertUpTo(p + 1)
+				nlength, noffset := m.longest(p+1, end)
+				if nlength <= length {
+					break
+				}
+				p++
+				length, offset = nlength, noffset
+			}
+		}
+		if length == 0 {
+			if m.lazy {
+				p++
+			} else {
+				// Skip ahead faster through data
+				// that does not seem to compress.
+				p += 1 + (p-lit)>>7
+			}
+			continue
+		}
+
+		// Extend the match back into the literals.
+		for p > lit && p-offset > 0 && m.hist[p-1] == m.hist[p-1-offset] {
+			p--
+			length++
+		}
+
+		lits = append(lits, m.hist[lit:p]...)
+		seqs = append(seqs, seq{
+			litLen:   uint32(p - lit),
+			matchLen: uint32(length),
+			offset:   uint32(offset),
+		})
+		m.lastOffset = offset
+		p += length
+		lit = p
+	}
+	return append(lits, m.hist[lit:end]...), seqs
+}
+
+// longest returns the length and offset of the longest match for the
+// data at p that ends by end, or 0, 0 if there is none.
+func (m *matcher) longest(p, end int) (length, offset int) {
+	hist := m.hist
+	maxLength := end - p
+	minPos := max(p-1<<m.windowLog, 0)
+	best := minMatch - 1
+
+	// Try the last offset first; it is the cheapest to encode.
+	if o := m.lastOffset; o > 0 && p-o >= minPos {
+		if n := matchLen(hist[p-o:], hist[p:end]); n > best {
+			best, offset = n, o
+		}
+	}
+
+	mask := 1<<m.windowLog - 1
+	cand := int(m.head[m.hash(p)]) - 1
+	for depth := m.depth; depth > 0 && cand >= minPos && best < maxLength; depth-- {
+		// Only a match that gets past best can be longer.
+		if hist[cand+best] == hist[p+best] {
+			if n := matchLen(hist[cand:], hist[p:end]); n > best {
+				best, offset = n, p-cand
+			}
+		}
+		next := int(m.chain[cand&mask]) - 1
+		if next >= cand {
+			break
+		}
+		cand = next
+	}
+
+	if best < minMatch {
+		return 0, 0
+	}
+	return best, offset
+}
+
+// matchLen returns the length of the common prefix of a and b.
+// a must be at least as long as b.
+func matchLen(a, b []byte) int {
+	n := 0
+	for ; len(b)-n >= 8; n += 8 {
+		if x := binary.LittleEndian.Uint64(a[n:]) ^ binary.LittleEndian.Uint64(b[n:]); x != 0 {
+			return n + bits.TrailingZeros64(x)/8
+		}
+	}
+	for n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,263 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+// parallelState holds the state of a Reader that decompresses
+// several frames at once. See [Reader.SetConcurrency].
+type parallelState struct {
+	// The number of frames to decompress at once.
+	n int
+
+	// A Reader for each frame in a batch.
+	workers []*Reader
+
+	// The current batch of frames, and the index in it of the
+	// frame that the Reader's buffer holds.
+	frames []parallelFrame
+	next   int
+
+	// Whether the input is exhausted.
+	done bool
+}
+
+// parallelFrame is a frame in a batch.
+type parallelFrame struct {
+	raw    []byte // compressed frame
+	offset int64  // offset of raw in the input
+	data   []byte // decompressed frame
+	err    error  // error reading or decompressing the frame
+}
+
+// SetConcurrency sets the number of frames that r decompresses at
+// once. By default, and if n is less than 2, r decompresses one
+// block at a time.
+//
+// A zstd stream may consist of several frames, each of which can be
+// decompressed independently, as in the seekable format written by
+// zstd's contrib/seekable_format. With a concurrency of n, r reads
+// up to n complete frames from its input and decompresses them in
+// separate goroutines, and then returns their data in order.
+// Each frame is held in memory in full, so this is best suited to
+// streams of many moderately sized frames. A stream with one large
+// frame is decompressed no faster.
+//
+// The concurrency is kept when r is Reset. SetConcurrency must be
+// called before the first Read, or after a call to Reset.
+func (r *Reader) SetConcurrency(n int) {
+	if n < 2 {
+		r.par = nil
+		return
+	}
+	if r.par == nil {
+		r.par = new(parallelState)
+	}
+	r.par.n = n
+	r.par.reset()
+}
+
+// reset discards the current batch of frames.
+func (p *parallelState) reset() {
+	p.frames = p.frames[:0]
+	p.next = 0
+	p.done = false
+}
+
+// refillParallel sets r.buffer to the data of the next frame,
+// decompressing a new batch of frames if necessary.
+func (r *Reader) refillParallel() error {
+	p := r.par
+	if p.next < len(p.frames) {
+		// Report the error in the previous frame,
+		// now that its data has been read.
+		if err := p.frames[p.next].err; err != nil {
+			return err
+		}
+		p.next++
+	}
+	if p.next >= len(p.frames) {
+		if p.done {
+			return io.EOF
+		}
+		r.readBatch()
+		p.next = 0
+		if len(p.frames) == 0 {
+			return io.EOF
+		}
+	}
+	r.buffer = p.frames[p.next].data
+	return nil
+}
+
+// readBatch reads up to p.n frames from the input,
+// and decompresses them concurrently.
+func (r *Reader) readBatch() {
+	p := r.par
+	p.frames = p.frames[:cap(p.frames)]
+	n := 0
+	for n < p.n {
+		if n == len(p.frames) {
+			p.frames = append(p.frames, parallelFrame{})
+		}
+		f := &p.frames[n]
+		f.offset = r.blockOffset
+		f.raw, f.err = r.readRawFrame(f.raw[:0])
+		f.data = f.data[:0]
+		if f.err == io.EOF {
+			p.done = true
+			break
+		}
+		n++
+		if f.err != nil {
+			p.done = true
+			break
+		}
+	}
+	p.frames = p.frames[:n]
+
+	for len(p.workers) < n {
+		p.workers = append(p.workers, new(Reader))
+	}
+	var wg sync.WaitGroup
+	for i := range p.frames {
+		f := &p.frames[i]
+		if f.err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(w *Reader) {
+			defer wg.Done()
+			w.Reset(bytes.NewReader(f.raw))
+			w.blockOffset = f.offset
+			w.dicts = r.dicts
+			f.data, f.err = w.readFrame(f.data)
+		}(p.workers[i])
+	}
+	wg.Wait()
+}
+
+// readFrame appends the data of the single frame in r's input to buf,
+// and returns it.
+func (r *Reader) readFrame(buf []byte) ([]byte, error) {
+	for {
+		if err := r.refill(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return buf, err
+		}
+		buf = append(buf, r.buffer...)
+	}
+}
+
+// readRawFrame appends the next frame in the input to buf without
+// decompressing it, and returns it. It skips skippable frames.
+// It returns io.EOF at the end of the input.
+func (r *Reader) readRawFrame(buf []byte) ([]byte, error) {
+	start := r.blockOffset
+	relativeOffset := 0
+
+	// read appends the next n bytes of the input to buf.
+	read := func(n int) error {
+		buf = append(buf, make([]byte, n)...)
+		if _, err := io.ReadFull(r.r, buf[len(buf)-n:]); err != nil {
+			return r.wrapNonEOFError(relativeOffset, err)
+		}
+		relativeOffset += n
+		return nil
+	}
+
+	for {
+		// Read magic number. RFC 3.1.1.
+		if _, err := io.ReadFull(r.r, r.scratch[:4]); err != nil {
+			// We require that the stream contains at least one frame.
+			if err == io.EOF && !r.readOneFrame {
+				err = io.ErrUnexpectedEOF
+			}
+			return buf, r.wrapError(0, err)
+		}
+		magic := binary.LittleEndian.Uint32(r.scratch[:4])
+		if magic < 0x184d2a50 || magic > 0x184d2a5f {
+			break
+		}
+		r.blockOffset += 4
+		if err := r.skipFrame(); err != nil {
+			return buf, err
+		}
+		r.readOneFrame = true
+		start = r.blockOffset
+	}
+	buf = append(buf, r.scratch[:4]...)
+	if binary.LittleEndian.Uint32(r.scratch[:4]) != 0xfd2fb528 {
+		return buf, r.makeError(relativeOffset, "invalid magic number")
+	}
+	relativeOffset += 4
+	r.readOneFrame = true
+
+	// The frame header is checked when the frame is decompressed;
+	// here we only need its size. RFC 3.1.1.1.
+	if err := read(1); err != nil {
+		return buf, err
+	}
+	descriptor := buf[len(buf)-1]
+	singleSegment := descriptor&(1<<5) != 0
+	fcsFieldSize := 1 << (descriptor >> 6)
+	if fcsFieldSize == 1 && !singleSegment {
+		fcsFieldSize = 0
+	}
+	headerSize := fcsFieldSize
+	if !singleSegment {
+		headerSize++
+	}
+	if dictIdFlag := descriptor & 3; dictIdFlag != 0 {
+		headerSize += 1 << (dictIdFlag - 1)
+	}
+	hasChecksum := descriptor&(1<<2) != 0
+	if err := read(headerSize); err != nil {
+		return buf, err
+	}
+
+	// Blocks. RFC 3.1.1.2.
+	for {
+		if err := read(3); err != nil {
+			return buf, err
+		}
+		b := buf[len(buf)-3:]
+		header := uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+		lastBlock := header&1 != 0
+		blockType := (header >> 1) & 3
+		blockSize := int(header >> 3)
+		if blockSize > 128<<10 {
+			return buf, r.makeError(relativeOffset, "block size too large")
+		}
+		switch blockType {
+		case 0, 2:
+		case 1:
+			blockSize = 1
+		case 3:
+			return buf, r.makeError(relativeOffset, "invalid block type")
+		}
+		if err := read(blockSize); err != nil {
+			return buf, err
+		}
+		if lastBlock {
+			break
+		}
+	}
+	if hasChecksum {
+		if err := read(4); err != nil {
+			return buf, err
+		}
+	}
+
+	r.blockOffset = start + int64(len(buf))
+	return buf, nil
+}
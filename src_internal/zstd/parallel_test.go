@@ -0,0 +1,135 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParallel(t *testing.T) {
+	data := writerInputs(t)["text"][:1<<20]
+	seekable := makeSeekable(t, data, 30000, true)
+
+	// Frames of several sizes, with skippable frames between them.
+	var mixed, mixedData bytes.Buffer
+	for i, size := range []int{0, 1, 1000, 200000, 5, 300000, 0} {
+		chunk := data[:size]
+		w := NewWriter(&mixed)
+		w.Write(chunk)
+		w.Close()
+		mixedData.Write(chunk)
+		if i%2 == 0 {
+			mixed.Write([]byte{0x50, 0x2a, 0x4d, 0x18, 3, 0, 0, 0, 1, 2, 3})
+		}
+	}
+
+	inputs := []struct {
+		name             string
+		compressed, data []byte
+	}{
+		{"seekable", seekable, data},
+		{"mixed", mixed.Bytes(), mixedData.Bytes()},
+	}
+	for _, test := range tests {
+		inputs = append(inputs, struct {
+			name             string
+			compressed, data []byte
+		}{"tests/" + test.name, []byte(test.compressed), []byte(test.uncompressed)})
+	}
+
+	r := NewReader(nil)
+	for _, n := range []int{2, 3, 16} {
+		r.SetConcurrency(n)
+		for _, in := range inputs {
+			t.Run(fmt.Sprintf("%s/%d", in.name, n), func(t *testing.T) {
+				r.Reset(bytes.NewReader(in.compressed))
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, in.data) {
+					showDiffs(t, got, in.data)
+				}
+			})
+		}
+	}
+}
+
+// TestParallelFileSamples checks that concurrent decompression
+// matches sequential decompression for the files in testdata,
+// including errors and the data before them.
+func TestParallelFileSamples(t *testing.T) {
+	samples, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := writerInputs(t)["text"][:200000]
+	inputs := map[string][]byte{
+		"seekable": makeSeekable(t, data, 10000, false),
+	}
+	for _, sample := range samples {
+		if sample.IsDir() {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join("testdata", sample.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs[sample.Name()] = b
+	}
+
+	for name, compressed := range inputs {
+		// Truncated and corrupted inputs as well.
+		corrupt := bytes.Clone(compressed)
+		corrupt[len(corrupt)*2/3] ^= 0xaa
+		for _, variant := range []struct {
+			name string
+			b    []byte
+		}{
+			{"", compressed},
+			{"/truncated", compressed[:len(compressed)/2]},
+			{"/corrupt", corrupt},
+		} {
+			t.Run(name+variant.name, func(t *testing.T) {
+				want, wantErr := io.ReadAll(NewReader(bytes.NewReader(variant.b)))
+				r := NewReader(bytes.NewReader(variant.b))
+				r.SetConcurrency(4)
+				got, err := io.ReadAll(r)
+				if (err == nil) != (wantErr == nil) {
+					t.Fatalf("got error %v, want %v", err, wantErr)
+				}
+				if wantErr == nil && !bytes.Equal(got, want) {
+					showDiffs(t, got, want)
+				}
+				// After an error, each frame before the one
+				// with the error is returned.
+				if !bytes.HasPrefix(want, got) && !bytes.HasPrefix(got, want) {
+					t.Errorf("got %d bytes, want %d, with different content", len(got), len(want))
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkParallel(b *testing.B) {
+	data := writerInputs(b)["text"]
+	compressed := makeSeekable(b, data, 256<<10, true)
+	for _, n := range []int{1, 4} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			r := NewReader(nil)
+			r.SetConcurrency(n)
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				r.Reset(bytes.NewReader(compressed))
+				io.Copy(io.Discard, r)
+			}
+		})
+	}
+}
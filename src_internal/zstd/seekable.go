@@ -0,0 +1,257 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// The seekable format is described in zstd's
+// contrib/seekable_format/zstd_seekable_compression_format.md.
+// A seekable stream is a sequence of independent frames followed by
+// a skippable frame holding the seek table, which gives the
+// compressed and decompressed size of each frame.
+const (
+	seekTableMagic    = 0x184d2a5e // magic number of the skippable frame
+	seekableMagic     = 0x8f92eab1 // magic number at the very end
+	seekTableFooterSz = 9          // Number_Of_Frames, descriptor, magic
+)
+
+// ErrNotSeekable is returned by [NewSeekableReader] if the data
+// does not end with a seek table.
+var ErrNotSeekable = errors.New("zstd: not in seekable format")
+
+// A SeekableReader implements [io.ReaderAt] to read data in the zstd
+// seekable format. Each read decompresses only the frames holding
+// the data that is read. Its methods may be called concurrently.
+type SeekableReader struct {
+	r io.ReaderAt
+
+	// The frames, in order.
+	frames []seekFrame
+
+	// The decompressed size of all the frames.
+	size int64
+
+	// Whether the seek table has a checksum for each frame.
+	hasChecksums bool
+
+	// Dictionaries by ID, shared by the Readers in pool.
+	dicts map[uint32]*Dict
+
+	// Readers used to decompress frames.
+	pool sync.Pool
+
+	// The most recently decompressed frame.
+	mu        sync.Mutex
+	lastFrame int
+	lastData  []byte
+}
+
+// seekFrame is an entry of the seek table.
+type seekFrame struct {
+	compressedOffset   int64
+	compressedSize     uint32
+	decompressedOffset int64
+	decompressedSize   uint32
+	checksum           uint32
+}
+
+// NewSeekableReader returns a SeekableReader that reads the data in
+// seekable format in the first size bytes of r. It reads the seek table
+// but no frames. It returns [ErrNotSeekable] if there is no seek table.
+func NewSeekableReader(r io.ReaderAt, size int64) (*SeekableReader, error) {
+	s := &SeekableReader{
+		r:         r,
+		lastFrame: -1,
+	}
+	if err := s.readSeekTable(size); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// readSeekTable reads the seek table at the end of the first size
+// bytes of s.r.
+func (s *SeekableReader) readSeekTable(size int64) error {
+	// Seek_Table_Footer.
+	var footer [seekTableFooterSz]byte
+	if size < 8+seekTableFooterSz {
+		return ErrNotSeekable
+	}
+	if _, err := s.r.ReadAt(footer[:], size-seekTableFooterSz); err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic {
+		return ErrNotSeekable
+	}
+	numFrames := int64(binary.LittleEndian.Uint32(footer[:]))
+	descriptor := footer[4]
+	if descriptor&0x7c != 0 {
+		return s.makeError(size-5, "reserved bits set in seek table descriptor")
+	}
+	s.hasChecksums = descriptor&(1<<7) != 0
+	entrySize := int64(8)
+	if s.hasChecksums {
+		entrySize = 12
+	}
+
+	// The skippable frame holding the seek table.
+	tableSize := 8 + numFrames*entrySize + seekTableFooterSz
+	if tableSize > size {
+		return s.makeError(size-seekTableFooterSz, "seek table larger than input")
+	}
+	tableOffset := size - tableSize
+	table := make([]byte, tableSize-seekTableFooterSz)
+	if _, err := s.r.ReadAt(table, tableOffset); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return s.wrapError(tableOffset, err)
+	}
+	if binary.LittleEndian.Uint32(table) != seekTableMagic {
+		return s.makeError(tableOffset, "invalid seek table magic number")
+	}
+	if binary.LittleEndian.Uint32(table[4:]) != uint32(tableSize-8) {
+		return s.makeError(tableOffset+4, "seek table size does not match number of frames")
+	}
+
+	// Seek_Table_Entries.
+	s.frames = make([]seekFrame, numFrames)
+	var coff, doff int64
+	for i := range s.frames {
+		e := table[8+int64(i)*entrySize:]
+		f := &s.frames[i]
+		f.compressedOffset = coff
+		f.compressedSize = binary.LittleEndian.Uint32(e)
+		f.decompressedOffset = doff
+		f.decompressedSize = binary.LittleEndian.Uint32(e[4:])
+		if s.hasChecksums {
+			f.checksum = binary.LittleEndian.Uint32(e[8:])
+		}
+		coff += int64(f.compressedSize)
+		doff += int64(f.decompressedSize)
+	}
+	if coff != tableOffset {
+		return s.makeError(tableOffset, fmt.Sprintf("seek table gives %d bytes of frames, found %d", coff, tableOffset))
+	}
+	s.size = doff
+	return nil
+}
+
+// Size returns the decompressed size of the data.
+func (s *SeekableReader) Size() int64 {
+	return s.size
+}
+
+// AddDict makes d available to decompress frames with d's ID, like
+// [Reader.AddDict]. It must be called before any reads.
+func (s *SeekableReader) AddDict(d *Dict) {
+	if s.dicts == nil {
+		s.dicts = make(map[uint32]*Dict)
+	}
+	s.dicts[d.id] = d
+}
+
+// ReadAt implements [io.ReaderAt].
+func (s *SeekableReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("zstd: negative offset")
+	}
+	n := 0
+	for len(p) > 0 && off < s.size {
+		// Find the frame holding off.
+		i := sort.Search(len(s.frames), func(i int) bool {
+			f := &s.frames[i]
+			return off < f.decompressedOffset+int64(f.decompressedSize)
+		})
+		f := &s.frames[i]
+		data, err := s.frameData(i)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p, data[off-f.decompressedOffset:])
+		p = p[c:]
+		off += int64(c)
+		n += c
+	}
+	if len(p) > 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// frameData returns the decompressed data of frame i.
+// The result must not be modified.
+func (s *SeekableReader) frameData(i int) ([]byte, error) {
+	s.mu.Lock()
+	if s.lastFrame == i {
+		data := s.lastData
+		s.mu.Unlock()
+		return data, nil
+	}
+	s.mu.Unlock()
+
+	data, err := s.decompressFrame(i)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.lastFrame, s.lastData = i, data
+	s.mu.Unlock()
+	return data, nil
+}
+
+// decompressFrame decompresses frame i.
+func (s *SeekableReader) decompressFrame(i int) ([]byte, error) {
+	f := &s.frames[i]
+	r, _ := s.pool.Get().(*Reader)
+	if r == nil {
+		r = new(Reader)
+	}
+	defer s.pool.Put(r)
+	r.Reset(io.NewSectionReader(s.r, f.compressedOffset, int64(f.compressedSize)))
+	r.blockOffset = f.compressedOffset
+	r.dicts = s.dicts
+
+	// The seek table is not trusted to size the buffer, since any
+	// size up to 4GiB can be written there. Let the buffer grow as
+	// the frame is decompressed, reading at most one byte more than
+	// the seek table promises.
+	data, err := io.ReadAll(io.LimitReader(r, int64(f.decompressedSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case int64(len(data)) < int64(f.decompressedSize):
+		return nil, s.makeError(f.compressedOffset, "frame smaller than seek table entry")
+	case int64(len(data)) > int64(f.decompressedSize):
+		return nil, s.makeError(f.compressedOffset, "frame larger than seek table entry")
+	}
+
+	if s.hasChecksums {
+		var h xxhash64
+		h.reset()
+		h.update(data)
+		if got := uint32(h.digest()); got != f.checksum {
+			return nil, s.wrapError(f.compressedOffset, fmt.Errorf("invalid frame checksum: got %#x want %#x", got, f.checksum))
+		}
+	}
+	return data, nil
+}
+
+func (s *SeekableReader) makeError(off int64, msg string) error {
+	return s.wrapError(off, errors.New(msg))
+}
+
+func (s *SeekableReader) wrapError(off int64, err error) error {
+	return &zstdError{off, err}
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"os/exec"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// makeSeekable compresses data in the seekable format,
+// with a frame for every frameSize bytes.
+func makeSeekable(t testing.TB, data []byte, frameSize int, checksums bool) []byte {
+	var out, table bytes.Buffer
+	n := 0
+	for len(data) > 0 {
+		chunk := data[:min(frameSize, len(data))]
+		data = data[len(chunk):]
+
+		start := out.Len()
+		w := NewWriter(&out)
+		w.SetChecksum(checksums)
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		table.Write(binary.LittleEndian.AppendUint32(nil, uint32(out.Len()-start)))
+		table.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(chunk))))
+		if checksums {
+			var h xxhash64
+			h.reset()
+			h.update(chunk)
+			table.Write(binary.LittleEndian.AppendUint32(nil, uint32(h.digest())))
+		}
+		n++
+	}
+
+	var descriptor byte
+	if checksums {
+		descriptor = 1 << 7
+	}
+	b := out.Bytes()
+	b = binary.LittleEndian.AppendUint32(b, seekTableMagic)
+	b = binary.LittleEndian.AppendUint32(b, uint32(table.Len()+seekTableFooterSz))
+	b = append(b, table.Bytes()...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(n))
+	b = append(b, descriptor)
+	b = binary.LittleEndian.AppendUint32(b, seekableMagic)
+	return b
+}
+
+func TestSeekableReadAt(t *testing.T) {
+	data := writerInputs(t)["text"][:1<<20]
+	for _, checksums := range []bool{false, true} {
+		compressed := makeSeekable(t, data, 50000, checksums)
+		s, err := NewSeekableReader(bytes.NewReader(compressed), int64(len(compressed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Size() != int64(len(data)) {
+			t.Fatalf("Size() = %d, want %d", s.Size(), len(data))
+		}
+
+		// Read everything, in one read and then sequentially.
+		got := make([]byte, len(data))
+		if n, err := s.ReadAt(got, 0); n != len(data) || err != nil {
+			t.Fatalf("ReadAt(all) = %d, %v", n, err)
+		}
+		if !bytes.Equal(got, data) {
+			showDiffs(t, got, data)
+		}
+		got, err = io.ReadAll(io.NewSectionReader(s, 0, s.Size()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			showDiffs(t, got, data)
+		}
+
+		// Read at random offsets, concurrently.
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+				rnd := rand.New(rand.NewSource(seed))
+				buf := make([]byte, 200000)
+				for i := 0; i < 50; i++ {
+					off := rnd.Int63n(int64(len(data)) + 10)
+					p := buf[:rnd.Intn(len(buf))]
+					n, err := s.ReadAt(p, off)
+					want := data[min(off, int64(len(data))):]
+					want = want[:min(len(want), len(p))]
+					if n != len(want) || (n < len(p)) != (err == io.EOF) || (err != nil && err != io.EOF) {
+						t.Errorf("ReadAt(%d bytes, %d) = %d, %v; want %d", len(p), off, n, err, len(want))
+						return
+					}
+					if !bytes.Equal(p[:n], want) {
+						t.Errorf("ReadAt(%d bytes, %d): wrong data", len(p), off)
+						return
+					}
+				}
+			}(int64(g))
+		}
+		wg.Wait()
+	}
+}
+
+// TestSeekableZstd checks that the zstd program decompresses the
+// seekable format as we write it in tests.
+func TestSeekableZstd(t *testing.T) {
+	zstd := findZstd(t)
+	data := writerInputs(t)["text"][:300000]
+	cmd := exec.Command(zstd, "-d")
+	cmd.Stdin = bytes.NewReader(makeSeekable(t, data, 70000, true))
+	got, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		showDiffs(t, got, data)
+	}
+}
+
+func TestSeekableBad(t *testing.T) {
+	data := writerInputs(t)["text"][:100000]
+	good := makeSeekable(t, data, 30000, true)
+
+	open := func(b []byte) (*SeekableReader, error) {
+		return NewSeekableReader(bytes.NewReader(b), int64(len(b)))
+	}
+	readAll := func(b []byte) error {
+		s, err := open(b)
+		if err != nil {
+			return err
+		}
+		_, err = s.ReadAt(make([]byte, s.Size()), 0)
+		return err
+	}
+
+	if _, err := open([]byte(tests[0].compressed)); err != ErrNotSeekable {
+		t.Errorf("plain frame: got %v, want ErrNotSeekable", err)
+	}
+	if _, err := open(good[:len(good)-1]); err != ErrNotSeekable {
+		t.Errorf("truncated: got %v, want ErrNotSeekable", err)
+	}
+
+	// The footer is the last 9 bytes and the entries of 12 bytes
+	// each are before it.
+	footer := len(good) - seekTableFooterSz
+	entry := footer - 4*12
+	for _, test := range []struct {
+		name   string
+		off    int
+		xor    byte
+		atOpen bool
+	}{
+		{"frame count", footer, 1, true},
+		{"reserved bit", footer + 4, 1 << 2, true},
+		{"table magic", entry - 8, 1, true},
+		{"compressed size", entry, 1, true},
+		{"decompressed size larger", entry + 4, 1, false},
+		{"decompressed size smaller", entry + 5, 0x80, false},
+		{"checksum", entry + 8, 1, false},
+		{"frame data", 100, 0x55, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			b := bytes.Clone(good)
+			b[test.off] ^= test.xor
+			if test.atOpen {
+				if _, err := open(b); err == nil {
+					t.Error("NewSeekableReader succeeded")
+				}
+				return
+			}
+			err := readAll(b)
+			if err == nil {
+				t.Fatal("ReadAt succeeded")
+			}
+			var ze *zstdError
+			if !errors.As(err, &ze) {
+				t.Errorf("got %v, want a decompression error", err)
+			}
+		})
+	}
+}
+
+// TestSeekableHugeEntry checks that a seek table entry claiming a huge
+// decompressed size does not make ReadAt allocate that much.
+func TestSeekableHugeEntry(t *testing.T) {
+	b := makeSeekable(t, []byte("hello, world\n"), 64, false)
+	entry := len(b) - seekTableFooterSz - 8
+	binary.LittleEndian.PutUint32(b[entry+4:], 0xffffffff)
+	s, err := NewSeekableReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = s.ReadAt(make([]byte, 10), 0)
+	runtime.ReadMemStats(&after)
+	var ze *zstdError
+	if !errors.As(err, &ze) {
+		t.Errorf("got %v, want a decompression error", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("ReadAt allocated %d bytes, want at most 1MiB", n)
+	}
+}
+
+func BenchmarkSeekableReadAt(b *testing.B) {
+	data := writerInputs(b)["text"]
+	compressed := makeSeekable(b, data, 64<<10, true)
+	s, err := NewSeekableReader(bytes.NewReader(compressed), int64(len(compressed)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, 4096)
+	rnd := rand.New(rand.NewSource(1))
+	b.SetBytes(int64(len(buf)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.ReadAt(buf, rnd.Int63n(s.Size()-int64(len(buf))))
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Compression levels for [NewWriterLevel].
+const (
+	// BestSpeed finds matches greedily, trying only
+	// a few earlier positions for each.
+	BestSpeed = 1
+
+	// DefaultCompression tries more earlier positions and a larger
+	// window, and prefers a longer match starting one byte later.
+	DefaultCompression = 2
+)
+
+// levelParams are the matcher parameters for each compression level.
+var levelParams = [...]matchParams{
+	BestSpeed:          {windowLog: 18, hashLog: 16, depth: 4},
+	DefaultCompression: {windowLog: 20, hashLog: 17, depth: 32, lazy: true},
+}
+
+// maxBlockSize is the largest amount of data in a block. RFC 3.1.1.2.3.
+const maxBlockSize = 128 << 10
+
+var errWriterClosed = errors.New("zstd: write to closed Writer")
+
+// Writer implements [io.WriteCloser] to write a zstd compressed stream.
+// The stream is a single frame, which ends when the Writer is closed.
+type Writer struct {
+	// The underlying Writer.
+	w io.Writer
+
+	// The compression level.
+	level int
+
+	// Whether to write a checksum of the content.
+	checksum bool
+
+	// Whether we have written the frame header.
+	wroteHeader bool
+
+	// The first error seen; any later write fails with it.
+	err error
+
+	// The match finder. The data that is not yet written in a
+	// block is at the end of m.hist, starting at start.
+	m     matcher
+	start int
+
+	// The block encoder.
+	enc blockEncoder
+
+	// The literals and sequences of the current block.
+	lits []byte
+	seqs []seq
+
+	// A buffer for the encoded block.
+	out []byte
+
+	// For checksum computation.
+	hash xxhash64
+}
+
+// NewWriter creates a new Writer that compresses data to the given
+// writer with the default compression level. Data may be buffered
+// until the Writer is flushed or closed.
+func NewWriter(output io.Writer) *Writer {
+	w, _ := NewWriterLevel(output, DefaultCompression)
+	return w
+}
+
+// NewWriterLevel is like [NewWriter] but uses the given compression
+// level, which is [BestSpeed] or [DefaultCompression].
+func NewWriterLevel(output io.Writer, level int) (*Writer, error) {
+	if level != BestSpeed && level != DefaultCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", level)
+	}
+	w := &Writer{
+		level:    level,
+		checksum: true,
+	}
+	w.Reset(output)
+	return w, nil
+}
+
+// SetChecksum sets whether w writes a checksum of the uncompressed
+// content at the end of the frame, which a decompressor verifies.
+// The checksum is written by default. SetChecksum must be called
+// before the first Write, or after a call to Reset.
+func (w *Writer) SetChecksum(checksum bool) {
+	w.checksum = checksum
+}
+
+// Reset discards the Writer's state and makes it equivalent to the
+// result of NewWriterLevel with the original level, but writing to
+// output instead. This permits reusing a Writer rather than
+// allocating a new one.
+func (w *Writer) Reset(output io.Writer) {
+	w.w = output
+	w.wroteHeader = false
+	w.err = nil
+	w.m.reset(levelParams[w.level])
+	w.start = 0
+	w.enc.reset()
+	w.hash.reset()
+}
+
+// Write implements [io.Writer].
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		pending := len(w.m.hist) - w.start
+		if pending == maxBlockSize {
+			if err := w.writeBlock(false); err != nil {
+				return n, err
+			}
+			pending = 0
+		}
+		c := min(len(p), maxBlockSize-pending)
+		w.m.hist = append(w.m.hist, p[:c]...)
+		if w.checksum {
+			w.hash.update(p[:c])
+		}
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Flush writes any buffered data as a block, so that a decompressor
+// can return all the data written so far. It does not end the frame.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.m.hist) == w.start {
+		return nil
+	}
+	return w.writeBlock(false)
+}
+
+// Close writes any buffered data and ends the frame. It does not close
+// the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.err == errWriterClosed {
+		return nil
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.writeBlock(true); err != nil {
+		return err
+	}
+	w.err = errWriterClosed
+	return nil
+}
+
+// writeHeader writes the frame header. RFC 3.1.1.1.
+func (w *Writer) writeHeader() error {
+	var hdr [6]byte
+	binary.LittleEndian.PutUint32(hdr[:], 0xfd2fb528)
+
+	// Frame_Header_Descriptor. We don't know the content size,
+	// and there is no dictionary.
+	if w.checksum {
+		hdr[4] |= 1 << 2
+	}
+
+	// Window_Descriptor, with a zero mantissa.
+	hdr[5] = (w.m.windowLog - 10) << 3
+
+	w.wroteHeader = true
+	return w.write(hdr[:])
+}
+
+// writeBlock writes the buffered data as a block. RFC 3.1.1.2.
+func (w *Writer) writeBlock(last bool) error {
+	if !w.wroteHeader {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	data := w.m.hist[w.start:]
+
+	// Leave room for the Block_Header.
+	w.out = append(w.out[:0], 0, 0, 0)
+	var blockType, blockSize int
+	if len(data) > 1 && allSame(data) {
+		// RLE_Block.
+		w.out = append(w.out, data[0])
+		blockType, blockSize = 1, len(data)
+	} else {
+		saved := w.enc.repeatedOffsets
+		w.lits, w.seqs = w.m.find(w.start, w.lits[:0], w.seqs[:0])
+		w.out = w.enc.appendBlock(w.out, w.lits, w.seqs)
+		blockType, blockSize = 2, len(w.out)-3
+		if blockSize >= len(data) {
+			// Raw_Block. The decoder won't see the
+			// sequences, so forget their offsets.
+			w.enc.repeatedOffsets = saved
+			w.out = append(w.out[:3], data...)
+			blockType, blockSize = 0, len(data)
+		}
+	}
+
+	hdr := blockSize<<3 | blockType<<1
+	if last {
+		hdr |= 1
+	}
+	w.out[0] = byte(hdr)
+	w.out[1] = byte(hdr >> 8)
+	w.out[2] = byte(hdr >> 16)
+
+	if last && w.checksum {
+		w.out = binary.LittleEndian.AppendUint32(w.out, uint32(w.hash.digest()))
+	}
+
+	w.start = len(w.m.hist)
+	w.start -= w.m.slide()
+
+	return w.write(w.out)
+}
+
+// write writes b to the underlying writer, recording any error.
+func (w *Writer) write(b []byte) error {
+	if _, err := w.w.Write(b); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writerInputs returns inputs for testing the Writer,
+// including the uncompressed content of the files in testdata.
+func writerInputs(t testing.TB) map[string][]byte {
+	inputs := map[string][]byte{
+		"empty":  nil,
+		"byte":   []byte("a"),
+		"hello":  []byte("hello, world\n"),
+		"run":    bytes.Repeat([]byte("x"), 300<<10),
+		"period": bytes.Repeat([]byte("abcdefghijklmnop"), 20000),
+	}
+	for _, test := range tests {
+		inputs["tests/"+test.name] = []byte(test.uncompressed)
+	}
+
+	var all bytes.Buffer
+	for i := 0; i < 1024; i++ {
+		all.WriteByte(byte(i))
+	}
+	inputs["bytes"] = all.Bytes()
+
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 200<<10)
+	rnd.Read(random)
+	inputs["random"] = random
+
+	// Text with words from a small vocabulary spread over more
+	// than the window size of either level, and some binary
+	// data mixed in.
+	words := strings.Fields("the quick brown fox jumps over a lazy dog while zstd compresses every frame block and sequence")
+	var text bytes.Buffer
+	for text.Len() < 3<<20 {
+		text.WriteString(words[rnd.Intn(len(words))])
+		switch rnd.Intn(20) {
+		case 0:
+			text.WriteString(".\n")
+		case 1:
+			fmt.Fprintf(&text, " %d\x00\xff%c ", rnd.Intn(1e6), rune(rnd.Intn(0x3000)))
+		default:
+			text.WriteByte(' ')
+		}
+	}
+	inputs["text"] = text.Bytes()
+
+	samples, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range samples {
+		name := sample.Name()
+		if !strings.HasSuffix(name, ".zst") {
+			continue
+		}
+		compressed, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs["testdata/"+name] = data
+	}
+	return inputs
+}
+
+// compress compresses data with the given level, writing it in
+// chunks of the given size.
+func compress(t testing.TB, data []byte, level int, checksum bool, chunk int) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriterLevel(&buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetChecksum(checksum)
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for name, data := range writerInputs(t) {
+		for _, level := range []int{BestSpeed, DefaultCompression} {
+			t.Run(fmt.Sprintf("%s/level%d", name, level), func(t *testing.T) {
+				compressed := compress(t, data, level, level == DefaultCompression, 100<<10)
+				t.Logf("compressed %d bytes to %d", len(data), len(compressed))
+				if len(data) > 1000 && name != "random" && len(compressed) > len(data)/2 {
+					t.Errorf("compressed %d bytes to %d", len(data), len(compressed))
+				}
+
+				got, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					showDiffs(t, got, data)
+				}
+			})
+		}
+	}
+}
+
+// TestWriterZstd checks that the zstd program can decompress what
+// we compress.
+func TestWriterZstd(t *testing.T) {
+	zstd := findZstd(t)
+	for name, data := range writerInputs(t) {
+		for _, level := range []int{BestSpeed, DefaultCompression} {
+			t.Run(fmt.Sprintf("%s/level%d", name, level), func(t *testing.T) {
+				compressed := compress(t, data, level, true, 1<<20)
+				cmd := exec.Command(zstd, "-d")
+				cmd.Stdin = bytes.NewReader(compressed)
+				var stderr bytes.Buffer
+				cmd.Stderr = &stderr
+				got, err := cmd.Output()
+				if err != nil {
+					t.Fatalf("zstd -d failed: %v\n%s", err, stderr.Bytes())
+				}
+				if !bytes.Equal(got, data) {
+					showDiffs(t, got, data)
+				}
+			})
+		}
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.Write([]byte("hello, ")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The flushed data can be read before the frame ends.
+	got := make([]byte, 7)
+	if _, err := io.ReadFull(NewReader(bytes.NewReader(buf.Bytes())), got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello, " {
+		t.Errorf("got %q, want %q", got, "hello, ")
+	}
+
+	if _, err := w.Write([]byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("!")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+
+	all, err := io.ReadAll(NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(all) != "hello, world" {
+		t.Errorf("got %q, want %q", all, "hello, world")
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	inputs := writerInputs(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, name := range []string{"text", "hello", "empty", "period"} {
+		buf.Reset()
+		w.Reset(&buf)
+		if _, err := w.Write(inputs[name]); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(NewReader(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, inputs[name]) {
+			showDiffs(t, got, inputs[name])
+		}
+	}
+}
+
+func TestWriterBadChecksum(t *testing.T) {
+	compressed := compress(t, []byte("hello, world\n"), DefaultCompression, true, 1<<10)
+	compressed[len(compressed)-1] ^= 1
+	if _, err := io.ReadAll(NewReader(bytes.NewReader(compressed))); err == nil {
+		t.Error("corrupted checksum not detected")
+	}
+}
+
+// FuzzWriter checks that we can decompress what we compress.
+func FuzzWriter(f *testing.F) {
+	for _, test := range tests {
+		f.Add([]byte(test.uncompressed))
+	}
+	f.Add(bytes.Repeat([]byte("abcdefghijklmnop"), 256))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, level := range []int{BestSpeed, DefaultCompression} {
+			compressed := compress(t, b, level, true, 1<<20)
+			got, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, b) {
+				showDiffs(t, got, b)
+			}
+		}
+	})
+}
+
+func BenchmarkWriter(b *testing.B) {
+	data := writerInputs(b)["text"]
+	for _, level := range []int{BestSpeed, DefaultCompression} {
+		b.Run(fmt.Sprintf("level%d", level), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			w, _ := NewWriterLevel(io.Discard, level)
+			for i := 0; i < b.N; i++ {
+				w.Reset(io.Discard)
+				w.Write(data)
+				w.Close()
+			}
+		})
+	}
+}
@@ -3,7 +3,11 @@
 // license that can be found in the LICENSE file.
 
 // Package zstd provides a decompressor for zstd streams,
-// described in RFC 8878. It does not support dictionaries.
+// described in RFC 8878. Frames that use a dictionary can be
+// decompressed once the dictionary is added with [Reader.AddDict].
+// A [Reader] can decompress several frames at once, and a
+// [SeekableReader] reads data at any offset in the seekable format
+// without decompressing the frames before it.
 package zstd
 
 import (
@@ -82,6 +86,13 @@ type Reader struct {
 
 	// For checksum computation.
 	checksum xxhash64
+
+	// Dictionaries by ID.
+	dicts map[uint32]*Dict
+
+	// For decompressing several frames at once, if not nil.
+	// See SetConcurrency.
+	par *parallelState
 }
 
 // NewReader creates a new Reader that decompresses data from the given reader.
@@ -119,6 +130,10 @@ func (r *Reader) Reset(input io.Reader) {
 	// seqTableBuffers
 	// scratch
 	// fseScratch
+	// dicts
+	if r.par != nil {
+		r.par.reset()
+	}
 }
 
 // Read implements [io.Reader].
@@ -144,7 +159,11 @@ func (r *Reader) ReadByte() (byte, error) {
 // refillIfNeeded reads the next block if necessary.
 func (r *Reader) refillIfNeeded() error {
 	for r.off >= len(r.buffer) {
-		if err := r.refill(); err != nil {
+		refill := r.refill
+		if r.par != nil {
+			refill = r.refillParallel
+		}
+		if err := refill(); err != nil {
 			return err
 		}
 		r.off = 0
@@ -255,14 +274,21 @@ retry:
 	}
 
 	// Dictionary_ID. RFC 3.1.1.1.3.
-	if dictionaryIdSize != 0 {
-		dictionaryId := r.scratch[windowDescriptorSize : windowDescriptorSize+dictionaryIdSize]
-		// Allow only zero Dictionary ID.
-		for _, b := range dictionaryId {
-			if b != 0 {
-				return r.makeError(relativeOffset, "dictionaries are not supported")
-			}
-		}
+	var dictionaryId uint32
+	switch dictionaryIdSize {
+	case 1:
+		dictionaryId = uint32(r.scratch[windowDescriptorSize])
+	case 2:
+		dictionaryId = uint32(binary.LittleEndian.Uint16(r.scratch[windowDescriptorSize:]))
+	case 4:
+		dictionaryId = binary.LittleEndian.Uint32(r.scratch[windowDescriptorSize:])
+	}
+	// A zero Dictionary_ID means that the frame does not name
+	// its dictionary, if it uses one. We use the dictionary
+	// with ID 0, if there is one.
+	dict := r.dicts[dictionaryId]
+	if dict == nil && dictionaryId != 0 {
+		return r.wrapError(relativeOffset, fmt.Errorf("unknown dictionary %d", dictionaryId))
 	}
 
 	// Frame_Content_Size. RFC 3.1.1.1.4.
@@ -296,6 +322,14 @@ retry:
 		windowSize = 8 << 20
 	}
 
+	// The dictionary content precedes the frame data, and may be
+	// referred to as long as it is within the window.
+	// We keep all of it so that it remains available to the end
+	// of a single segment frame.
+	if dict != nil {
+		windowSize += len(dict.content)
+	}
+
 	relativeOffset += headerSize
 
 	r.sawFrameHeader = true
@@ -311,6 +345,9 @@ retry:
 	r.seqTables[0] = nil
 	r.seqTables[1] = nil
 	r.seqTables[2] = nil
+	if dict != nil {
+		r.startDict(dict)
+	}
 
 	return nil
 }